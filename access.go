@@ -0,0 +1,237 @@
+package k6provider
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// accessLogFile is the file in the binary directory that records the binaries provisioned from it
+	accessLogFile = ".access.log"
+	// maxAccessLogSize is the size of the access log above which its oldest half is discarded
+	maxAccessLogSize = 1 << 20
+	// DefaultTargetHitRatio is the ratio of the binaries found in the cache targeted by [Provider.RecommendHWM]
+	DefaultTargetHitRatio = 0.9
+)
+
+// access records the provisioning of a binary from the cache
+type access struct {
+	time time.Time
+	id   string
+	size int64
+}
+
+// recordAccess appends the provisioning of the binary to the access log of its binary directory,
+// if enabled (see [Config.AccessAnalytics]). Only the binaries in the primary binary directory are
+// recorded, as they are the ones pruned. Failing to record it is not an error.
+func (p *Provider) recordAccess(binPath string, id string) {
+	artifactDir := filepath.Dir(binPath)
+	if !p.config.AccessAnalytics || filepath.Dir(artifactDir) != p.binDir {
+		return
+	}
+
+	size, err := artifactSize(artifactDir)
+	if err != nil {
+		return
+	}
+
+	logPath := filepath.Join(p.binDir, accessLogFile)
+	file, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, cacheFilePerm) //nolint:gosec
+	if err != nil {
+		return
+	}
+
+	// a single write per access, so concurrent records are not interleaved
+	_, _ = fmt.Fprintf(file, "%d %s %d\n", time.Now().UnixNano(), id, size)
+	info, err := file.Stat()
+	_ = file.Close()
+
+	_ = p.permissions.shareFile(logPath)
+
+	if err == nil && info.Size() > maxAccessLogSize {
+		p.truncateSharedLog(logPath)
+	}
+}
+
+// artifactSize returns the size of the artifact directory, which includes the binary's companion
+// tools. It is recorded in the artifact's metadata, so the directory is not walked every time.
+func artifactSize(artifactDir string) (int64, error) {
+	metadata, err := readMetadata(artifactDir)
+	if err == nil && metadata.Size > 0 {
+		return metadata.Size, nil
+	}
+
+	size, sizeErr := artifactDirSize(artifactDir)
+	if sizeErr != nil {
+		return 0, sizeErr
+	}
+
+	// artifacts without metadata cannot record it
+	if err == nil {
+		metadata.Size = size
+		_ = saveMetadata(artifactDir, metadata)
+	}
+
+	return size, nil
+}
+
+// truncateSharedLog truncates a log in the binary directory shared with other processes, such as
+// the access log, holding the lock of the binary directory, so it is not truncated by several of
+// them at once. If the lock is held, for example, by a pruner, the log is truncated the next time
+// a record is appended. Failing to truncate it is not an error.
+func (p *Provider) truncateSharedLog(logPath string) {
+	cacheLock := p.downloadLock(p.binDir, p.binDir)
+	if err := cacheLock.lock(); err != nil {
+		return
+	}
+	defer cacheLock.unlock() //nolint:errcheck
+
+	if err := truncateLog(logPath); err == nil {
+		_ = p.permissions.shareFile(logPath)
+	}
+}
+
+// truncateLog discards the oldest half of a log, such as the access log. The log is replaced
+// with a temporary file with the records kept. Callers must prevent concurrent truncations,
+// for example, holding the lock of its directory.
+func truncateLog(logPath string) error {
+	content, err := os.ReadFile(logPath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	// keep whole lines
+	half := content[len(content)/2:]
+	if newline := bytes.IndexByte(half, '\n'); newline >= 0 {
+		half = half[newline+1:]
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(logPath), filepath.Base(logPath)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	_, err = tmp.Write(half)
+	if err == nil {
+		err = tmp.Chmod(cacheFilePerm)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), logPath)
+}
+
+// readAccessLog returns the accesses recorded in the binary directory since the given time
+func readAccessLog(binDir string, since time.Time) ([]access, error) {
+	file, err := os.Open(filepath.Join(binDir, accessLogFile)) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	accesses := []access{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+
+		nanos, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		accessed := time.Unix(0, nanos)
+		if accessed.Before(since) {
+			continue
+		}
+
+		accesses = append(accesses, access{time: accessed, id: fields[1], size: size})
+	}
+
+	// concurrent processes may append accesses out of order
+	sort.SliceStable(accesses, func(i, j int) bool { return accesses[i].time.Before(accesses[j].time) })
+
+	return accesses, scanner.Err()
+}
+
+// RecommendHWM analyzes the binaries provisioned from the cache in the given period and
+// returns the smallest high-water mark that would have kept [DefaultTargetHitRatio] of the
+// binaries provisioned again found in the cache, assuming the least recently used binaries
+// are pruned first. The first time a binary is provisioned it is never found in the cache,
+// so these provisions are not considered. The high-water mark is never smaller than the
+// largest binary. See [Config.HighWaterMark].
+//
+// The binaries provisioned are recorded in the binary directory if [Config.AccessAnalytics] is
+// enabled, so the analysis includes those provisioned by other processes sharing it. Returns 0
+// if no binary was provisioned in the period.
+func (p *Provider) RecommendHWM(history time.Duration) int64 {
+	accesses, err := readAccessLog(p.binDir, time.Now().Add(-history))
+	if err != nil || len(accesses) == 0 {
+		return 0
+	}
+
+	distances, largest := reuseDistances(accesses)
+	if len(distances) == 0 {
+		return largest
+	}
+
+	sort.Slice(distances, func(i, j int) bool { return distances[i] < distances[j] })
+
+	// the size that keeps the target ratio of binaries provisioned again in the cache
+	index := int(float64(len(distances))*DefaultTargetHitRatio+0.5) - 1
+	index = max(0, min(index, len(distances)-1))
+
+	return max(distances[index], largest)
+}
+
+// reuseDistances returns, for each access to a binary accessed before, the size of the
+// binaries accessed since the previous access to it, including itself. A LRU cache of this
+// size would have kept the binary. Also returns the size of the largest binary.
+func reuseDistances(accesses []access) ([]int64, int64) {
+	// binaries from the most to the least recently accessed
+	stack := []access{}
+	distances := []int64{}
+	largest := int64(0)
+
+	for _, current := range accesses {
+		largest = max(largest, current.size)
+
+		distance := int64(0)
+		found := -1
+		for i, recent := range stack {
+			distance += recent.size
+			if recent.id == current.id {
+				found = i
+				break
+			}
+		}
+
+		if found >= 0 {
+			// the binary may have been downloaded again with a different size
+			distances = append(distances, distance-stack[found].size+current.size)
+			stack = append(stack[:found], stack[found+1:]...)
+		}
+
+		stack = append([]access{current}, stack...)
+	}
+
+	return distances, largest
+}
@@ -0,0 +1,178 @@
+package k6provider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecommendHWM(t *testing.T) {
+	t.Parallel()
+
+	type logEntry struct {
+		age  time.Duration
+		id   string
+		size int64
+	}
+
+	testCases := []struct {
+		title   string
+		entries []logEntry
+		expect  int64
+	}{
+		{
+			title:   "no accesses",
+			entries: nil,
+			expect:  0,
+		},
+		{
+			title:   "single access",
+			entries: []logEntry{{time.Hour, "a", 10}},
+			expect:  10,
+		},
+		{
+			title: "binary reused after another",
+			entries: []logEntry{
+				{3 * time.Hour, "a", 10},
+				{2 * time.Hour, "b", 20},
+				{time.Hour, "a", 10},
+			},
+			expect: 30,
+		},
+		{
+			title: "cyclic accesses",
+			entries: []logEntry{
+				{6 * time.Hour, "a", 10},
+				{5 * time.Hour, "b", 10},
+				{4 * time.Hour, "c", 10},
+				{3 * time.Hour, "a", 10},
+				{2 * time.Hour, "b", 10},
+				{time.Hour, "c", 10},
+			},
+			expect: 30,
+		},
+		{
+			title: "accesses before the period are ignored",
+			entries: []logEntry{
+				{48 * time.Hour, "a", 10},
+				{47 * time.Hour, "b", 100},
+				{3 * time.Hour, "a", 10},
+				{2 * time.Hour, "c", 10},
+				{time.Hour, "a", 10},
+			},
+			expect: 20,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			binDir := t.TempDir()
+			if len(tc.entries) > 0 {
+				lines := []string{}
+				for _, entry := range tc.entries {
+					accessed := time.Now().Add(-entry.age).UnixNano()
+					lines = append(lines, fmt.Sprintf("%d %s %d\n", accessed, entry.id, entry.size))
+				}
+				content := strings.Join(lines, "")
+				if err := os.WriteFile(filepath.Join(binDir, accessLogFile), []byte(content), 0o600); err != nil {
+					t.Fatalf("test setup: writing access log %v", err)
+				}
+			}
+
+			provider := newTestProvider(t, nil, binDir)
+
+			recommended := provider.RecommendHWM(24 * time.Hour)
+			if recommended != tc.expect {
+				t.Fatalf("expected %d got %d", tc.expect, recommended)
+			}
+		})
+	}
+}
+
+func TestRecordAccess(t *testing.T) {
+	t.Parallel()
+
+	binDir := t.TempDir()
+	provider := newTestProvider(t, nil, binDir)
+
+	binPath := filepath.Join(binDir, "artifact", k6Binary)
+	if err := os.MkdirAll(filepath.Dir(binPath), 0o700); err != nil {
+		t.Fatalf("test setup: creating dir %v", err)
+	}
+	if err := os.WriteFile(binPath, []byte("k6 binary"), 0o700); err != nil { //nolint:gosec
+		t.Fatalf("test setup: writing binary %v", err)
+	}
+	if err := writeMetadata(filepath.Dir(binPath), Artifact{ID: "artifact"}, "request"); err != nil {
+		t.Fatalf("test setup: writing metadata %v", err)
+	}
+	size, err := artifactDirSize(filepath.Dir(binPath))
+	if err != nil {
+		t.Fatalf("test setup: getting size %v", err)
+	}
+
+	// accesses are not recorded unless enabled
+	provider.recordAccess(binPath, "artifact")
+	if _, err = os.Stat(filepath.Join(binDir, accessLogFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected no access log got %v", err)
+	}
+
+	provider.config.AccessAnalytics = true
+
+	// binaries outside the binary directory are not recorded
+	other := filepath.Join(t.TempDir(), "other", k6Binary)
+	provider.recordAccess(other, "other")
+
+	provider.recordAccess(binPath, "artifact")
+
+	// the size recorded in the metadata is used
+	if err = os.WriteFile(binPath, []byte("larger k6 binary"), 0o700); err != nil { //nolint:gosec
+		t.Fatalf("test setup: writing binary %v", err)
+	}
+	provider.recordAccess(binPath, "artifact")
+
+	accesses, err := readAccessLog(binDir, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("reading access log %v", err)
+	}
+
+	if len(accesses) != 2 || accesses[0].id != "artifact" || accesses[1].size != size {
+		t.Fatalf("unexpected accesses %v", accesses)
+	}
+}
+
+func TestTruncateLog(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, accessLogFile)
+	if err := os.WriteFile(logPath, []byte("1 a 10\n2 b 10\n3 c 10\n4 d 10\n"), 0o600); err != nil {
+		t.Fatalf("test setup: writing log %v", err)
+	}
+
+	if err := truncateLog(logPath); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// the oldest half is discarded, keeping whole lines
+	content, err := os.ReadFile(logPath) //nolint:gosec
+	if err != nil {
+		t.Fatalf("reading log %v", err)
+	}
+	if string(content) != "4 d 10\n" {
+		t.Fatalf("expected %q got %q", "4 d 10\n", content)
+	}
+
+	// no temporary file is left
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("reading dir %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the log got %v", entries)
+	}
+}
@@ -0,0 +1,69 @@
+package k6provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+// loadAliases returns the extension aliases defined in the configuration, including the
+// ones defined in the aliases file, if any. Aliases defined in the configuration take
+// precedence over the ones in the aliases file.
+func loadAliases(config Config) (map[string]string, error) {
+	aliases := map[string]string{}
+
+	if config.ExtensionAliasesFile != "" {
+		content, err := os.ReadFile(config.ExtensionAliasesFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading aliases file %w", err)
+		}
+		if err = json.Unmarshal(content, &aliases); err != nil {
+			return nil, fmt.Errorf("parsing aliases file %w", err)
+		}
+	}
+
+	for alias, name := range config.ExtensionAliases {
+		aliases[alias] = name
+	}
+
+	for alias, name := range aliases {
+		if name == "" {
+			return nil, fmt.Errorf("empty name for alias %q", alias)
+		}
+	}
+
+	return aliases, nil
+}
+
+// resolveAlias returns the name of the extension an alias refers to. Names that are not
+// aliases are returned as is.
+func (p *Provider) resolveAlias(alias string) string {
+	if p.config.ResolveExtensionAlias != nil {
+		if name, found := p.config.ResolveExtensionAlias(alias); found {
+			return name
+		}
+	}
+
+	if name, found := p.aliases[alias]; found {
+		return name
+	}
+
+	return alias
+}
+
+// buildDeps returns the k6 constrains and the dependencies for the build service, with the
+// extension aliases resolved to the extension names, the dependency overrides applied and
+// raised to the minimum versions
+func (p *Provider) buildDeps(deps k6deps.Dependencies) (string, []k6build.Dependency) {
+	k6Constrains, bdeps := buildDeps(deps)
+	for i := range bdeps {
+		bdeps[i].Name = p.resolveAlias(bdeps[i].Name)
+	}
+
+	k6Constrains, bdeps = applyOverrides(p.overrides, k6Constrains, bdeps)
+
+	return p.enforceMinimumVersions(k6Constrains, bdeps)
+}
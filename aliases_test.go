@@ -0,0 +1,123 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+func TestExtensionAliases(t *testing.T) {
+	t.Parallel()
+
+	aliasesFile := filepath.Join(t.TempDir(), "aliases.json")
+	aliases := `{"kubernetes": "k6/x/kubernetes", "faker": "k6/x/faker"}`
+	if err := os.WriteFile(aliasesFile, []byte(aliases), 0o600); err != nil {
+		t.Fatalf("test setup: writing aliases %v", err)
+	}
+
+	testCases := []struct {
+		title     string
+		config    Config
+		deps      string
+		expect    string
+		expectErr error
+	}{
+		{
+			title:  "no aliases",
+			deps:   "k6/x/kubernetes=v0.9.0",
+			expect: "k6/x/kubernetes",
+		},
+		{
+			title:  "alias from aliases file",
+			config: Config{ExtensionAliasesFile: aliasesFile},
+			deps:   "kubernetes=v0.9.0",
+			expect: "k6/x/kubernetes",
+		},
+		{
+			title: "alias from config overrides aliases file",
+			config: Config{
+				ExtensionAliasesFile: aliasesFile,
+				ExtensionAliases:     map[string]string{"kubernetes": "github.com/example/xk6-kubernetes"},
+			},
+			deps:   "kubernetes=v0.9.0",
+			expect: "github.com/example/xk6-kubernetes",
+		},
+		{
+			title: "alias from callback",
+			config: Config{
+				ExtensionAliasesFile: aliasesFile,
+				ResolveExtensionAlias: func(name string) (string, bool) {
+					return "k6/x/internal-" + name, name == "kubernetes"
+				},
+			},
+			deps:   "kubernetes=v0.9.0",
+			expect: "k6/x/internal-kubernetes",
+		},
+		{
+			title: "callback falls back to aliases",
+			config: Config{
+				ExtensionAliasesFile: aliasesFile,
+				ResolveExtensionAlias: func(string) (string, bool) {
+					return "", false
+				},
+			},
+			deps:   "faker=v0.3.0",
+			expect: "k6/x/faker",
+		},
+		{
+			title:     "invalid alias",
+			config:    Config{ExtensionAliases: map[string]string{"kubernetes": ""}},
+			expectErr: ErrConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			loaded, err := loadAliases(tc.config)
+			if err != nil {
+				err = NewWrappedError(ErrConfig, err)
+			}
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			var deps []k6build.Dependency
+			provider := &Provider{
+				config: tc.config,
+				buildSrv: newTestBuildServices(
+					buildServiceFunc(
+						func(_ context.Context, _ string, _ string, d []k6build.Dependency) (k6build.Artifact, error) {
+							deps = d
+							return k6build.Artifact{}, nil
+						},
+					),
+				),
+				aliases: loaded,
+				ctx:     context.Background(),
+			}
+
+			requested := k6deps.Dependencies{}
+			if err = requested.UnmarshalText([]byte(tc.deps)); err != nil {
+				t.Fatalf("test setup: parsing dependencies %v", err)
+			}
+
+			if _, err = provider.GetArtifact(context.TODO(), requested); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if len(deps) != 1 || deps[0].Name != tc.expect {
+				t.Fatalf("expected %v got %v", tc.expect, deps)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package k6provider
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/grafana/k6deps"
+)
+
+// archiveExt is the extension of k6 archive files
+const archiveExt = ".tar"
+
+// analyzeOptions returns the options for analyzing dependencies, based on the DepsOptions
+// in the configuration. The returned options can be modified without affecting the configuration.
+func (p *Provider) analyzeOptions() *k6deps.Options {
+	opts := &k6deps.Options{}
+	if p.config.DepsOptions != nil {
+		*opts = *p.config.DepsOptions
+	}
+
+	return opts
+}
+
+// Analyze returns the dependencies of a k6 script or archive (files with the .tar extension)
+// using the DepsOptions in the configuration, so the manifest, environment and ignored sources
+// are considered consistently by all the functions that analyze scripts.
+func (p *Provider) Analyze(path string) (k6deps.Dependencies, error) {
+	if strings.HasSuffix(path, archiveExt) {
+		return p.analyzeArchive(path)
+	}
+
+	return p.analyzeScript(path)
+}
+
+// analyzeScript returns the dependencies of a k6 script
+func (p *Provider) analyzeScript(path string) (k6deps.Dependencies, error) {
+	contents, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	return p.analyzeScriptContents(path, contents)
+}
+
+// analyzeScriptContents returns the dependencies of the contents of a k6 script
+func (p *Provider) analyzeScriptContents(name string, contents []byte) (k6deps.Dependencies, error) {
+	opts := p.analyzeOptions()
+	opts.Script = k6deps.Source{Name: name, Contents: contents}
+	opts.Archive = k6deps.Source{}
+
+	return k6deps.Analyze(opts)
+}
+
+// analyzeArchive returns the dependencies of a k6 archive
+func (p *Provider) analyzeArchive(path string) (k6deps.Dependencies, error) {
+	contents, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	opts := p.analyzeOptions()
+	opts.Archive = k6deps.Source{Name: path, Contents: contents}
+	opts.Script = k6deps.Source{}
+
+	return k6deps.Analyze(opts)
+}
+
+// GetBinaryForScript returns a custom k6 binary that satisfies the dependencies of a k6 script.
+// The dependencies are analyzed using [Config.DepsOptions], if set.
+//
+// If the dependencies cannot be obtained from the script, an [ErrDependencies] error is returned.
+func (p *Provider) GetBinaryForScript(ctx context.Context, scriptPath string) (K6Binary, error) {
+	deps, err := p.analyzeScript(scriptPath)
+	if err != nil {
+		return K6Binary{}, NewWrappedError(ErrDependencies, err)
+	}
+
+	return p.GetBinary(ctx, deps)
+}
+
+// GetBinaryForScriptReader returns a custom k6 binary that satisfies the dependencies of the
+// k6 script read from the reader. The dependencies are analyzed using [Config.DepsOptions], if set.
+//
+// If the dependencies cannot be obtained from the script, an [ErrDependencies] error is returned.
+func (p *Provider) GetBinaryForScriptReader(ctx context.Context, script io.Reader) (K6Binary, error) {
+	contents, err := io.ReadAll(script)
+	if err != nil {
+		return K6Binary{}, NewWrappedError(ErrDependencies, err)
+	}
+
+	// the name is only used for reporting
+	deps, err := p.analyzeScriptContents("script.js", contents)
+	if err != nil {
+		return K6Binary{}, NewWrappedError(ErrDependencies, err)
+	}
+
+	return p.GetBinary(ctx, deps)
+}
+
+// GetBinaryForConstraints returns a custom k6 binary that satisfies the dependencies given as
+// text, using the same format as the K6_DEPENDENCIES environment variable.
+// e.g. "k6>=0.52;k6/x/sql=*"
+//
+// If the constraints cannot be parsed, an [ErrDependencies] error is returned.
+func (p *Provider) GetBinaryForConstraints(ctx context.Context, constraints string) (K6Binary, error) {
+	deps := make(k6deps.Dependencies)
+	if err := deps.UnmarshalText([]byte(constraints)); err != nil {
+		return K6Binary{}, NewWrappedError(ErrDependencies, err)
+	}
+
+	return p.GetBinary(ctx, deps)
+}
+
+// GetBinaryForArchive returns a custom k6 binary that satisfies the dependencies of a
+// k6 archive, as created by the "k6 archive" command.
+// The dependencies are analyzed using [Config.DepsOptions], if set.
+//
+// If the dependencies cannot be obtained from the archive, an [ErrDependencies] error is returned.
+func (p *Provider) GetBinaryForArchive(ctx context.Context, archivePath string) (K6Binary, error) {
+	deps, err := p.analyzeArchive(archivePath)
+	if err != nil {
+		return K6Binary{}, NewWrappedError(ErrDependencies, err)
+	}
+
+	return p.GetBinary(ctx, deps)
+}
@@ -0,0 +1,122 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+func TestGetBinaryForArchive(t *testing.T) {
+	t.Parallel()
+
+	provider := newTestProvider(t, nil, t.TempDir())
+
+	archive := filepath.Join(t.TempDir(), "missing.tar")
+	_, err := provider.GetBinaryForArchive(context.TODO(), archive)
+	if !errors.Is(err, ErrDependencies) {
+		t.Fatalf("expected %v got %v", ErrDependencies, err)
+	}
+}
+
+func TestAnalyzeWithDepsOptions(t *testing.T) {
+	t.Parallel()
+
+	script := filepath.Join(t.TempDir(), "script.js")
+	if err := os.WriteFile(script, []byte(`"use k6 >= 0.50";`), 0o600); err != nil {
+		t.Fatalf("test setup writing script %v", err)
+	}
+
+	provider := newTestProvider(t, nil, t.TempDir())
+	provider.config.DepsOptions = &k6deps.Options{
+		Env: k6deps.Source{Name: "K6_DEPENDENCIES", Contents: []byte("k6/x/faker>0.3")},
+	}
+
+	deps, err := provider.Analyze(script)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	for _, name := range []string{"k6", "k6/x/faker"} {
+		if _, found := deps[name]; !found {
+			t.Fatalf("expected %s in dependencies got %v", name, deps)
+		}
+	}
+
+	// the configured options are not modified
+	if provider.config.DepsOptions.Script.Name != "" {
+		t.Fatalf("configured options modified")
+	}
+}
+
+func TestGetBinaryForConstraints(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title       string
+		constraints string
+		script      string
+		expectK6    string
+		expectDeps  int
+		expectErr   error
+	}{
+		{
+			title:       "constraints",
+			constraints: "k6>=0.52;k6/x/sql=*",
+			expectK6:    ">=0.52",
+			expectDeps:  1,
+		},
+		{
+			title:       "invalid constraints",
+			constraints: "k6>>0.52",
+			expectErr:   ErrDependencies,
+		},
+		{
+			title:      "script reader",
+			script:     `"use k6 with k6/x/faker > 0.3";`,
+			expectK6:   "*",
+			expectDeps: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				k6Constrains string
+				deps         []k6build.Dependency
+			)
+			buildSrv := buildServiceFunc(
+				func(_ context.Context, _ string, k6 string, d []k6build.Dependency) (k6build.Artifact, error) {
+					k6Constrains, deps = k6, d
+					return k6build.Artifact{}, errors.New("build failed")
+				},
+			)
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+
+			var err error
+			if tc.script != "" {
+				_, err = provider.GetBinaryForScriptReader(context.TODO(), strings.NewReader(tc.script))
+			} else {
+				_, err = provider.GetBinaryForConstraints(context.TODO(), tc.constraints)
+			}
+
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Fatalf("expected %v got %v", tc.expectErr, err)
+				}
+				return
+			}
+
+			if k6Constrains != tc.expectK6 || len(deps) != tc.expectDeps {
+				t.Fatalf("expected k6 %q and %d dependencies got %q %v", tc.expectK6, tc.expectDeps, k6Constrains, deps)
+			}
+		})
+	}
+}
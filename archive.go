@@ -0,0 +1,346 @@
+package k6provider
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// archiveFormat is the format of a downloaded artifact
+type archiveFormat string
+
+const (
+	// formatBinary the artifact is the binary
+	formatBinary archiveFormat = ""
+	// formatTarGz the artifact is a gzip compressed tar archive containing the binary
+	formatTarGz archiveFormat = "tar.gz"
+	// formatZip the artifact is a zip archive containing the binary
+	formatZip archiveFormat = "zip"
+)
+
+// acceptedFormats are the media types of the artifact formats the downloader can handle
+const acceptedFormats = "application/octet-stream, application/gzip, application/zip"
+
+const (
+	// extractSuffix is the suffix of the file a binary is extracted to from an archive
+	extractSuffix = ".extract"
+	// toolsSuffix is the suffix of the directory the companion tools in an archive are
+	// extracted to, next to the file the binary is extracted to
+	toolsSuffix = ".tools"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}           //nolint:gochecknoglobals
+	zipMagic  = []byte{'P', 'K', 0x03, 0x04} //nolint:gochecknoglobals
+)
+
+// errMemberNotFound is returned when the archive doesn't contain the binary
+var errMemberNotFound = errors.New("binary not found in archive")
+
+// detectArchive returns the format of the downloaded artifact from its leading bytes
+func detectArchive(filePath string) (archiveFormat, error) {
+	file, err := os.Open(filePath) //nolint:gosec
+	if err != nil {
+		return formatBinary, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	header := make([]byte, len(zipMagic))
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return formatBinary, err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return formatTarGz, nil
+	case bytes.HasPrefix(header, zipMagic):
+		return formatZip, nil
+	default:
+		return formatBinary, nil
+	}
+}
+
+// isMember returns true if the archive entry is the binary, matching the member either
+// by its full name or by its base name
+func isMember(name string, member string) bool {
+	return name == member || path.Base(name) == member
+}
+
+// extractBinary replaces the downloaded archive with the binary it contains, returning
+// the hash of the binary. The binary is the member of the archive with the given name.
+// The companion tools in the archive are extracted to a directory next to the binary, with
+// the same name and the tools suffix.
+func (d *downloader) extractBinary(
+	archivePath string,
+	format archiveFormat,
+	member string,
+	sync bool,
+) (hash.Hash, error) {
+	extractPath := archivePath + extractSuffix
+
+	binHash, err := d.extractTo(archivePath, format, member, extractPath, sync)
+	if err != nil {
+		_ = os.Remove(extractPath)
+		return nil, err
+	}
+
+	if err = d.extractTools(archivePath, format, member, archivePath+toolsSuffix, sync); err != nil {
+		_ = os.Remove(extractPath)
+		_ = os.RemoveAll(archivePath + toolsSuffix)
+		return nil, storageError(err)
+	}
+
+	if err = os.Rename(extractPath, archivePath); err != nil {
+		_ = os.Remove(extractPath)
+		return nil, storageError(err)
+	}
+
+	return binHash, nil
+}
+
+// extractTo extracts the member of the archive to the given path
+func (d *downloader) extractTo(
+	archivePath string,
+	format archiveFormat,
+	member string,
+	extractPath string,
+	sync bool,
+) (hash.Hash, error) {
+	target, err := os.OpenFile( //nolint:gosec
+		extractPath,
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		syscall.S_IRUSR|syscall.S_IXUSR|syscall.S_IWUSR,
+	)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	binHash := sha256.New()
+	writer := io.MultiWriter(target, binHash)
+
+	switch format {
+	case formatTarGz:
+		err = d.extractTarGz(archivePath, member, writer)
+	case formatZip:
+		err = d.extractZip(archivePath, member, writer)
+	default:
+		err = fmt.Errorf("unsupported archive format %q", format)
+	}
+
+	if err == nil && sync {
+		err = target.Sync()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return binHash, nil
+}
+
+// extractTarGz copies the member of a gzip compressed tar archive to the writer
+func (d *downloader) extractTarGz(archivePath string, member string, dest io.Writer) error {
+	file, err := os.Open(archivePath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer file.Close() //nolint:errcheck
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+	defer gz.Close() //nolint:errcheck
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: %q", errMemberNotFound, member)
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !isMember(header.Name, member) {
+			continue
+		}
+
+		_, err = d.buffers.copy(dest, archive)
+		return err
+	}
+}
+
+// extractZip copies the member of a zip archive to the writer
+func (d *downloader) extractZip(archivePath string, member string, dest io.Writer) error {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+	defer archive.Close() //nolint:errcheck
+
+	for _, file := range archive.File {
+		if !file.Mode().IsRegular() || !isMember(file.Name, member) {
+			continue
+		}
+
+		content, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+		defer content.Close() //nolint:errcheck
+
+		_, err = d.buffers.copy(dest, content)
+		return err
+	}
+
+	return fmt.Errorf("%w: %q", errMemberNotFound, member)
+}
+
+// toolName returns the name of the companion tool in an archive entry. Companion tools are the
+// executable files, or files with the ".exe" extension, other than the binary. Hidden files
+// are ignored.
+func toolName(name string, mode fs.FileMode) (string, bool) {
+	base := path.Base(name)
+	if strings.HasPrefix(base, ".") || base == "/" {
+		return "", false
+	}
+
+	if !mode.IsRegular() || (mode&0o111 == 0 && !strings.HasSuffix(base, ".exe")) {
+		return "", false
+	}
+
+	return base, true
+}
+
+// extractTools extracts the companion tools in the archive, all the executable files except
+// the binary, to the tools directory. The directory is created only if there are tools.
+func (d *downloader) extractTools(
+	archivePath string,
+	format archiveFormat,
+	member string,
+	dir string,
+	sync bool,
+) error {
+	switch format {
+	case formatTarGz:
+		return d.extractTarGzTools(archivePath, member, dir, sync)
+	case formatZip:
+		return d.extractZipTools(archivePath, member, dir, sync)
+	default:
+		return nil
+	}
+}
+
+// extractTarGzTools extracts the companion tools in a gzip compressed tar archive
+func (d *downloader) extractTarGzTools(archivePath string, member string, dir string, sync bool) error {
+	file, err := os.Open(archivePath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer file.Close() //nolint:errcheck
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+	defer gz.Close() //nolint:errcheck
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || isMember(header.Name, member) {
+			continue
+		}
+
+		name, ok := toolName(header.Name, header.FileInfo().Mode())
+		if !ok {
+			continue
+		}
+
+		if err = d.writeTool(dir, name, archive, sync); err != nil {
+			return err
+		}
+	}
+}
+
+// extractZipTools extracts the companion tools in a zip archive
+func (d *downloader) extractZipTools(archivePath string, member string, dir string, sync bool) error {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+	defer archive.Close() //nolint:errcheck
+
+	for _, file := range archive.File {
+		if isMember(file.Name, member) {
+			continue
+		}
+
+		name, ok := toolName(file.Name, file.Mode())
+		if !ok {
+			continue
+		}
+
+		content, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+		err = d.writeTool(dir, name, content, sync)
+		_ = content.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTool writes the content of a companion tool to the tools directory
+func (d *downloader) writeTool(dir string, name string, content io.Reader, sync bool) error {
+	if err := os.MkdirAll(dir, cacheDirPerm); err != nil {
+		return err
+	}
+
+	target, err := os.OpenFile( //nolint:gosec
+		filepath.Join(dir, name),
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		cacheBinaryPerm,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.buffers.copy(target, content)
+	if err == nil && sync {
+		err = target.Sync()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
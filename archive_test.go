@@ -0,0 +1,168 @@
+package k6provider
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTarGz returns a gzip compressed tar archive with the given files
+func newTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	buffer := &bytes.Buffer{}
+	gz := gzip.NewWriter(buffer)
+	archive := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := archive.WriteHeader(header); err != nil {
+			t.Fatalf("writing archive %v", err)
+		}
+		if _, err := archive.Write([]byte(content)); err != nil {
+			t.Fatalf("writing archive %v", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("writing archive %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("writing archive %v", err)
+	}
+
+	return buffer.Bytes()
+}
+
+// newZip returns a zip archive with the given files
+func newZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	buffer := &bytes.Buffer{}
+	archive := zip.NewWriter(buffer)
+	for name, content := range files {
+		file, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("writing archive %v", err)
+		}
+		if _, err = file.Write([]byte(content)); err != nil {
+			t.Fatalf("writing archive %v", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("writing archive %v", err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestExtractBinary(t *testing.T) {
+	t.Parallel()
+
+	binary := "k6 binary"
+
+	testCases := []struct {
+		title        string
+		artifact     func(t *testing.T) []byte
+		member       string
+		expectFormat archiveFormat
+		expectErr    error
+	}{
+		{
+			title:        "binary",
+			artifact:     func(_ *testing.T) []byte { return []byte(binary) },
+			member:       "k6",
+			expectFormat: formatBinary,
+		},
+		{
+			title: "tar.gz",
+			artifact: func(t *testing.T) []byte {
+				return newTarGz(t, map[string]string{"README.md": "readme", "k6": binary})
+			},
+			member:       "k6",
+			expectFormat: formatTarGz,
+		},
+		{
+			title: "tar.gz with directory",
+			artifact: func(t *testing.T) []byte {
+				return newTarGz(t, map[string]string{"k6-v0.50.0-linux-amd64/k6": binary})
+			},
+			member:       "k6",
+			expectFormat: formatTarGz,
+		},
+		{
+			title: "zip",
+			artifact: func(t *testing.T) []byte {
+				return newZip(t, map[string]string{"LICENSE": "license", "bin/k6.exe": binary})
+			},
+			member:       "k6.exe",
+			expectFormat: formatZip,
+		},
+		{
+			title: "member not found",
+			artifact: func(t *testing.T) []byte {
+				return newZip(t, map[string]string{"k6-extension": binary})
+			},
+			member:       "k6",
+			expectFormat: formatZip,
+			expectErr:    errMemberNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "k6")
+			if err := os.WriteFile(path, tc.artifact(t), 0o600); err != nil {
+				t.Fatalf("writing artifact %v", err)
+			}
+
+			format, err := detectArchive(path)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if format != tc.expectFormat {
+				t.Fatalf("expected %q got %q", tc.expectFormat, format)
+			}
+
+			if format == formatBinary {
+				return
+			}
+
+			downloader := &downloader{buffers: defaultBuffers}
+			hash, err := downloader.extractBinary(path, format, tc.member, false)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			if tc.expectErr != nil {
+				return
+			}
+
+			expected := sha256.Sum256([]byte(binary))
+			if err = verifyChecksum(hex.EncodeToString(expected[:]), hash); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			content, err := os.ReadFile(path) //nolint:gosec
+			if err != nil {
+				t.Fatalf("reading binary %v", err)
+			}
+
+			if string(content) != binary {
+				t.Fatalf("expected %q got %q", binary, content)
+			}
+
+			if _, err = os.Stat(path + extractSuffix); !os.IsNotExist(err) {
+				t.Fatalf("expected extracted file to be removed got %v", err)
+			}
+		})
+	}
+}
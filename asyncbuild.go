@@ -0,0 +1,369 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6build/pkg/api"
+	"github.com/grafana/k6deps"
+)
+
+// defaultBuildPollInterval is the default time between requests for the status of an async build
+const defaultBuildPollInterval = 2 * time.Second
+
+// BuildStatus is the status of an asynchronous build
+type BuildStatus string
+
+const (
+	// BuildQueued the build is waiting to be started
+	BuildQueued BuildStatus = "queued"
+	// BuildBuilding the build is in progress
+	BuildBuilding BuildStatus = "building"
+	// BuildReady the build finished and the artifact is available
+	BuildReady BuildStatus = "ready"
+	// BuildFailed the build finished with an error
+	BuildFailed BuildStatus = "failed"
+)
+
+// BuildProgress describes the progress of an asynchronous build, as reported by the build service
+type BuildProgress struct {
+	// BuildID id of the build
+	BuildID string
+	// Status of the build
+	Status BuildStatus
+	// QueuePosition position of the build in the build service's queue, if queued. 0 if unknown
+	QueuePosition int
+	// ETA estimated time until the build is ready. 0 if unknown
+	ETA time.Duration
+	// Message additional information from the build service, if any
+	Message string
+}
+
+// buildState is the state of an asynchronous build reported by the build service
+type buildState struct {
+	ID            string                `json:"id,omitempty"`
+	Status        BuildStatus           `json:"status,omitempty"`
+	QueuePosition int                   `json:"queuePosition,omitempty"`
+	ETA           int                   `json:"eta,omitempty"` // seconds
+	Message       string                `json:"message,omitempty"`
+	Artifact      k6build.Artifact      `json:"artifact,omitempty"`
+	Error         *k6build.WrappedError `json:"error,omitempty"`
+}
+
+func (s buildState) progress() BuildProgress {
+	return BuildProgress{
+		BuildID:       s.ID,
+		Status:        s.Status,
+		QueuePosition: s.QueuePosition,
+		ETA:           time.Duration(s.ETA) * time.Second,
+		Message:       s.Message,
+	}
+}
+
+// asyncBuildClient is a client for the asynchronous build protocol.
+//
+// Builds are requested with a POST <url>/build request with the "Prefer: respond-async" header.
+// The build service can respond with the artifact (200 OK) as usual or accept the build (202 Accepted)
+// returning its id and status. The status of the build is then polled with GET <url>/build/<id>
+// requests until it is ready or failed. The Retry-After header is used as polling interval, if present.
+type asyncBuildClient struct {
+	srvURL       *url.URL
+	auth         string
+	authType     string
+	headers      map[string]string
+	client       *http.Client
+	pollInterval time.Duration
+}
+
+func newAsyncBuildClient(config Config, srvURL string, auth string, client *http.Client) (*asyncBuildClient, error) {
+	parsed, err := url.Parse(srvURL)
+	if err != nil {
+		return nil, err
+	}
+
+	authType := config.BuildServiceAuthType
+	if authType == "" {
+		authType = "Bearer"
+	}
+
+	pollInterval := config.BuildPollInterval
+	if pollInterval == 0 {
+		pollInterval = defaultBuildPollInterval
+	}
+
+	return &asyncBuildClient{
+		srvURL:       parsed,
+		auth:         auth,
+		authType:     authType,
+		headers:      config.BuildServiceHeaders,
+		client:       client,
+		pollInterval: pollInterval,
+	}, nil
+}
+
+func (c *asyncBuildClient) do(req *http.Request) (*http.Response, error) {
+	// add authorization header "Authorization: <type> <auth>"
+	if c.auth != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("%s %s", c.authType, c.auth))
+	}
+
+	// add custom headers
+	for h, v := range c.headers {
+		req.Header.Add(h, v)
+	}
+
+	return c.client.Do(req)
+}
+
+// submit requests a build, returning its state and the time to wait before polling its status
+func (c *asyncBuildClient) submit(
+	ctx context.Context,
+	platform string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (buildState, time.Duration, error) {
+	marshaled := &bytes.Buffer{}
+	err := json.NewEncoder(marshaled).Encode(api.BuildRequest{
+		Platform:     platform,
+		K6Constrains: k6Constrains,
+		Dependencies: deps,
+	})
+	if err != nil {
+		return buildState{}, 0, k6build.NewWrappedError(api.ErrInvalidRequest, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.srvURL.JoinPath("build").String(), marshaled)
+	if err != nil {
+		return buildState{}, 0, k6build.NewWrappedError(api.ErrRequestFailed, err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Prefer", "respond-async")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return buildState{}, 0, k6build.NewWrappedError(api.ErrRequestFailed, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// the build service completed the build synchronously
+		buildResponse := api.BuildResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&buildResponse); err != nil {
+			return buildState{}, 0, k6build.NewWrappedError(api.ErrRequestFailed, err)
+		}
+		if buildResponse.Error != nil {
+			return buildState{}, 0, buildResponse.Error
+		}
+		return buildState{ID: buildResponse.Artifact.ID, Status: BuildReady, Artifact: buildResponse.Artifact}, 0, nil
+	case http.StatusAccepted:
+		state := buildState{}
+		if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+			return buildState{}, 0, k6build.NewWrappedError(api.ErrRequestFailed, err)
+		}
+		if state.ID == "" {
+			return buildState{}, 0, k6build.NewWrappedError(api.ErrRequestFailed, errors.New("missing build id"))
+		}
+		if state.Status == "" {
+			state.Status = BuildQueued
+		}
+		return state, c.retryAfter(resp), nil
+	default:
+		return buildState{}, 0, k6build.NewWrappedError(api.ErrRequestFailed, errors.New(resp.Status))
+	}
+}
+
+// status returns the state of a build and the time to wait before polling it again
+func (c *asyncBuildClient) status(ctx context.Context, id string) (buildState, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.srvURL.JoinPath("build", id).String(), nil)
+	if err != nil {
+		return buildState{}, 0, k6build.NewWrappedError(api.ErrRequestFailed, err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return buildState{}, 0, k6build.NewWrappedError(api.ErrRequestFailed, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return buildState{}, 0, k6build.NewWrappedError(api.ErrRequestFailed, errors.New(resp.Status))
+	}
+
+	state := buildState{}
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return buildState{}, 0, k6build.NewWrappedError(api.ErrRequestFailed, err)
+	}
+
+	return state, c.retryAfter(resp), nil
+}
+
+// retryAfter returns the polling interval requested by the build service or the default
+func (c *asyncBuildClient) retryAfter(resp *http.Response) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return c.pollInterval
+}
+
+// wait polls the status of the build until it is ready or failed, notifying the changes in its progress
+func (c *asyncBuildClient) wait(
+	ctx context.Context,
+	state buildState,
+	delay time.Duration,
+	notify func(previous BuildProgress, current BuildProgress),
+) (k6build.Artifact, error) {
+	for {
+		switch state.Status {
+		case BuildReady:
+			return state.Artifact, nil
+		case BuildFailed:
+			if state.Error != nil {
+				return k6build.Artifact{}, state.Error
+			}
+			return k6build.Artifact{}, api.ErrBuildFailed
+		case BuildQueued, BuildBuilding:
+		default:
+			return k6build.Artifact{}, k6build.NewWrappedError(
+				api.ErrRequestFailed,
+				fmt.Errorf("unknown build status %q", state.Status),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return k6build.Artifact{}, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		previous := state.progress()
+		id := state.ID
+
+		var err error
+		state, delay, err = c.status(ctx, id)
+		if err != nil {
+			return k6build.Artifact{}, err
+		}
+		state.ID = id
+
+		notify(previous, state.progress())
+	}
+}
+
+// build requests a build and waits until it is ready or failed
+func (c *asyncBuildClient) build(
+	ctx context.Context,
+	platform string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+	notify func(previous BuildProgress, current BuildProgress),
+) (k6build.Artifact, error) {
+	state, delay, err := c.submit(ctx, platform, k6Constrains, deps)
+	if err != nil {
+		return k6build.Artifact{}, err
+	}
+
+	notify(BuildProgress{}, state.progress())
+
+	return c.wait(ctx, state, delay, notify)
+}
+
+// pendingBuild is a build started with [Provider.StartBuild]
+type pendingBuild struct {
+	endpoint *buildEndpoint
+	state    buildState
+	delay    time.Duration
+}
+
+// pendingBuilds keeps track of the builds started with [Provider.StartBuild]
+type pendingBuilds struct {
+	mutex  sync.Mutex
+	builds map[string]pendingBuild
+}
+
+func (p *pendingBuilds) add(build pendingBuild) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.builds == nil {
+		p.builds = map[string]pendingBuild{}
+	}
+	p.builds[build.state.ID] = build
+}
+
+func (p *pendingBuilds) get(id string) (pendingBuild, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	build, found := p.builds[id]
+	return build, found
+}
+
+func (p *pendingBuilds) remove(id string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.builds, id)
+}
+
+// StartBuild requests the build of a custom k6 artifact that satisfies the given set of
+// dependencies, without waiting for it to finish. Returns the id of the build, which can be
+// used for waiting for the artifact with [Provider.WaitForArtifact].
+//
+// Requires the asynchronous build protocol to be enabled. See [Config.AsyncBuilds]
+func (p *Provider) StartBuild(ctx context.Context, deps k6deps.Dependencies) (string, error) {
+	if p.ctx.Err() != nil {
+		return "", ErrClosed
+	}
+
+	k6Constrains, buildDeps := p.buildDeps(deps)
+
+	buildSrv, err := p.buildServices()
+	if err != nil {
+		return "", p.buildError(err)
+	}
+
+	build, err := buildSrv.submit(ctx, p.platform, k6Constrains, buildDeps)
+	if err != nil {
+		return "", p.buildError(err)
+	}
+
+	p.builds.add(build)
+
+	return build.state.ID, nil
+}
+
+// WaitForArtifact waits until the build started with [Provider.StartBuild] is finished and
+// returns its artifact. The changes in the status of the build are notified to [Config.OnBuildStatus]
+// and [Config.OnBuildProgress].
+func (p *Provider) WaitForArtifact(ctx context.Context, id string) (Artifact, error) {
+	if p.ctx.Err() != nil {
+		return Artifact{}, ErrClosed
+	}
+
+	build, found := p.builds.get(id)
+	if !found {
+		return Artifact{}, NewWrappedError(ErrBuild, fmt.Errorf("unknown build %q", id))
+	}
+
+	artifact, err := build.endpoint.async.wait(ctx, build.state, build.delay, p.buildSrv.notify)
+	if err != nil {
+		if ctx.Err() == nil {
+			p.builds.remove(id)
+		}
+		return Artifact{}, p.buildError(err)
+	}
+
+	p.builds.remove(id)
+
+	return newArtifact(artifact, build.endpoint.url), nil
+}
@@ -0,0 +1,215 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6build/pkg/api"
+	"github.com/grafana/k6deps"
+)
+
+// newAsyncBuildServer returns a build service that accepts the builds asynchronously and
+// reports the given states on each status request
+func newAsyncBuildServer(t *testing.T, states ...buildState) *httptest.Server {
+	t.Helper()
+
+	polls := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/build":
+			if r.Header.Get("Prefer") != "respond-async" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+			_ = json.NewEncoder(w).Encode(buildState{ID: "build-1", Status: BuildQueued})
+		case r.Method == http.MethodGet && r.URL.Path == "/build/build-1":
+			poll := int(polls.Add(1)) - 1
+			_ = json.NewEncoder(w).Encode(states[min(poll, len(states)-1)])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestAsyncBuild(t *testing.T) {
+	t.Parallel()
+
+	artifact := k6build.Artifact{ID: "artifact", URL: "http://localhost/artifact"}
+
+	testCases := []struct {
+		title          string
+		states         []buildState
+		expectErr      error
+		expectStatuses []BuildStatus
+	}{
+		{
+			title: "build ready",
+			states: []buildState{
+				{Status: BuildQueued},
+				{Status: BuildBuilding},
+				{Status: BuildBuilding},
+				{Status: BuildReady, Artifact: artifact},
+			},
+			expectStatuses: []BuildStatus{BuildQueued, BuildBuilding, BuildReady},
+		},
+		{
+			title: "build failed",
+			states: []buildState{
+				{Status: BuildBuilding},
+				{Status: BuildFailed, Error: k6build.NewWrappedError(api.ErrBuildFailed, errors.New("compiler error"))},
+			},
+			expectErr:      ErrBuild,
+			expectStatuses: []BuildStatus{BuildQueued, BuildBuilding, BuildFailed},
+		},
+		{
+			title: "invalid parameters",
+			states: []buildState{
+				{Status: BuildFailed, Error: k6build.NewWrappedError(ErrInvalidParameters, errors.New("unknown extension"))},
+			},
+			expectErr:      ErrInvalidParameters,
+			expectStatuses: []BuildStatus{BuildQueued, BuildFailed},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			srv := newAsyncBuildServer(t, tc.states...)
+
+			mutex := sync.Mutex{}
+			statuses := []BuildStatus{}
+			provider, err := NewProvider(Config{
+				BinDir:            t.TempDir(),
+				BuildServiceURL:   srv.URL,
+				AsyncBuilds:       true,
+				BuildPollInterval: time.Millisecond,
+				OnBuildStatus: func(status BuildStatus) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					statuses = append(statuses, status)
+				},
+			})
+			if err != nil {
+				t.Fatalf("creating provider %v", err)
+			}
+
+			id, err := provider.StartBuild(context.TODO(), k6deps.Dependencies{})
+			if err != nil {
+				t.Fatalf("starting build %v", err)
+			}
+
+			result, err := provider.WaitForArtifact(context.TODO(), id)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			if tc.expectErr == nil && (result.ID != artifact.ID || result.BuildService != srv.URL) {
+				t.Fatalf("expected artifact %v got %v", artifact, result)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if len(statuses) != len(tc.expectStatuses) {
+				t.Fatalf("expected %v got %v", tc.expectStatuses, statuses)
+			}
+			for i := range statuses {
+				if statuses[i] != tc.expectStatuses[i] {
+					t.Fatalf("expected %v got %v", tc.expectStatuses, statuses)
+				}
+			}
+
+			// the build is no longer pending
+			if _, err := provider.WaitForArtifact(context.TODO(), id); !errors.Is(err, ErrBuild) {
+				t.Fatalf("expected %v got %v", ErrBuild, err)
+			}
+		})
+	}
+}
+
+func TestAsyncGetArtifact(t *testing.T) {
+	t.Parallel()
+
+	srv := newAsyncBuildServer(t,
+		buildState{Status: BuildBuilding},
+		buildState{Status: BuildReady, Artifact: k6build.Artifact{ID: "artifact"}},
+	)
+
+	provider, err := NewProvider(Config{
+		BinDir:            t.TempDir(),
+		BuildServiceURL:   srv.URL,
+		AsyncBuilds:       true,
+		BuildPollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("creating provider %v", err)
+	}
+
+	artifact, err := provider.GetArtifact(context.TODO(), k6deps.Dependencies{})
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if artifact.ID != "artifact" {
+		t.Fatalf("expected artifact got %v", artifact)
+	}
+}
+
+func TestBuildProgress(t *testing.T) {
+	t.Parallel()
+
+	srv := newAsyncBuildServer(t,
+		buildState{Status: BuildQueued, QueuePosition: 3, ETA: 120},
+		buildState{Status: BuildQueued, QueuePosition: 3, ETA: 120},
+		buildState{Status: BuildQueued, QueuePosition: 1, ETA: 60},
+		buildState{Status: BuildBuilding, ETA: 30, Message: "compiling"},
+		buildState{Status: BuildReady, Artifact: k6build.Artifact{ID: "artifact"}},
+	)
+
+	progress := []BuildProgress{}
+	provider, err := NewProvider(Config{
+		BinDir:            t.TempDir(),
+		BuildServiceURL:   srv.URL,
+		AsyncBuilds:       true,
+		BuildPollInterval: time.Millisecond,
+		OnBuildProgress: func(p BuildProgress) {
+			progress = append(progress, p)
+		},
+	})
+	if err != nil {
+		t.Fatalf("creating provider %v", err)
+	}
+
+	if _, err = provider.GetArtifact(context.TODO(), k6deps.Dependencies{}); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// repeated reports are not notified
+	expected := []BuildProgress{
+		{BuildID: "build-1", Status: BuildQueued},
+		{BuildID: "build-1", Status: BuildQueued, QueuePosition: 3, ETA: 2 * time.Minute},
+		{BuildID: "build-1", Status: BuildQueued, QueuePosition: 1, ETA: time.Minute},
+		{BuildID: "build-1", Status: BuildBuilding, ETA: 30 * time.Second, Message: "compiling"},
+		{BuildID: "build-1", Status: BuildReady},
+	}
+	if len(progress) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, progress)
+	}
+	for i := range expected {
+		if progress[i] != expected[i] {
+			t.Fatalf("expected %v got %v", expected[i], progress[i])
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// attestationSuffix is appended to the URL of an artifact to obtain the URL of its attestation
+	attestationSuffix = ".intoto.jsonl"
+	// attestationFile is the file in the artifact directory where the attestation is stored
+	attestationFile = "attestation.intoto.jsonl"
+	// inTotoPayloadType is the payload type of the envelopes with in-toto statements
+	inTotoPayloadType = "application/vnd.in-toto+json"
+)
+
+// AttestationConfig defines the retrieval and verification of the provenance attestations published
+// by the build service alongside the artifacts, as a [DSSE] envelope with an in-toto statement with
+// a [SLSA provenance] predicate at <artifact URL>.intoto.jsonl
+//
+// [DSSE]: https://github.com/secure-systems-lab/dsse
+// [SLSA provenance]: https://slsa.dev/provenance/v1
+type AttestationConfig struct {
+	// Enabled fetches and verifies the attestation of the artifacts. If the build service doesn't
+	// publish the attestation of an artifact, the binary is returned without it unless Required is set
+	Enabled bool
+	// Required fails with an [ErrAttestation] error if the attestation of an artifact is not published
+	Required bool
+	// TrustedBuilders IDs of the builders trusted for building the binaries. If empty, any builder is trusted
+	TrustedBuilders []string
+	// TrustedSources prefixes of the URIs of the sources trusted for building the binaries,
+	// such as "git+https://github.com/grafana/". If empty, any source is trusted
+	TrustedSources []string
+	// VerifyEnvelope verifies the signatures of the envelope, for example, using the keys of the
+	// build service. If not set, the signatures are not verified.
+	VerifyEnvelope func(envelope AttestationEnvelope) error `json:"-"`
+}
+
+// AttestationEnvelope is a DSSE envelope with an attestation
+type AttestationEnvelope struct {
+	// PayloadType type of the payload. e.g. "application/vnd.in-toto+json"
+	PayloadType string `json:"payloadType"`
+	// Payload of the envelope, base64 encoded
+	Payload string `json:"payload"`
+	// Signatures of the payload
+	Signatures []AttestationSignature `json:"signatures"`
+}
+
+// AttestationSignature is a signature of an envelope
+type AttestationSignature struct {
+	// KeyID identifies the key used for the signature
+	KeyID string `json:"keyid,omitempty"`
+	// Sig signature, base64 encoded
+	Sig string `json:"sig"`
+}
+
+// AttestedSource is a source used for building a binary, as recorded in its attestation
+type AttestedSource struct {
+	// URI of the source. e.g. "git+https://github.com/grafana/xk6-kubernetes@v0.9.0"
+	URI string `json:"uri"`
+	// Digest of the source as a map of algorithm: digest
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// Attestation is the verified provenance of a binary
+type Attestation struct {
+	// PredicateType type of the provenance. e.g. "https://slsa.dev/provenance/v1"
+	PredicateType string `json:"predicateType"`
+	// BuilderID identifies the builder that built the binary
+	BuilderID string `json:"builderId"`
+	// Sources used for building the binary, such as k6 and the extensions
+	Sources []AttestedSource `json:"sources,omitempty"`
+	// Envelope with the attestation, for keeping it in audit records
+	Envelope AttestationEnvelope `json:"envelope"`
+}
+
+// inTotoSubject is an artifact an in-toto statement refers to
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// inTotoStatement is the subset of an in-toto statement with a SLSA provenance used for verification
+type inTotoStatement struct {
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     struct {
+		BuildDefinition struct {
+			ResolvedDependencies []AttestedSource `json:"resolvedDependencies"`
+		} `json:"buildDefinition"`
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+		} `json:"runDetails"`
+	} `json:"predicate"`
+}
+
+// attest returns the binary with its verified attestation, if attestations are enabled.
+// The attestation is downloaded the first time and stored with the binary.
+func (p *Provider) attest(ctx context.Context, binary K6Binary, artifact Artifact) (K6Binary, error) {
+	config := p.config.Attestations
+	if !config.Enabled {
+		return binary, nil
+	}
+
+	content, err := p.fetchAttestation(ctx, filepath.Dir(binary.Path), artifact)
+	if err != nil {
+		return K6Binary{}, p.recordError(NewWrappedError(ErrAttestation, err))
+	}
+
+	if content == nil {
+		if config.Required {
+			err = fmt.Errorf("artifact %s has no attestation", artifact.ID)
+			return K6Binary{}, p.recordError(NewWrappedError(ErrAttestation, err))
+		}
+		return binary, nil
+	}
+
+	attestation, err := verifyAttestation(config, content, binary.Checksum)
+	if err != nil {
+		return K6Binary{}, p.recordError(NewWrappedError(ErrAttestation, err))
+	}
+
+	binary.Attestation = &attestation
+	return binary, nil
+}
+
+// fetchAttestation returns the attestation stored in the artifact directory or, if not stored,
+// downloads it and stores it. Returns nil if the build service doesn't publish the attestation.
+func (p *Provider) fetchAttestation(ctx context.Context, artifactDir string, artifact Artifact) ([]byte, error) {
+	path := filepath.Join(artifactDir, attestationFile)
+
+	content, err := os.ReadFile(path) //nolint:gosec
+	if err == nil {
+		return content, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if artifact.URL == "" {
+		return nil, nil
+	}
+
+	buffer := &bytes.Buffer{}
+	err = p.downloader.download(ctx, artifact.URL+attestationSuffix, buffer)
+	var downloadErr *DownloadError
+	if errors.As(err, &downloadErr) && downloadErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// failing to store the attestation is not an error, it is downloaded again next time
+	_ = os.WriteFile(path, buffer.Bytes(), 0o600)
+
+	return buffer.Bytes(), nil
+}
+
+// verifyAttestation verifies the attestation is for a binary with the given checksum and
+// was built by a trusted builder from trusted sources
+func verifyAttestation(config AttestationConfig, content []byte, checksum string) (Attestation, error) {
+	envelope := AttestationEnvelope{}
+	// the attestation may be a bundle with one envelope per line, the first one is used
+	line, _, _ := bytes.Cut(bytes.TrimSpace(content), []byte("\n"))
+	if err := json.Unmarshal(line, &envelope); err != nil {
+		return Attestation{}, fmt.Errorf("parsing envelope %w", err)
+	}
+
+	if envelope.PayloadType != inTotoPayloadType {
+		return Attestation{}, fmt.Errorf("unsupported payload type %q", envelope.PayloadType)
+	}
+
+	if config.VerifyEnvelope != nil {
+		if err := config.VerifyEnvelope(envelope); err != nil {
+			return Attestation{}, fmt.Errorf("verifying envelope %w", err)
+		}
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return Attestation{}, fmt.Errorf("decoding payload %w", err)
+	}
+
+	statement := inTotoStatement{}
+	if err = json.Unmarshal(payload, &statement); err != nil {
+		return Attestation{}, fmt.Errorf("parsing statement %w", err)
+	}
+
+	if !slices.ContainsFunc(statement.Subject, func(subject inTotoSubject) bool {
+		return checksum != "" && strings.EqualFold(subject.Digest["sha256"], checksum)
+	}) {
+		return Attestation{}, fmt.Errorf("attestation is not for binary with checksum %s", checksum)
+	}
+
+	builder := statement.Predicate.RunDetails.Builder.ID
+	if len(config.TrustedBuilders) > 0 && !slices.Contains(config.TrustedBuilders, builder) {
+		return Attestation{}, fmt.Errorf("untrusted builder %q", builder)
+	}
+
+	sources := statement.Predicate.BuildDefinition.ResolvedDependencies
+	for _, source := range sources {
+		if !trustedSource(config.TrustedSources, source.URI) {
+			return Attestation{}, fmt.Errorf("untrusted source %q", source.URI)
+		}
+	}
+
+	return Attestation{
+		PredicateType: statement.PredicateType,
+		BuilderID:     builder,
+		Sources:       sources,
+		Envelope:      envelope,
+	}, nil
+}
+
+// trustedSource returns true if the URI has one of the trusted prefixes or no prefix is given
+func trustedSource(trusted []string, uri string) bool {
+	if len(trusted) == 0 {
+		return true
+	}
+
+	for _, prefix := range trusted {
+		if strings.HasPrefix(uri, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
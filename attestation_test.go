@@ -0,0 +1,170 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+// newTestAttestation returns an envelope with a SLSA provenance for a binary with the given checksum
+func newTestAttestation(t *testing.T, checksum string, builder string, source string) []byte {
+	t.Helper()
+
+	statement := map[string]any{
+		"_type":         "https://in-toto.io/Statement/v1",
+		"subject":       []any{map[string]any{"name": "k6", "digest": map[string]string{"sha256": checksum}}},
+		"predicateType": "https://slsa.dev/provenance/v1",
+		"predicate": map[string]any{
+			"buildDefinition": map[string]any{
+				"resolvedDependencies": []any{map[string]any{"uri": source}},
+			},
+			"runDetails": map[string]any{"builder": map[string]any{"id": builder}},
+		},
+	}
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		t.Fatalf("test setup: %v", err)
+	}
+
+	envelope, err := json.Marshal(AttestationEnvelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []AttestationSignature{{KeyID: "key", Sig: "c2lnbmF0dXJl"}},
+	})
+	if err != nil {
+		t.Fatalf("test setup: %v", err)
+	}
+
+	return envelope
+}
+
+func TestAttestations(t *testing.T) {
+	t.Parallel()
+
+	binary := []byte("k6 binary")
+	hash := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(hash[:])
+
+	builder := "https://build.example.com/k6build"
+	source := "git+https://github.com/grafana/xk6-kubernetes@v0.9.0"
+
+	testCases := []struct {
+		title         string
+		config        AttestationConfig
+		attestation   []byte
+		expectBuilder string
+		expectErr     error
+	}{
+		{
+			title:       "attestations disabled",
+			attestation: newTestAttestation(t, "other", builder, source),
+		},
+		{
+			title:  "attestation not published",
+			config: AttestationConfig{Enabled: true},
+		},
+		{
+			title:     "attestation required",
+			config:    AttestationConfig{Enabled: true, Required: true},
+			expectErr: ErrAttestation,
+		},
+		{
+			title:         "valid attestation",
+			config:        AttestationConfig{Enabled: true},
+			attestation:   newTestAttestation(t, checksum, builder, source),
+			expectBuilder: builder,
+		},
+		{
+			title: "trusted builder and source",
+			config: AttestationConfig{
+				Enabled:         true,
+				TrustedBuilders: []string{builder},
+				TrustedSources:  []string{"git+https://github.com/grafana/"},
+			},
+			attestation:   newTestAttestation(t, checksum, builder, source),
+			expectBuilder: builder,
+		},
+		{
+			title:       "attestation for other binary",
+			config:      AttestationConfig{Enabled: true},
+			attestation: newTestAttestation(t, "other", builder, source),
+			expectErr:   ErrAttestation,
+		},
+		{
+			title:       "untrusted builder",
+			config:      AttestationConfig{Enabled: true, TrustedBuilders: []string{"https://other"}},
+			attestation: newTestAttestation(t, checksum, builder, source),
+			expectErr:   ErrAttestation,
+		},
+		{
+			title:       "untrusted source",
+			config:      AttestationConfig{Enabled: true, TrustedSources: []string{"git+https://github.com/example/"}},
+			attestation: newTestAttestation(t, checksum, builder, source),
+			expectErr:   ErrAttestation,
+		},
+		{
+			title: "invalid signature",
+			config: AttestationConfig{
+				Enabled:        true,
+				VerifyEnvelope: func(AttestationEnvelope) error { return errors.New("invalid signature") },
+			},
+			attestation: newTestAttestation(t, checksum, builder, source),
+			expectErr:   ErrAttestation,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/k6", func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write(binary)
+			})
+			mux.HandleFunc("/k6"+attestationSuffix, func(w http.ResponseWriter, _ *http.Request) {
+				if tc.attestation == nil {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write(tc.attestation)
+			})
+			store := httptest.NewServer(mux)
+			t.Cleanup(store.Close)
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL + "/k6", Checksum: checksum}, nil
+				},
+			)
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+			provider.config.Attestations = tc.config
+
+			// the second time, the attestation is taken from the cache
+			for range 2 {
+				k6, err := provider.GetBinary(context.TODO(), nil)
+				if !errors.Is(err, tc.expectErr) {
+					t.Fatalf("expected %v got %v", tc.expectErr, err)
+				}
+				if err != nil {
+					return
+				}
+
+				builderID := ""
+				if k6.Attestation != nil {
+					builderID = k6.Attestation.BuilderID
+				}
+				if builderID != tc.expectBuilder {
+					t.Fatalf("expected %v got %v", tc.expectBuilder, builderID)
+				}
+			}
+		})
+	}
+}
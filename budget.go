@@ -0,0 +1,83 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PhaseBudget splits the time remaining until the deadline of the context passed to
+// [Provider.GetBinary] between the phases of the provisioning, so a slow build service
+// doesn't consume all of it, leaving no time for downloading the binary.
+//
+// If a phase exceeds its deadline, the error returned wraps a [PhaseDeadlineError] that
+// identifies it.
+type PhaseBudget struct {
+	// ResolveFraction fraction of the remaining time given to resolving the dependencies with the
+	// build service, between 0 and 1 (e.g. 0.3). The binary is downloaded with the time left. If
+	// resolving them exceeds its budget, the binary that satisfied the same dependencies before is
+	// provided from the cache, if enabled (see StaleIfError). Defaults to 0 (the phases share the deadline)
+	ResolveFraction float64
+}
+
+// PhaseDeadlineError indicates a phase of the provisioning of a binary exceeded the time given
+// to it. See [PhaseBudget]. It can be obtained from the errors returned by the provider using errors.As
+type PhaseDeadlineError struct {
+	// Phase that exceeded its deadline: "resolve" or "download"
+	Phase string
+	// Budget time given to the phase
+	Budget time.Duration
+	// Err error returned by the phase
+	Err error
+}
+
+// Error returns the phase, its budget and the error returned by the phase
+func (e *PhaseDeadlineError) Error() string {
+	return fmt.Sprintf("%s phase exceeded its budget of %s: %v", e.Phase, e.Budget, e.Err)
+}
+
+// Unwrap returns [context.DeadlineExceeded] and the error returned by the phase
+func (e *PhaseDeadlineError) Unwrap() []error {
+	return []error{context.DeadlineExceeded, e.Err}
+}
+
+// resolveContext returns a context with the deadline for resolving the dependencies and the time
+// given to it, if the context has a deadline and the budget gives a fraction of it to the phase.
+// Otherwise, the context is returned unchanged.
+func (b PhaseBudget) resolveContext(ctx context.Context) (context.Context, time.Duration, context.CancelFunc) {
+	remaining := b.remaining(ctx)
+	if remaining <= 0 {
+		return ctx, 0, func() {}
+	}
+
+	budget := time.Duration(float64(remaining) * b.ResolveFraction)
+	ctx, cancel := context.WithTimeout(ctx, budget)
+
+	return ctx, budget, cancel
+}
+
+// remaining returns the time until the context's deadline, if the budget is enabled and the
+// context has one
+func (b PhaseBudget) remaining(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok || b.ResolveFraction <= 0 {
+		return 0
+	}
+
+	return time.Until(deadline)
+}
+
+// phaseDeadline returns the error of a phase as a [PhaseDeadlineError], keeping its category,
+// if the phase was given a budget and the deadline of its context was exceeded
+func phaseDeadline(ctx context.Context, err error, phase string, budget time.Duration) error {
+	if err == nil || budget <= 0 || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	if wrapped, ok := err.(WrappedError); ok { //nolint:errorlint
+		return NewWrappedError(wrapped.Err, &PhaseDeadlineError{Phase: phase, Budget: budget, Err: wrapped.Reason})
+	}
+
+	return &PhaseDeadlineError{Phase: phase, Budget: budget, Err: err}
+}
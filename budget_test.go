@@ -0,0 +1,112 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+)
+
+func TestPhaseBudget(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	testCases := []struct {
+		title        string
+		slowBuild    bool
+		slowDownload bool
+		cached       bool
+		expectPhase  string
+		expectErr    error
+	}{
+		{
+			title:       "within budget",
+			expectPhase: "",
+			expectErr:   nil,
+		},
+		{
+			title:       "slow build",
+			slowBuild:   true,
+			expectPhase: "resolve",
+			expectErr:   ErrBuild,
+		},
+		{
+			title:        "slow download",
+			slowDownload: true,
+			expectPhase:  "download",
+			expectErr:    ErrDownload,
+		},
+		{
+			title:       "slow build with cached binary",
+			slowBuild:   true,
+			cached:      true,
+			expectPhase: "",
+			expectErr:   nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			slow := atomic.Bool{}
+			store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if slow.Load() && tc.slowDownload {
+					<-r.Context().Done()
+					return
+				}
+				_, _ = w.Write(content)
+			}))
+			t.Cleanup(store.Close)
+
+			buildSrv := buildServiceFunc(
+				func(ctx context.Context, _ string, _ string, _ []k6build.Dependency) (k6build.Artifact, error) {
+					if slow.Load() && tc.slowBuild {
+						<-ctx.Done()
+						return k6build.Artifact{}, ctx.Err()
+					}
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+				},
+			)
+
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+			provider.config.PhaseBudget = PhaseBudget{ResolveFraction: 0.5}
+			provider.config.StaleIfError = time.Hour
+			provider.downloader.backoff = time.Millisecond
+
+			if tc.cached {
+				if _, err := provider.GetBinary(context.TODO(), nil); err != nil {
+					t.Fatalf("unexpected %v", err)
+				}
+			}
+
+			slow.Store(true)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			_, err := provider.GetBinary(ctx, nil)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			var deadlineErr *PhaseDeadlineError
+			found := errors.As(err, &deadlineErr)
+			if found != (tc.expectPhase != "") || (found && deadlineErr.Phase != tc.expectPhase) {
+				t.Fatalf("expected phase %q got %v", tc.expectPhase, err)
+			}
+			if found && !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected %v got %v", context.DeadlineExceeded, err)
+			}
+		})
+	}
+}
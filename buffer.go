@@ -0,0 +1,49 @@
+package k6provider
+
+import (
+	"io"
+	"sync"
+)
+
+// DefaultBufferSize size of the buffers used for copying binaries
+const DefaultBufferSize = 32 * 1024
+
+// bufferPool is a pool of buffers of a fixed size used for copying binaries, so provisioning
+// many binaries concurrently doesn't allocate a buffer for each copy
+type bufferPool struct {
+	pool sync.Pool
+}
+
+// defaultBuffers is the pool of buffers of the default size
+var defaultBuffers = buffersOfSize(DefaultBufferSize) //nolint:gochecknoglobals
+
+// newBufferPool returns a pool of buffers of the given size, or the pool of the default size
+// if the size is not set
+func newBufferPool(size int) *bufferPool {
+	if size <= 0 || size == DefaultBufferSize {
+		return defaultBuffers
+	}
+
+	return buffersOfSize(size)
+}
+
+// buffersOfSize returns a new pool of buffers of the given size
+func buffersOfSize(size int) *bufferPool {
+	return &bufferPool{
+		pool: sync.Pool{
+			New: func() any {
+				buffer := make([]byte, size)
+				return &buffer
+			},
+		},
+	}
+}
+
+// copy copies from the reader to the writer using a buffer from the pool
+func (b *bufferPool) copy(dst io.Writer, src io.Reader) (int64, error) {
+	buffer, _ := b.pool.Get().(*[]byte)
+	defer b.pool.Put(buffer)
+
+	// hide the reader's WriterTo and the writer's ReaderFrom so the buffer is used
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buffer)
+}
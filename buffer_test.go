@@ -0,0 +1,42 @@
+package k6provider
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBufferPool(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title string
+		size  int
+	}{
+		{title: "default size", size: 0},
+		{title: "custom size", size: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			pool := newBufferPool(tc.size)
+			if tc.size == 0 && pool != defaultBuffers {
+				t.Fatalf("expected default buffers")
+			}
+
+			content := strings.Repeat("k6 binary ", 10)
+			for range 3 {
+				out := &bytes.Buffer{}
+				n, err := pool.copy(out, strings.NewReader(content))
+				if err != nil {
+					t.Fatalf("unexpected %v", err)
+				}
+				if n != int64(len(content)) || out.String() != content {
+					t.Fatalf("expected %q got %q", content, out.String())
+				}
+			}
+		})
+	}
+}
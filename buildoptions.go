@@ -0,0 +1,169 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/grafana/k6deps"
+)
+
+// buildOptionsHeader is the header of the build service requests with the options for
+// building the binary
+const buildOptionsHeader = "X-K6build-Options"
+
+// buildTagRegex matches a valid go build tag
+var buildTagRegex = regexp.MustCompile(`^[A-Za-z0-9_.]+$`) //nolint:gochecknoglobals
+
+// racePlatforms are the os/arch combinations supported by the race detector
+var racePlatforms = []string{ //nolint:gochecknoglobals
+	"darwin/amd64", "darwin/arm64", "freebsd/amd64", "linux/amd64", "linux/arm64",
+	"linux/ppc64le", "linux/s390x", "netbsd/amd64", "windows/amd64",
+}
+
+// BuildOptions defines options for building specialized k6 binaries, for example, for debugging.
+// See [Provider.GetBinaryWithOptions]
+type BuildOptions struct {
+	// Tags build tags for all platforms. e.g. ["netgo", "osusergo"]
+	Tags []string `json:"tags,omitempty"`
+	// PlatformTags build tags used only when building for a given os (e.g. "linux") or
+	// platform (e.g. "linux/amd64"), added to Tags
+	PlatformTags map[string][]string `json:"platformTags,omitempty"`
+	// CGO builds the binary with cgo enabled. By default, binaries are built without cgo
+	CGO bool `json:"cgo,omitempty"`
+	// Flags additional flags passed to go build. e.g. ["-trimpath"]
+	Flags []string `json:"flags,omitempty"`
+	// Race builds the binary with the race detector enabled, for troubleshooting extension
+	// crashes. As the race detector requires cgo, it implies CGO
+	Race bool `json:"race,omitempty"`
+	// DebugInfo builds the binary keeping the symbol table and the debug information, which are
+	// stripped from the binaries by default, so crashes can be analyzed with a debugger
+	DebugInfo bool `json:"debugInfo,omitempty"`
+}
+
+// IsZero returns true if no option is set
+func (o BuildOptions) IsZero() bool {
+	return len(o.Tags) == 0 && len(o.PlatformTags) == 0 && !o.CGO && len(o.Flags) == 0 &&
+		!o.Race && !o.DebugInfo
+}
+
+// channel returns the name of the troubleshooting build channel of the options: "race",
+// "debug" or "race-debug". Returns an empty string for optimized builds.
+func (o BuildOptions) channel() string {
+	channel := []string{}
+	if o.Race {
+		channel = append(channel, "race")
+	}
+	if o.DebugInfo {
+		channel = append(channel, "debug")
+	}
+
+	return strings.Join(channel, "-")
+}
+
+// forPlatform returns the options for building for the platform, with the platform's tags
+// added to the tags, sorted and without duplicates
+func (o BuildOptions) forPlatform(platform string) BuildOptions {
+	goos, _, _ := strings.Cut(platform, "/")
+
+	tags := slices.Clone(o.Tags)
+	for target, platformTags := range o.PlatformTags {
+		if target == goos || target == platform {
+			tags = append(tags, platformTags...)
+		}
+	}
+	slices.Sort(tags)
+
+	return BuildOptions{
+		Tags:      slices.Compact(tags),
+		CGO:       o.CGO || o.Race,
+		Flags:     slices.Clone(o.Flags),
+		Race:      o.Race,
+		DebugInfo: o.DebugInfo,
+	}
+}
+
+// validate checks the build tags are valid and the race detector, if enabled, supports the platform
+func (o BuildOptions) validate(platform string) error {
+	if o.Race {
+		parts := strings.SplitN(platform, "/", 3)
+		if len(parts) < 2 || !slices.Contains(racePlatforms, parts[0]+"/"+parts[1]) {
+			return fmt.Errorf("race detector not supported on %q", platform)
+		}
+	}
+
+	tags := slices.Clone(o.Tags)
+	for _, platformTags := range o.PlatformTags {
+		tags = append(tags, platformTags...)
+	}
+
+	for _, tag := range tags {
+		if !buildTagRegex.MatchString(tag) {
+			return fmt.Errorf("invalid build tag %q", tag)
+		}
+	}
+
+	return nil
+}
+
+// encode returns the options as sent to the build service, or an empty string if no option is set
+func (o BuildOptions) encode() string {
+	if o.IsZero() {
+		return ""
+	}
+
+	encoded, _ := json.Marshal(o)
+	return string(encoded)
+}
+
+// key returns the key identifying the options in the cache, or an empty string if no option is set
+func (o BuildOptions) key() string {
+	return scopeKey(o.encode())
+}
+
+type buildOptionsKey struct{}
+
+// WithBuildOptions returns a context that selects the options for building the binaries
+// requested by the provider's functions called with the context. See [Provider.GetBinaryWithOptions]
+func WithBuildOptions(ctx context.Context, options BuildOptions) context.Context {
+	return context.WithValue(ctx, buildOptionsKey{}, options)
+}
+
+// buildOptionsFrom returns the build options selected in the context, if any
+func buildOptionsFrom(ctx context.Context) BuildOptions {
+	options, _ := ctx.Value(buildOptionsKey{}).(BuildOptions)
+	return options
+}
+
+// withBuildOptionsHeader returns a client that sends the build options selected in the context
+// of the requests
+func withBuildOptionsHeader(client *http.Client) *http.Client {
+	return withContextHeader(client, buildOptionsHeader, func(ctx context.Context) string {
+		return buildOptionsFrom(ctx).encode()
+	})
+}
+
+// GetBinaryWithOptions returns a custom k6 binary that satisfies the given set of dependencies,
+// like [Provider.GetBinary], built with the given options, for example, with build tags or cgo
+// enabled for debugging.
+//
+// The options for the provider's platform are sent to the build service as JSON in the
+// X-K6build-Options header, so the build service must support them. Binaries built with
+// different options are cached separately. Race-enabled and debug builds are stored in
+// directories named after their channel (e.g. "<id>-race-<key>"), so they are easily told
+// apart from the optimized binaries used by normal runs.
+func (p *Provider) GetBinaryWithOptions(
+	ctx context.Context,
+	deps k6deps.Dependencies,
+	options BuildOptions,
+) (K6Binary, error) {
+	if err := options.validate(p.platform); err != nil {
+		return K6Binary{}, NewWrappedError(ErrInvalidParameters, err)
+	}
+
+	return p.GetBinary(WithBuildOptions(ctx, options), deps)
+}
@@ -0,0 +1,164 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6build/pkg/api"
+)
+
+func TestGetBinaryWithOptions(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("k6"))
+	}))
+	t.Cleanup(store.Close)
+
+	testCases := []struct {
+		title         string
+		options       BuildOptions
+		expectSent    *BuildOptions
+		expectChannel string
+		platform      string
+		expectErr     error
+	}{
+		{
+			title:      "no options",
+			options:    BuildOptions{},
+			expectSent: nil,
+		},
+		{
+			title:      "cgo",
+			options:    BuildOptions{CGO: true},
+			expectSent: &BuildOptions{CGO: true},
+		},
+		{
+			title: "platform tags",
+			options: BuildOptions{
+				Tags: []string{"netgo"},
+				PlatformTags: map[string][]string{
+					"linux":         {"osusergo", "netgo"},
+					"linux/arm64":   {"arm"},
+					"windows/amd64": {"windows"},
+				},
+				Flags: []string{"-trimpath"},
+			},
+			expectSent: &BuildOptions{Tags: []string{"netgo", "osusergo"}, Flags: []string{"-trimpath"}},
+		},
+		{
+			title:         "race",
+			options:       BuildOptions{Race: true},
+			expectSent:    &BuildOptions{Race: true, CGO: true},
+			expectChannel: "race",
+		},
+		{
+			title:         "race with debug info",
+			options:       BuildOptions{Race: true, DebugInfo: true},
+			expectSent:    &BuildOptions{Race: true, DebugInfo: true, CGO: true},
+			expectChannel: "race-debug",
+		},
+		{
+			title:         "debug info",
+			options:       BuildOptions{DebugInfo: true},
+			expectSent:    &BuildOptions{DebugInfo: true},
+			expectChannel: "debug",
+		},
+		{
+			title:     "race not supported",
+			options:   BuildOptions{Race: true},
+			platform:  "linux/arm/v7",
+			expectErr: ErrInvalidParameters,
+		},
+		{
+			title:     "invalid tag",
+			options:   BuildOptions{Tags: []string{"net go"}},
+			expectErr: ErrInvalidParameters,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			mutex := sync.Mutex{}
+			sent := []string{}
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/build" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				mutex.Lock()
+				sent = append(sent, r.Header.Get(buildOptionsHeader))
+				mutex.Unlock()
+
+				artifact := k6build.Artifact{ID: "artifact", URL: store.URL}
+				_ = json.NewEncoder(w).Encode(api.BuildResponse{Artifact: artifact})
+			}))
+			t.Cleanup(srv.Close)
+
+			platform := tc.platform
+			if platform == "" {
+				platform = "linux/amd64"
+			}
+
+			binDir := t.TempDir()
+			provider, err := NewProvider(Config{
+				BuildServiceURL: srv.URL,
+				BinDir:          binDir,
+				Platform:        platform,
+			})
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			t.Cleanup(func() { _ = provider.Close() })
+
+			binary, err := provider.GetBinaryWithOptions(context.TODO(), nil, tc.options)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(sent) != 1 {
+				t.Fatalf("expected 1 build request got %d", len(sent))
+			}
+
+			var got *BuildOptions
+			if sent[0] != "" {
+				got = &BuildOptions{}
+				if err = json.Unmarshal([]byte(sent[0]), got); err != nil {
+					t.Fatalf("invalid options %q: %v", sent[0], err)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.expectSent) {
+				t.Fatalf("expected %+v got %+v", tc.expectSent, got)
+			}
+
+			// binaries built with options are cached separately, in the directory of their channel
+			expectDir := "artifact"
+			if tc.expectSent != nil {
+				expectDir = strings.Join(slices.DeleteFunc(
+					[]string{"artifact", tc.expectChannel, tc.expectSent.key()},
+					func(part string) bool { return part == "" },
+				), "-")
+			}
+			if dir := filepath.Base(filepath.Dir(binary.Path)); dir != expectDir {
+				t.Fatalf("expected cache dir %s got %s", expectDir, dir)
+			}
+		})
+	}
+}
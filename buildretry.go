@@ -0,0 +1,128 @@
+package k6provider
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/grafana/k6build"
+)
+
+const (
+	// DefaultBuildRetryBackoff initial time between retries of build requests
+	DefaultBuildRetryBackoff = 1 * time.Second
+	// DefaultBuildRetryMaxBackoff maximum time between retries of build requests
+	DefaultBuildRetryMaxBackoff = 30 * time.Second
+	// idempotencyKeyHeader is the header of the build requests that identifies the retries of a request
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+// BuildRetryPolicy defines how the build requests that fail with a transient error, such as a 502,
+// 503 or 504 response or a connection failure, are retried. The retries are separate from those of
+// the downloads (see [DownloadConfig]).
+//
+// All the attempts of a request are sent with the same Idempotency-Key header, generated for each
+// request, so the build service can handle a retry of a request it already received as the same
+// build, while other requests for the same dependencies are handled as new builds.
+type BuildRetryPolicy struct {
+	// Retries number of times a failed build request is retried. Defaults to 0 (not retried)
+	Retries int
+	// Backoff initial time between retries. It is doubled on each retry, up to MaxBackoff, and
+	// randomized (jitter) so clients that failed at the same time don't retry at the same time.
+	// Defaults to [DefaultBuildRetryBackoff]
+	Backoff time.Duration
+	// MaxBackoff maximum time between retries. Defaults to [DefaultBuildRetryMaxBackoff]
+	MaxBackoff time.Duration
+}
+
+// delay returns the time to wait before the given retry (starting at 0): the exponential backoff,
+// randomized between half and the full backoff
+func (r BuildRetryPolicy) delay(retry int) time.Duration {
+	backoff := r.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBuildRetryBackoff
+	}
+
+	maxBackoff := r.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultBuildRetryMaxBackoff
+	}
+
+	for range retry {
+		if backoff >= maxBackoff {
+			break
+		}
+		backoff *= 2
+	}
+	backoff = min(backoff, maxBackoff)
+
+	return backoff/2 + rand.N(backoff/2+1) //nolint:gosec
+}
+
+// retryableBuildError returns true if the build request failed with an error that is likely to
+// be transient
+func retryableBuildError(err error) bool {
+	switch responseStatus(err) {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case 0:
+		return isUnreachable(err)
+	default:
+		return false
+	}
+}
+
+// build requests the artifact from the build services, retrying the request if it fails with a
+// transient error, as defined by the retry policy
+func (b *buildServices) build(
+	ctx context.Context,
+	platform string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (k6build.Artifact, string, error) {
+	if idempotencyKeyFrom(ctx) == "" {
+		ctx = withIdempotencyKey(ctx, newIdempotencyKey())
+	}
+
+	for retry := 0; ; retry++ {
+		artifact, buildSrvURL, err := b.buildOnce(ctx, platform, k6Constrains, deps)
+		if err == nil || retry >= b.retry.Retries || ctx.Err() != nil || !retryableBuildError(err) {
+			return artifact, buildSrvURL, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return artifact, buildSrvURL, err
+		case <-time.After(b.retry.delay(retry)):
+		}
+	}
+}
+
+type idempotencyKeyKey struct{}
+
+// newIdempotencyKey returns a random key that identifies the attempts of a build request
+func newIdempotencyKey() string {
+	key := make([]byte, 16)
+	_, _ = cryptorand.Read(key)
+
+	return hex.EncodeToString(key)
+}
+
+// withIdempotencyKey returns a context that sends the idempotency key with the build requests
+func withIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// idempotencyKeyFrom returns the idempotency key in the context, if any
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyKey{}).(string)
+	return key
+}
+
+// withIdempotencyKeyHeader returns a client that sends the idempotency key in the context of the requests
+func withIdempotencyKeyHeader(client *http.Client) *http.Client {
+	return withContextHeader(client, idempotencyKeyHeader, idempotencyKeyFrom)
+}
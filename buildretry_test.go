@@ -0,0 +1,188 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6build/pkg/api"
+)
+
+func TestBuildRetry(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title          string
+		statuses       []int
+		retries        int
+		expectErr      error
+		expectAttempts int
+	}{
+		{
+			title:          "not retried by default",
+			statuses:       []int{http.StatusServiceUnavailable},
+			retries:        0,
+			expectErr:      ErrServiceUnavailable,
+			expectAttempts: 1,
+		},
+		{
+			title:          "transient errors retried",
+			statuses:       []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+			retries:        3,
+			expectErr:      nil,
+			expectAttempts: 3,
+		},
+		{
+			title:          "retries exhausted",
+			statuses:       []int{http.StatusGatewayTimeout, http.StatusGatewayTimeout, http.StatusGatewayTimeout},
+			retries:        2,
+			expectErr:      ErrServiceUnavailable,
+			expectAttempts: 3,
+		},
+		{
+			title:          "permanent error not retried",
+			statuses:       []int{http.StatusInternalServerError},
+			retries:        3,
+			expectErr:      ErrBuild,
+			expectAttempts: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			mutex := sync.Mutex{}
+			keys := []string{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/build" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				mutex.Lock()
+				attempt := len(keys)
+				keys = append(keys, r.Header.Get(idempotencyKeyHeader))
+				mutex.Unlock()
+
+				if attempt < len(tc.statuses) {
+					w.WriteHeader(tc.statuses[attempt])
+					return
+				}
+				_ = json.NewEncoder(w).Encode(api.BuildResponse{Artifact: k6build.Artifact{ID: "artifact"}})
+			}))
+			t.Cleanup(srv.Close)
+
+			provider, err := NewProvider(Config{
+				BuildServiceURL: srv.URL,
+				BinDir:          t.TempDir(),
+				BuildRetry:      BuildRetryPolicy{Retries: tc.retries, Backoff: time.Millisecond},
+			})
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			t.Cleanup(func() { _ = provider.Close() })
+
+			_, err = provider.GetArtifact(context.TODO(), nil)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if len(keys) != tc.expectAttempts {
+				t.Fatalf("expected %d attempts got %d", tc.expectAttempts, len(keys))
+			}
+
+			// all the attempts are sent with the same idempotency key
+			for _, key := range keys {
+				if key == "" || key != keys[0] {
+					t.Fatalf("expected same idempotency key got %v", keys)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	policy := BuildRetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	testCases := []struct {
+		retry     int
+		expectMax time.Duration
+	}{
+		{retry: 0, expectMax: time.Second},
+		{retry: 1, expectMax: 2 * time.Second},
+		{retry: 2, expectMax: 4 * time.Second},
+		{retry: 10, expectMax: 5 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		delay := policy.delay(tc.retry)
+		if delay < tc.expectMax/2 || delay > tc.expectMax {
+			t.Fatalf("retry %d: expected delay between %v and %v got %v", tc.retry, tc.expectMax/2, tc.expectMax, delay)
+		}
+	}
+}
+
+func TestBuildRetryIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	mutex := sync.Mutex{}
+	keys := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/build" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		mutex.Lock()
+		attempt := len(keys)
+		keys = append(keys, r.Header.Get(idempotencyKeyHeader))
+		mutex.Unlock()
+
+		// the first attempt of each request fails
+		if attempt%2 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(api.BuildResponse{Artifact: k6build.Artifact{ID: "artifact"}})
+	}))
+	t.Cleanup(srv.Close)
+
+	provider, err := NewProvider(Config{
+		BuildServiceURL: srv.URL,
+		BinDir:          t.TempDir(),
+		BuildRetry:      BuildRetryPolicy{Retries: 1, Backoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	t.Cleanup(func() { _ = provider.Close() })
+
+	// the same dependencies are requested twice
+	for range 2 {
+		if _, err = provider.GetArtifact(context.TODO(), nil); err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(keys) != 4 {
+		t.Fatalf("expected 4 attempts got %d", len(keys))
+	}
+
+	// the retries of a request reuse its key, other requests have their own
+	if keys[0] == "" || keys[0] != keys[1] || keys[2] != keys[3] || keys[0] == keys[2] {
+		t.Fatalf("expected a key per request got %v", keys)
+	}
+}
@@ -0,0 +1,570 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6build/pkg/client"
+)
+
+// BalancingStrategy defines how requests are distributed across the replicas of a build service
+type BalancingStrategy string
+
+const (
+	// RoundRobin distributes the requests evenly across the replicas
+	RoundRobin BalancingStrategy = "round-robin"
+	// LeastLatency sends the requests to the replica with the lowest latency
+	LeastLatency BalancingStrategy = "least-latency"
+)
+
+const (
+	// buildServiceCooldown is the time a build service that failed is tried after the healthy ones
+	buildServiceCooldown = time.Minute
+	// latencyWeight is the weight of the last request in the moving average of the latency
+	latencyWeight = 0.2
+)
+
+// buildEndpoint is a build service and its health
+type buildEndpoint struct {
+	url         string
+	addr        string
+	srv         k6build.BuildService
+	api         *asyncBuildClient
+	async       *asyncBuildClient
+	mutex       sync.Mutex
+	lastFailure time.Time
+	latency     time.Duration
+	caps        *ServiceCapabilities
+}
+
+func newBuildEndpoint(url string, srv k6build.BuildService) *buildEndpoint {
+	return &buildEndpoint{url: url, srv: srv}
+}
+
+// String returns the endpoint's URL and the address of the replica, if any
+func (e *buildEndpoint) String() string {
+	if e.addr == "" {
+		return e.url
+	}
+	return fmt.Sprintf("%s (%s)", e.url, e.addr)
+}
+
+func (e *buildEndpoint) healthy(now time.Time) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.lastFailure.IsZero() || now.Sub(e.lastFailure) > buildServiceCooldown
+}
+
+func (e *buildEndpoint) failed() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.lastFailure = time.Now()
+}
+
+// succeeded resets the endpoint's health and updates the moving average of its latency
+func (e *buildEndpoint) succeeded(latency time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.lastFailure = time.Time{}
+	if e.latency == 0 {
+		e.latency = latency
+		return
+	}
+	e.latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(e.latency))
+}
+
+func (e *buildEndpoint) averageLatency() time.Duration {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.latency
+}
+
+// buildTier is a build service with one or more replicas
+type buildTier struct {
+	replicas []*buildEndpoint
+	next     atomic.Uint64
+}
+
+// buildServices is a list of build services tried in order until one succeeds.
+// Requests are distributed across the replicas of each service.
+type buildServices struct {
+	tiers      []*buildTier
+	platforms  map[string][]*buildTier
+	strategy   BalancingStrategy
+	onStatus   func(BuildStatus)
+	onProgress func(BuildProgress)
+	retry      BuildRetryPolicy
+}
+
+// newBuildServices returns the build services in the configuration and the URL of the first one,
+// taken from the configuration, the environment or discovered from the configured domain
+func newBuildServices(config Config) (*buildServices, string, error) {
+	buildSrvURLs, err := buildServiceURLs(config)
+	if err != nil {
+		return nil, "", err
+	}
+
+	buildSrvAuth := config.BuildServiceAuth
+	if buildSrvAuth == "" {
+		buildSrvAuth = os.Getenv("K6_BUILD_SERVICE_AUTH")
+	}
+
+	buildSrvProxyURL := config.BuildServiceProxyURL
+	if buildSrvProxyURL == "" && config.BuildServiceProxy == nil {
+		buildSrvProxyURL = os.Getenv("K6_BUILD_SERVICE_PROXY")
+	}
+
+	proxy, err := selectProxy(buildSrvProxyURL, config.BuildServiceNoProxy, config.BuildServiceProxy)
+	if err != nil {
+		return nil, "", NewWrappedError(ErrConfig, err)
+	}
+
+	dial, err := newDialFunc(config)
+	if err != nil {
+		return nil, "", NewWrappedError(ErrConfig, err)
+	}
+
+	// a token obtained interactively is used for all the build services
+	auth := newInteractiveAuth(config)
+
+	newEndpoint := func(url string, addr string) (*buildEndpoint, error) {
+		httpClient := http.DefaultClient
+		if proxy != nil || dial != nil {
+			httpClient = newHTTPClient(proxy, dial)
+		}
+		if addr != "" {
+			httpClient = newPinnedClient(addr, dial)
+		}
+		httpClient = withHTTPDebug(httpClient, config.HTTPDebug, HTTPTargetBuildService)
+		httpClient = withIdempotencyKeyHeader(withBuildOptionsHeader(withCatalogHeader(httpClient)))
+		httpClient = withInteractiveAuth(httpClient, auth)
+
+		buildSrv, err := client.NewBuildServiceClient(
+			client.BuildServiceClientConfig{
+				URL:               url,
+				Authorization:     buildSrvAuth,
+				AuthorizationType: config.BuildServiceAuthType,
+				Headers:           config.BuildServiceHeaders,
+				HTTPClient:        httpClient,
+			},
+		)
+		if err != nil {
+			return nil, NewWrappedError(ErrConfig, err)
+		}
+
+		api, err := newAsyncBuildClient(config, url, buildSrvAuth, httpClient)
+		if err != nil {
+			return nil, NewWrappedError(ErrConfig, err)
+		}
+
+		endpoint := &buildEndpoint{url: url, addr: addr, srv: buildSrv, api: api}
+		if config.AsyncBuilds {
+			endpoint.async = api
+		}
+
+		return endpoint, nil
+	}
+
+	tiers := []*buildTier{}
+
+	if len(buildSrvURLs) > 0 {
+		primary, err := primaryReplicas(config, buildSrvURLs[0], newEndpoint)
+		if err != nil {
+			return nil, "", err
+		}
+		tiers = append(tiers, &buildTier{replicas: primary})
+	}
+
+	for _, url := range slices.Concat(buildSrvURLs[min(1, len(buildSrvURLs)):], config.BuildServiceURLs) {
+		endpoint, err := newEndpoint(url, "")
+		if err != nil {
+			return nil, "", err
+		}
+		tiers = append(tiers, &buildTier{replicas: []*buildEndpoint{endpoint}})
+	}
+
+	platforms := map[string][]*buildTier{}
+	for platform, url := range config.PlatformServiceMap {
+		platform, err := NormalizePlatform(platform)
+		if err != nil {
+			return nil, "", NewWrappedError(ErrConfig, err)
+		}
+		endpoint, err := newEndpoint(url, "")
+		if err != nil {
+			return nil, "", err
+		}
+		platforms[platform] = []*buildTier{{replicas: []*buildEndpoint{endpoint}}}
+	}
+
+	if len(tiers) == 0 && len(platforms) == 0 {
+		return nil, "", NewWrappedError(ErrConfig, fmt.Errorf("build service URL is required"))
+	}
+
+	strategy := config.BuildServiceBalancing
+	if strategy == "" {
+		strategy = RoundRobin
+	}
+
+	buildSrv := &buildServices{
+		tiers:      tiers,
+		platforms:  platforms,
+		strategy:   strategy,
+		onStatus:   config.OnBuildStatus,
+		onProgress: config.OnBuildProgress,
+		retry:      config.BuildRetry,
+	}
+
+	return buildSrv, buildSrv.primaryURL(), nil
+}
+
+// buildServiceURLs returns the URL of the primary build service followed by the URLs of its
+// fallbacks, taken from the configuration or the environment as a comma-separated list, or
+// discovered from the configured domain
+func buildServiceURLs(config Config) ([]string, error) {
+	buildSrvURL := config.BuildServiceURL
+	if buildSrvURL == "" {
+		buildSrvURL = os.Getenv("K6_BUILD_SERVICE_URL")
+	}
+
+	discoveryDomain := config.DiscoveryDomain
+	if discoveryDomain == "" {
+		discoveryDomain = os.Getenv("K6_BUILD_SERVICE_DISCOVERY_DOMAIN")
+	}
+
+	if buildSrvURL == "" && len(config.BuildServiceURLs) == 0 && discoveryDomain != "" {
+		if networkDenied(config) {
+			return nil, NewWrappedError(ErrDiscovery, ErrNetworkDisabled)
+		}
+		dial, err := newDialFunc(config)
+		if err != nil {
+			return nil, NewWrappedError(ErrConfig, err)
+		}
+		discovered, err := newDiscoverer(resolver(config), dial).discover(context.Background(), discoveryDomain)
+		if err != nil {
+			return nil, err
+		}
+		return []string{discovered}, nil
+	}
+
+	return splitURLs(buildSrvURL), nil
+}
+
+// splitURLs returns the URLs in a comma-separated list
+func splitURLs(urls string) []string {
+	split := []string{}
+	for _, url := range strings.Split(urls, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			split = append(split, url)
+		}
+	}
+	return split
+}
+
+// primaryURL returns the URL of the first build service, or of the service of the first platform
+// if there is no default build service
+func (b *buildServices) primaryURL() string {
+	if len(b.tiers) > 0 {
+		return b.tiers[0].replicas[0].url
+	}
+
+	platforms := make([]string, 0, len(b.platforms))
+	for platform := range b.platforms {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	return b.platforms[platforms[0]][0].replicas[0].url
+}
+
+// primaryReplicas returns the endpoints for the replicas of the primary build service: the
+// configured replicas or, if enabled, one for each address the build service host resolves to
+func primaryReplicas(
+	config Config,
+	buildSrvURL string,
+	newEndpoint func(url string, addr string) (*buildEndpoint, error),
+) ([]*buildEndpoint, error) {
+	addrs := []string{""}
+	if config.ResolveBuildServiceReplicas {
+		resolved, err := resolveAddrs(resolver(config), buildSrvURL)
+		if err != nil {
+			return nil, NewWrappedError(ErrConfig, err)
+		}
+		addrs = resolved
+	}
+
+	replicas := []*buildEndpoint{}
+	for _, addr := range addrs {
+		endpoint, err := newEndpoint(buildSrvURL, addr)
+		if err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, endpoint)
+	}
+
+	for _, url := range config.BuildServiceReplicas {
+		endpoint, err := newEndpoint(url, "")
+		if err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, endpoint)
+	}
+
+	return replicas, nil
+}
+
+// resolveAddrs returns the addresses of the host of the URL. If the host resolves
+// to a single address, no address is returned so the host is used as usual.
+func resolveAddrs(resolver *net.Resolver, srvURL string) ([]string, error) {
+	parsed, err := url.Parse(srvURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	addrs, err := resolver.LookupHost(ctx, parsed.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(addrs) < 2 {
+		return []string{""}, nil
+	}
+
+	return addrs, nil
+}
+
+// newPinnedClient returns a client that connects to the given address regardless of the host
+// in the request's URL, which is still used for the Host header and TLS verification.
+// The connection is established using the dial function, if any.
+func newPinnedClient(addr string, dial DialFunc) *http.Client {
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		dial = dialer.DialContext
+	}
+
+	transport := &http.Transport{}
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	}
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network string, hostPort string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			return nil, err
+		}
+		return dial(ctx, network, net.JoinHostPort(addr, port))
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// order returns the endpoints for the platform in the order they should be tried: the replicas
+// of each tier ordered by the balancing strategy, with those that failed recently tried last.
+// Platforms mapped to a build service only use it.
+func (b *buildServices) order(platform string) []*buildEndpoint {
+	tiers, found := b.platforms[platform]
+	if !found {
+		tiers = b.tiers
+	}
+
+	now := time.Now()
+	healthy := []*buildEndpoint{}
+	unhealthy := []*buildEndpoint{}
+	for _, tier := range tiers {
+		for _, endpoint := range b.balance(tier) {
+			if endpoint.healthy(now) {
+				healthy = append(healthy, endpoint)
+			} else {
+				unhealthy = append(unhealthy, endpoint)
+			}
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// balance returns the replicas of the tier in the order defined by the balancing strategy
+func (b *buildServices) balance(tier *buildTier) []*buildEndpoint {
+	replicas := make([]*buildEndpoint, len(tier.replicas))
+
+	if b.strategy == LeastLatency {
+		copy(replicas, tier.replicas)
+		// replicas without latency are tried first, so their latency is measured
+		sort.SliceStable(replicas, func(i, j int) bool {
+			return replicas[i].averageLatency() < replicas[j].averageLatency()
+		})
+		return replicas
+	}
+
+	start := int((tier.next.Add(1) - 1) % uint64(len(tier.replicas))) //nolint:gosec
+	for i := range replicas {
+		replicas[i] = tier.replicas[(start+i)%len(tier.replicas)]
+	}
+	return replicas
+}
+
+// buildOnce requests the artifact from the build services, returning the artifact and the URL of the
+// service that produced it. Requests with invalid parameters are not tried in other services and
+// services that don't support the platform are skipped.
+func (b *buildServices) buildOnce(
+	ctx context.Context,
+	platform string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (k6build.Artifact, string, error) {
+	endpoints := b.order(platform)
+	if len(endpoints) == 0 {
+		return k6build.Artifact{}, "", NewWrappedError(ErrConfig, fmt.Errorf("no build service for platform %q", platform))
+	}
+
+	errs := []error{}
+	for _, endpoint := range endpoints {
+		capabilities := endpoint.capabilities(ctx)
+		if !capabilities.SupportsPlatform(platform) {
+			err := unsupportedPlatform(platform, capabilities.Platforms)
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+			continue
+		}
+
+		start := time.Now()
+		artifact, err := b.buildWith(ctx, endpoint, capabilities, platform, k6Constrains, deps)
+		if err == nil {
+			endpoint.succeeded(time.Since(start))
+			return artifact, endpoint.url, nil
+		}
+
+		if _, ok := invalidParameters(err); ok {
+			return k6build.Artifact{}, endpoint.url, err
+		}
+
+		// the failure is caused by the caller, not the build service
+		if ctx.Err() != nil {
+			return k6build.Artifact{}, endpoint.url, err
+		}
+
+		endpoint.failed()
+
+		if len(endpoints) == 1 {
+			return k6build.Artifact{}, endpoint.url, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+	}
+
+	return k6build.Artifact{}, "", errors.Join(errs...)
+}
+
+// buildWith requests the artifact to the endpoint, using the async build protocol if enabled
+// and supported by the build service
+func (b *buildServices) buildWith(
+	ctx context.Context,
+	endpoint *buildEndpoint,
+	capabilities ServiceCapabilities,
+	platform string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (k6build.Artifact, error) {
+	if endpoint.async != nil && capabilities.supportsAsyncBuilds() {
+		return endpoint.async.build(ctx, platform, k6Constrains, deps, b.notify)
+	}
+	return endpoint.srv.Build(ctx, platform, k6Constrains, deps)
+}
+
+// notify reports the changes in the progress of an async build
+func (b *buildServices) notify(previous BuildProgress, current BuildProgress) {
+	if current == previous {
+		return
+	}
+
+	if b.onProgress != nil {
+		b.onProgress(current)
+	}
+
+	if current.Status != previous.Status && b.onStatus != nil {
+		b.onStatus(current.Status)
+	}
+}
+
+// submit requests an async build to the build services, without waiting for it to finish
+func (b *buildServices) submit(
+	ctx context.Context,
+	platform string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (pendingBuild, error) {
+	endpoints := b.order(platform)
+	if len(endpoints) == 0 {
+		return pendingBuild{}, NewWrappedError(ErrConfig, fmt.Errorf("no build service for platform %q", platform))
+	}
+
+	errs := []error{}
+	for _, endpoint := range endpoints {
+		if endpoint.async == nil {
+			return pendingBuild{}, NewWrappedError(ErrConfig, errors.New("async builds are not enabled"))
+		}
+
+		capabilities := endpoint.capabilities(ctx)
+		if !capabilities.SupportsPlatform(platform) {
+			err := unsupportedPlatform(platform, capabilities.Platforms)
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+			continue
+		}
+
+		state, delay, err := b.submitTo(ctx, endpoint, capabilities, platform, k6Constrains, deps)
+		if err == nil {
+			b.notify(BuildProgress{}, state.progress())
+			return pendingBuild{endpoint: endpoint, state: state, delay: delay}, nil
+		}
+
+		if _, ok := invalidParameters(err); ok || ctx.Err() != nil {
+			return pendingBuild{}, err
+		}
+
+		endpoint.failed()
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+	}
+
+	if len(errs) == 1 {
+		return pendingBuild{}, errors.Unwrap(errs[0])
+	}
+	return pendingBuild{}, errors.Join(errs...)
+}
+
+// submitTo requests an async build to the endpoint. If the build service doesn't support the
+// async build protocol, the build is done synchronously and returned as ready.
+func (b *buildServices) submitTo(
+	ctx context.Context,
+	endpoint *buildEndpoint,
+	capabilities ServiceCapabilities,
+	platform string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (buildState, time.Duration, error) {
+	if capabilities.supportsAsyncBuilds() {
+		return endpoint.async.submit(ctx, platform, k6Constrains, deps)
+	}
+
+	artifact, err := endpoint.srv.Build(ctx, platform, k6Constrains, deps)
+	if err != nil {
+		return buildState{}, 0, err
+	}
+
+	return buildState{ID: artifact.ID, Status: BuildReady, Artifact: artifact}, 0, nil
+}
@@ -0,0 +1,277 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+func TestBuildServicesFailover(t *testing.T) {
+	t.Parallel()
+
+	failing := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{}, errors.New("service unavailable")
+		},
+	)
+	invalid := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{}, NewWrappedError(ErrInvalidParameters, errors.New("unknown extension"))
+		},
+	)
+	healthy := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact"}, nil
+		},
+	)
+
+	testCases := []struct {
+		title         string
+		services      []k6build.BuildService
+		expectService string
+		expectErr     error
+	}{
+		{
+			title:         "primary succeeds",
+			services:      []k6build.BuildService{healthy, failing},
+			expectService: "0",
+		},
+		{
+			title:         "fallback after failure",
+			services:      []k6build.BuildService{failing, failing, healthy},
+			expectService: "2",
+		},
+		{
+			title:     "all fail",
+			services:  []k6build.BuildService{failing, failing},
+			expectErr: ErrBuild,
+		},
+		{
+			title:     "invalid parameters are not retried",
+			services:  []k6build.BuildService{invalid, healthy},
+			expectErr: ErrInvalidParameters,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				buildSrv: newTestBuildServices(tc.services...),
+				ctx:      context.Background(),
+			}
+
+			artifact, err := provider.GetArtifact(context.TODO(), k6deps.Dependencies{})
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			if artifact.BuildService != tc.expectService {
+				t.Fatalf("expected service %q got %q", tc.expectService, artifact.BuildService)
+			}
+		})
+	}
+}
+
+func TestBuildServicesOrder(t *testing.T) {
+	t.Parallel()
+
+	calls := []string{}
+	service := func(name string, err error) k6build.BuildService {
+		return buildServiceFunc(
+			func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+				calls = append(calls, name)
+				return k6build.Artifact{}, err
+			},
+		)
+	}
+
+	buildSrv := newTestBuildServices(service("primary", errors.New("failed")), service("fallback", nil))
+
+	for range 2 {
+		if _, _, err := buildSrv.build(context.TODO(), "linux/amd64", "*", nil); err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+	}
+
+	// the primary failed in the first build, so it is tried last in the second one
+	expected := []string{"primary", "fallback", "fallback"}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Fatalf("expected %v got %v", expected, calls)
+		}
+	}
+}
+
+func TestBuildServicesPlatformRouting(t *testing.T) {
+	t.Parallel()
+
+	buildSrv, primary, err := newBuildServices(Config{
+		BuildServiceURL:    "http://primary:8000, http://fallback:8000",
+		BuildServiceURLs:   []string{"http://global:8000"},
+		PlatformServiceMap: map[string]string{"windows/amd64": "http://windows:8000"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if primary != "http://primary:8000" {
+		t.Fatalf("expected %q got %q", "http://primary:8000", primary)
+	}
+
+	testCases := []struct {
+		platform string
+		expect   []string
+	}{
+		{
+			platform: "linux/amd64",
+			expect:   []string{"http://primary:8000", "http://fallback:8000", "http://global:8000"},
+		},
+		{
+			platform: "windows/amd64",
+			expect:   []string{"http://windows:8000"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.platform, func(t *testing.T) {
+			t.Parallel()
+
+			order := []string{}
+			for _, endpoint := range buildSrv.order(tc.platform) {
+				order = append(order, endpoint.url)
+			}
+
+			if fmt.Sprint(order) != fmt.Sprint(tc.expect) {
+				t.Fatalf("expected %v got %v", tc.expect, order)
+			}
+		})
+	}
+}
+
+func TestBuildServicesUnmappedPlatform(t *testing.T) {
+	t.Parallel()
+
+	buildSrv := &buildServices{
+		platforms: map[string][]*buildTier{
+			"windows/amd64": {{replicas: []*buildEndpoint{newBuildEndpoint("windows", nil)}}},
+		},
+	}
+
+	_, _, err := buildSrv.build(context.TODO(), "linux/amd64", "*", nil)
+	if !errors.Is(err, ErrConfig) {
+		t.Fatalf("expected %v got %v", ErrConfig, err)
+	}
+}
+
+func TestBuildServicesBalancing(t *testing.T) {
+	t.Parallel()
+
+	newReplicas := func(latencies ...time.Duration) []*buildEndpoint {
+		replicas := []*buildEndpoint{}
+		for i, latency := range latencies {
+			replica := newBuildEndpoint(fmt.Sprint(i), nil)
+			replica.latency = latency
+			replicas = append(replicas, replica)
+		}
+		return replicas
+	}
+
+	testCases := []struct {
+		title    string
+		strategy BalancingStrategy
+		replicas []*buildEndpoint
+		failed   []int
+		expect   [][]string
+	}{
+		{
+			title:    "round robin",
+			strategy: RoundRobin,
+			replicas: newReplicas(0, 0, 0),
+			expect:   [][]string{{"0", "1", "2"}, {"1", "2", "0"}, {"2", "0", "1"}, {"0", "1", "2"}},
+		},
+		{
+			title:    "round robin avoids failed replicas",
+			strategy: RoundRobin,
+			replicas: newReplicas(0, 0, 0),
+			failed:   []int{1},
+			expect:   [][]string{{"0", "2", "1"}, {"2", "0", "1"}, {"2", "0", "1"}},
+		},
+		{
+			title:    "least latency",
+			strategy: LeastLatency,
+			replicas: newReplicas(3*time.Second, time.Second, 2*time.Second),
+			expect:   [][]string{{"1", "2", "0"}, {"1", "2", "0"}},
+		},
+		{
+			title:    "least latency tries replicas without latency first",
+			strategy: LeastLatency,
+			replicas: newReplicas(time.Second, 0),
+			expect:   [][]string{{"1", "0"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			for _, i := range tc.failed {
+				tc.replicas[i].failed()
+			}
+
+			buildSrv := &buildServices{
+				tiers:    []*buildTier{{replicas: tc.replicas}},
+				strategy: tc.strategy,
+			}
+
+			for _, expected := range tc.expect {
+				order := []string{}
+				for _, endpoint := range buildSrv.order("linux/amd64") {
+					order = append(order, endpoint.url)
+				}
+
+				if fmt.Sprint(order) != fmt.Sprint(expected) {
+					t.Fatalf("expected %v got %v", expected, order)
+				}
+			}
+		})
+	}
+}
+
+func TestPinnedClient(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	t.Cleanup(srv.Close)
+
+	srvURL, _ := url.Parse(srv.URL)
+
+	// the request is sent to the pinned address keeping the original host
+	client := newPinnedClient(srvURL.Hostname(), nil)
+	resp, err := client.Get(fmt.Sprintf("http://build.example.invalid:%s/", srvURL.Port()))
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	host, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(string(host), "build.example.invalid") {
+		t.Fatalf("expected original host got %q", host)
+	}
+}
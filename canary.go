@@ -0,0 +1,184 @@
+package k6provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// canaryFile is the file in the binary directory that records the results of the canary
+// verifications, one JSON object per line
+const canaryFile = ".canary.jsonl"
+
+// CanaryResult is the result of the canary verification of a build service version.
+// See [Config.CanaryVerification]
+type CanaryResult struct {
+	// BuildService URL of the build service
+	BuildService string `json:"buildService"`
+	// ServiceVersion version of the build service, as reported by its capabilities
+	ServiceVersion string `json:"serviceVersion"`
+	// ArtifactID artifact verified
+	ArtifactID string `json:"artifactId"`
+	// Time when the artifact was verified
+	Time time.Time `json:"time"`
+	// Passed the binary could be executed and has the dependencies declared by the artifact
+	Passed bool `json:"passed"`
+	// Error reason of the failure, if not passed
+	Error string `json:"error,omitempty"`
+	// Drift differences from the dependencies declared by the artifact to those built into the binary
+	Drift *DependencyDiff `json:"drift,omitempty"`
+}
+
+// CanaryResults returns the results of the canary verifications recorded in the cache,
+// oldest first. See [Config.CanaryVerification]
+func (p *Provider) CanaryResults() ([]CanaryResult, error) {
+	p.canaryMutex.Lock()
+	defer p.canaryMutex.Unlock()
+
+	results, err := readCanaryResults(filepath.Join(p.binDir, canaryFile))
+	if err != nil {
+		return nil, NewWrappedError(ErrBinary, err)
+	}
+
+	return results, nil
+}
+
+// canary verifies the binary if it is the first artifact provided from the version of the build
+// service that built it, recording the result and reporting failures to the OnCanaryFailure
+// callback. The binary is provided regardless of the result.
+func (p *Provider) canary(artifact Artifact, binary K6Binary) {
+	if !p.config.CanaryVerification {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, versionTimeout)
+	defer cancel()
+
+	buildSrv, err := p.buildServices()
+	if err != nil {
+		return
+	}
+
+	version := buildSrv.serviceVersion(ctx, artifact.BuildService)
+	if version == "" {
+		return
+	}
+
+	// the mutex prevents concurrent requests from verifying the same version
+	p.canaryMutex.Lock()
+	defer p.canaryMutex.Unlock()
+
+	resultsPath := filepath.Join(p.binDir, canaryFile)
+	results, err := readCanaryResults(resultsPath)
+	if err != nil {
+		return
+	}
+	for _, result := range results {
+		if result.BuildService == artifact.BuildService && result.ServiceVersion == version {
+			return
+		}
+	}
+
+	result := verifyCanary(ctx, binary)
+	result.BuildService = artifact.BuildService
+	result.ServiceVersion = version
+
+	// failing to record the result is not an error, the version is verified again next time
+	if err = appendCanaryResult(resultsPath, result); err == nil {
+		_ = p.permissions.shareFile(resultsPath)
+	}
+
+	if !result.Passed && p.config.OnCanaryFailure != nil {
+		p.config.OnCanaryFailure(result)
+	}
+}
+
+// verifyCanary runs the binary's version command and checks the dependencies built into the
+// binary are those declared by the artifact
+func verifyCanary(ctx context.Context, binary K6Binary) CanaryResult {
+	result := CanaryResult{ArtifactID: binary.ID, Time: time.Now().UTC()}
+
+	info, err := binary.Inspect(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	drift := DiffDependencies(binary, K6Binary{Dependencies: info.Dependencies()})
+	if !drift.Empty() {
+		result.Drift = &drift
+		result.Error = "dependencies built into the binary don't match the artifact"
+		return result
+	}
+
+	result.Passed = true
+
+	return result
+}
+
+// readCanaryResults reads the results recorded in the file, if it exists
+func readCanaryResults(path string) ([]CanaryResult, error) {
+	file, err := os.Open(path) //nolint:gosec
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	results := []CanaryResult{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		result := CanaryResult{}
+		// lines partially written by an interrupted process are ignored
+		if json.Unmarshal(scanner.Bytes(), &result) == nil {
+			results = append(results, result)
+		}
+	}
+
+	return results, scanner.Err()
+}
+
+// appendCanaryResult appends the result to the file
+func appendCanaryResult(path string, result CanaryResult) error {
+	line, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, cacheFilePerm) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	// a single write per result, so concurrent records are not interleaved
+	_, err = fmt.Fprintf(file, "%s\n", line)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// serviceVersion returns the version reported by the build service with the URL, if any
+func (b *buildServices) serviceVersion(ctx context.Context, url string) string {
+	tiers := b.tiers
+	for _, platformTiers := range b.platforms {
+		tiers = append(tiers[:len(tiers):len(tiers)], platformTiers...)
+	}
+
+	for _, tier := range tiers {
+		for _, endpoint := range tier.replicas {
+			if endpoint.url == url {
+				return endpoint.capabilities(ctx).Version
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,126 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6build/pkg/api"
+)
+
+func TestCanaryVerification(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the test binary is a shell script")
+	}
+
+	script := []byte("#!/bin/sh\necho \"k6 v0.55.0\"\necho \"Extensions:\"\necho \"  github.com/grafana/xk6-faker v0.4.0, k6/x/faker [js]\"\n")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(script))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(script)
+	}))
+	t.Cleanup(store.Close)
+
+	testCases := []struct {
+		title          string
+		serviceVersion string
+		deps           map[string]string
+		expectResults  int
+		expectPassed   bool
+		expectAlerts   int32
+	}{
+		{
+			title:          "binary matches artifact",
+			serviceVersion: "v0.9.0",
+			deps:           map[string]string{"k6": "v0.55.0", "k6/x/faker": "v0.4.0"},
+			expectResults:  1,
+			expectPassed:   true,
+			expectAlerts:   0,
+		},
+		{
+			title:          "extension missing",
+			serviceVersion: "v0.9.0",
+			deps:           map[string]string{"k6": "v0.55.0", "k6/x/sql": "v0.1.0"},
+			expectResults:  1,
+			expectPassed:   false,
+			expectAlerts:   1,
+		},
+		{
+			title:          "version not reported",
+			serviceVersion: "",
+			deps:           map[string]string{"k6": "v0.55.0", "k6/x/sql": "v0.1.0"},
+			expectResults:  0,
+			expectAlerts:   0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/capabilities":
+					_ = json.NewEncoder(w).Encode(ServiceCapabilities{APIVersion: "v2", Version: tc.serviceVersion})
+				case "/build":
+					_ = json.NewEncoder(w).Encode(api.BuildResponse{Artifact: k6build.Artifact{
+						ID:           "artifact",
+						URL:          store.URL,
+						Checksum:     checksum,
+						Dependencies: tc.deps,
+					}})
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(srv.Close)
+
+			alerts := atomic.Int32{}
+			provider, err := NewProvider(Config{
+				BuildServiceURL:    srv.URL,
+				BinDir:             t.TempDir(),
+				CanaryVerification: true,
+				OnCanaryFailure: func(result CanaryResult) {
+					if result.Drift == nil || result.ServiceVersion != tc.serviceVersion {
+						t.Errorf("unexpected result %+v", result)
+					}
+					alerts.Add(1)
+				},
+			})
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			t.Cleanup(func() { _ = provider.Close() })
+
+			// the version is verified only the first time and the binary is provided regardless
+			for range 2 {
+				if _, err = provider.GetBinary(context.TODO(), nil); err != nil {
+					t.Fatalf("unexpected %v", err)
+				}
+			}
+
+			results, err := provider.CanaryResults()
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			if len(results) != tc.expectResults {
+				t.Fatalf("expected %d results got %+v", tc.expectResults, results)
+			}
+			if len(results) > 0 && results[0].Passed != tc.expectPassed {
+				t.Fatalf("expected passed %v got %+v", tc.expectPassed, results[0])
+			}
+			if alerts.Load() != tc.expectAlerts {
+				t.Fatalf("expected %d alerts got %d", tc.expectAlerts, alerts.Load())
+			}
+		})
+	}
+}
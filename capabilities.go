@@ -0,0 +1,164 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// capabilitiesTimeout is the maximum time for probing the capabilities of a build service
+const capabilitiesTimeout = 10 * time.Second
+
+// ServiceCapabilities describes the features supported by a build service, as reported by
+// its capabilities endpoint (GET <url>/capabilities).
+//
+// Build services that don't have this endpoint, such as older k6build deployments, report no
+// capabilities. In this case, APIVersion is empty and the provider uses the build service as configured.
+type ServiceCapabilities struct {
+	// APIVersion version of the build service's API. Empty if the capabilities are unknown
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Version release of the build service (e.g. "v0.9.0"). Empty if not reported.
+	// See [Config.CanaryVerification]
+	Version string `json:"version,omitempty"`
+	// AsyncBuilds the build service supports the asynchronous build protocol. See [Config.AsyncBuilds]
+	AsyncBuilds bool `json:"asyncBuilds,omitempty"`
+	// Signatures the build service signs the artifacts. See [Config.Attestations]
+	Signatures bool `json:"signatures,omitempty"`
+	// Compression encodings supported for downloading the artifacts (e.g. "gzip", "zstd")
+	Compression []string `json:"compression,omitempty"`
+	// Platforms for which binaries can be built. Empty if not reported
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// Known returns true if the build service reported its capabilities
+func (c ServiceCapabilities) Known() bool {
+	return c.APIVersion != ""
+}
+
+// SupportsPlatform returns true if the build service can build binaries for the platform
+// or doesn't report the platforms it supports
+func (c ServiceCapabilities) SupportsPlatform(platform string) bool {
+	return len(c.Platforms) == 0 || slices.Contains(c.Platforms, platform)
+}
+
+// supportsAsyncBuilds returns true unless the build service reported it doesn't
+// support the asynchronous build protocol
+func (c ServiceCapabilities) supportsAsyncBuilds() bool {
+	return !c.Known() || c.AsyncBuilds
+}
+
+// unsupportedPlatform returns the error for a build service that doesn't support the platform,
+// listing the platforms it supports
+func unsupportedPlatform(platform string, supported []string) error {
+	return fmt.Errorf(
+		"%w: %q (supported: %s)",
+		ErrPlatformUnsupported,
+		platform,
+		strings.Join(supported, ", "),
+	)
+}
+
+// capabilities returns the capabilities of the build service. A build service without the
+// capabilities endpoint reports no capabilities.
+func (c *asyncBuildClient) capabilities(ctx context.Context) (ServiceCapabilities, error) {
+	ctx, cancel := context.WithTimeout(ctx, capabilitiesTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.srvURL.JoinPath("capabilities").String(), nil)
+	if err != nil {
+		return ServiceCapabilities{}, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return ServiceCapabilities{}, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return ServiceCapabilities{}, nil
+	default:
+		return ServiceCapabilities{}, errors.New(resp.Status)
+	}
+
+	capabilities := ServiceCapabilities{}
+	if err := json.NewDecoder(resp.Body).Decode(&capabilities); err != nil {
+		return ServiceCapabilities{}, fmt.Errorf("invalid capabilities: %w", err)
+	}
+
+	return capabilities, nil
+}
+
+// probe returns the capabilities of the endpoint's build service, requesting them only the
+// first time they are successfully obtained
+func (e *buildEndpoint) probe(ctx context.Context) (ServiceCapabilities, error) {
+	e.mutex.Lock()
+	cached := e.caps
+	e.mutex.Unlock()
+
+	if cached != nil {
+		return *cached, nil
+	}
+
+	if e.api == nil {
+		return ServiceCapabilities{}, nil
+	}
+
+	capabilities, err := e.api.capabilities(ctx)
+	if err != nil {
+		return ServiceCapabilities{}, err
+	}
+
+	e.mutex.Lock()
+	e.caps = &capabilities
+	e.mutex.Unlock()
+
+	return capabilities, nil
+}
+
+// capabilities returns the capabilities of the endpoint's build service or no capabilities
+// if they cannot be obtained, so the build service is used as configured
+func (e *buildEndpoint) capabilities(ctx context.Context) ServiceCapabilities {
+	capabilities, _ := e.probe(ctx)
+	return capabilities
+}
+
+// ServiceCapabilities returns the capabilities of the build service used for building the
+// binaries for the provider's platform. If there are multiple build services, the first one
+// to be tried is probed.
+//
+// The provider adapts to the capabilities of each build service: build services that don't
+// support the provider's platform are skipped and the synchronous build protocol is used
+// with those that don't support the asynchronous one.
+func (p *Provider) ServiceCapabilities(ctx context.Context) (ServiceCapabilities, error) {
+	if p.ctx.Err() != nil {
+		return ServiceCapabilities{}, ErrClosed
+	}
+
+	buildSrv, err := p.buildServices()
+	if err != nil {
+		return ServiceCapabilities{}, p.recordError(err)
+	}
+
+	endpoints := buildSrv.order(p.platform)
+	if len(endpoints) == 0 {
+		return ServiceCapabilities{}, NewWrappedError(
+			ErrConfig,
+			fmt.Errorf("no build service for platform %q", p.platform),
+		)
+	}
+
+	capabilities, err := endpoints[0].probe(ctx)
+	if err != nil {
+		return ServiceCapabilities{}, p.recordError(NewWrappedError(ErrBuild, err))
+	}
+
+	return capabilities, nil
+}
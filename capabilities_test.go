@@ -0,0 +1,130 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6build/pkg/api"
+)
+
+// newCapabilitiesServer returns a build service that reports the given capabilities, if any,
+// and builds artifacts synchronously, rejecting async build requests
+func newCapabilitiesServer(t *testing.T, capabilities *ServiceCapabilities, builds *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/capabilities" && capabilities != nil:
+			_ = json.NewEncoder(w).Encode(capabilities)
+		case r.Method == http.MethodPost && r.URL.Path == "/build":
+			builds.Add(1)
+			if r.Header.Get("Prefer") == "respond-async" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(api.BuildResponse{Artifact: k6build.Artifact{ID: "artifact"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestServiceCapabilities(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title         string
+		capabilities  *ServiceCapabilities
+		platform      string
+		expectKnown   bool
+		expectInitErr error
+		expectErr     error
+		expectBuilds  int32
+	}{
+		{
+			title:        "older build service",
+			capabilities: nil,
+			platform:     "linux/amd64",
+			expectKnown:  false,
+			expectErr:    ErrBuild, // async build rejected
+			expectBuilds: 1,
+		},
+		{
+			title:        "sync builds only",
+			capabilities: &ServiceCapabilities{APIVersion: "v2", Platforms: []string{"linux/amd64"}},
+			platform:     "linux/amd64",
+			expectKnown:  true,
+			expectErr:    nil,
+			expectBuilds: 1,
+		},
+		{
+			title:         "unsupported platform",
+			capabilities:  &ServiceCapabilities{APIVersion: "v2", Platforms: []string{"linux/amd64"}},
+			platform:      "windows/amd64",
+			expectKnown:   true,
+			expectInitErr: ErrConfig,
+			expectErr:     ErrBuild,
+			expectBuilds:  0,
+		},
+		{
+			title:        "platform alias",
+			capabilities: &ServiceCapabilities{APIVersion: "v2", Platforms: []string{"linux/amd64"}},
+			platform:     "Linux/x86_64",
+			expectKnown:  true,
+			expectErr:    nil,
+			expectBuilds: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			builds := &atomic.Int32{}
+			srv := newCapabilitiesServer(t, tc.capabilities, builds)
+
+			provider, err := NewProvider(Config{
+				BuildServiceURL: srv.URL,
+				BinDir:          t.TempDir(),
+				Platform:        tc.platform,
+				AsyncBuilds:     true,
+			})
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			t.Cleanup(func() { _ = provider.Close() })
+
+			capabilities, err := provider.ServiceCapabilities(context.TODO())
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if capabilities.Known() != tc.expectKnown {
+				t.Fatalf("expected known %v got %+v", tc.expectKnown, capabilities)
+			}
+
+			err = provider.Init(context.TODO())
+			if !errors.Is(err, tc.expectInitErr) {
+				t.Fatalf("expected %v got %v", tc.expectInitErr, err)
+			}
+
+			_, err = provider.GetArtifact(context.TODO(), nil)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			if builds.Load() != tc.expectBuilds {
+				t.Fatalf("expected %d builds got %d", tc.expectBuilds, builds.Load())
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package k6provider
+
+import (
+	"context"
+	"net/http"
+)
+
+// catalogHeader is the header of the build service requests with the catalog the
+// dependencies are resolved against
+const catalogHeader = "X-K6build-Catalog"
+
+type catalogKey struct{}
+
+// WithCatalog returns a context that selects the extensions catalog the build service resolves
+// the dependencies against, given as a reference such as the URL of the catalog or a version
+// (e.g. "https://example.com/experimental.json"). It allows resolving the dependencies against
+// different catalogs (e.g. a vetted catalog in production and an experimental one in testing)
+// using the same provider.
+//
+// The catalog is sent to the build service in the X-K6build-Catalog header of the requests
+// made by the provider's functions called with the context. Build services that don't support
+// selecting the catalog use their default one.
+func WithCatalog(ctx context.Context, catalog string) context.Context {
+	return context.WithValue(ctx, catalogKey{}, catalog)
+}
+
+// catalogFrom returns the catalog selected in the context, if any
+func catalogFrom(ctx context.Context) string {
+	catalog, _ := ctx.Value(catalogKey{}).(string)
+	return catalog
+}
+
+// contextHeaderTransport sets a header of the requests to a value obtained from their context,
+// if any
+type contextHeaderTransport struct {
+	base   http.RoundTripper
+	header string
+	value  func(context.Context) string
+}
+
+func (t contextHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	value := t.value(req.Context())
+	if value == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	// a round tripper must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, value)
+
+	return t.base.RoundTrip(req)
+}
+
+// withContextHeader returns a client that sends the header with the value obtained from the
+// context of the requests
+func withContextHeader(client *http.Client, header string, value func(context.Context) string) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	withHeader := *client
+	withHeader.Transport = contextHeaderTransport{base: base, header: header, value: value}
+
+	return &withHeader
+}
+
+// withCatalogHeader returns a client that sends the catalog selected in the context of the requests
+func withCatalogHeader(client *http.Client) *http.Client {
+	return withContextHeader(client, catalogHeader, catalogFrom)
+}
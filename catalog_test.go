@@ -0,0 +1,95 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6build/pkg/api"
+)
+
+func TestCatalog(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title   string
+		catalog string
+	}{
+		{
+			title:   "default catalog",
+			catalog: "",
+		},
+		{
+			title:   "catalog url",
+			catalog: "https://example.com/experimental.json",
+		},
+		{
+			title:   "catalog version",
+			catalog: "v1.2.0",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			mutex := sync.Mutex{}
+			catalogs := []string{}
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/build" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				mutex.Lock()
+				catalogs = append(catalogs, r.Header.Get(catalogHeader))
+				mutex.Unlock()
+
+				_ = json.NewEncoder(w).Encode(api.BuildResponse{Artifact: k6build.Artifact{ID: "artifact"}})
+			}))
+			t.Cleanup(srv.Close)
+
+			provider, err := NewProvider(Config{
+				BuildServiceURL: srv.URL,
+				BinDir:          t.TempDir(),
+			})
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			t.Cleanup(func() { _ = provider.Close() })
+
+			ctx := context.TODO()
+			if tc.catalog != "" {
+				ctx = WithCatalog(ctx, tc.catalog)
+			}
+
+			_, err = provider.GetArtifact(ctx, nil)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if len(catalogs) != 1 || catalogs[0] != tc.catalog {
+				t.Fatalf("expected %q got %q", tc.catalog, catalogs)
+			}
+		})
+	}
+}
+
+func TestCatalogRequestKey(t *testing.T) {
+	t.Parallel()
+
+	deps := []k6build.Dependency{{Name: "k6/x/faker", Constraints: "*"}}
+
+	defaultKey := requestKey("linux/amd64", "", "", "*", deps)
+	catalogKey := requestKey("linux/amd64", "v1.2.0", "", "*", deps)
+	otherKey := requestKey("linux/amd64", "v1.3.0", "", "*", deps)
+
+	if defaultKey == catalogKey || catalogKey == otherKey {
+		t.Fatalf("expected different keys got %q %q %q", defaultKey, catalogKey, otherKey)
+	}
+}
@@ -0,0 +1,26 @@
+package k6provider
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// verifyChecksum checks the hash matches the expected checksum, given as an hexadecimal string.
+// If the expected checksum is empty, the hash is not verified.
+func verifyChecksum(expected string, hash hash.Hash) error {
+	if expected == "" {
+		return nil
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(actual, expected) {
+		return NewWrappedError(
+			ErrChecksumMismatch,
+			fmt.Errorf("expected %s got %s", expected, actual),
+		)
+	}
+
+	return nil
+}
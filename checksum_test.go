@@ -0,0 +1,90 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestChecksumRetries(t *testing.T) {
+	t.Parallel()
+
+	binary := []byte("k6 binary")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+
+	testCases := []struct {
+		title           string
+		corrupted       int
+		expectErr       error
+		expectDownloads int32
+	}{
+		{
+			title:           "valid checksum",
+			corrupted:       0,
+			expectErr:       nil,
+			expectDownloads: 1,
+		},
+		{
+			title:           "corrupted download retried",
+			corrupted:       1,
+			expectErr:       nil,
+			expectDownloads: 2,
+		},
+		{
+			title:           "retries exhausted",
+			corrupted:       math.MaxInt,
+			expectErr:       ErrChecksumMismatch,
+			expectDownloads: DefaultChecksumRetries + 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			downloads := atomic.Int32{}
+			store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if int(downloads.Add(1)) <= tc.corrupted {
+					_, _ = w.Write([]byte("corrupted"))
+					return
+				}
+				_, _ = w.Write(binary)
+			}))
+			t.Cleanup(store.Close)
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+				},
+			)
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+
+			_, err := provider.GetBinary(context.TODO(), nil)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			if err != nil && !errors.Is(err, ErrDownload) {
+				t.Fatalf("expected %v got %v", ErrDownload, err)
+			}
+
+			if downloads.Load() != tc.expectDownloads {
+				t.Fatalf("expected %d downloads got %d", tc.expectDownloads, downloads.Load())
+			}
+
+			// mismatches are recorded
+			if len(provider.errors.recent()) != tc.corrupted && tc.expectErr == nil {
+				t.Fatalf("expected %d errors recorded got %v", tc.corrupted, provider.errors.recent())
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package k6provider
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// partialSuffix is the suffix of the file a binary is downloaded to before being
+	// moved to its final location
+	partialSuffix = ".partial"
+	// partialFileTTL is the time after which a partial file that has not been modified
+	// is considered orphaned by an interrupted download
+	partialFileTTL = 10 * time.Minute
+	// trashDir is the directory in the binary directory where pruned artifacts are moved to
+	trashDir = ".trash"
+)
+
+// isArtifactDir returns true if the entry of a binary directory may be an artifact directory.
+// Spurious files (e.g. lock files) and the hidden directories, such as the trash directory and
+// the request index, are excluded.
+func isArtifactDir(entry fs.DirEntry) bool {
+	return entry.IsDir() && !strings.HasPrefix(entry.Name(), ".")
+}
+
+// removePartial removes a partial file, the companion tools extracted with it, the state of
+// its interrupted download and its artifact directory, if left empty
+func removePartial(partialPath string) {
+	_ = os.Remove(partialPath)
+	_ = os.Remove(partialPath + resumeSuffix)
+	_ = os.RemoveAll(partialPath + toolsSuffix)
+	_ = os.Remove(filepath.Dir(partialPath))
+}
+
+// reapPartialFiles removes the orphaned partial files in a binary directory and the
+// artifact directories left empty after removing them
+func reapPartialFiles(dir string) error {
+	return cleanBinDir(dir, false)
+}
+
+// reconcileBinDir repairs a binary directory left inconsistent by interrupted downloads.
+// Besides removing orphaned partial files, it removes the artifact directories that
+// don't contain a valid binary and the exclusive lock files left by processes that did not
+// release them, and rebuilds the request index from the metadata of the remaining artifacts.
+func reconcileBinDir(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	errs := []error{removeStaleLocks(dir), cleanBinDir(dir, true)}
+
+	// the index is rebuilt once the invalid artifacts are removed
+	errs = append(errs, rebuildRequestIndex(dir))
+
+	return errors.Join(errs...)
+}
+
+func cleanBinDir(dir string, removeInvalid bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	errs := []error{}
+	for _, entry := range entries {
+		// skip any spurious file (e.g. lock files), each binary is in a directory
+		if !isArtifactDir(entry) {
+			continue
+		}
+
+		artifactDir := filepath.Join(dir, entry.Name())
+		if !removeInvalid {
+			if _, err := reapPartialFile(filepath.Join(artifactDir, k6Binary+partialSuffix)); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		// the lock file is removed after the directory, as removing them updates its
+		// modification time
+		removed, err := collectOrphan(artifactDir)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if !removed {
+			errs = append(errs, removeStaleLocks(artifactDir))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// removeStaleLocks removes the exclusive lock file in the directory if it was left by a process
+// that did not release it, that is, if it is older than staleLockAge. Advisory lock files are
+// kept, as a process waiting for the lock may have opened the file before it is removed, and
+// would place the lock on a file that other processes no longer see.
+func removeStaleLocks(dir string) error {
+	exclusive := newExclusiveFileLock(dir)
+	if !exclusive.stale() {
+		return nil
+	}
+
+	if err := os.Remove(exclusive.lockFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// collectOrphan removes an artifact directory that doesn't contain a valid binary, unless a
+// download may be in progress, removing the orphaned partial file if any.
+// Returns true if the directory was removed.
+func collectOrphan(artifactDir string) (bool, error) {
+	inProgress, err := reapPartialFile(filepath.Join(artifactDir, k6Binary+partialSuffix))
+	if err != nil || inProgress {
+		return false, err
+	}
+
+	return removeInvalidArtifact(artifactDir)
+}
+
+// reapPartialFile removes a partial file if it is orphaned. Returns true if the partial
+// file exists and the download may still be in progress.
+func reapPartialFile(partialPath string) (bool, error) {
+	info, err := os.Stat(partialPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if time.Since(info.ModTime()) < partialFileTTL {
+		return true, nil
+	}
+
+	removePartial(partialPath)
+
+	return false, nil
+}
+
+// removeInvalidArtifact removes an artifact directory if it doesn't contain a binary or
+// the binary is empty. Recently modified directories are kept as a download may be starting.
+// Returns true if the directory was removed.
+func removeInvalidArtifact(artifactDir string) (bool, error) {
+	binInfo, err := os.Stat(filepath.Join(artifactDir, k6Binary))
+	if err == nil && binInfo.Mode().IsRegular() && binInfo.Size() > 0 {
+		return false, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	dirInfo, err := os.Stat(artifactDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if time.Since(dirInfo.ModTime()) < partialFileTTL {
+		return false, nil
+	}
+
+	if err := os.RemoveAll(artifactDir); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// artifactDirSize returns the size of the files in an artifact directory, including the binary
+// and any other file such as the artifact's metadata
+func artifactDirSize(artifactDir string) (int64, error) {
+	size := int64(0)
+	err := filepath.WalkDir(artifactDir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+
+	return size, err
+}
@@ -0,0 +1,222 @@
+package k6provider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReconcileBinDir(t *testing.T) {
+	t.Parallel()
+
+	stale := time.Now().Add(-2 * partialFileTTL)
+
+	testCases := []struct {
+		title  string
+		files  map[string][]byte
+		old    bool
+		expect bool
+	}{
+		{
+			title:  "valid binary",
+			files:  map[string][]byte{k6Binary: []byte("k6")},
+			old:    true,
+			expect: true,
+		},
+		{
+			title:  "empty directory",
+			files:  map[string][]byte{},
+			old:    true,
+			expect: false,
+		},
+		{
+			title:  "empty binary",
+			files:  map[string][]byte{k6Binary: {}},
+			old:    true,
+			expect: false,
+		},
+		{
+			title:  "orphaned partial file",
+			files:  map[string][]byte{k6Binary + partialSuffix: []byte("k")},
+			old:    true,
+			expect: false,
+		},
+		{
+			title:  "download in progress",
+			files:  map[string][]byte{k6Binary + partialSuffix: []byte("k")},
+			old:    false,
+			expect: true,
+		},
+		{
+			title:  "recently created directory",
+			files:  map[string][]byte{},
+			old:    false,
+			expect: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			binDir := t.TempDir()
+			artifactDir := filepath.Join(binDir, "artifact")
+			if err := os.MkdirAll(artifactDir, 0o700); err != nil {
+				t.Fatalf("test setup: creating dir %v", err)
+			}
+
+			for name, data := range tc.files {
+				path := filepath.Join(artifactDir, name)
+				if err := os.WriteFile(path, data, 0o600); err != nil {
+					t.Fatalf("test setup: writing file %v", err)
+				}
+				if tc.old {
+					_ = os.Chtimes(path, stale, stale)
+				}
+			}
+			if tc.old {
+				_ = os.Chtimes(artifactDir, stale, stale)
+			}
+
+			if err := reconcileBinDir(binDir); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			_, err := os.Stat(artifactDir)
+			if exists := err == nil; exists != tc.expect {
+				t.Fatalf("expected artifact dir exists=%t got %v", tc.expect, err)
+			}
+		})
+	}
+}
+
+func TestReconcileStaleLocks(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title  string
+		setup  func(t *testing.T, dir string)
+		file   string
+		expect bool
+	}{
+		{
+			title: "stale exclusive lock",
+			setup: func(t *testing.T, dir string) {
+				content := fmt.Sprintf("%d\n", time.Now().Add(-2*staleLockAge).UnixNano())
+				if err := os.WriteFile(filepath.Join(dir, exclusiveLockFileName), []byte(content), 0o600); err != nil {
+					t.Fatalf("test setup: writing lock file %v", err)
+				}
+			},
+			file:   exclusiveLockFileName,
+			expect: false,
+		},
+		{
+			title: "held exclusive lock",
+			setup: func(t *testing.T, dir string) {
+				if err := newExclusiveFileLock(dir).lock(); err != nil {
+					t.Fatalf("test setup: locking %v", err)
+				}
+			},
+			file:   exclusiveLockFileName,
+			expect: true,
+		},
+		{
+			title: "released advisory lock is kept",
+			setup: func(t *testing.T, dir string) {
+				advisory := newFileLock(dir)
+				if err := advisory.lock(); err != nil {
+					t.Fatalf("test setup: locking %v", err)
+				}
+				if err := advisory.unlock(); err != nil {
+					t.Fatalf("test setup: unlocking %v", err)
+				}
+			},
+			file:   lockFileName,
+			expect: true,
+		},
+		{
+			title: "held advisory lock",
+			setup: func(t *testing.T, dir string) {
+				advisory := newFileLock(dir)
+				if err := advisory.lock(); err != nil {
+					t.Fatalf("test setup: locking %v", err)
+				}
+				t.Cleanup(func() { _ = advisory.unlock() })
+			},
+			file:   lockFileName,
+			expect: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			// the locks are removed both from the binary directory and the artifact directories
+			binDir := t.TempDir()
+			artifactDir := filepath.Join(binDir, "artifact")
+			if err := os.MkdirAll(artifactDir, 0o700); err != nil {
+				t.Fatalf("test setup: creating dir %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(artifactDir, k6Binary), []byte("k6"), 0o600); err != nil {
+				t.Fatalf("test setup: writing binary %v", err)
+			}
+
+			for _, dir := range []string{binDir, artifactDir} {
+				tc.setup(t, dir)
+			}
+
+			if err := reconcileBinDir(binDir); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			for _, dir := range []string{binDir, artifactDir} {
+				_, err := os.Stat(filepath.Join(dir, tc.file))
+				if exists := err == nil; exists != tc.expect {
+					t.Fatalf("expected %s exists=%t got %v", filepath.Join(dir, tc.file), tc.expect, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileRequestIndex(t *testing.T) {
+	t.Parallel()
+
+	binDir := t.TempDir()
+	for _, id := range []string{"artifact", "invalid"} {
+		artifactDir := filepath.Join(binDir, id)
+		if err := os.MkdirAll(artifactDir, 0o700); err != nil {
+			t.Fatalf("test setup: creating dir %v", err)
+		}
+		if err := writeMetadata(artifactDir, Artifact{ID: id}, "request"); err != nil {
+			t.Fatalf("test setup: writing metadata %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "artifact", k6Binary), []byte("k6"), 0o600); err != nil {
+		t.Fatalf("test setup: writing binary %v", err)
+	}
+
+	// the invalid artifact has no binary
+	stale := time.Now().Add(-2 * partialFileTTL)
+	_ = os.Chtimes(filepath.Join(binDir, "invalid"), stale, stale)
+
+	// the index is lost, as if the cache was created by a previous version
+	if err := os.RemoveAll(filepath.Join(binDir, requestIndexDir)); err != nil {
+		t.Fatalf("test setup: removing index %v", err)
+	}
+
+	if err := reconcileBinDir(binDir); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(binDir, requestIndexDir, "request"))
+	if err != nil {
+		t.Fatalf("reading index %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "artifact" {
+		t.Fatalf("expected artifact indexed got %v", entries)
+	}
+}
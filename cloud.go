@@ -0,0 +1,47 @@
+package k6provider
+
+import (
+	"errors"
+)
+
+const (
+	// GrafanaCloudBuildServiceURL URL of the build service hosted by Grafana Cloud k6
+	GrafanaCloudBuildServiceURL = "https://ingest.k6.io/builder/api/v1"
+	// grafanaCloudAuthType type of the authorization header used by Grafana Cloud k6
+	grafanaCloudAuthType = "Token"
+	// grafanaCloudStackHeader header identifying the Grafana Cloud stack of the requests
+	grafanaCloudStackHeader = "X-Stack-Id"
+)
+
+// GrafanaCloudConfig returns the configuration for using the build service hosted by Grafana Cloud k6
+// with the given stack ID and API token. The configuration can be further customized, for example,
+// for setting the binary directory.
+func GrafanaCloudConfig(stackID string, token string) Config {
+	return Config{
+		BuildServiceURL:      GrafanaCloudBuildServiceURL,
+		BuildServiceAuthType: grafanaCloudAuthType,
+		BuildServiceAuth:     token,
+		BuildServiceHeaders: map[string]string{
+			grafanaCloudStackHeader: stackID,
+		},
+		// the binaries are isolated from the ones obtained from other build services
+		ScopeCacheByBuildService: true,
+	}
+}
+
+// NewGrafanaCloudProvider returns a [Provider] that uses the build service hosted by Grafana Cloud k6
+// with the given stack ID and API token. See [GrafanaCloudConfig]
+func NewGrafanaCloudProvider(stackID string, token string) (*Provider, error) {
+	errs := []error{}
+	if stackID == "" {
+		errs = append(errs, errors.New("grafana cloud stack ID is required"))
+	}
+	if token == "" {
+		errs = append(errs, errors.New("grafana cloud token is required"))
+	}
+	if len(errs) > 0 {
+		return nil, NewWrappedError(ErrConfig, errors.Join(errs...))
+	}
+
+	return NewProvider(GrafanaCloudConfig(stackID, token))
+}
@@ -0,0 +1,59 @@
+package k6provider
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGrafanaCloudProvider(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title     string
+		stackID   string
+		token     string
+		expectErr error
+	}{
+		{
+			title:   "stack and token",
+			stackID: "12345",
+			token:   "glc_token",
+		},
+		{
+			title:     "missing stack",
+			token:     "glc_token",
+			expectErr: ErrConfig,
+		},
+		{
+			title:     "missing token",
+			stackID:   "12345",
+			expectErr: ErrConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			provider, err := NewGrafanaCloudProvider(tc.stackID, tc.token)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = provider.Close() })
+
+			config := provider.config
+			if config.BuildServiceURL != GrafanaCloudBuildServiceURL {
+				t.Fatalf("expected %v got %v", GrafanaCloudBuildServiceURL, config.BuildServiceURL)
+			}
+			if config.BuildServiceAuthType != "Token" || config.BuildServiceAuth != tc.token {
+				t.Fatalf("expected Token %v got %v %v", tc.token, config.BuildServiceAuthType, config.BuildServiceAuth)
+			}
+			if stack := config.BuildServiceHeaders["X-Stack-Id"]; stack != tc.stackID {
+				t.Fatalf("expected %v got %v", tc.stackID, stack)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package k6provider
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Command returns the command for running the binary with the given arguments, such as
+// "run script.js". The process is killed if the context is done before it exits.
+//
+// See the runner package for running the binary with its output captured and a graceful stop.
+func (b K6Binary) Command(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, b.Path, args...) //nolint:gosec
+}
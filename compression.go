@@ -0,0 +1,297 @@
+package k6provider
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// compressedFile is the file in the artifact directory with the name of the compression
+	// used for storing its binary. Binaries without it are stored uncompressed.
+	compressedFile = ".compressed"
+	// compressSuffix is the suffix of the file the partial file is compressed into
+	compressSuffix = ".compress"
+	// gzipName is the name of the built-in gzip compression
+	gzipName = "gzip"
+)
+
+// Compression compresses the binaries stored in the cache, trading CPU for disk space.
+// See [Config.CacheCompression].
+//
+// [GzipCompression] is built-in. Other algorithms, such as zstd, can be used by implementing
+// this interface, for example, using github.com/klauspost/compress/zstd.
+type Compression interface {
+	// Name identifies the compression. It is stored with each compressed binary, so it must
+	// not change between versions of the implementation that are not compatible
+	Name() string
+	// Compress returns a writer that compresses the data written to w. Closing it flushes
+	// any buffered data but does not close w
+	Compress(w io.Writer) (io.WriteCloser, error)
+	// Decompress returns a reader that decompresses the data read from r
+	Decompress(r io.Reader) (io.ReadCloser, error)
+}
+
+// gzipCompression is the built-in gzip compression
+type gzipCompression struct{}
+
+// GzipCompression returns the [Compression] using gzip
+func GzipCompression() Compression {
+	return gzipCompression{}
+}
+
+func (gzipCompression) Name() string {
+	return gzipName
+}
+
+func (gzipCompression) Compress(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, gzip.BestCompression)
+}
+
+func (gzipCompression) Decompress(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// compressionNamed returns the compression with the given name: the configured compression
+// or the built-in one, so binaries compressed with gzip can be used if the configuration changes
+func (p *Provider) compressionNamed(name string) (Compression, error) {
+	if configured := p.config.CacheCompression; configured != nil && configured.Name() == name {
+		return configured, nil
+	}
+
+	if name == gzipName {
+		return GzipCompression(), nil
+	}
+
+	return nil, fmt.Errorf("unknown compression %q", name)
+}
+
+// cachedCompression returns the compression of the binary in the artifact directory,
+// or nil if it is stored uncompressed
+func (p *Provider) cachedCompression(artifactDir string) (Compression, error) {
+	name, err := os.ReadFile(filepath.Join(artifactDir, compressedFile)) //nolint:gosec
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.compressionNamed(strings.TrimSpace(string(name)))
+}
+
+// compressPartial compresses the binary downloaded to the partial file, if the cache is
+// compressed, and records the compression in the artifact directory before the binary is
+// moved to its final location
+func (p *Provider) compressPartial(partialPath string) error {
+	marker := filepath.Join(filepath.Dir(partialPath), compressedFile)
+
+	compression := p.config.CacheCompression
+	if compression == nil {
+		// the marker may be left by a compressed binary that was removed
+		if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	compressedPath := partialPath + compressSuffix
+	err := p.compressFile(compression, partialPath, compressedPath)
+	if err == nil {
+		err = os.Rename(compressedPath, partialPath)
+	}
+	if err != nil {
+		_ = os.Remove(compressedPath)
+		return err
+	}
+
+	if err = os.WriteFile(marker, []byte(compression.Name()), cacheFilePerm); err != nil {
+		return err
+	}
+
+	return p.permissions.shareFile(marker)
+}
+
+// compressFile compresses the source file into the target file
+func (p *Provider) compressFile(compression Compression, source string, target string) error {
+	from, err := os.Open(source) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer from.Close() //nolint:errcheck
+
+	to, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, cacheBinaryPerm) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	compressor, err := compression.Compress(to)
+	if err == nil {
+		_, err = defaultBuffers.copy(compressor, from)
+		if closeErr := compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	// ensure the binary is written to the file server before it is visible to other hosts
+	if err == nil && p.networkFS {
+		err = to.Sync()
+	}
+	if closeErr := to.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// openCached opens the binary in the cache for reading its content, decompressing it if needed
+func (p *Provider) openCached(binPath string) (io.ReadCloser, error) {
+	compression, err := p.cachedCompression(filepath.Dir(binPath))
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(binPath) //nolint:gosec
+	if err != nil || compression == nil {
+		return file, err
+	}
+
+	reader, err := compression.Decompress(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &decompressedFile{ReadCloser: reader, file: file}, nil
+}
+
+// decompressedFile reads the decompressed content of a file, closing both when closed
+type decompressedFile struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (d *decompressedFile) Close() error {
+	return errors.Join(d.ReadCloser.Close(), d.file.Close())
+}
+
+// execDir is the temporary directory where compressed binaries are decompressed for executing
+// them. It is created when first needed and removed when the provider is closed.
+type execDir struct {
+	mutex sync.Mutex
+	path  string
+}
+
+// get returns the directory, creating it in the parent directory if it doesn't exist
+func (e *execDir) get(parent string) (string, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.path != "" {
+		return e.path, nil
+	}
+
+	path, err := os.MkdirTemp(parent, "k6provider-exec-")
+	if err != nil {
+		return "", err
+	}
+	e.path = path
+
+	return path, nil
+}
+
+// remove removes the directory and the binaries in it
+func (e *execDir) remove() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.path == "" {
+		return nil
+	}
+
+	err := os.RemoveAll(e.path)
+	e.path = ""
+
+	return err
+}
+
+// executable returns the binary with the path to an executable file. Binaries stored compressed
+// are decompressed into the provider's exec directory, verifying their checksum, unless they
+// were decompressed before. If the checksum does not match, the binary is removed from the cache.
+func (p *Provider) executable(binary K6Binary) (K6Binary, error) {
+	artifactDir := filepath.Dir(binary.Path)
+	compression, err := p.cachedCompression(artifactDir)
+	if err != nil {
+		return K6Binary{}, p.recordError(withArtifact(NewWrappedError(ErrBinary, err), binary.ID))
+	}
+	if compression == nil {
+		return binary, nil
+	}
+
+	dir, err := p.exec.get(p.config.PrivateCopyDir)
+	if err != nil {
+		return K6Binary{}, p.recordError(withArtifact(NewWrappedError(ErrBinary, err), binary.ID))
+	}
+
+	execPath := filepath.Join(dir, filepath.Base(artifactDir), k6Binary)
+	if _, err = os.Stat(execPath); err == nil {
+		binary.Path = execPath
+		return binary, nil
+	}
+
+	err = p.decompressTo(binary, execPath)
+	if errors.Is(err, ErrChecksumMismatch) {
+		// the binary is downloaded again next time
+		_ = os.Remove(binary.Path)
+	}
+	if err != nil {
+		return K6Binary{}, p.recordError(withArtifact(NewWrappedError(ErrBinary, err), binary.ID))
+	}
+
+	binary.Path = execPath
+	return binary, nil
+}
+
+// decompressTo decompresses the binary into the target path, verifying its checksum. The binary
+// is decompressed into a temporary file that is moved to the target path when completed.
+func (p *Provider) decompressTo(binary K6Binary, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), cacheDirPerm); err != nil {
+		return err
+	}
+
+	source, err := p.openCached(binary.Path)
+	if err != nil {
+		return err
+	}
+	defer source.Close() //nolint:errcheck
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), k6Binary+"-*")
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	_, err = defaultBuffers.copy(io.MultiWriter(tmp, hash), source)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = verifyChecksum(binary.Checksum, hash)
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), cacheBinaryPerm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,119 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestCacheCompression(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6 binary content")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	testCases := []struct {
+		title       string
+		compression Compression
+		corrupt     bool
+		expectErr   error
+	}{
+		{
+			title:       "uncompressed",
+			compression: nil,
+			expectErr:   nil,
+		},
+		{
+			title:       "gzip",
+			compression: GzipCompression(),
+			expectErr:   nil,
+		},
+		{
+			title:       "corrupted compressed binary",
+			compression: GzipCompression(),
+			corrupt:     true,
+			expectErr:   ErrBinary,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+				},
+			)
+			binDir := t.TempDir()
+			provider := newTestProvider(t, buildSrv, binDir)
+			provider.config.CacheCompression = tc.compression
+			provider.config.PrivateCopyDir = t.TempDir()
+
+			binary, err := provider.GetBinary(context.TODO(), nil)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			cachedPath := filepath.Join(binDir, "artifact", k6Binary)
+			if tc.compression != nil && binary.Path == cachedPath {
+				t.Fatalf("expected decompressed binary got %s", binary.Path)
+			}
+
+			got, err := os.ReadFile(binary.Path)
+			if err != nil {
+				t.Fatalf("reading binary %v", err)
+			}
+			if string(got) != string(content) {
+				t.Fatalf("expected %q got %q", content, got)
+			}
+
+			if tc.corrupt {
+				// the cached binary is decompressed again once the decompressed one is removed
+				if err = provider.exec.remove(); err != nil {
+					t.Fatalf("test setup: removing exec dir %v", err)
+				}
+				corrupted := filepath.Join(t.TempDir(), "corrupted")
+				if err = os.WriteFile(corrupted, []byte("corrupted"), 0o600); err != nil {
+					t.Fatalf("test setup: %v", err)
+				}
+				if err = provider.compressFile(tc.compression, corrupted, cachedPath); err != nil {
+					t.Fatalf("test setup: corrupting binary %v", err)
+				}
+			}
+
+			_, err = provider.GetBinary(context.TODO(), nil)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			if err = provider.Close(); err != nil {
+				t.Fatalf("closing provider %v", err)
+			}
+
+			// the corrupted binary is removed from the cache
+			if _, err = os.Stat(cachedPath); tc.corrupt != os.IsNotExist(err) {
+				t.Fatalf("expected binary removed %v got %v", tc.corrupt, err)
+			}
+
+			if tc.compression != nil {
+				if _, err = os.Stat(binary.Path); !os.IsNotExist(err) {
+					t.Fatalf("expected decompressed binary removed got %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// validatorsFile is the file in the artifact directory with the validators of the binary's content
+const validatorsFile = ".validators.json"
+
+// validators identify the version of the content of an URL, so it can be requested
+// only if it changed (conditional request)
+type validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// empty returns true if there are no validators
+func (v validators) empty() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// responseValidators returns the validators of the content of a response
+func responseValidators(resp *http.Response) validators {
+	return validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+}
+
+// readValidators reads the validators of the binary in an artifact directory
+func readValidators(artifactDir string) (validators, error) {
+	content, err := os.ReadFile(filepath.Join(artifactDir, validatorsFile)) //nolint:gosec
+	if err != nil {
+		return validators{}, err
+	}
+
+	v := validators{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return validators{}, err
+	}
+
+	return v, nil
+}
+
+// writeValidators writes the validators of the binary in an artifact directory, removing
+// those of a previous binary if there are none
+func writeValidators(artifactDir string, v validators) error {
+	validatorsPath := filepath.Join(artifactDir, validatorsFile)
+	if v.empty() {
+		if err := os.Remove(validatorsPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(validatorsPath, content, cacheFilePerm)
+}
+
+// notModified requests the URL with the validators of a previous download and returns true
+// if the server reports the content was not modified. The response body is not read.
+func (d *downloader) notModified(ctx context.Context, from string, v validators) (bool, error) {
+	req, err := d.newRequest(ctx, from)
+	if err != nil {
+		return false, err
+	}
+
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	resp, err := d.send(req)
+	if err != nil {
+		return false, classifyDownloadError(err)
+	}
+	_ = resp.Body.Close()
+
+	return resp.StatusCode == http.StatusNotModified, nil
+}
+
+// unchanged returns true if the store reports the content of the artifact's URL didn't change
+// since the binary in the artifact directory was downloaded. Binaries downloaded without
+// validators are considered changed.
+func (p *Provider) unchanged(ctx context.Context, artifactDir string, url string) bool {
+	v, err := readValidators(artifactDir)
+	if err != nil || v.empty() || url == "" {
+		return false
+	}
+
+	notModified, err := p.downloader.notModified(ctx, url, v)
+	return err == nil && notModified
+}
@@ -0,0 +1,384 @@
+package k6provider
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// redacted replaces the value of secrets
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are fragments of the names of headers that may contain secrets
+var sensitiveHeaders = []string{"auth", "token", "key", "secret", "cookie", "password", "credential"} //nolint:gochecknoglobals,lll
+
+// isSensitiveHeader returns true if the header may contain secrets
+func isSensitiveHeader(header string) bool {
+	header = strings.ToLower(header)
+	for _, fragment := range sensitiveHeaders {
+		if strings.Contains(header, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeaders returns a copy of the headers with the value of sensitive headers redacted
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	redactedHeaders := make(map[string]string, len(headers))
+	for header, value := range headers {
+		if isSensitiveHeader(header) {
+			value = redacted
+		}
+		redactedHeaders[header] = value
+	}
+	return redactedHeaders
+}
+
+// redactSecret returns the secret redacted, if not empty
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURLs returns the comma-separated list of URLs with their passwords and the value of
+// their sensitive query parameters redacted. Values that are not valid URLs are kept.
+func redactURLs(urls string) string {
+	if urls == "" {
+		return ""
+	}
+
+	redactedURLs := strings.Split(urls, ",")
+	for i, value := range redactedURLs {
+		if parsed, err := url.Parse(strings.TrimSpace(value)); err == nil {
+			redactedURLs[i] = redactURL(parsed)
+		}
+	}
+
+	return strings.Join(redactedURLs, ",")
+}
+
+// Redacted returns a copy of the configuration with the credentials, the passwords in the
+// URLs and the value of sensitive headers redacted, so it can be safely logged.
+func (c Config) Redacted() Config {
+	c.BuildServiceURL = redactURLs(c.BuildServiceURL)
+	c.BuildServiceProxyURL = redactURLs(c.BuildServiceProxyURL)
+	c.DownloadConfig.ProxyURL = redactURLs(c.DownloadConfig.ProxyURL)
+	c.Telemetry.URL = redactURLs(c.Telemetry.URL)
+	c.DNSOverHTTPSURL = redactURLs(c.DNSOverHTTPSURL)
+	c.DiscoveryDomain = redactDomain(c.DiscoveryDomain)
+	c.BuildServiceURLs = redactURLList(c.BuildServiceURLs)
+	c.BuildServiceReplicas = redactURLList(c.BuildServiceReplicas)
+	if c.PlatformServiceMap != nil {
+		platformServiceMap := make(map[string]string, len(c.PlatformServiceMap))
+		for platform, srvURL := range c.PlatformServiceMap {
+			platformServiceMap[platform] = redactURLs(srvURL)
+		}
+		c.PlatformServiceMap = platformServiceMap
+	}
+	c.BuildServiceAuth = redactSecret(c.BuildServiceAuth)
+	c.BuildServiceHeaders = redactHeaders(c.BuildServiceHeaders)
+	c.DownloadConfig.Authorization = redactSecret(c.DownloadConfig.Authorization)
+	c.DownloadConfig.Headers = redactHeaders(c.DownloadConfig.Headers)
+	c.Telemetry.Headers = redactHeaders(c.Telemetry.Headers)
+	return c
+}
+
+// redactDomain returns the domain with the password redacted, if it is given with userinfo,
+// with or without a scheme
+func redactDomain(domain string) string {
+	if domain == "" || strings.Contains(domain, "://") {
+		return redactURLs(domain)
+	}
+
+	parsed, err := url.Parse("//" + domain)
+	if err != nil {
+		return domain
+	}
+
+	return strings.TrimPrefix(redactURL(parsed), "//")
+}
+
+// redactURLList returns a copy of the URLs with their passwords redacted
+func redactURLList(urls []string) []string {
+	if urls == nil {
+		return nil
+	}
+
+	redactedURLs := make([]string, len(urls))
+	for i, value := range urls {
+		redactedURLs[i] = redactURLs(value)
+	}
+
+	return redactedURLs
+}
+
+// configFields prevents the recursive call to Config's String method when formatting
+type configFields Config
+
+// String returns a representation of the configuration with the credentials redacted
+func (c Config) String() string {
+	return fmt.Sprintf("%+v", configFields(c.Redacted()))
+}
+
+// LogValue implements the slog.LogValuer interface, logging the configuration with
+// the credentials redacted
+func (c Config) LogValue() slog.Value {
+	r := c.Redacted()
+	return slog.GroupValue(
+		slog.String("platform", r.Platform),
+		slog.String("binDir", r.BinDir),
+		slog.Bool("tempBinDir", r.TempBinDir),
+		slog.Any("fallbackBinDirs", r.FallbackBinDirs),
+		slog.Any("execBinDirs", r.ExecBinDirs),
+		slog.String("buildServiceURL", r.BuildServiceURL),
+		slog.Any("buildServiceURLs", r.BuildServiceURLs),
+		slog.Any("buildServiceReplicas", r.BuildServiceReplicas),
+		slog.Any("platformServiceMap", r.PlatformServiceMap),
+		slog.String("buildServiceBalancing", string(r.BuildServiceBalancing)),
+		slog.String("discoveryDomain", r.DiscoveryDomain),
+		slog.String("buildServiceProxyURL", r.BuildServiceProxyURL),
+		slog.String("buildServiceNoProxy", r.BuildServiceNoProxy),
+		slog.String("credentialSource", r.CredentialSource),
+		slog.String("buildServiceAuthType", r.BuildServiceAuthType),
+		slog.String("buildServiceAuth", r.BuildServiceAuth),
+		slog.Any("buildServiceHeaders", r.BuildServiceHeaders),
+		slog.Any("staticHosts", r.StaticHosts),
+		slog.String("dnsOverHTTPSURL", r.DNSOverHTTPSURL),
+		slog.String("networkPolicy", string(r.NetworkPolicy)),
+		slog.Int64("highWaterMark", r.HighWaterMark),
+		slog.Duration("pruneInterval", r.PruneInterval),
+		slog.String("cacheScope", r.CacheScope),
+		slog.String("cacheGroup", r.CacheGroup),
+		slog.Bool("sharedCache", r.SharedCache),
+		slog.Bool("strictPermissions", r.StrictPermissions),
+		slog.Group(
+			"downloadConfig",
+			slog.String("authType", r.DownloadConfig.AuthType),
+			slog.String("authorization", r.DownloadConfig.Authorization),
+			slog.Any("headers", r.DownloadConfig.Headers),
+			slog.String("proxyURL", r.DownloadConfig.ProxyURL),
+			slog.String("noProxy", r.DownloadConfig.NoProxy),
+			slog.Int("retries", r.DownloadConfig.Retries),
+			slog.Duration("backoff", r.DownloadConfig.Backoff),
+		),
+	)
+}
+
+// Validate checks the configuration for common mistakes, such as credentials without
+// a build service URL or malformed URLs. Values taken from the environment when not
+// set in the configuration are also considered.
+//
+// All the problems found are reported in the returned error, which is an [ErrConfig].
+func (c Config) Validate() error {
+	errs := c.validateBuildServices()
+	errs = append(errs, c.validateConnections()...)
+	errs = append(errs, c.validateLimits()...)
+
+	if _, err := loadOverrides(c); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := loadMinimumVersions(c); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return NewWrappedError(ErrConfig, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// validateBuildServices checks the build services and how requests are distributed across them
+func (c Config) validateBuildServices() []error {
+	errs := []error{}
+
+	buildSrvURL := c.BuildServiceURL
+	if buildSrvURL == "" {
+		buildSrvURL = os.Getenv("K6_BUILD_SERVICE_URL")
+	}
+
+	discoveryDomain := c.DiscoveryDomain
+	if discoveryDomain == "" {
+		discoveryDomain = os.Getenv("K6_BUILD_SERVICE_DISCOVERY_DOMAIN")
+	}
+
+	urls := slices.Concat(c.BuildServiceURLs, c.BuildServiceReplicas)
+	for _, url := range c.PlatformServiceMap {
+		urls = append(urls, url)
+	}
+	for _, url := range urls {
+		if err := validateURL(url, "http", "https"); err != nil {
+			errs = append(errs, fmt.Errorf("build service URL %w", err))
+		}
+	}
+
+	switch {
+	case buildSrvURL == "" && (len(c.BuildServiceURLs) > 0 || len(c.PlatformServiceMap) > 0):
+		// the additional build services are used
+	case buildSrvURL == "" && discoveryDomain != "":
+		// the URL is discovered when the provider is created
+	case buildSrvURL == "":
+		errs = append(errs, errors.New("build service URL is required"))
+		if c.BuildServiceAuth != "" || len(c.BuildServiceHeaders) > 0 {
+			errs = append(errs, errors.New("build service credentials set without build service URL"))
+		}
+	default:
+		for _, url := range splitURLs(buildSrvURL) {
+			if err := validateURL(url, "http", "https"); err != nil {
+				errs = append(errs, fmt.Errorf("build service URL %w", err))
+			}
+		}
+	}
+
+	if c.Platform != "" {
+		if _, err := NormalizePlatform(c.Platform); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for platform := range c.PlatformServiceMap {
+		if _, err := NormalizePlatform(platform); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	switch c.BuildServiceBalancing {
+	case "", RoundRobin, LeastLatency:
+	default:
+		errs = append(errs, fmt.Errorf("unknown balancing strategy %q", c.BuildServiceBalancing))
+	}
+
+	return errs
+}
+
+// validateConnections checks the settings for connecting to the build services and downloading binaries
+func (c Config) validateConnections() []error {
+	errs := []error{}
+
+	if err := validateProxyURL(c.DownloadConfig.ProxyURL, "K6_DOWNLOAD_PROXY"); err != nil {
+		errs = append(errs, fmt.Errorf("download proxy URL %w", err))
+	}
+	if err := validateProxyURL(c.BuildServiceProxyURL, "K6_BUILD_SERVICE_PROXY"); err != nil {
+		errs = append(errs, fmt.Errorf("build service proxy URL %w", err))
+	}
+
+	switch c.NetworkPolicy {
+	case "", NetworkAllow, NetworkDeny:
+	default:
+		errs = append(errs, fmt.Errorf("unknown network policy %q", c.NetworkPolicy))
+	}
+
+	if c.CredentialSource != "" {
+		if _, err := keyringService(c.CredentialSource); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if _, err := parseStaticHosts(c.StaticHosts); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.DNSOverHTTPSURL != "" {
+		if err := validateURL(c.DNSOverHTTPSURL, "https"); err != nil {
+			errs = append(errs, fmt.Errorf("DNS over HTTPS URL %w", err))
+		}
+	}
+
+	if c.Telemetry.URL != "" {
+		if err := validateURL(c.Telemetry.URL, "http", "https"); err != nil {
+			errs = append(errs, fmt.Errorf("telemetry URL %w", err))
+		}
+	}
+
+	return errs
+}
+
+// validateLimits checks the limits of the cache and the downloads are not negative and
+// the cache options are valid
+func (c Config) validateLimits() []error {
+	errs := []error{}
+
+	switch c.LockMode {
+	case "", LockFile, LockNamedMutex:
+	default:
+		errs = append(errs, fmt.Errorf("unknown lock mode %q", c.LockMode))
+	}
+
+	if c.PhaseBudget.ResolveFraction < 0 || c.PhaseBudget.ResolveFraction >= 1 {
+		errs = append(errs, errors.New("phase budget resolve fraction must be less than 1 and cannot be negative"))
+	}
+
+	if c.HighWaterMark < 0 {
+		errs = append(errs, errors.New("high-water-mark cannot be negative"))
+	}
+	if c.KeepPerFamily < 0 {
+		errs = append(errs, errors.New("binaries kept per family cannot be negative"))
+	}
+	if c.TrashGracePeriod < 0 {
+		errs = append(errs, errors.New("trash grace period cannot be negative"))
+	}
+	if c.PruneInterval < 0 {
+		errs = append(errs, errors.New("prune interval cannot be negative"))
+	}
+	if c.StaleWhileRevalidate < 0 || c.StaleIfError < 0 {
+		errs = append(errs, errors.New("stale durations cannot be negative"))
+	}
+	if c.ShutdownGracePeriod < 0 {
+		errs = append(errs, errors.New("shutdown grace period cannot be negative"))
+	}
+	if c.Telemetry.Interval < 0 {
+		errs = append(errs, errors.New("telemetry interval cannot be negative"))
+	}
+	if c.DownloadConfig.Retries < 0 {
+		errs = append(errs, errors.New("download retries cannot be negative"))
+	}
+	if c.DownloadConfig.Backoff < 0 {
+		errs = append(errs, errors.New("download backoff cannot be negative"))
+	}
+	if c.DownloadConfig.StallTimeout < 0 {
+		errs = append(errs, errors.New("download stall timeout cannot be negative"))
+	}
+
+	return errs
+}
+
+// validateProxyURL checks the proxy URL, taken from the environment variable if not set, is valid
+func validateProxyURL(proxyURL string, env string) error {
+	if proxyURL == "" {
+		proxyURL = os.Getenv(env)
+	}
+	if proxyURL == "" {
+		return nil
+	}
+	return validateURL(proxyURL, "http", "https", "socks5")
+}
+
+// validateURL checks the URL is absolute and uses one of the given schemes
+func validateURL(value string, schemes ...string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if parsed.Host == "" {
+		return fmt.Errorf("%q has no host", value)
+	}
+
+	for _, scheme := range schemes {
+		if parsed.Scheme == scheme {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%q has unsupported scheme %q", value, parsed.Scheme)
+}
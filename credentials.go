@@ -0,0 +1,90 @@
+package k6provider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	// keyringSource is the prefix of credential sources that use the system's keyring
+	keyringSource = "keyring:"
+	// CredentialBuildService is the name of the build service credential in a credential source
+	CredentialBuildService = "build-service-auth"
+	// CredentialDownload is the name of the download credential in a credential source
+	CredentialDownload = "download-auth"
+)
+
+// keyringService returns the service under which the credentials are kept in the system's
+// keyring for a credential source of the form "keyring:<service>"
+func keyringService(source string) (string, error) {
+	service, found := strings.CutPrefix(source, keyringSource)
+	if !found {
+		return "", fmt.Errorf("unsupported credential source %q", source)
+	}
+	if service == "" {
+		return "", fmt.Errorf("credential source %q has no service", source)
+	}
+	return service, nil
+}
+
+// loadCredential returns a credential from the credential source, or an empty string if
+// the credential is not found
+func loadCredential(source string, name string) (string, error) {
+	service, err := keyringService(source)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := keyring.Get(service, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", nil
+	}
+
+	return secret, err
+}
+
+// SaveCredential stores a credential in a credential source, such as the system's keyring
+// ("keyring:<service>"), so it can be loaded by a provider using it as [Config.CredentialSource].
+// The name of the credential is either [CredentialBuildService] or [CredentialDownload].
+func SaveCredential(source string, name string, secret string) error {
+	if name != CredentialBuildService && name != CredentialDownload {
+		return NewWrappedError(ErrConfig, fmt.Errorf("unknown credential %q", name))
+	}
+
+	service, err := keyringService(source)
+	if err != nil {
+		return NewWrappedError(ErrConfig, err)
+	}
+
+	return keyring.Set(service, name, secret)
+}
+
+// withCredentials returns the configuration with the credentials not set in the configuration or
+// the environment loaded from the credential source, if any
+func withCredentials(config Config) (Config, error) {
+	if config.CredentialSource == "" {
+		return config, nil
+	}
+
+	if config.BuildServiceAuth == "" && os.Getenv("K6_BUILD_SERVICE_AUTH") == "" {
+		auth, err := loadCredential(config.CredentialSource, CredentialBuildService)
+		if err != nil {
+			return config, NewWrappedError(ErrConfig, err)
+		}
+		config.BuildServiceAuth = auth
+	}
+
+	if config.DownloadConfig.Authorization == "" && os.Getenv("K6_DOWNLOAD_AUTH") == "" {
+		auth, err := loadCredential(config.CredentialSource, CredentialDownload)
+		if err != nil {
+			return config, NewWrappedError(ErrConfig, err)
+		}
+		config.DownloadConfig.Authorization = auth
+	}
+
+	return config, nil
+}
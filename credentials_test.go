@@ -0,0 +1,87 @@
+package k6provider
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestCredentialSource(t *testing.T) {
+	t.Parallel()
+
+	keyring.MockInit()
+
+	source := "keyring:k6provider-test"
+	if err := SaveCredential(source, CredentialBuildService, "build-token"); err != nil {
+		t.Fatalf("test setup: saving credential %v", err)
+	}
+	if err := SaveCredential(source, CredentialDownload, "download-token"); err != nil {
+		t.Fatalf("test setup: saving credential %v", err)
+	}
+
+	testCases := []struct {
+		title          string
+		config         Config
+		expectBuild    string
+		expectDownload string
+		expectErr      error
+	}{
+		{
+			title:  "no credential source",
+			config: Config{},
+		},
+		{
+			title:          "credentials from keyring",
+			config:         Config{CredentialSource: source},
+			expectBuild:    "build-token",
+			expectDownload: "download-token",
+		},
+		{
+			title: "configured credentials take precedence",
+			config: Config{
+				CredentialSource: source,
+				BuildServiceAuth: "configured",
+			},
+			expectBuild:    "configured",
+			expectDownload: "download-token",
+		},
+		{
+			title:  "credentials not in keyring",
+			config: Config{CredentialSource: "keyring:other"},
+		},
+		{
+			title:     "unsupported source",
+			config:    Config{CredentialSource: "vault:k6provider"},
+			expectErr: ErrConfig,
+		},
+	}
+
+	for _, tc := range testCases { //nolint:paralleltest
+		t.Run(tc.title, func(t *testing.T) {
+			config, err := withCredentials(tc.config)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if config.BuildServiceAuth != tc.expectBuild {
+				t.Fatalf("expected %v got %v", tc.expectBuild, config.BuildServiceAuth)
+			}
+			if config.DownloadConfig.Authorization != tc.expectDownload {
+				t.Fatalf("expected %v got %v", tc.expectDownload, config.DownloadConfig.Authorization)
+			}
+		})
+	}
+}
+
+func TestSaveCredentialUnknown(t *testing.T) {
+	t.Parallel()
+
+	err := SaveCredential("keyring:k6provider-test", "password", "secret")
+	if !errors.Is(err, ErrConfig) {
+		t.Fatalf("expected %v got %v", ErrConfig, err)
+	}
+}
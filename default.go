@@ -0,0 +1,71 @@
+package k6provider
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grafana/k6deps"
+)
+
+// defaultProvider is the process-wide provider used by the package-level functions
+var defaultProvider struct { //nolint:gochecknoglobals
+	mutex    sync.Mutex
+	provider *Provider
+}
+
+// Default returns the process-wide default provider, creating it on first use using
+// [NewDefaultProvider]. If the creation fails, it is attempted again on the next call,
+// so the configuration can be fixed (e.g. setting K6_BUILD_SERVICE_URL).
+//
+// It is safe for concurrent use.
+func Default() (*Provider, error) {
+	defaultProvider.mutex.Lock()
+	defer defaultProvider.mutex.Unlock()
+
+	if defaultProvider.provider != nil {
+		return defaultProvider.provider, nil
+	}
+
+	provider, err := NewDefaultProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultProvider.provider = provider
+	return provider, nil
+}
+
+// SetDefault replaces the process-wide default provider, returning the previous one, if any.
+// Setting it to nil causes the default provider to be created again on next use.
+//
+// The previous provider is not closed, as it may still be in use.
+func SetDefault(provider *Provider) *Provider {
+	defaultProvider.mutex.Lock()
+	defer defaultProvider.mutex.Unlock()
+
+	previous := defaultProvider.provider
+	defaultProvider.provider = provider
+	return previous
+}
+
+// GetBinary returns a custom k6 binary that satisfies the given a set of dependencies
+// using the process-wide default provider. See [Default] and [Provider.GetBinary].
+func GetBinary(ctx context.Context, deps k6deps.Dependencies) (K6Binary, error) {
+	provider, err := Default()
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	return provider.GetBinary(ctx, deps)
+}
+
+// GetArtifact returns a custom k6 artifact that satisfies the given a set of dependencies
+// using the process-wide default provider. See [Default] and [Provider.GetArtifact].
+func GetArtifact(ctx context.Context, deps k6deps.Dependencies) (Artifact, error) {
+	provider, err := Default()
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	return provider.GetArtifact(ctx, deps)
+}
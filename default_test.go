@@ -0,0 +1,65 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestDefaultProvider(t *testing.T) { //nolint:paralleltest
+	// the default provider is global state, so this test cannot run in parallel
+	t.Setenv("K6_BUILD_SERVICE_URL", "")
+	previous := SetDefault(nil)
+	t.Cleanup(func() { SetDefault(previous) })
+
+	// creation fails without a build service URL
+	if _, err := GetBinary(context.TODO(), nil); !errors.Is(err, ErrConfig) {
+		t.Fatalf("expected %v got %v", ErrConfig, err)
+	}
+
+	// creation is retried once the configuration is fixed
+	t.Setenv("K6_BUILD_SERVICE_URL", "http://localhost:8000")
+
+	providers := make(chan *Provider, 10)
+	wg := sync.WaitGroup{}
+	for i := 0; i < cap(providers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			provider, err := Default()
+			if err != nil {
+				t.Errorf("unexpected %v", err)
+			}
+			providers <- provider
+		}()
+	}
+	wg.Wait()
+	close(providers)
+
+	first := <-providers
+	for provider := range providers {
+		if provider != first {
+			t.Fatalf("expected a single default provider")
+		}
+	}
+
+	// replace the default provider
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact"}, nil
+		},
+	)
+	SetDefault(newTestProvider(t, buildSrv, t.TempDir()))
+
+	artifact, err := GetArtifact(context.TODO(), nil)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if artifact.ID != "artifact" {
+		t.Fatalf("expected artifact from the default provider got %v", artifact)
+	}
+}
@@ -0,0 +1,73 @@
+package k6provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DialFunc connects to the address on the named network, with the semantics of [net.Dialer.DialContext]
+type DialFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+// newDialFunc returns the function used by the HTTP clients for connecting to the build service
+// and the artifact store, or nil if the default is used. The hosts in StaticHosts are connected
+// to their static address using DialContext or, if not set, a dialer with the configured Resolver.
+// If the network policy denies accessing the network, the function fails all the connections.
+func newDialFunc(config Config) (DialFunc, error) {
+	if networkDenied(config) {
+		return denyDial, nil
+	}
+
+	if config.DialContext == nil && config.Resolver == nil && len(config.StaticHosts) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	dial := config.DialContext
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Resolver: config.Resolver}
+		dial = dialer.DialContext
+	}
+
+	if len(config.StaticHosts) == 0 {
+		return dial, nil
+	}
+
+	hosts, err := parseStaticHosts(config.StaticHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err == nil {
+			if ip, found := hosts[strings.ToLower(host)]; found {
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		return dial(ctx, network, addr)
+	}, nil
+}
+
+// parseStaticHosts returns the static hosts with the host names in lowercase, checking
+// their addresses are valid IP addresses
+func parseStaticHosts(staticHosts map[string]string) (map[string]string, error) {
+	hosts := make(map[string]string, len(staticHosts))
+	for host, ip := range staticHosts {
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid IP address %q for host %q", ip, host)
+		}
+		hosts[strings.ToLower(host)] = ip
+	}
+
+	return hosts, nil
+}
+
+// resolver returns the resolver in the configuration or the default resolver if not set
+func resolver(config Config) *net.Resolver {
+	if config.Resolver != nil {
+		return config.Resolver
+	}
+	return net.DefaultResolver
+}
@@ -0,0 +1,90 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestStaticHosts(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	t.Cleanup(store.Close)
+
+	storeURL, _ := url.Parse(store.URL)
+
+	dialed := ""
+	recordDial := func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		dialed = addr
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+
+	testCases := []struct {
+		title        string
+		config       Config
+		expectDialed string
+		expectErr    error
+	}{
+		{
+			title:  "static host",
+			config: Config{StaticHosts: map[string]string{"Store.Internal": storeURL.Hostname()}},
+		},
+		{
+			title: "static host with custom dial",
+			config: Config{
+				StaticHosts: map[string]string{"store.internal": storeURL.Hostname()},
+				DialContext: recordDial,
+			},
+			expectDialed: storeURL.Host,
+		},
+		{
+			title:     "invalid address",
+			config:    Config{StaticHosts: map[string]string{"store.internal": "store"}},
+			expectErr: ErrConfig,
+		},
+	}
+
+	for _, tc := range testCases { //nolint:paralleltest
+		t.Run(tc.title, func(t *testing.T) {
+			dial, err := newDialFunc(tc.config)
+			if err != nil {
+				err = NewWrappedError(ErrConfig, err)
+			}
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			noProxy := func(*http.Request) (*url.URL, error) { return nil, nil } //nolint:nilnil
+			downloader, err := newDownloader(DownloadConfig{Proxy: noProxy}, dial)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			// the request is sent to the static address keeping the original host
+			host := "store.internal:" + storeURL.Port()
+			out := &bytes.Buffer{}
+			if err = downloader.download(context.TODO(), "http://"+host, out); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if out.String() != host {
+				t.Fatalf("expected %v got %v", host, out.String())
+			}
+
+			if dialed != tc.expectDialed {
+				t.Fatalf("expected %v got %v", tc.expectDialed, dialed)
+			}
+		})
+	}
+}
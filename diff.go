@@ -0,0 +1,97 @@
+package k6provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VersionChange is the change of the version of a dependency between two binaries
+type VersionChange struct {
+	// From version in the first binary
+	From string `json:"from"`
+	// To version in the second binary
+	To string `json:"to"`
+}
+
+// DependencyDiff describes the differences in the dependencies of two binaries,
+// including the version of k6 (dependency "k6")
+type DependencyDiff struct {
+	// Added dependencies only in the second binary, as a map of name: version
+	Added map[string]string `json:"added,omitempty"`
+	// Removed dependencies only in the first binary, as a map of name: version
+	Removed map[string]string `json:"removed,omitempty"`
+	// Changed dependencies in both binaries with different versions
+	Changed map[string]VersionChange `json:"changed,omitempty"`
+}
+
+// DiffDependencies returns the differences from the dependencies of binary a to those of binary b.
+// It allows explaining why a different binary was obtained, for example, between two runs of a script.
+func DiffDependencies(a, b K6Binary) DependencyDiff {
+	diff := DependencyDiff{
+		Added:   map[string]string{},
+		Removed: map[string]string{},
+		Changed: map[string]VersionChange{},
+	}
+
+	for name, from := range a.Dependencies {
+		to, found := b.Dependencies[name]
+		switch {
+		case !found:
+			diff.Removed[name] = from
+		case to != from:
+			diff.Changed[name] = VersionChange{From: from, To: to}
+		}
+	}
+
+	for name, to := range b.Dependencies {
+		if _, found := a.Dependencies[name]; !found {
+			diff.Added[name] = to
+		}
+	}
+
+	return diff
+}
+
+// Empty returns true if both binaries have the same dependencies
+func (d DependencyDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// K6 returns the change of the k6 version, if any
+func (d DependencyDiff) K6() (VersionChange, bool) {
+	change, found := d.Changed[k6Module]
+	return change, found
+}
+
+// String returns the differences as a list of changes separated by ";" sorted by name.
+// e.g. +k6/x/faker:"v0.4.0";-k6/x/sql:"v1.0.0";k6:"v0.50.0"->"v0.51.0";
+func (d DependencyDiff) String() string {
+	names := make([]string, 0, len(d.Added)+len(d.Removed)+len(d.Changed))
+	for name := range d.Added {
+		names = append(names, name)
+	}
+	for name := range d.Removed {
+		names = append(names, name)
+	}
+	for name := range d.Changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buffer := &strings.Builder{}
+	for _, name := range names {
+		if version, found := d.Added[name]; found {
+			fmt.Fprintf(buffer, "+%s:%q;", name, version)
+			continue
+		}
+		if version, found := d.Removed[name]; found {
+			fmt.Fprintf(buffer, "-%s:%q;", name, version)
+			continue
+		}
+		change := d.Changed[name]
+		fmt.Fprintf(buffer, "%s:%q->%q;", name, change.From, change.To)
+	}
+
+	return buffer.String()
+}
@@ -0,0 +1,69 @@
+package k6provider
+
+import (
+	"testing"
+)
+
+func TestDiffDependencies(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title    string
+		a        map[string]string
+		b        map[string]string
+		expect   string
+		expectK6 bool
+	}{
+		{
+			title:  "same dependencies",
+			a:      map[string]string{"k6": "v0.50.0", "k6/x/faker": "v0.4.0"},
+			b:      map[string]string{"k6": "v0.50.0", "k6/x/faker": "v0.4.0"},
+			expect: "",
+		},
+		{
+			title:  "added extension",
+			a:      map[string]string{"k6": "v0.50.0"},
+			b:      map[string]string{"k6": "v0.50.0", "k6/x/faker": "v0.4.0"},
+			expect: `+k6/x/faker:"v0.4.0";`,
+		},
+		{
+			title:  "removed extension",
+			a:      map[string]string{"k6": "v0.50.0", "k6/x/faker": "v0.4.0"},
+			b:      map[string]string{"k6": "v0.50.0"},
+			expect: `-k6/x/faker:"v0.4.0";`,
+		},
+		{
+			title:    "changed versions",
+			a:        map[string]string{"k6": "v0.50.0", "k6/x/faker": "v0.4.0", "k6/x/sql": "v1.0.0"},
+			b:        map[string]string{"k6": "v0.51.0", "k6/x/faker": "v0.4.1", "k6/x/kubernetes": "v0.9.0"},
+			expect:   `k6:"v0.50.0"->"v0.51.0";k6/x/faker:"v0.4.0"->"v0.4.1";+k6/x/kubernetes:"v0.9.0";-k6/x/sql:"v1.0.0";`,
+			expectK6: true,
+		},
+		{
+			title:  "no dependencies",
+			a:      nil,
+			b:      map[string]string{"k6": "v0.50.0"},
+			expect: `+k6:"v0.50.0";`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			diff := DiffDependencies(K6Binary{Dependencies: tc.a}, K6Binary{Dependencies: tc.b})
+
+			if diff.String() != tc.expect {
+				t.Fatalf("expected %s got %s", tc.expect, diff.String())
+			}
+
+			if diff.Empty() != (tc.expect == "") {
+				t.Fatalf("expected empty %v got %v", tc.expect == "", diff.Empty())
+			}
+
+			if _, changed := diff.K6(); changed != tc.expectK6 {
+				t.Fatalf("expected k6 changed %v got %v", tc.expectK6, changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// discoveryPath is the well-known endpoint that returns the build service URL
+	discoveryPath = "/.well-known/k6build"
+	// discoveryService is the service name used in the SRV and TXT records
+	discoveryService = "k6build"
+	// discoveryTXTPrefix is the prefix of the TXT record's value with the build service URL
+	discoveryTXTPrefix = "url="
+	// discoveryTimeout is the maximum time for discovering the build service
+	discoveryTimeout = 10 * time.Second
+)
+
+// discoveryResponse is the response of the well-known discovery endpoint
+type discoveryResponse struct {
+	URL string `json:"url"`
+}
+
+// discoverer finds the URL of the build service for a domain
+type discoverer struct {
+	client    *http.Client
+	lookupTXT func(ctx context.Context, name string) ([]string, error)
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+func newDiscoverer(resolver *net.Resolver, dial DialFunc) *discoverer {
+	client := http.DefaultClient
+	if dial != nil {
+		client = newHTTPClient(nil, dial)
+	}
+
+	return &discoverer{
+		client:    client,
+		lookupTXT: resolver.LookupTXT,
+		lookupSRV: resolver.LookupSRV,
+	}
+}
+
+// discover returns the URL of the build service for the domain, trying in order:
+//   - the well-known endpoint https://<domain>/.well-known/k6build, returning {"url": "<url>"}
+//   - a TXT record _k6build.<domain> with the value "url=<url>"
+//   - a SRV record _k6build._tcp.<domain>, using https://<target>:<port> as URL
+//
+// The domain can be given as an URL (e.g. http://localhost:8080) to use a different scheme or
+// port for the well-known endpoint. In this case, the DNS records are looked up for its host.
+func (d *discoverer) discover(ctx context.Context, domain string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+
+	base := domain
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	parsed, err := url.Parse(base)
+	if err != nil {
+		return "", NewWrappedError(ErrDiscovery, err)
+	}
+	host := parsed.Hostname()
+
+	errs := []error{}
+
+	srvURL, err := d.fromWellKnown(ctx, base+discoveryPath)
+	if err == nil {
+		return srvURL, nil
+	}
+	errs = append(errs, fmt.Errorf("well-known endpoint: %w", err))
+
+	srvURL, err = d.fromTXT(ctx, host)
+	if err == nil {
+		return srvURL, nil
+	}
+	errs = append(errs, fmt.Errorf("TXT record: %w", err))
+
+	srvURL, err = d.fromSRV(ctx, host)
+	if err == nil {
+		return srvURL, nil
+	}
+	errs = append(errs, fmt.Errorf("SRV record: %w", err))
+
+	return "", NewWrappedError(ErrDiscovery, errors.Join(errs...))
+}
+
+func (d *discoverer) fromWellKnown(ctx context.Context, endpoint string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %s", resp.Status)
+	}
+
+	discovered := discoveryResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&discovered); err != nil {
+		return "", err
+	}
+
+	if err := validateURL(discovered.URL, "http", "https"); err != nil {
+		return "", err
+	}
+
+	return discovered.URL, nil
+}
+
+func (d *discoverer) fromTXT(ctx context.Context, host string) (string, error) {
+	records, err := d.lookupTXT(ctx, "_"+discoveryService+"."+host)
+	if err != nil {
+		return "", err
+	}
+
+	for _, record := range records {
+		value, found := strings.CutPrefix(strings.TrimSpace(record), discoveryTXTPrefix)
+		if !found {
+			continue
+		}
+		if err := validateURL(value, "http", "https"); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+
+	return "", errors.New("no record found")
+}
+
+func (d *discoverer) fromSRV(ctx context.Context, host string) (string, error) {
+	// records are returned sorted by priority and randomized by weight
+	_, records, err := d.lookupSRV(ctx, discoveryService, "tcp", host)
+	if err != nil {
+		return "", err
+	}
+
+	if len(records) == 0 {
+		return "", errors.New("no record found")
+	}
+
+	target := strings.TrimSuffix(records[0].Target, ".")
+	return fmt.Sprintf("https://%s", net.JoinHostPort(target, fmt.Sprint(records[0].Port))), nil
+}
@@ -0,0 +1,100 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscovery(t *testing.T) {
+	t.Parallel()
+
+	wellKnown := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != discoveryPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"url": "https://wellknown.example.com"}`))
+	}))
+	t.Cleanup(wellKnown.Close)
+
+	// a domain without well-known endpoint
+	noWellKnown := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(noWellKnown.Close)
+
+	noRecords := func(context.Context, string) ([]string, error) {
+		return nil, &net.DNSError{Err: "no such host", IsNotFound: true}
+	}
+	noSRV := func(context.Context, string, string, string) (string, []*net.SRV, error) {
+		return "", nil, &net.DNSError{Err: "no such host", IsNotFound: true}
+	}
+
+	testCases := []struct {
+		title     string
+		domain    string
+		lookupTXT func(context.Context, string) ([]string, error)
+		lookupSRV func(context.Context, string, string, string) (string, []*net.SRV, error)
+		expectURL string
+		expectErr error
+	}{
+		{
+			title:     "well-known endpoint",
+			domain:    wellKnown.URL,
+			lookupTXT: noRecords,
+			lookupSRV: noSRV,
+			expectURL: "https://wellknown.example.com",
+		},
+		{
+			title:  "TXT record",
+			domain: noWellKnown.URL,
+			lookupTXT: func(_ context.Context, name string) ([]string, error) {
+				if name != "_k6build.127.0.0.1" {
+					return nil, &net.DNSError{Err: "no such host", IsNotFound: true}
+				}
+				return []string{"v=spf1", "url=https://txt.example.com"}, nil
+			},
+			lookupSRV: noSRV,
+			expectURL: "https://txt.example.com",
+		},
+		{
+			title:     "SRV record",
+			domain:    noWellKnown.URL,
+			lookupTXT: noRecords,
+			lookupSRV: func(context.Context, string, string, string) (string, []*net.SRV, error) {
+				return "", []*net.SRV{{Target: "srv.example.com.", Port: 8443}}, nil
+			},
+			expectURL: "https://srv.example.com:8443",
+		},
+		{
+			title:     "nothing found",
+			domain:    noWellKnown.URL,
+			lookupTXT: noRecords,
+			lookupSRV: noSRV,
+			expectErr: ErrDiscovery,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			d := &discoverer{
+				client:    http.DefaultClient,
+				lookupTXT: tc.lookupTXT,
+				lookupSRV: tc.lookupSRV,
+			}
+
+			url, err := d.discover(context.TODO(), tc.domain)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			if url != tc.expectURL {
+				t.Fatalf("expected %q got %q", tc.expectURL, url)
+			}
+		})
+	}
+}
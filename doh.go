@@ -0,0 +1,193 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// dnsMessageType is the media type of the DNS messages exchanged with DNS over HTTPS endpoints
+	dnsMessageType = "application/dns-message"
+	// maxDNSMessageSize maximum size of a DNS message
+	maxDNSMessageSize = 65535
+	// dohTimeout maximum time for a DNS over HTTPS request if the resolver doesn't set a deadline
+	dohTimeout = 10 * time.Second
+)
+
+// NewDoHResolver returns a resolver that resolves the host names using the DNS over HTTPS (RFC 8484)
+// endpoint (e.g. "https://1.1.1.1/dns-query"), for environments where the system's DNS cannot
+// resolve the build service or the artifact store but a DNS over HTTPS endpoint is reachable.
+// It can be used as [Config.Resolver]. See also [Config.DNSOverHTTPSURL].
+//
+// The host of the endpoint is resolved using the system's resolver, so it should be an IP
+// address or a name the system can resolve. Requests to the endpoint use the proxy in the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func NewDoHResolver(dohURL string) (*net.Resolver, error) {
+	if err := validateURL(dohURL, "https"); err != nil {
+		return nil, NewWrappedError(ErrConfig, fmt.Errorf("DNS over HTTPS URL %w", err))
+	}
+
+	return newDoHResolver(dohURL, newHTTPClient(nil, nil)), nil
+}
+
+// newDoHResolver returns a resolver that sends the DNS queries to the endpoint using the client
+func newDoHResolver(dohURL string, client *http.Client) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: client, url: dohURL}, nil
+		},
+	}
+}
+
+// withDoHResolver returns the configuration with a resolver for the DNS over HTTPS endpoint,
+// if one is configured and no resolver is set
+func withDoHResolver(config Config) (Config, error) {
+	if config.DNSOverHTTPSURL == "" || config.Resolver != nil {
+		return config, nil
+	}
+
+	resolver, err := NewDoHResolver(config.DNSOverHTTPSURL)
+	if err != nil {
+		return config, err
+	}
+	config.Resolver = resolver
+
+	return config, nil
+}
+
+// dohConn is a connection to a DNS server used by the resolver, which sends each DNS query
+// written to it to the DNS over HTTPS endpoint and returns the response when read.
+// It behaves as a stream connection, so the messages are prefixed with their length.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	url      string
+	mutex    sync.Mutex
+	query    bytes.Buffer
+	response bytes.Buffer
+	deadline time.Time
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.query.Write(b)
+
+	// exchange the complete queries
+	for c.query.Len() >= 2 {
+		size := int(binary.BigEndian.Uint16(c.query.Bytes()))
+		if c.query.Len() < size+2 {
+			break
+		}
+		c.query.Next(2)
+
+		response, err := c.exchange(c.query.Next(size))
+		if err != nil {
+			return 0, err
+		}
+
+		_ = binary.Write(&c.response, binary.BigEndian, uint16(len(response))) //nolint:gosec
+		c.response.Write(response)
+	}
+
+	return len(b), nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.response.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	return c.response.Read(b)
+}
+
+// exchange sends the query to the endpoint and returns its response
+func (c *dohConn) exchange(query []byte) ([]byte, error) {
+	deadline := c.deadline
+	if deadline.IsZero() {
+		deadline = time.Now().Add(dohTimeout)
+	}
+
+	ctx, cancel := context.WithDeadline(c.ctx, deadline)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dnsMessageType)
+	req.Header.Set("Accept", dnsMessageType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS over HTTPS: unexpected status %s", resp.Status)
+	}
+
+	response, err := io.ReadAll(io.LimitReader(resp.Body, maxDNSMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(response) > maxDNSMessageSize {
+		return nil, errors.New("DNS over HTTPS: response too large")
+	}
+
+	return response, nil
+}
+
+func (c *dohConn) Close() error {
+	return nil
+}
+
+func (c *dohConn) LocalAddr() net.Addr {
+	return dohAddr(c.url)
+}
+
+func (c *dohConn) RemoteAddr() net.Addr {
+	return dohAddr(c.url)
+}
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.deadline = t
+
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func (c *dohConn) SetWriteDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+// dohAddr is the address of a DNS over HTTPS endpoint
+type dohAddr string
+
+func (a dohAddr) Network() string {
+	return "https"
+}
+
+func (a dohAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,115 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// dnsAnswer returns the response to a DNS query with an A record with the given address for the
+// host, and no records for other hosts or types
+func dnsAnswer(query []byte, host string, ip [4]byte) []byte {
+	// the question starts after the 12 bytes of the header and ends after the name and
+	// its type and class
+	end := 12
+	name := ""
+	for query[end] != 0 {
+		size := int(query[end])
+		name += string(query[end+1:end+1+size]) + "."
+		end += size + 1
+	}
+	end += 5
+	qtype := binary.BigEndian.Uint16(query[end-4:])
+
+	response := bytes.Buffer{}
+	response.Write(query[:2])                                     // id
+	_ = binary.Write(&response, binary.BigEndian, uint16(0x8180)) // response, recursion available
+	_ = binary.Write(&response, binary.BigEndian, uint16(1))      // questions
+
+	if name != host+"." || qtype != 1 {
+		response.Write(make([]byte, 6)) // no answers
+		response.Write(query[12:end])
+		return response.Bytes()
+	}
+
+	_ = binary.Write(&response, binary.BigEndian, []uint16{1, 0, 0}) // answers
+	response.Write(query[12:end])
+	_ = binary.Write(&response, binary.BigEndian, []uint16{0xc00c, 1, 1}) // name, type A, class IN
+	_ = binary.Write(&response, binary.BigEndian, uint32(60))             // ttl
+	_ = binary.Write(&response, binary.BigEndian, uint16(4))              // length
+	response.Write(ip[:])
+
+	return response.Bytes()
+}
+
+func TestDoHResolver(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != dnsMessageType {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", dnsMessageType)
+		_, _ = w.Write(dnsAnswer(query, "build.internal", [4]byte{10, 0, 0, 5}))
+	}))
+	t.Cleanup(srv.Close)
+
+	resolver := newDoHResolver(srv.URL+"/dns-query", srv.Client())
+
+	testCases := []struct {
+		title     string
+		host      string
+		expect    string
+		expectErr bool
+	}{
+		{title: "internal host", host: "build.internal", expect: "10.0.0.5"},
+		{title: "unknown host", host: "other.internal", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			addrs, err := resolver.LookupHost(context.TODO(), tc.host)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %v got %v", tc.expectErr, err)
+			}
+
+			if !tc.expectErr && (len(addrs) != 1 || addrs[0] != tc.expect) {
+				t.Fatalf("expected %s got %v", tc.expect, addrs)
+			}
+		})
+	}
+}
+
+func TestNewDoHResolver(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title     string
+		url       string
+		expectErr error
+	}{
+		{title: "https endpoint", url: "https://1.1.1.1/dns-query", expectErr: nil},
+		{title: "plain http", url: "http://1.1.1.1/dns-query", expectErr: ErrConfig},
+		{title: "no host", url: "https:///dns-query", expectErr: ErrConfig},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewDoHResolver(tc.url)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -7,8 +7,9 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,6 +18,8 @@ const (
 	DefaultRetries = 3
 	// DefaultBackoff initial backoff time between retries. It is incremented exponentially between retries.
 	DefaultBackoff = 1 * time.Second
+	// DefaultChecksumRetries number of retries for downloads with a checksum mismatch
+	DefaultChecksumRetries = 2
 )
 
 // DownloadConfig defines the configuration for downloading files
@@ -33,42 +36,88 @@ type DownloadConfig struct {
 	// DownloadHeaders HTTP headers for the download requests
 	Headers map[string]string
 	// ProxyURL URL to proxy for downloading binaries
+	// If not specified the value of K6_DOWNLOAD_PROXY is used.
+	// If no value is defined, the proxy is selected using the Proxy function or, if not set,
+	// from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
 	ProxyURL string
+	// NoProxy comma-separated list of hosts, domains (e.g. ".example.com"), IP addresses or CIDR
+	// blocks that are downloaded from without using the proxy specified in ProxyURL
+	NoProxy string
+	// Proxy selects the proxy for each download request, for example, using a proxy auto-config
+	// (PAC) file. Ignored if a proxy URL is specified (see ProxyURL)
+	Proxy ProxyFunc `json:"-"`
 	// Retries number of retries for download requests. Default to 3
 	Retries int
 	// Backoff initial backoff time between retries. Default to 1s
 	// It is incremented exponentially between retries: 1s, 2s, 4s...
 	Backoff time.Duration
+	// ChecksumRetries number of times a download is retried if the checksum of the
+	// downloaded binary does not match the expected one. Default to 2
+	ChecksumRetries int
+	// BufferSize size of the buffers used for writing the downloaded binaries. Buffers are
+	// reused across downloads. Default to 32KiB
+	BufferSize int
+	// ArchiveMember name of the binary in artifacts served as tar.gz or zip archives, which
+	// are detected and unpacked after being downloaded. The checksum of the artifact is verified
+	// against the extracted binary. Entries are matched by their full name or base name.
+	// Default to "k6" ("k6.exe" on windows)
+	ArchiveMember string
+	// RequestSigner if set, is called with each download request before it is sent (including
+	// retries), for signing it, for example, for artifact stores that require AWS Signature
+	// Version 4 (see [NewSigV4Signer]). If it returns an error, the download fails
+	RequestSigner func(*http.Request) error `json:"-"`
+	// TimeoutFactor if set, each download is given a timeout adapted to the throughput observed in
+	// previous downloads from the same host: the time expected for the size of the download, using
+	// the moving average of the throughput, multiplied by this factor (e.g. 3). Large binaries on
+	// slow links are given more time, while stalled downloads of small binaries fail quickly.
+	// Downloads from hosts without observed throughput have no timeout. Default to 0 (no timeout)
+	TimeoutFactor float64
+	// MinTimeout minimum timeout of a download when TimeoutFactor is set. Default to 30s
+	MinTimeout time.Duration
+	// StallTimeout if set, a download that receives no content for this time is aborted and
+	// retried (up to Retries times), resuming from the content already received. Unlike the
+	// timeouts set with TimeoutFactor, it doesn't limit the duration of downloads that progress.
+	// Default to 0 (no stall detection)
+	StallTimeout time.Duration
+	// Stages pipeline stages the content of the binaries goes through as it is downloaded, in
+	// order, for example, for verifying its signature or decompressing it. See [DownloadStage]
+	Stages []DownloadStage `json:"-"`
 }
 
 // downloader is a utility for downloading files
 type downloader struct {
-	client   *http.Client
-	auth     string
-	authType string
-	headers  map[string]string
-	retries  int
-	backoff  time.Duration
+	client          *http.Client
+	auth            string
+	authType        string
+	headers         map[string]string
+	retries         int
+	backoff         time.Duration
+	checksumRetries int
+	buffers         *bufferPool
+	archiveMember   string
+	signer          func(*http.Request) error
+	timeoutFactor   float64
+	minTimeout      time.Duration
+	throughput      *throughputTracker
+	stallTimeout    time.Duration
+	stages          []DownloadStage
+	denied          bool
 }
 
-// newDownloader returns a new Downloader
-func newDownloader(config DownloadConfig) (*downloader, error) {
-	httpClient := http.DefaultClient
-
+// newDownloader returns a new Downloader that connects using the dial function, if any
+func newDownloader(config DownloadConfig, dial DialFunc) (*downloader, error) {
 	proxyURL := config.ProxyURL
-	if proxyURL == "" {
+	if proxyURL == "" && config.Proxy == nil {
 		proxyURL = os.Getenv("K6_DOWNLOAD_PROXY")
 	}
-	if proxyURL != "" {
-		parsed, err := url.Parse(proxyURL)
-		if err != nil {
-			return nil, NewWrappedError(ErrConfig, err)
-		}
-		proxy := http.ProxyURL(parsed)
-		transport := &http.Transport{Proxy: proxy}
-		httpClient = &http.Client{Transport: transport}
+
+	proxy, err := selectProxy(proxyURL, config.NoProxy, config.Proxy)
+	if err != nil {
+		return nil, NewWrappedError(ErrConfig, err)
 	}
 
+	httpClient := newHTTPClient(proxy, dial)
+
 	downloadAuth := config.Authorization
 	if downloadAuth == "" {
 		downloadAuth = os.Getenv("K6_DOWNLOAD_AUTH")
@@ -79,20 +128,50 @@ func newDownloader(config DownloadConfig) (*downloader, error) {
 		downloadAuthType = "Bearer"
 	}
 
+	checksumRetries := config.ChecksumRetries
+	if checksumRetries == 0 {
+		checksumRetries = DefaultChecksumRetries
+	}
+
+	minTimeout := config.MinTimeout
+	if minTimeout == 0 {
+		minTimeout = DefaultMinDownloadTimeout
+	}
+
+	archiveMember := config.ArchiveMember
+	if archiveMember == "" {
+		archiveMember = k6Binary
+	}
+
 	return &downloader{
-		client:   httpClient,
-		auth:     downloadAuth,
-		authType: downloadAuthType,
-		headers:  config.Headers,
-		retries:  config.Retries,
-		backoff:  config.Backoff,
+		client:          httpClient,
+		auth:            downloadAuth,
+		authType:        downloadAuthType,
+		headers:         config.Headers,
+		retries:         config.Retries,
+		backoff:         config.Backoff,
+		checksumRetries: checksumRetries,
+		buffers:         newBufferPool(config.BufferSize),
+		archiveMember:   archiveMember,
+		signer:          config.RequestSigner,
+		timeoutFactor:   config.TimeoutFactor,
+		minTimeout:      minTimeout,
+		throughput:      newThroughputTracker(),
+		stallTimeout:    config.StallTimeout,
+		stages:          config.Stages,
 	}, nil
 }
 
-func (d *downloader) download(ctx context.Context, from string, dest io.Writer) error {
+// newRequest returns a request for downloading from the URL. Returns an [ErrNetworkDisabled]
+// error if the network policy denies accessing the network
+func (d *downloader) newRequest(ctx context.Context, from string) (*http.Request, error) {
+	if d.denied {
+		return nil, ErrNetworkDisabled
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, from, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// add authorization header "Authorization: <type> <auth>"
@@ -100,21 +179,97 @@ func (d *downloader) download(ctx context.Context, from string, dest io.Writer)
 		req.Header.Add("Authorization", fmt.Sprintf("%s %s", d.authType, d.auth))
 	}
 
+	// stores may serve the artifacts as archives
+	req.Header.Set("Accept", acceptedFormats)
+
 	// add custom headers
 	for h, v := range d.headers {
 		req.Header.Add(h, v)
 	}
 
+	return req, nil
+}
+
+func (d *downloader) download(ctx context.Context, from string, dest io.Writer) error {
+	// the download is cancelled if it exceeds its timeout or stalls
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	req, err := d.newRequest(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	// request only the remainder if the destination has part of the content
+	resumer, resumable := dest.(resumableWriter)
+	offset := int64(0)
+	if resumable {
+		offset = setRange(req, resumer)
+	}
+
+	resp, err := d.do(req)
+	if err != nil {
+		return classifyDownloadError(err)
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	size := resp.ContentLength
+	switch {
+	case resp.StatusCode == http.StatusOK && offset > 0:
+		// the content changed or the server doesn't support ranges
+		if err = resumer.restart(); err != nil {
+			return err
+		}
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && rangeStart(resp) == offset:
+		if size >= 0 {
+			size += offset
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the partial content doesn't match the content, start over
+		_ = resp.Body.Close()
+		if err = resumer.restart(); err != nil {
+			return err
+		}
+		return d.download(ctx, from, dest)
+	default:
+		return classifyStatus(resp.StatusCode, newDownloadError(resp))
+	}
+
+	// the size of the download is reported if the destination keeps track of the progress
+	if sized, ok := dest.(interface{ setSize(size int64) }); ok {
+		sized.setSize(size)
+	}
+
+	// the validators are reported if the destination keeps track of them
+	if validated, ok := dest.(interface{ setValidators(v validators) }); ok {
+		validated.setValidators(responseValidators(resp))
+	}
+
+	writer := &trackingWriter{writer: dest}
+	err = d.copyBody(cancel, resp, writer)
+
+	// errors writing the binary are not download errors
+	if err != nil && !errors.Is(err, writer.err) {
+		if cause := context.Cause(ctx); errors.Is(cause, errDownloadTimeout) || stalled(cause) {
+			return NewWrappedError(ErrDownloadTemporary, cause)
+		}
+		return classifyDownloadError(err)
+	}
+
+	return err
+}
+
+// do sends the request, retrying with an exponential backoff if it fails with a temporary error
+func (d *downloader) do(req *http.Request) (*http.Response, error) {
 	var (
 		resp    *http.Response
+		err     error
 		backoff = d.backoff
-		retries = d.retries
+		retries = d.maxRetries()
 	)
 
-	if retries == 0 {
-		retries = DefaultRetries
-	}
-
 	if backoff == 0 {
 		backoff = DefaultBackoff
 	}
@@ -122,10 +277,14 @@ func (d *downloader) download(ctx context.Context, from string, dest io.Writer)
 	// try at least once
 	for {
 		// it is safe to reuse the request as it doesn't have a body
-		resp, err = d.client.Do(req)
+		resp, err = d.send(req)
 
 		if retries == 0 || !shouldRetry(err, resp) {
-			break
+			return resp, err
+		}
+
+		if resp != nil {
+			_ = resp.Body.Close()
 		}
 
 		time.Sleep(backoff)
@@ -134,20 +293,167 @@ func (d *downloader) download(ctx context.Context, from string, dest io.Writer)
 		backoff *= 2
 		retries--
 	}
+}
+
+// maxRetries returns the number of retries for download requests
+func (d *downloader) maxRetries() int {
+	if d.retries == 0 {
+		return DefaultRetries
+	}
 
+	return d.retries
+}
+
+// send signs the request, if a signer is configured, and sends it
+func (d *downloader) send(req *http.Request) (*http.Response, error) {
+	if d.signer != nil {
+		if err := d.signer(req); err != nil {
+			return nil, fmt.Errorf("signing request: %w", err)
+		}
+	}
+
+	return d.client.Do(req)
+}
+
+// maxErrorBody is the maximum length of the excerpt of the response body kept in a DownloadError
+const maxErrorBody = 512
+
+// errorHeaders are the response headers kept in a DownloadError
+var errorHeaders = []string{ //nolint:gochecknoglobals
+	"Retry-After",
+	"X-Request-Id",
+	"X-Amz-Request-Id",
+	"X-Amz-Cf-Id",
+	"X-Cache",
+	"Cf-Ray",
+	"Www-Authenticate",
+}
+
+// DownloadError describes an unsuccessful response to a download request.
+// It can be obtained from the errors returned by the provider using errors.As
+type DownloadError struct {
+	// StatusCode of the response
+	StatusCode int
+	// Status of the response. e.g. "503 Service Unavailable"
+	Status string
+	// Headers of the response useful for diagnosing the error, such as Retry-After
+	// and request IDs
+	Headers map[string]string
+	// Body excerpt of the response body (up to 512 bytes)
+	Body string
+}
+
+// newDownloadError returns a DownloadError from a response, reading a bounded excerpt of its body
+func newDownloadError(resp *http.Response) *DownloadError {
+	headers := map[string]string{}
+	for _, header := range errorHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			headers[header] = value
+		}
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	return &DownloadError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Headers:    headers,
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
+
+// Error returns the error's status and body excerpt
+func (e *DownloadError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("status %s", e.Status)
+	}
+	return fmt.Sprintf("status %s: %s", e.Status, e.Body)
+}
+
+// RetryAfter returns the delay requested by the server in the Retry-After header, if any
+func (e *DownloadError) RetryAfter() (time.Duration, bool) {
+	value, found := e.Headers["Retry-After"]
+	if !found {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+
+	return 0, false
+}
+
+// trackingWriter keeps track of the errors writing to a writer, so they can be
+// distinguished from errors reading the response
+type trackingWriter struct {
+	writer io.Writer
+	err    error
+}
+
+func (w *trackingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
 	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// classifyStatus classifies an error response as [ErrDownloadTemporary] if retrying the
+// request later may succeed or as [ErrDownloadPermanent] otherwise
+func classifyStatus(status int, err error) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return NewWrappedError(ErrDownloadTemporary, NewWrappedError(ErrQuotaExceeded, err))
+	case status >= http.StatusInternalServerError:
+		return NewWrappedError(ErrDownloadTemporary, NewWrappedError(ErrServiceUnavailable, err))
+	case status == http.StatusRequestTimeout:
+		return NewWrappedError(ErrDownloadTemporary, err)
+	default:
+		return NewWrappedError(ErrDownloadPermanent, err)
+	}
+}
+
+// classifyDownloadError classifies an error sending the request or receiving the response
+// as [ErrDownloadTemporary] if retrying the request later may succeed or as
+// [ErrDownloadPermanent] otherwise. Errors caused by the cancellation of the request
+// are not classified.
+func classifyDownloadError(err error) error {
+	if errors.Is(err, context.Canceled) {
 		return err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("status %s", resp.Status)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return NewWrappedError(ErrDownloadPermanent, err)
 	}
 
-	defer resp.Body.Close() //nolint:errcheck
+	if isOffline(err) {
+		return NewWrappedError(ErrDownloadTemporary, NewWrappedError(ErrOffline, err))
+	}
+
+	// failed or interrupted connections
+	var opErr *net.OpError
+	if errors.As(err, &opErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		isTimeout(err) {
+		return NewWrappedError(ErrDownloadTemporary, err)
+	}
 
-	_, err = io.Copy(dest, resp.Body)
+	// other errors such as an invalid URL, unsupported scheme or invalid certificates
+	return NewWrappedError(ErrDownloadPermanent, err)
+}
 
-	return err
+// isTimeout returns true if the error is a network timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
 
 // shouldRetry returns true if the error or response indicates that the request should be retried
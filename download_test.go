@@ -0,0 +1,122 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadErrorClassification(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title     string
+		status    int
+		url       string
+		expectErr error
+	}{
+		{
+			title:     "not found",
+			status:    http.StatusNotFound,
+			expectErr: ErrDownloadPermanent,
+		},
+		{
+			title:     "unauthorized",
+			status:    http.StatusUnauthorized,
+			expectErr: ErrDownloadPermanent,
+		},
+		{
+			title:     "service unavailable",
+			status:    http.StatusServiceUnavailable,
+			expectErr: ErrDownloadTemporary,
+		},
+		{
+			title:     "too many requests",
+			status:    http.StatusTooManyRequests,
+			expectErr: ErrDownloadTemporary,
+		},
+		{
+			title:     "connection refused",
+			url:       "http://127.0.0.1:1",
+			expectErr: ErrDownloadTemporary,
+		},
+		{
+			title:     "unsupported scheme",
+			url:       "ftp://127.0.0.1/k6",
+			expectErr: ErrDownloadPermanent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			url := tc.url
+			if url == "" {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(tc.status)
+				}))
+				t.Cleanup(srv.Close)
+				url = srv.URL
+			}
+
+			downloader, err := newDownloader(DownloadConfig{Retries: 1, Backoff: time.Millisecond}, nil)
+			if err != nil {
+				t.Fatalf("creating downloader %v", err)
+			}
+
+			err = downloader.download(context.TODO(), url, &bytes.Buffer{})
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestDownloadError(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.Header().Set("X-Request-Id", "request-id")
+		w.Header().Set("X-Other", "other")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte("rate limited\n" + strings.Repeat("x", 2*maxErrorBody)))
+	}))
+	t.Cleanup(srv.Close)
+
+	downloader, err := newDownloader(DownloadConfig{}, nil)
+	if err != nil {
+		t.Fatalf("creating downloader %v", err)
+	}
+
+	err = downloader.download(context.TODO(), srv.URL, &bytes.Buffer{})
+
+	var downloadErr *DownloadError
+	if !errors.As(NewWrappedError(ErrDownload, err), &downloadErr) {
+		t.Fatalf("expected DownloadError got %v", err)
+	}
+
+	if downloadErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d got %d", http.StatusTooManyRequests, downloadErr.StatusCode)
+	}
+
+	if len(downloadErr.Body) > maxErrorBody || !strings.HasPrefix(downloadErr.Body, "rate limited") {
+		t.Fatalf("unexpected body excerpt %q", downloadErr.Body)
+	}
+
+	expectedHeaders := map[string]string{"Retry-After": "30", "X-Request-Id": "request-id"}
+	if !reflect.DeepEqual(downloadErr.Headers, expectedHeaders) {
+		t.Fatalf("expected headers %v got %v", expectedHeaders, downloadErr.Headers)
+	}
+
+	if delay, ok := downloadErr.RetryAfter(); !ok || delay != 30*time.Second {
+		t.Fatalf("expected retry after 30s got %v", delay)
+	}
+}
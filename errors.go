@@ -0,0 +1,320 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrorChain returns the chain of errors obtained by successively unwrapping err,
+// starting with err itself and ending with its root cause.
+//
+//	Example:
+//	var (
+//	    root  = errors.New("root cause")
+//	    cause = NewWrappedError(ErrDownload, root)
+//	    err   = NewWrappedError(ErrBinary, cause)
+//	)
+//
+//	ErrorChain(err) // returns [err, cause, root]
+func ErrorChain(err error) []error {
+	chain := []error{}
+	for err != nil {
+		chain = append(chain, err)
+		err = errors.Unwrap(err)
+	}
+	return chain
+}
+
+// RootCause returns the last error in the chain of errors obtained by successively
+// unwrapping err. Returns nil if err is nil.
+func RootCause(err error) error {
+	chain := ErrorChain(err)
+	if len(chain) == 0 {
+		return nil
+	}
+	return chain[len(chain)-1]
+}
+
+// invalidParameters checks if the error returned by the build service reports invalid
+// build parameters, returning the reason reported by the service.
+//
+// The errors are received from the build service as [WrappedError] which only preserve
+// the error's message, so the error must be compared using it.
+func invalidParameters(err error) (error, bool) {
+	for _, e := range ErrorChain(err) {
+		wrapped, ok := e.(WrappedError) //nolint:errorlint
+		if !ok {
+			if e.Error() == ErrInvalidParameters.Error() {
+				return e, true
+			}
+			continue
+		}
+
+		if wrapped.Err == nil || wrapped.Err.Error() != ErrInvalidParameters.Error() {
+			continue
+		}
+
+		if wrapped.Reason == nil {
+			return wrapped, true
+		}
+		return wrapped.Reason, true
+	}
+
+	return nil, false
+}
+
+// ErrorCode is a machine-readable identifier of the category of an error
+type ErrorCode string
+
+const (
+	// CodeUnknown the error doesn't belong to any known category
+	CodeUnknown ErrorCode = ""
+	// CodeChecksumMismatch see [ErrChecksumMismatch]
+	CodeChecksumMismatch ErrorCode = "checksum_mismatch"
+	// CodeIntegrity see [ErrIntegrity]
+	CodeIntegrity ErrorCode = "integrity"
+	// CodeAttestation see [ErrAttestation]
+	CodeAttestation ErrorCode = "attestation"
+	// CodeVersionMismatch see [ErrVersionMismatch]
+	CodeVersionMismatch ErrorCode = "version_mismatch"
+	// CodeDeviceCodeDenied see [ErrDeviceCodeDenied]
+	CodeDeviceCodeDenied ErrorCode = "device_code_denied"
+	// CodeNotInitialized see [ErrNotInitialized]
+	CodeNotInitialized ErrorCode = "not_initialized"
+	// CodePlatformUnsupported see [ErrPlatformUnsupported]
+	CodePlatformUnsupported ErrorCode = "platform_unsupported"
+	// CodeInvalidParameters see [ErrInvalidParameters]
+	CodeInvalidParameters ErrorCode = "invalid_parameters"
+	// CodeQuotaExceeded see [ErrQuotaExceeded]
+	CodeQuotaExceeded ErrorCode = "quota_exceeded"
+	// CodeNetworkDisabled see [ErrNetworkDisabled]
+	CodeNetworkDisabled ErrorCode = "network_disabled"
+	// CodeOffline see [ErrOffline]
+	CodeOffline ErrorCode = "offline"
+	// CodeServiceUnavailable see [ErrServiceUnavailable]
+	CodeServiceUnavailable ErrorCode = "service_unavailable"
+	// CodeLocked see [ErrLocked]
+	CodeLocked ErrorCode = "locked"
+	// CodeNoSpace see [ErrNoSpace]
+	CodeNoSpace ErrorCode = "no_space"
+	// CodeReadOnly see [ErrReadOnly]
+	CodeReadOnly ErrorCode = "read_only"
+	// CodeNoExec see [ErrNoExec]
+	CodeNoExec ErrorCode = "no_exec"
+	// CodeLockfile see [ErrLockfile]
+	CodeLockfile ErrorCode = "lockfile"
+	// CodeConfig see [ErrConfig]
+	CodeConfig ErrorCode = "config"
+	// CodeClosed see [ErrClosed]
+	CodeClosed ErrorCode = "closed"
+	// CodeDependencies see [ErrDependencies]
+	CodeDependencies ErrorCode = "dependencies"
+	// CodeDiscovery see [ErrDiscovery]
+	CodeDiscovery ErrorCode = "discovery"
+	// CodeDownload see [ErrDownload]
+	CodeDownload ErrorCode = "download"
+	// CodeBuild see [ErrBuild]
+	CodeBuild ErrorCode = "build"
+	// CodeBinary see [ErrBinary]
+	CodeBinary ErrorCode = "binary"
+	// CodePruningCache see [ErrPruningCache]
+	CodePruningCache ErrorCode = "pruning_cache"
+)
+
+// errorCodes maps the errors to their codes, from the most to the least specific,
+// as an error can belong to multiple categories (e.g. a checksum mismatch is a download error)
+var errorCodes = []struct { //nolint:gochecknoglobals
+	err  error
+	code ErrorCode
+}{
+	{ErrChecksumMismatch, CodeChecksumMismatch},
+	{ErrIntegrity, CodeIntegrity},
+	{ErrAttestation, CodeAttestation},
+	{ErrVersionMismatch, CodeVersionMismatch},
+	{ErrDeviceCodeDenied, CodeDeviceCodeDenied},
+	{ErrNotInitialized, CodeNotInitialized},
+	{ErrPlatformUnsupported, CodePlatformUnsupported},
+	{ErrInvalidParameters, CodeInvalidParameters},
+	{ErrQuotaExceeded, CodeQuotaExceeded},
+	{ErrNetworkDisabled, CodeNetworkDisabled},
+	{ErrOffline, CodeOffline},
+	{ErrServiceUnavailable, CodeServiceUnavailable},
+	{ErrLocked, CodeLocked},
+	{ErrNoSpace, CodeNoSpace},
+	{ErrReadOnly, CodeReadOnly},
+	{ErrNoExec, CodeNoExec},
+	{ErrLockfile, CodeLockfile},
+	{ErrConfig, CodeConfig},
+	{ErrClosed, CodeClosed},
+	{ErrDependencies, CodeDependencies},
+	{ErrDiscovery, CodeDiscovery},
+	{ErrDownload, CodeDownload},
+	{ErrBuild, CodeBuild},
+	{ErrBinary, CodeBinary},
+	{ErrPruningCache, CodePruningCache},
+}
+
+// ErrorDetails are the machine-readable details of an error returned by the provider
+type ErrorDetails struct {
+	// Code of the most specific category of the error
+	Code ErrorCode
+	// RetryAfter time to wait before retrying, if requested by the build service or the artifact store
+	RetryAfter time.Duration
+	// ArtifactID of the artifact the error refers to, if any
+	ArtifactID string
+}
+
+// Details returns the machine-readable details of an error returned by the provider.
+//
+//	Example:
+//	details := k6provider.Details(err)
+//	if details.Code == k6provider.CodeQuotaExceeded {
+//	    time.Sleep(details.RetryAfter)
+//	}
+func Details(err error) ErrorDetails {
+	details := ErrorDetails{}
+	if err == nil {
+		return details
+	}
+
+	for _, category := range errorCodes {
+		if errors.Is(err, category.err) {
+			details.Code = category.code
+			break
+		}
+	}
+
+	var retryAfter interface{ RetryAfter() (time.Duration, bool) }
+	if errors.As(err, &retryAfter) {
+		if delay, ok := retryAfter.RetryAfter(); ok && delay > 0 {
+			details.RetryAfter = delay
+		}
+	}
+
+	var artifactErr *artifactError
+	if errors.As(err, &artifactErr) {
+		details.ArtifactID = artifactErr.id
+	}
+
+	return details
+}
+
+// artifactError records the artifact an error refers to
+type artifactError struct {
+	id  string
+	err error
+}
+
+func (e *artifactError) Error() string {
+	return e.err.Error()
+}
+
+func (e *artifactError) Unwrap() error {
+	return e.err
+}
+
+// withArtifact records the artifact the error refers to, keeping the error's category and message
+func withArtifact(err error, id string) error {
+	if err == nil || id == "" {
+		return err
+	}
+
+	if wrapped, ok := err.(WrappedError); ok { //nolint:errorlint
+		return NewWrappedError(wrapped.Err, &artifactError{id: id, err: wrapped.Reason})
+	}
+
+	return &artifactError{id: id, err: err}
+}
+
+// classifyServiceError wraps an error communicating with the build service in the category of
+// the failure: [ErrQuotaExceeded], [ErrOffline] or [ErrServiceUnavailable].
+// Other errors are returned unchanged.
+func classifyServiceError(err error) error {
+	// the failure is caused by the caller
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	status := responseStatus(err)
+	switch {
+	case status == http.StatusTooManyRequests:
+		return NewWrappedError(ErrQuotaExceeded, err)
+	case isOffline(err):
+		return NewWrappedError(ErrOffline, err)
+	case status >= http.StatusInternalServerError, status == 0 && isUnreachable(err):
+		return NewWrappedError(ErrServiceUnavailable, err)
+	default:
+		return err
+	}
+}
+
+// responseStatus returns the status of the unsuccessful response reported by the error,
+// if any. The build service client reports them as errors with the response's status
+// (e.g. "503 Service Unavailable")
+func responseStatus(err error) int {
+	status := 0
+	walkErrors(err, func(e error) bool {
+		code, _, found := strings.Cut(e.Error(), " ")
+		if !found || len(code) != 3 {
+			return false
+		}
+		if value, convErr := strconv.Atoi(code); convErr == nil && value >= 100 {
+			status = value
+			return true
+		}
+		return false
+	})
+
+	return status
+}
+
+// isOffline returns true if the error was caused by the network not being available
+func isOffline(err error) bool {
+	if errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETDOWN) {
+		return true
+	}
+
+	// the name exists, but could not be resolved
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && !dnsErr.IsNotFound
+}
+
+// isUnreachable returns true if the error was caused by a failure connecting to the service
+func isUnreachable(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || isTimeout(err)
+}
+
+// walkErrors calls the function for the error and the errors it wraps, including those joined,
+// until it returns true
+func walkErrors(err error, visit func(error) bool) bool {
+	if err == nil {
+		return false
+	}
+
+	if visit(err) {
+		return true
+	}
+
+	switch wrapped := err.(type) { //nolint:errorlint
+	case interface{ Unwrap() []error }:
+		for _, e := range wrapped.Unwrap() {
+			if walkErrors(e, visit) {
+				return true
+			}
+		}
+		return false
+	case interface{ Unwrap() error }:
+		return walkErrors(wrapped.Unwrap(), visit)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,199 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6build/pkg/api"
+	"github.com/grafana/k6deps"
+)
+
+func TestErrorChain(t *testing.T) {
+	t.Parallel()
+
+	root := errors.New("root cause")
+	cause := NewWrappedError(ErrDownload, root)
+	err := NewWrappedError(ErrBinary, cause)
+
+	chain := ErrorChain(err)
+	if len(chain) != 3 || chain[0] != err || chain[1] != cause || chain[2] != root { //nolint:errorlint
+		t.Fatalf("unexpected chain %v", chain)
+	}
+
+	if RootCause(err) != root { //nolint:errorlint
+		t.Fatalf("expected %v got %v", root, RootCause(err))
+	}
+
+	if RootCause(nil) != nil {
+		t.Fatalf("expected nil got %v", RootCause(nil))
+	}
+}
+
+func TestInvalidParameters(t *testing.T) {
+	t.Parallel()
+
+	reason := errors.New("unsupported extension k6/x/unknown")
+
+	testCases := []struct {
+		title        string
+		buildErr     error
+		expectErr    error
+		expectReason error
+	}{
+		{
+			title: "invalid parameters from build service",
+			buildErr: NewWrappedError(
+				errors.New("request failed"),
+				NewWrappedError(errors.New("invalid build parameters"), reason),
+			),
+			expectErr:    ErrInvalidParameters,
+			expectReason: reason,
+		},
+		{
+			title:        "invalid parameters from local build service",
+			buildErr:     fmt.Errorf("building: %w", errors.New("invalid build parameters")),
+			expectErr:    ErrInvalidParameters,
+			expectReason: nil,
+		},
+		{
+			title:        "build failed",
+			buildErr:     NewWrappedError(errors.New("build failed"), reason),
+			expectErr:    ErrBuild,
+			expectReason: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				buildSrv: newTestBuildServices(
+					buildServiceFunc(
+						func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+							return k6build.Artifact{}, tc.buildErr
+						},
+					),
+				),
+				ctx: context.Background(),
+			}
+
+			_, err := provider.GetArtifact(context.TODO(), k6deps.Dependencies{})
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			if tc.expectReason != nil && errors.Unwrap(err) != tc.expectReason { //nolint:errorlint
+				t.Fatalf("expected reason %v got %v", tc.expectReason, errors.Unwrap(err))
+			}
+		})
+	}
+}
+
+func TestDetails(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title  string
+		err    error
+		expect ErrorDetails
+	}{
+		{
+			title:  "no error",
+			err:    nil,
+			expect: ErrorDetails{},
+		},
+		{
+			title:  "unknown error",
+			err:    errors.New("unknown"),
+			expect: ErrorDetails{Code: CodeUnknown},
+		},
+		{
+			title: "checksum mismatch",
+			err: withArtifact(
+				NewWrappedError(ErrDownload, NewWrappedError(ErrChecksumMismatch, errors.New("expected a got b"))),
+				"artifact",
+			),
+			expect: ErrorDetails{Code: CodeChecksumMismatch, ArtifactID: "artifact"},
+		},
+		{
+			title: "download quota exceeded",
+			err: NewWrappedError(ErrDownload, classifyStatus(
+				http.StatusTooManyRequests,
+				&DownloadError{StatusCode: http.StatusTooManyRequests, Headers: map[string]string{"Retry-After": "30"}},
+			)),
+			expect: ErrorDetails{Code: CodeQuotaExceeded, RetryAfter: 30 * time.Second},
+		},
+		{
+			title: "build service unavailable",
+			err: NewWrappedError(ErrBuild, classifyServiceError(
+				k6build.NewWrappedError(api.ErrRequestFailed, errors.New("503 Service Unavailable")),
+			)),
+			expect: ErrorDetails{Code: CodeServiceUnavailable},
+		},
+		{
+			title: "build service quota exceeded",
+			err: NewWrappedError(ErrBuild, classifyServiceError(
+				k6build.NewWrappedError(api.ErrRequestFailed, errors.New("429 Too Many Requests")),
+			)),
+			expect: ErrorDetails{Code: CodeQuotaExceeded},
+		},
+		{
+			title: "offline",
+			err: NewWrappedError(ErrBuild, classifyServiceError(
+				k6build.NewWrappedError(api.ErrRequestFailed, &net.OpError{Op: "dial", Err: syscall.ENETUNREACH}),
+			)),
+			expect: ErrorDetails{Code: CodeOffline},
+		},
+		{
+			title: "canceled build",
+			err: NewWrappedError(ErrBuild, classifyServiceError(
+				k6build.NewWrappedError(api.ErrRequestFailed, context.Canceled),
+			)),
+			expect: ErrorDetails{Code: CodeBuild},
+		},
+		{
+			title: "platform unsupported",
+			err: NewWrappedError(ErrBuild, errors.Join(
+				fmt.Errorf("http://localhost: %w", unsupportedPlatform("linux/riscv64", []string{"linux/amd64"})),
+			)),
+			expect: ErrorDetails{Code: CodePlatformUnsupported},
+		},
+		{
+			title:  "locked",
+			err:    NewWrappedError(ErrBinary, fmt.Errorf("%w: %w", ErrLocked, context.DeadlineExceeded)),
+			expect: ErrorDetails{Code: CodeLocked},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			details := Details(tc.err)
+			if details != tc.expect {
+				t.Fatalf("expected %+v got %+v", tc.expect, details)
+			}
+		})
+	}
+}
+
+func TestErrorCategoriesDistinct(t *testing.T) {
+	t.Parallel()
+
+	for _, category := range errorCodes {
+		err := NewWrappedError(category.err, errors.New("cause"))
+		for _, other := range errorCodes {
+			if other.code != category.code && errors.Is(err, other.err) {
+				t.Fatalf("expected %v not to be %v", category.err, other.err)
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package k6provider
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// eventBuffer is the number of events buffered for each subscriber. If a subscriber
+	// doesn't keep up, new events are dropped.
+	eventBuffer = 64
+	// progressInterval is the minimum time between download progress events
+	progressInterval = 100 * time.Millisecond
+)
+
+// EventType identifies the type of provisioning event
+type EventType string
+
+const (
+	// EventResolveStarted the artifact for a set of dependencies is requested to the build service
+	EventResolveStarted EventType = "resolve-started"
+	// EventCacheHit the binary for an artifact was found in the cache
+	EventCacheHit EventType = "cache-hit"
+	// EventDownloadStarted the download of the binary for an artifact started
+	EventDownloadStarted EventType = "download-started"
+	// EventDownloadProgress part of the binary for an artifact was downloaded
+	EventDownloadProgress EventType = "download-progress"
+	// EventDownloadCompleted the binary for an artifact was downloaded
+	EventDownloadCompleted EventType = "download-completed"
+	// EventUpdateAvailable a newer artifact satisfies the dependencies of a binary provided from
+	// the cache. ArtifactID is the id of the newer artifact. See [Config.UpdateCheck]
+	EventUpdateAvailable EventType = "update-available"
+	// EventPruneCompleted a prune of the cache completed. Err is set if it failed
+	EventPruneCompleted EventType = "prune-completed"
+	// EventError an error occurred. Err is set with the error
+	EventError EventType = "error"
+)
+
+// Event describes a step in the provisioning of a binary
+type Event struct {
+	// Type of the event
+	Type EventType
+	// Time the event occurred
+	Time time.Time
+	// ArtifactID id of the artifact, if the event refers to an artifact
+	ArtifactID string
+	// Downloaded number of bytes downloaded, for download events
+	Downloaded int64
+	// Size of the binary, for download events, if known. -1 otherwise
+	Size int64
+	// Err error, for error events
+	Err error
+}
+
+// eventBus distributes events to its subscribers
+type eventBus struct {
+	mutex       sync.Mutex
+	subscribers map[int]chan Event
+	next        int
+	closed      bool
+}
+
+// subscribe returns a channel for receiving events and a function for cancelling the subscription
+func (b *eventBus) subscribe() (<-chan Event, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	events := make(chan Event, eventBuffer)
+	if b.closed {
+		close(events)
+		return events, func() {}
+	}
+
+	if b.subscribers == nil {
+		b.subscribers = map[int]chan Event{}
+	}
+	id := b.next
+	b.next++
+	b.subscribers[id] = events
+
+	return events, func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		if events, found := b.subscribers[id]; found {
+			delete(b.subscribers, id)
+			close(events)
+		}
+	}
+}
+
+// publish sends the event to the subscribers, without blocking
+func (b *eventBus) publish(event Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	for _, events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// close closes the channels of all the subscribers
+func (b *eventBus) close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for id, events := range b.subscribers {
+		delete(b.subscribers, id)
+		close(events)
+	}
+	b.closed = true
+}
+
+// Events returns a channel that receives the provisioning events of the provider,
+// such as cache hits and the progress of downloads. The channel is closed when the
+// provider is closed. See [Provider.Subscribe] for cancelling the subscription earlier.
+//
+// Events are not buffered indefinitely. If the receiver doesn't keep up, events are dropped.
+func (p *Provider) Events() <-chan Event {
+	events, _ := p.events.subscribe()
+	return events
+}
+
+// Subscribe returns a channel that receives the provisioning events of the provider,
+// and a function that cancels the subscription, closing the channel.
+func (p *Provider) Subscribe() (<-chan Event, func()) {
+	return p.events.subscribe()
+}
+
+// progressWriter publishes the progress of a download
+type progressWriter struct {
+	writer     io.Writer
+	events     *eventBus
+	artifact   string
+	size       int64
+	downloaded int64
+	last       time.Time
+	validators validators
+	// resumed is the state of the interrupted download resumed, if any
+	resumed resumeState
+	// onRestart discards the content of the resumed download
+	onRestart func() error
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.downloaded += int64(n)
+
+	if time.Since(w.last) >= progressInterval {
+		w.last = time.Now()
+		w.events.publish(Event{
+			Type:       EventDownloadProgress,
+			ArtifactID: w.artifact,
+			Downloaded: w.downloaded,
+			Size:       w.size,
+		})
+	}
+
+	return n, err
+}
+
+// setSize sets the size of the download, when the response is received
+func (w *progressWriter) setSize(size int64) {
+	w.size = size
+}
+
+// setValidators sets the validators of the downloaded content, when the response is received
+func (w *progressWriter) setValidators(v validators) {
+	w.validators = v
+}
+
+// resumeFrom returns the length of the content of the resumed download and its validator
+func (w *progressWriter) resumeFrom() (int64, string) {
+	return w.resumed.Size, w.resumed.Validator
+}
+
+// restart discards the content of the resumed download when it cannot be resumed
+func (w *progressWriter) restart() error {
+	w.resumed = resumeState{}
+	w.downloaded = 0
+	if w.onRestart == nil {
+		return nil
+	}
+
+	return w.onRestart()
+}
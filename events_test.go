@@ -0,0 +1,71 @@
+package k6provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	binary := []byte("k6 binary")
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(binary)
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", URL: store.URL}, nil
+		},
+	)
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+
+	events := provider.Events()
+	cancelled, cancel := provider.Subscribe()
+	cancel()
+
+	// cancelled subscriptions are closed
+	if _, ok := <-cancelled; ok {
+		t.Fatalf("expected cancelled subscription closed")
+	}
+
+	for range 2 {
+		if _, err := provider.GetBinary(context.TODO(), nil); err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+	}
+
+	if err := provider.Close(); err != nil {
+		t.Fatalf("closing provider %v", err)
+	}
+
+	received := []EventType{}
+	for event := range events {
+		if event.Type == EventDownloadCompleted && event.Downloaded != int64(len(binary)) {
+			t.Fatalf("expected %d bytes downloaded got %d", len(binary), event.Downloaded)
+		}
+		received = append(received, event.Type)
+	}
+
+	expected := []EventType{
+		EventResolveStarted,
+		EventDownloadStarted,
+		EventDownloadProgress,
+		EventDownloadCompleted,
+		EventResolveStarted,
+		EventCacheHit,
+	}
+	if len(received) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, received)
+	}
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Fatalf("expected %v got %v", expected, received)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package k6provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// failuresFile is the file in the artifact directory that records the failures
+	// executing the binary
+	failuresFile = ".failures"
+	// DefaultFailureThreshold number of failures executing a binary after which it is evicted from the cache
+	DefaultFailureThreshold = 3
+)
+
+// ReportFailure records a failure executing the binary, such as "exec format error" or
+// "permission denied". Once the binary has failed DefaultFailureThreshold times, it is
+// evicted from the cache, so it is downloaded again the next time it is requested from
+// the provider, breaking loops of failures caused by a corrupted binary in the cache.
+//
+// Errors reporting the binary exited with an error (exec.ExitError) are ignored, as the
+// binary was executed. Failures are recorded in the cache, so they are counted across
+// processes sharing it.
+//
+// Returns true if the binary was evicted. In this case, the binary must be obtained
+// again from the provider.
+func (b K6Binary) ReportFailure(err error) bool {
+	var exitErr *exec.ExitError
+	if b.Path == "" || err == nil || errors.As(err, &exitErr) {
+		return false
+	}
+
+	failuresPath := filepath.Join(filepath.Dir(b.Path), failuresFile)
+	failures, recordErr := recordFailure(failuresPath, err)
+	if recordErr != nil || failures < DefaultFailureThreshold {
+		return false
+	}
+
+	if removeErr := os.Remove(b.Path); removeErr != nil && !os.IsNotExist(removeErr) {
+		return false
+	}
+	_ = os.Remove(failuresPath)
+
+	return true
+}
+
+// recordFailure appends the failure to the failures file and returns the number of failures recorded
+func recordFailure(failuresPath string, failure error) (int, error) {
+	file, err := os.OpenFile(failuresPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, cacheFilePerm) //nolint:gosec
+	if err != nil {
+		return 0, err
+	}
+
+	// a single write per failure, so concurrent reports are not interleaved
+	_, err = fmt.Fprintf(file, "%s %q\n", time.Now().UTC().Format(time.RFC3339), failure.Error())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := os.ReadFile(failuresPath) //nolint:gosec
+	if err != nil {
+		return 0, err
+	}
+
+	return bytes.Count(content, []byte("\n")), nil
+}
@@ -0,0 +1,73 @@
+package k6provider
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestReportFailure(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title         string
+		failure       error
+		reports       int
+		expectEvicted bool
+	}{
+		{
+			title:         "below threshold",
+			failure:       syscall.ENOEXEC,
+			reports:       DefaultFailureThreshold - 1,
+			expectEvicted: false,
+		},
+		{
+			title:         "threshold reached",
+			failure:       syscall.ENOEXEC,
+			reports:       DefaultFailureThreshold,
+			expectEvicted: true,
+		},
+		{
+			title:         "binary exited with error",
+			failure:       &exec.ExitError{},
+			reports:       DefaultFailureThreshold,
+			expectEvicted: false,
+		},
+		{
+			title:         "no error",
+			failure:       nil,
+			reports:       DefaultFailureThreshold,
+			expectEvicted: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			binPath := filepath.Join(t.TempDir(), k6Binary)
+			if err := os.WriteFile(binPath, []byte("corrupted"), 0o700); err != nil { //nolint:gosec
+				t.Fatalf("writing binary %v", err)
+			}
+
+			binary := K6Binary{Path: binPath}
+
+			evicted := false
+			for range tc.reports {
+				evicted = binary.ReportFailure(tc.failure)
+			}
+
+			if evicted != tc.expectEvicted {
+				t.Fatalf("expected evicted %v got %v", tc.expectEvicted, evicted)
+			}
+
+			_, err := os.Stat(binPath)
+			if removed := errors.Is(err, os.ErrNotExist); removed != tc.expectEvicted {
+				t.Fatalf("expected removed %v got %v", tc.expectEvicted, err)
+			}
+		})
+	}
+}
@@ -3,15 +3,22 @@ module github.com/grafana/k6provider
 go 1.22.4
 
 require (
+	github.com/Masterminds/semver/v3 v3.3.1
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/grafana/k6build v0.5.4
 	github.com/grafana/k6deps v0.2.0
+	github.com/zalando/go-keyring v0.2.1
+	golang.org/x/sys v0.29.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/Masterminds/semver/v3 v3.3.1 // indirect
+	github.com/alessio/shellescape v1.4.1 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/danieljoos/wincred v1.1.0 // indirect
 	github.com/evanw/esbuild v0.24.2 // indirect
+	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/grafana/k6foundry v0.3.1 // indirect
 	github.com/grafana/k6pack v0.2.4 // indirect
 	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
@@ -20,6 +27,5 @@ require (
 	github.com/prometheus/common v0.55.0 // indirect
 	github.com/prometheus/procfs v0.15.1 // indirect
 	golang.org/x/mod v0.22.0 // indirect
-	golang.org/x/sys v0.29.0 // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
 )
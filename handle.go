@@ -0,0 +1,113 @@
+package k6provider
+
+import (
+	"context"
+	"os"
+
+	"github.com/grafana/k6deps"
+)
+
+// Handle is a binary resolved by [Provider.Resolve] that is provisioned the first time it is used
+type Handle struct {
+	provider *Provider
+	artifact Artifact
+	request  string
+	// binary found in the cache when the build service was not available
+	cached *K6Binary
+	// semaphore serializing the provisioning of the binary
+	sem    chan struct{}
+	binary *K6Binary
+}
+
+// Resolve resolves the custom k6 binary that satisfies the given set of dependencies, like
+// [Provider.GetBinary], but the binary is not downloaded until it is used, calling the handle's
+// Path or Binary functions. It allows orchestrators to resolve the binaries of many jobs
+// up-front and download only those of the jobs that actually run.
+//
+// If the build service cannot be reached, the handle provides the binary that satisfied the
+// same dependencies previously from the cache, if any.
+func (p *Provider) Resolve(ctx context.Context, deps k6deps.Dependencies) (*Handle, error) {
+	if p.ctx.Err() != nil {
+		return nil, ErrClosed
+	}
+
+	artifact, request, cached, err := p.resolve(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil {
+		artifact = Artifact{
+			ID:           cached.ID,
+			Dependencies: cached.Dependencies,
+			Platform:     cached.Platform,
+			Checksum:     cached.Checksum,
+		}
+	}
+
+	return &Handle{
+		provider: p,
+		artifact: artifact,
+		request:  request,
+		cached:   cached,
+		sem:      make(chan struct{}, 1),
+	}, nil
+}
+
+// Artifact returns the artifact the binary is obtained from
+func (h *Handle) Artifact() Artifact {
+	return h.artifact
+}
+
+// Binary returns the binary, downloading it the first time it is called if it is not in the cache.
+// Later calls return the same binary, unless it is no longer in the cache (e.g. it was pruned).
+// Failed attempts are not cached, so the binary can be requested again.
+//
+// Concurrent calls wait for the binary to be provisioned once.
+func (h *Handle) Binary(ctx context.Context) (K6Binary, error) {
+	select {
+	case h.sem <- struct{}{}:
+	case <-ctx.Done():
+		return K6Binary{}, ctx.Err()
+	}
+	defer func() { <-h.sem }()
+
+	if h.binary != nil {
+		if _, err := os.Stat(h.binary.Path); err == nil {
+			return *h.binary, nil
+		}
+	}
+
+	p := h.provider
+	if p.ctx.Err() != nil {
+		return K6Binary{}, ErrClosed
+	}
+
+	var (
+		binary K6Binary
+		err    error
+	)
+	if h.cached != nil {
+		binary, err = p.deliver(*h.cached)
+	} else {
+		binary, err = p.binaryForRequest(ctx, h.artifact, h.request)
+	}
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	h.binary = &binary
+
+	return binary, nil
+}
+
+// Path returns the path to the binary, downloading it the first time it is called.
+// See [Handle.Binary]
+func (h *Handle) Path(ctx context.Context) (string, error) {
+	binary, err := h.Binary(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return binary.Path, nil
+}
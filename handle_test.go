@@ -0,0 +1,82 @@
+package k6provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	downloads := atomic.Int32{}
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		downloads.Add(1)
+		_, _ = w.Write([]byte("k6"))
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", URL: store.URL}, nil
+		},
+	)
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+
+	handle, err := provider.Resolve(context.TODO(), nil)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if handle.Artifact().ID != "artifact" {
+		t.Fatalf("expected artifact %q got %q", "artifact", handle.Artifact().ID)
+	}
+
+	// resolving doesn't download the binary
+	if downloads.Load() != 0 {
+		t.Fatalf("expected no downloads got %d", downloads.Load())
+	}
+
+	// concurrent uses download the binary once
+	paths := make([]string, 5)
+	wg := sync.WaitGroup{}
+	for i := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			paths[i], _ = handle.Path(context.TODO())
+		}()
+	}
+	wg.Wait()
+
+	if downloads.Load() != 1 {
+		t.Fatalf("expected 1 download got %d", downloads.Load())
+	}
+	for _, path := range paths {
+		if path == "" || path != paths[0] {
+			t.Fatalf("expected path %q got %q", paths[0], path)
+		}
+	}
+
+	// the binary is provisioned again if removed from the cache
+	if err = os.Remove(paths[0]); err != nil {
+		t.Fatalf("test setup: %v", err)
+	}
+
+	path, err := handle.Path(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if _, err = os.Stat(path); err != nil {
+		t.Fatalf("expected binary at %s got %v", path, err)
+	}
+	if downloads.Load() != 2 {
+		t.Fatalf("expected 2 downloads got %d", downloads.Load())
+	}
+}
@@ -0,0 +1,151 @@
+package k6provider
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPTarget identifies the service a request is sent to. See [HTTPDebugConfig]
+type HTTPTarget string
+
+const (
+	// HTTPTargetBuildService requests to the build service
+	HTTPTargetBuildService HTTPTarget = "build-service"
+	// HTTPTargetStore requests to the artifact store for downloading binaries
+	HTTPTargetStore HTTPTarget = "store"
+)
+
+// HTTPExchange describes a request sent to the build service or the artifact store, and its
+// response. The credentials are redacted: the value of sensitive headers (such as Authorization),
+// the password in the URL and sensitive query parameters (such as those of pre-signed URLs)
+type HTTPExchange struct {
+	// Target service the request is sent to
+	Target HTTPTarget
+	// Method of the request
+	Method string
+	// URL of the request
+	URL string
+	// RequestHeader headers sent, as set by the provider. Headers added by the transport,
+	// such as User-Agent or Accept-Encoding, are not included
+	RequestHeader http.Header
+	// StatusCode of the response. 0 if no response was received
+	StatusCode int
+	// ResponseHeader headers received
+	ResponseHeader http.Header
+	// Duration from sending the request until the response headers were received
+	Duration time.Duration
+	// Err error sending the request, if no response was received
+	Err error
+}
+
+// HTTPDebugConfig defines hooks for observing the requests sent to the build service and the
+// artifact store, for example, for diagnosing proxy or authentication issues without packet
+// captures. Each attempt, retry and redirect is reported as a separate request.
+type HTTPDebugConfig struct {
+	// OnRequest is called with each request before it is sent
+	OnRequest func(HTTPExchange)
+	// OnResponse is called with each request and its response once the response headers are
+	// received or sending it failed. The response body is not included
+	OnResponse func(HTTPExchange)
+	// Trace if set, traces the connections used by the requests, such as the DNS lookups, the
+	// connections to the proxy or the TLS handshakes. See [httptrace.ClientTrace]
+	Trace *httptrace.ClientTrace
+}
+
+// enabled returns true if any hook is set
+func (c HTTPDebugConfig) enabled() bool {
+	return c.OnRequest != nil || c.OnResponse != nil || c.Trace != nil
+}
+
+// httpDebugTransport reports the requests and responses to the debug hooks
+type httpDebugTransport struct {
+	base   http.RoundTripper
+	config HTTPDebugConfig
+	target HTTPTarget
+}
+
+func (t httpDebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.config.Trace != nil {
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), t.config.Trace))
+	}
+
+	exchange := HTTPExchange{
+		Target:        t.target,
+		Method:        req.Method,
+		URL:           redactURL(req.URL),
+		RequestHeader: redactHTTPHeader(req.Header),
+	}
+	if t.config.OnRequest != nil {
+		t.config.OnRequest(exchange)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	exchange.Duration = time.Since(start)
+	exchange.Err = err
+	if resp != nil {
+		exchange.StatusCode = resp.StatusCode
+		exchange.ResponseHeader = redactHTTPHeader(resp.Header)
+	}
+
+	if t.config.OnResponse != nil {
+		t.config.OnResponse(exchange)
+	}
+
+	return resp, err
+}
+
+// withHTTPDebug returns a client that reports its requests to the debug hooks, or the client
+// if no hook is set. It must wrap the client's transport before any other wrapper, so the
+// requests are reported as sent, including the headers added by the other wrappers
+func withHTTPDebug(client *http.Client, config HTTPDebugConfig, target HTTPTarget) *http.Client {
+	if !config.enabled() {
+		return client
+	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	withDebug := *client
+	withDebug.Transport = httpDebugTransport{base: base, config: config, target: target}
+
+	return &withDebug
+}
+
+// redactHTTPHeader returns a copy of the headers with the value of sensitive headers redacted
+func redactHTTPHeader(header http.Header) http.Header {
+	redactedHeader := make(http.Header, len(header))
+	for name, values := range header {
+		if isSensitiveHeader(name) {
+			values = []string{redacted}
+		}
+		redactedHeader[name] = append([]string(nil), values...)
+	}
+
+	return redactedHeader
+}
+
+// redactURL returns the URL with the password and the value of sensitive query parameters,
+// such as the signature of pre-signed URLs, redacted
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+
+	query := u.Query()
+	for name := range query {
+		if isSensitiveHeader(name) || strings.Contains(strings.ToLower(name), "signature") {
+			query.Set(name, redacted)
+		}
+	}
+
+	redactedURL := *u
+	redactedURL.RawQuery = query.Encode()
+
+	return redactedURL.Redacted()
+}
@@ -0,0 +1,141 @@
+package k6provider
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// requestIndexDir is the directory in the binary directory that indexes the artifact directories
+// by the requests they satisfied, so they are found without reading the metadata of every artifact.
+// Each request has a directory, named by its key, with an empty file for each artifact directory
+// that satisfied the request. The index is only a hint, the metadata of the artifacts is the
+// source of truth.
+const requestIndexDir = ".requests"
+
+// indexRequest adds the artifact directory to the artifacts that satisfied the request in the
+// index of its binary directory
+func indexRequest(artifactDir string, request string) error {
+	entryDir := filepath.Join(filepath.Dir(artifactDir), requestIndexDir, request)
+	if err := os.MkdirAll(entryDir, cacheDirPerm); err != nil {
+		return err
+	}
+
+	entry, err := os.OpenFile( //nolint:gosec
+		filepath.Join(entryDir, filepath.Base(artifactDir)),
+		os.O_CREATE|os.O_WRONLY,
+		0o600,
+	)
+	if err != nil {
+		return err
+	}
+
+	return entry.Close()
+}
+
+// unindexRequest removes the artifact directory from the artifacts that satisfied the request
+// in the index of its binary directory
+func unindexRequest(artifactDir string, request string) error {
+	entryDir := filepath.Join(filepath.Dir(artifactDir), requestIndexDir, request)
+	err := os.Remove(filepath.Join(entryDir, filepath.Base(artifactDir)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// the request's directory is removed when empty
+	_ = os.Remove(entryDir)
+
+	return nil
+}
+
+// indexArtifact adds the artifact directory to the index of its binary directory for all the
+// requests recorded in its metadata
+func indexArtifact(artifactDir string) error {
+	metadata, err := readMetadata(artifactDir)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, request := range metadata.Requests {
+		errs = append(errs, indexRequest(artifactDir, request))
+	}
+
+	return errors.Join(errs...)
+}
+
+// indexedArtifacts returns the names of the artifact directories that satisfied the request
+// according to the index of the binary directory. The index is built from the metadata of the
+// artifacts if the directory has none, for example, when it was created by a previous version.
+func indexedArtifacts(dir string, request string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(dir, requestIndexDir)); os.IsNotExist(err) {
+		if err = rebuildRequestIndex(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, requestIndexDir, request))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	return names, nil
+}
+
+// rebuildRequestIndex replaces the index of the binary directory with one built from the
+// metadata of its artifacts. The new index is built aside and then swapped with the current one.
+func rebuildRequestIndex(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	// the artifacts are indexed as if they were in the temporary directory
+	building, err := os.MkdirTemp(dir, requestIndexDir+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(building) //nolint:errcheck
+
+	if err = os.Mkdir(filepath.Join(building, requestIndexDir), cacheDirPerm); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !isArtifactDir(entry) {
+			continue
+		}
+
+		metadata, err := readMetadata(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, request := range metadata.Requests {
+			if err = indexRequest(filepath.Join(building, entry.Name()), request); err != nil {
+				return err
+			}
+		}
+	}
+
+	index := filepath.Join(dir, requestIndexDir)
+	if err = os.RemoveAll(index); err != nil {
+		return err
+	}
+
+	err = os.Rename(filepath.Join(building, requestIndexDir), index)
+	if err != nil && errors.Is(err, os.ErrExist) {
+		// rebuilt concurrently by another process
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,99 @@
+package k6provider
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRequestIndex(t *testing.T) {
+	t.Parallel()
+
+	// setupArtifact creates an artifact directory with a binary and its metadata
+	setupArtifact := func(t *testing.T, binDir string, id string, request string) string {
+		t.Helper()
+
+		artifactDir := filepath.Join(binDir, id)
+		if err := os.MkdirAll(artifactDir, cacheDirPerm); err != nil {
+			t.Fatalf("creating artifact dir %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(artifactDir, k6Binary), []byte("k6"), 0o700); err != nil {
+			t.Fatalf("writing binary %v", err)
+		}
+		if err := writeMetadata(artifactDir, Artifact{ID: id}, request); err != nil {
+			t.Fatalf("writing metadata %v", err)
+		}
+
+		return artifactDir
+	}
+
+	testCases := []struct {
+		title  string
+		setup  func(t *testing.T, binDir string)
+		expect string
+	}{
+		{
+			title: "indexed request",
+			setup: func(t *testing.T, binDir string) {
+				setupArtifact(t, binDir, "artifact", "request")
+				setupArtifact(t, binDir, "other", "other request")
+			},
+			expect: "artifact",
+		},
+		{
+			title: "forgotten request",
+			setup: func(t *testing.T, binDir string) {
+				artifactDir := setupArtifact(t, binDir, "artifact", "request")
+				if err := forgetRequest(artifactDir, "request"); err != nil {
+					t.Fatalf("forgetting request %v", err)
+				}
+			},
+			expect: "",
+		},
+		{
+			title: "cache without index",
+			setup: func(t *testing.T, binDir string) {
+				setupArtifact(t, binDir, "artifact", "request")
+				if err := os.RemoveAll(filepath.Join(binDir, requestIndexDir)); err != nil {
+					t.Fatalf("removing index %v", err)
+				}
+			},
+			expect: "artifact",
+		},
+		{
+			title: "removed artifact",
+			setup: func(t *testing.T, binDir string) {
+				artifactDir := setupArtifact(t, binDir, "artifact", "request")
+				if err := os.RemoveAll(artifactDir); err != nil {
+					t.Fatalf("removing artifact %v", err)
+				}
+			},
+			expect: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			binDir := t.TempDir()
+			tc.setup(t, binDir)
+
+			provider := newTestProvider(t, nil, binDir)
+			binary, found := provider.lookupRequest("request", 0)
+			if found != (tc.expect != "") || binary.ID != tc.expect {
+				t.Fatalf("expected %q got %q (found %t)", tc.expect, binary.ID, found)
+			}
+
+			// the index only keeps the artifacts found
+			names, err := indexedArtifacts(binDir, "request")
+			if err != nil {
+				t.Fatalf("reading index %v", err)
+			}
+			if tc.expect != "" && !slices.Equal(names, []string{tc.expect}) || tc.expect == "" && len(names) > 0 {
+				t.Fatalf("expected %q indexed got %v", tc.expect, names)
+			}
+		})
+	}
+}
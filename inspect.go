@@ -0,0 +1,161 @@
+package k6provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var (
+	// versionLineRegex matches the first line of the text output of the version command
+	// e.g. k6 v0.55.0 (commit/abc1234, go1.23.1, linux/amd64)
+	versionLineRegex = regexp.MustCompile(`^k6 (v\S+)(?: \((.*)\))?`) //nolint:gochecknoglobals
+	// extensionLineRegex matches an extension in the text output of the version command
+	// e.g.   github.com/grafana/xk6-faker v0.4.0, k6/x/faker [js]
+	extensionLineRegex = regexp.MustCompile(`^\s+(\S+) (\S+), (.+) \[\w+\]$`) //nolint:gochecknoglobals
+)
+
+// BinaryInfo describes a k6 binary as reported by its version command. See [K6Binary.Inspect]
+type BinaryInfo struct {
+	// Version of k6 (e.g. "v0.55.0")
+	Version string `json:"version"`
+	// Commit of k6 the binary was built from
+	Commit string `json:"commit,omitempty"`
+	// GoVersion version of go used for building the binary (e.g. "go1.23.1")
+	GoVersion string `json:"goVersion,omitempty"`
+	// Extensions compiled into the binary as a map of name: version. The names are those used
+	// for requesting the extensions (e.g. "k6/x/faker" or the name of an output extension)
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+// Dependencies returns the dependencies compiled into the binary, including k6, as a map of
+// name: version, as in [K6Binary.Dependencies]
+func (i BinaryInfo) Dependencies() map[string]string {
+	deps := map[string]string{k6Module: i.Version}
+	for name, version := range i.Extensions {
+		deps[name] = version
+	}
+
+	return deps
+}
+
+// versionJSON is the output of the version command with the --json flag
+type versionJSON struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	GoVersion  string `json:"go_version"`
+	Extensions []struct {
+		Module  string   `json:"module"`
+		Version string   `json:"version"`
+		Imports []string `json:"imports"`
+		Outputs []string `json:"outputs"`
+	} `json:"extensions"`
+}
+
+// Inspect runs the binary's version command and returns the information it reports,
+// including the extensions actually compiled into the binary, for example, for detecting
+// drift from the declared dependencies:
+//
+//	info, err := binary.Inspect(ctx)
+//	...
+//	drift := DiffDependencies(binary, K6Binary{Dependencies: info.Dependencies()})
+//
+// The version command is run with the --json flag. Versions of k6 that don't support it
+// are inspected by parsing the text output.
+func (b K6Binary) Inspect(ctx context.Context) (BinaryInfo, error) {
+	output, err := exec.CommandContext(ctx, b.Path, "version", "--json").Output() //nolint:gosec
+	if err == nil {
+		if info, parseErr := parseVersionJSON(output); parseErr == nil {
+			return info, nil
+		}
+	}
+
+	// the binary may not have been executed
+	if ctx.Err() != nil {
+		return BinaryInfo{}, ctx.Err()
+	}
+
+	output, err = exec.CommandContext(ctx, b.Path, "version").Output() //nolint:gosec
+	if err != nil {
+		return BinaryInfo{}, fmt.Errorf("running version command: %w", err)
+	}
+
+	return parseVersionText(output)
+}
+
+// parseVersionJSON parses the output of the version command with the --json flag
+func parseVersionJSON(output []byte) (BinaryInfo, error) {
+	parsed := versionJSON{}
+	if err := json.Unmarshal(bytes.TrimSpace(output), &parsed); err != nil {
+		return BinaryInfo{}, err
+	}
+	if parsed.Version == "" {
+		return BinaryInfo{}, errors.New("missing version")
+	}
+
+	info := BinaryInfo{
+		Version:   parsed.Version,
+		Commit:    parsed.Commit,
+		GoVersion: parsed.GoVersion,
+	}
+	for _, extension := range parsed.Extensions {
+		for _, name := range append(extension.Imports, extension.Outputs...) {
+			info.addExtension(name, extension.Version)
+		}
+	}
+
+	return info, nil
+}
+
+// parseVersionText parses the text output of the version command
+func parseVersionText(output []byte) (BinaryInfo, error) {
+	info := BinaryInfo{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := versionLineRegex.FindStringSubmatch(line); match != nil && info.Version == "" {
+			info.Version = match[1]
+			for _, attribute := range strings.Split(match[2], ",") {
+				attribute = strings.TrimSpace(attribute)
+				switch {
+				case strings.HasPrefix(attribute, "commit/"):
+					info.Commit = strings.TrimPrefix(attribute, "commit/")
+				case strings.HasPrefix(attribute, "go1"):
+					info.GoVersion = attribute
+				}
+			}
+			continue
+		}
+
+		if match := extensionLineRegex.FindStringSubmatch(line); match != nil {
+			for _, name := range strings.Split(match[3], ",") {
+				info.addExtension(strings.TrimSpace(name), match[2])
+			}
+		}
+	}
+
+	if info.Version == "" {
+		return BinaryInfo{}, fmt.Errorf("unexpected version output %q", output)
+	}
+
+	return info, nil
+}
+
+// addExtension adds an extension to the information
+func (i *BinaryInfo) addExtension(name string, version string) {
+	if name == "" {
+		return
+	}
+	if i.Extensions == nil {
+		i.Extensions = map[string]string{}
+	}
+	i.Extensions[name] = version
+}
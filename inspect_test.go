@@ -0,0 +1,73 @@
+package k6provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the test binaries are shell scripts")
+	}
+
+	expected := BinaryInfo{
+		Version:    "v0.55.0",
+		Commit:     "abc1234",
+		GoVersion:  "go1.23.1",
+		Extensions: map[string]string{"k6/x/faker": "v0.4.0", "xk6-output-kafka": "v0.8.0"},
+	}
+
+	testCases := []struct {
+		title  string
+		script string
+		expect BinaryInfo
+	}{
+		{
+			title: "json output",
+			script: `echo '{"version":"v0.55.0","commit":"abc1234","go_version":"go1.23.1","extensions":[` +
+				`{"module":"github.com/grafana/xk6-faker","version":"v0.4.0","imports":["k6/x/faker"]},` +
+				`{"module":"github.com/grafana/xk6-output-kafka","version":"v0.8.0","outputs":["xk6-output-kafka"]}]}'`,
+			expect: expected,
+		},
+		{
+			title: "text output",
+			script: `[ "$2" = "--json" ] && exit 1
+echo "k6 v0.55.0 (commit/abc1234, go1.23.1, linux/amd64)"
+echo "Extensions:"
+echo "  github.com/grafana/xk6-faker v0.4.0, k6/x/faker [js]"
+echo "  github.com/grafana/xk6-output-kafka v0.8.0, xk6-output-kafka [output]"`,
+			expect: expected,
+		},
+		{
+			title:  "no extensions",
+			script: `[ "$2" = "--json" ] && exit 1; echo "k6 v0.50.0 (2024-03-01T00:00:00+0000/v0.50.0-0-gabc1234, go1.22.0, linux/amd64)"`,
+			expect: BinaryInfo{Version: "v0.50.0", GoVersion: "go1.22.0"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			binPath := filepath.Join(t.TempDir(), k6Binary)
+			if err := os.WriteFile(binPath, []byte("#!/bin/sh\n"+tc.script+"\n"), 0o700); err != nil { //nolint:gosec
+				t.Fatalf("test setup: %v", err)
+			}
+
+			info, err := K6Binary{Path: binPath}.Inspect(context.TODO())
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if !reflect.DeepEqual(info, tc.expect) {
+				t.Fatalf("expected %+v got %+v", tc.expect, info)
+			}
+		})
+	}
+}
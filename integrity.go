@@ -0,0 +1,45 @@
+package k6provider
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+type expectedChecksumsKey struct{}
+
+// WithExpectedChecksums returns a context that pins the checksums of artifacts, given as a map of
+// artifact ID: checksum (e.g. taken from a [Lockfile]). If the build service returns an artifact
+// with a pinned ID but a different checksum to the provider's functions called with the context,
+// they fail with an [ErrIntegrity] error instead of using the artifact.
+//
+// The checksums are added to those already pinned in the context, if any.
+func WithExpectedChecksums(ctx context.Context, checksums map[string]string) context.Context {
+	pinned := maps.Clone(expectedChecksums(ctx))
+	if pinned == nil {
+		pinned = make(map[string]string, len(checksums))
+	}
+	maps.Copy(pinned, checksums)
+
+	return context.WithValue(ctx, expectedChecksumsKey{}, pinned)
+}
+
+// expectedChecksums returns the checksums pinned in the context, if any
+func expectedChecksums(ctx context.Context) map[string]string {
+	checksums, _ := ctx.Value(expectedChecksumsKey{}).(map[string]string)
+	return checksums
+}
+
+// verifyIntegrity checks the checksum of the artifact matches the one pinned in the context, if any
+func verifyIntegrity(ctx context.Context, artifact Artifact) error {
+	expected, found := expectedChecksums(ctx)[artifact.ID]
+	if !found || strings.EqualFold(expected, artifact.Checksum) {
+		return nil
+	}
+
+	return NewWrappedError(
+		ErrIntegrity,
+		fmt.Errorf("artifact %s: expected checksum %s got %s", artifact.ID, expected, artifact.Checksum),
+	)
+}
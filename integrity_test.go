@@ -0,0 +1,70 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+func TestExpectedChecksums(t *testing.T) {
+	t.Parallel()
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", Checksum: "abc123"}, nil
+		},
+	)
+
+	testCases := []struct {
+		title     string
+		pins      []map[string]string
+		expectErr error
+	}{
+		{
+			title: "no pinned checksums",
+		},
+		{
+			title: "matching checksum",
+			pins:  []map[string]string{{"artifact": "ABC123"}},
+		},
+		{
+			title: "checksum of other artifact",
+			pins:  []map[string]string{{"other": "def456"}},
+		},
+		{
+			title:     "mismatched checksum",
+			pins:      []map[string]string{{"artifact": "def456"}},
+			expectErr: ErrIntegrity,
+		},
+		{
+			title:     "checksums are accumulated",
+			pins:      []map[string]string{{"artifact": "def456"}, {"other": "abc123"}},
+			expectErr: ErrIntegrity,
+		},
+		{
+			title: "later checksums take precedence",
+			pins:  []map[string]string{{"artifact": "def456"}, {"artifact": "abc123"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+
+			ctx := context.TODO()
+			for _, pins := range tc.pins {
+				ctx = WithExpectedChecksums(ctx, pins)
+			}
+
+			_, err := provider.GetArtifact(ctx, k6deps.Dependencies{})
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// deviceCodeGrantType is the grant type of the token requests of the device authorization grant
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+	// defaultDeviceCodeInterval is the time between token requests if not set by the server
+	defaultDeviceCodeInterval = 5 * time.Second
+	// slowDownIncrement is the increment of the interval between token requests requested by the server
+	slowDownIncrement = 5 * time.Second
+)
+
+// InteractiveAuthHandler obtains a token for the build service interactively, for example, by
+// asking the user to log in with a browser (see [DeviceCodeAuth]). See [Config.InteractiveAuth]
+type InteractiveAuthHandler func(ctx context.Context) (string, error)
+
+// interactiveAuth obtains a token with the interactive handler when the build service rejects
+// the credentials of a request, and keeps it for the following requests
+type interactiveAuth struct {
+	handler  InteractiveAuthHandler
+	authType string
+	source   string
+	mutex    sync.Mutex
+	token    string
+}
+
+// newInteractiveAuth returns the interactive authentication in the configuration, if any
+func newInteractiveAuth(config Config) *interactiveAuth {
+	if config.InteractiveAuth == nil {
+		return nil
+	}
+
+	authType := config.BuildServiceAuthType
+	if authType == "" {
+		authType = "Bearer"
+	}
+
+	return &interactiveAuth{
+		handler:  config.InteractiveAuth,
+		authType: authType,
+		source:   config.CredentialSource,
+	}
+}
+
+// current returns the token obtained interactively, if any
+func (a *interactiveAuth) current() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.token
+}
+
+// refresh obtains a new token, unless it was already replaced since the rejected one was used,
+// so concurrent requests rejected with the same token don't ask the user more than once.
+// The token is saved in the credential source, if any, so it is used by other processes.
+func (a *interactiveAuth) refresh(ctx context.Context, rejected string) (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.token != rejected {
+		return a.token, nil
+	}
+
+	token, err := a.handler(ctx)
+	if err != nil {
+		return "", err
+	}
+	a.token = token
+
+	// failing to save the token is not an error, it is obtained again next time
+	if a.source != "" {
+		_ = SaveCredential(a.source, CredentialBuildService, token)
+	}
+
+	return token, nil
+}
+
+// authorize returns the request with the token, if any, replacing the configured credentials
+func (a *interactiveAuth) authorize(req *http.Request, token string) *http.Request {
+	if token == "" {
+		return req
+	}
+
+	// a round tripper must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", fmt.Sprintf("%s %s", a.authType, token))
+
+	return req
+}
+
+// interactiveAuthTransport retries the requests rejected by the build service as unauthorized
+// with a token obtained interactively
+type interactiveAuthTransport struct {
+	base http.RoundTripper
+	auth *interactiveAuth
+}
+
+func (t interactiveAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := t.auth.current()
+	resp, err := t.base.RoundTrip(t.auth.authorize(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// the request can only be retried if its body can be sent again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	_ = resp.Body.Close()
+
+	token, err = t.auth.refresh(req.Context(), token)
+	if err != nil {
+		return nil, fmt.Errorf("interactive authentication: %w", err)
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.base.RoundTrip(t.auth.authorize(retry, token))
+}
+
+// withInteractiveAuth returns a client that retries the requests rejected as unauthorized with a
+// token obtained interactively, or the client if there is no interactive authentication
+func withInteractiveAuth(client *http.Client, auth *interactiveAuth) *http.Client {
+	if auth == nil {
+		return client
+	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	withAuth := *client
+	withAuth.Transport = interactiveAuthTransport{base: base, auth: auth}
+
+	return &withAuth
+}
+
+// DeviceAuthorization is the code the user enters for authorizing the provider in the device
+// authorization grant. See [DeviceCodeConfig]
+type DeviceAuthorization struct {
+	// UserCode code the user enters in the verification page
+	UserCode string
+	// VerificationURI page where the user enters the code
+	VerificationURI string
+	// VerificationURIComplete page that includes the code, so the user doesn't have to enter it.
+	// Empty if not supported by the authorization server
+	VerificationURIComplete string
+	// ExpiresIn time the code is valid
+	ExpiresIn time.Duration
+}
+
+// DeviceCodeConfig defines the OAuth 2.0 device authorization grant (RFC 8628) used by [DeviceCodeAuth]
+type DeviceCodeConfig struct {
+	// Issuer URL of the OpenID Connect provider. If set, the endpoints not specified are discovered
+	// from its configuration (<issuer>/.well-known/openid-configuration)
+	Issuer string
+	// DeviceAuthorizationURL endpoint for requesting the device code
+	DeviceAuthorizationURL string
+	// TokenURL endpoint for requesting the token
+	TokenURL string
+	// ClientID identifier of the application in the authorization server
+	ClientID string
+	// Scopes requested. e.g. ["openid", "k6build"]
+	Scopes []string
+	// Prompt is called with the code the user must enter and the page where to enter it, for
+	// showing them to the user or opening the page in a browser. Required
+	Prompt func(DeviceAuthorization) error
+	// HTTPClient used for the requests to the authorization server. Defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// DeviceCodeAuth returns an [InteractiveAuthHandler] that obtains the token using the OAuth 2.0
+// device authorization grant: it requests a code, which is shown to the user with the Prompt
+// function, and waits until the user enters it in the verification page and logs in.
+//
+// For example, in a CLI:
+//
+//	config.InteractiveAuth = k6provider.DeviceCodeAuth(k6provider.DeviceCodeConfig{
+//		Issuer:   "https://auth.example.com",
+//		ClientID: "k6-cli",
+//		Prompt: func(auth k6provider.DeviceAuthorization) error {
+//			fmt.Printf("open %s and enter the code %s\n", auth.VerificationURI, auth.UserCode)
+//			return nil
+//		},
+//	})
+//
+// If the user denies the authorization or the code expires, an [ErrDeviceCodeDenied] error is returned.
+func DeviceCodeAuth(config DeviceCodeConfig) InteractiveAuthHandler {
+	return func(ctx context.Context) (string, error) {
+		if config.Prompt == nil {
+			return "", errors.New("device code prompt not set")
+		}
+
+		client := config.HTTPClient
+		if client == nil {
+			client = http.DefaultClient
+		}
+
+		config, err := config.discover(ctx, client)
+		if err != nil {
+			return "", err
+		}
+
+		code := deviceCode{}
+		err = postForm(ctx, client, config.DeviceAuthorizationURL, url.Values{
+			"client_id": {config.ClientID},
+			"scope":     {strings.Join(config.Scopes, " ")},
+		}, &code)
+		if err != nil {
+			return "", fmt.Errorf("requesting device code: %w", err)
+		}
+
+		err = config.Prompt(DeviceAuthorization{
+			UserCode:                code.UserCode,
+			VerificationURI:         code.VerificationURI,
+			VerificationURIComplete: code.VerificationURIComplete,
+			ExpiresIn:               time.Duration(code.ExpiresIn) * time.Second,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return config.pollToken(ctx, client, code)
+	}
+}
+
+// deviceCode is the response of the device authorization endpoint
+type deviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// tokenResponse is the response of the token endpoint, either the token or an error
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	Error       string `json:"error"`
+}
+
+// discover returns the configuration with the endpoints not specified discovered from the
+// configuration of the OpenID Connect provider
+func (c DeviceCodeConfig) discover(ctx context.Context, client *http.Client) (DeviceCodeConfig, error) {
+	if c.Issuer == "" || (c.DeviceAuthorizationURL != "" && c.TokenURL != "") {
+		return c, nil
+	}
+
+	wellKnown := strings.TrimSuffix(c.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return c, err
+	}
+
+	discovered := struct {
+		DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+		TokenEndpoint               string `json:"token_endpoint"`
+	}{}
+	if err = doJSON(client, req, &discovered); err != nil {
+		return c, fmt.Errorf("discovering endpoints: %w", err)
+	}
+
+	if c.DeviceAuthorizationURL == "" {
+		c.DeviceAuthorizationURL = discovered.DeviceAuthorizationEndpoint
+	}
+	if c.TokenURL == "" {
+		c.TokenURL = discovered.TokenEndpoint
+	}
+
+	return c, nil
+}
+
+// pollToken requests the token until the user authorizes the device, denies it or the code expires
+func (c DeviceCodeConfig) pollToken(ctx context.Context, client *http.Client, code deviceCode) (string, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultDeviceCodeInterval
+	}
+
+	for {
+		token := tokenResponse{}
+		err := postForm(ctx, client, c.TokenURL, url.Values{
+			"grant_type":  {deviceCodeGrantType},
+			"device_code": {code.DeviceCode},
+			"client_id":   {c.ClientID},
+		}, &token)
+		// errors are reported in the body of unsuccessful responses
+		if err != nil && token.Error == "" {
+			return "", fmt.Errorf("requesting token: %w", err)
+		}
+
+		switch token.Error {
+		case "":
+			if token.AccessToken == "" {
+				return "", errors.New("requesting token: empty token")
+			}
+			return token.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += slowDownIncrement
+		case "access_denied", "expired_token":
+			return "", NewWrappedError(ErrDeviceCodeDenied, errors.New(token.Error))
+		default:
+			return "", fmt.Errorf("requesting token: %s", token.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// postForm posts the form to the URL and decodes the JSON response. The response is decoded even
+// if unsuccessful, as it may describe the error.
+func postForm(ctx context.Context, client *http.Client, to string, form url.Values, response any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, to, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return doJSON(client, req, response)
+}
+
+// doJSON sends the request and decodes the JSON response, returning an error if it is not successful
+func doJSON(client *http.Client, req *http.Request, response any) error {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	decodeErr := json.NewDecoder(resp.Body).Decode(response)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return decodeErr
+}
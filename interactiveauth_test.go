@@ -0,0 +1,180 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestInteractiveAuth(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title        string
+		token        string
+		handlerErr   error
+		expectStatus int
+		expectErr    bool
+	}{
+		{title: "token obtained", token: "token", expectStatus: http.StatusOK},
+		{title: "token rejected", token: "other", expectStatus: http.StatusUnauthorized},
+		{title: "authentication failed", handlerErr: ErrDeviceCodeDenied, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Header.Get("Authorization") != "Bearer token" || string(body) != "request" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			t.Cleanup(srv.Close)
+
+			calls := atomic.Int32{}
+			auth := newInteractiveAuth(Config{
+				InteractiveAuth: func(context.Context) (string, error) {
+					calls.Add(1)
+					return tc.token, tc.handlerErr
+				},
+			})
+			client := withInteractiveAuth(&http.Client{}, auth)
+
+			// concurrent requests rejected with the same credentials obtain the token once
+			wg := sync.WaitGroup{}
+			for range 3 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("request")) //nolint:noctx
+					if (err != nil) != tc.expectErr {
+						t.Errorf("expected error %v got %v", tc.expectErr, err)
+						return
+					}
+					if err != nil {
+						return
+					}
+					_ = resp.Body.Close()
+
+					if resp.StatusCode != tc.expectStatus {
+						t.Errorf("expected status %d got %d", tc.expectStatus, resp.StatusCode)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if tc.handlerErr == nil && calls.Load() != 1 {
+				t.Fatalf("expected 1 call got %d", calls.Load())
+			}
+		})
+	}
+}
+
+func TestDeviceCodeAuth(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title       string
+		responses   []string
+		expectToken string
+		expectErr   error
+	}{
+		{
+			title:       "authorized",
+			responses:   []string{"", "token"},
+			expectToken: "token",
+		},
+		{
+			title:     "denied",
+			responses: []string{"access_denied"},
+			expectErr: ErrDeviceCodeDenied,
+		},
+		{
+			title:     "expired",
+			responses: []string{"expired_token"},
+			expectErr: ErrDeviceCodeDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			polls := atomic.Int32{}
+			mux := http.NewServeMux()
+			srv := httptest.NewServer(mux)
+			t.Cleanup(srv.Close)
+
+			mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+				_ = json.NewEncoder(w).Encode(map[string]string{
+					"device_authorization_endpoint": srv.URL + "/device",
+					"token_endpoint":                srv.URL + "/token",
+				})
+			})
+			mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+				if r.FormValue("client_id") != "k6" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"device_code":      "device",
+					"user_code":        "USER-CODE",
+					"verification_uri": srv.URL + "/verify",
+					"expires_in":       60,
+					"interval":         1,
+				})
+			})
+			mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+				if r.FormValue("device_code") != "device" || r.FormValue("grant_type") != deviceCodeGrantType {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				response := tc.responses[min(int(polls.Add(1))-1, len(tc.responses)-1)]
+				switch response {
+				case "":
+					w.WriteHeader(http.StatusBadRequest)
+					_ = json.NewEncoder(w).Encode(map[string]string{"error": "authorization_pending"})
+				case "access_denied", "expired_token":
+					w.WriteHeader(http.StatusBadRequest)
+					_ = json.NewEncoder(w).Encode(map[string]string{"error": response})
+				default:
+					_ = json.NewEncoder(w).Encode(map[string]string{"access_token": response, "token_type": "Bearer"})
+				}
+			})
+
+			prompted := DeviceAuthorization{}
+			handler := DeviceCodeAuth(DeviceCodeConfig{
+				Issuer:   srv.URL,
+				ClientID: "k6",
+				Prompt: func(auth DeviceAuthorization) error {
+					prompted = auth
+					return nil
+				},
+			})
+
+			token, err := handler(context.TODO())
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if token != tc.expectToken {
+				t.Fatalf("expected token %q got %q", tc.expectToken, token)
+			}
+			if prompted.UserCode != "USER-CODE" || prompted.VerificationURI != srv.URL+"/verify" {
+				t.Fatalf("unexpected prompt %+v", prompted)
+			}
+		})
+	}
+}
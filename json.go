@@ -0,0 +1,65 @@
+package k6provider
+
+import (
+	"encoding/json"
+)
+
+// k6BinaryJSON prevents the recursive call to K6Binary's MarshalJSON
+type k6BinaryJSON K6Binary
+
+// artifactJSON prevents the recursive call to Artifact's MarshalJSON
+type artifactJSON Artifact
+
+// MarshalJSON implements the json.Marshaler interface
+func (b K6Binary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k6BinaryJSON(b))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (b *K6Binary) UnmarshalJSON(data []byte) error {
+	var binary k6BinaryJSON
+	if err := json.Unmarshal(data, &binary); err != nil {
+		return err
+	}
+
+	*b = K6Binary(binary)
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+// The text representation of the binary is its JSON representation.
+func (b K6Binary) MarshalText() ([]byte, error) {
+	return b.MarshalJSON()
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (b *K6Binary) UnmarshalText(text []byte) error {
+	return b.UnmarshalJSON(text)
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (a Artifact) MarshalJSON() ([]byte, error) {
+	return json.Marshal(artifactJSON(a))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (a *Artifact) UnmarshalJSON(data []byte) error {
+	var artifact artifactJSON
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return err
+	}
+
+	*a = Artifact(artifact)
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+// The text representation of the artifact is its JSON representation.
+func (a Artifact) MarshalText() ([]byte, error) {
+	return a.MarshalJSON()
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (a *Artifact) UnmarshalText(text []byte) error {
+	return a.UnmarshalJSON(text)
+}
@@ -0,0 +1,81 @@
+package k6provider
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestK6BinaryJSON(t *testing.T) {
+	t.Parallel()
+
+	binary := K6Binary{
+		Path:         "/path/to/k6",
+		Dependencies: map[string]string{"k6": "v0.50.0", "k6/x/kubernetes": "v0.9.0"},
+		Checksum:     "checksum",
+	}
+
+	data, err := json.Marshal(binary)
+	if err != nil {
+		t.Fatalf("marshaling %v", err)
+	}
+
+	expected := `{"path":"/path/to/k6","dependencies":{"k6":"v0.50.0","k6/x/kubernetes":"v0.9.0"},"checksum":"checksum"}`
+	if string(data) != expected {
+		t.Fatalf("expected %s got %s", expected, string(data))
+	}
+
+	unmarshaled := K6Binary{}
+	if err = json.Unmarshal(data, &unmarshaled); err != nil {
+		t.Fatalf("unmarshaling %v", err)
+	}
+
+	if !reflect.DeepEqual(binary, unmarshaled) {
+		t.Fatalf("expected %v got %v", binary, unmarshaled)
+	}
+
+	text, err := binary.MarshalText()
+	if err != nil {
+		t.Fatalf("marshaling text %v", err)
+	}
+
+	unmarshaled = K6Binary{}
+	if err = unmarshaled.UnmarshalText(text); err != nil {
+		t.Fatalf("unmarshaling text %v", err)
+	}
+
+	if !reflect.DeepEqual(binary, unmarshaled) {
+		t.Fatalf("expected %v got %v", binary, unmarshaled)
+	}
+
+	expectedDeps := `k6:"v0.50.0";k6/x/kubernetes:"v0.9.0";`
+	if binary.FormatDeps() != expectedDeps {
+		t.Fatalf("expected %s got %s", expectedDeps, binary.FormatDeps())
+	}
+}
+
+func TestArtifactJSON(t *testing.T) {
+	t.Parallel()
+
+	artifact := Artifact{
+		ID:           "id",
+		URL:          "http://example.com/id",
+		Dependencies: map[string]string{"k6": "v0.50.0"},
+		Platform:     "linux/amd64",
+		Checksum:     "checksum",
+	}
+
+	data, err := json.Marshal(artifact)
+	if err != nil {
+		t.Fatalf("marshaling %v", err)
+	}
+
+	unmarshaled := Artifact{}
+	if err = json.Unmarshal(data, &unmarshaled); err != nil {
+		t.Fatalf("unmarshaling %v", err)
+	}
+
+	if !reflect.DeepEqual(artifact, unmarshaled) {
+		t.Fatalf("expected %v got %v", artifact, unmarshaled)
+	}
+}
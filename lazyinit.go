@@ -0,0 +1,98 @@
+package k6provider
+
+import (
+	"context"
+)
+
+// Init validates the configuration of the build services, such as their URL, and checks the
+// build service used for the provider's platform can be reached with the configured credentials
+// by requesting its capabilities. If the build service reports it doesn't support the platform,
+// an [ErrConfig] error listing the platforms it supports is returned. It can be used, for
+// example, as the readiness check of a service.
+//
+// With [Config.LazyInit], the build services are configured the first time they are used, so
+// the provider can be created before the build service URL (K6_BUILD_SERVICE_URL) or its
+// credentials (K6_BUILD_SERVICE_AUTH) are available. Until they are, Init and the functions that
+// use the build service return an [ErrNotInitialized] error, which wraps an [ErrConfig] error
+// describing the problem. Once configured, the build services are not configured again.
+func (p *Provider) Init(ctx context.Context) error {
+	if p.ctx.Err() != nil {
+		return ErrClosed
+	}
+
+	if _, err := p.buildServices(); err != nil {
+		return p.recordError(err)
+	}
+
+	capabilities, err := p.ServiceCapabilities(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !capabilities.SupportsPlatform(p.platform) {
+		err = unsupportedPlatform(p.platform, capabilities.Platforms)
+		return p.recordError(NewWrappedError(ErrConfig, err))
+	}
+
+	return nil
+}
+
+// buildServices returns the build services, configuring them if they were not configured
+// when the provider was created (see [Config.LazyInit]). Returns an [ErrNetworkDisabled] error
+// if the network policy denies accessing the network, without configuring them, as it may
+// require accessing the network, for example, for discovering the build service
+func (p *Provider) buildServices() (*buildServices, error) {
+	if networkDenied(p.config) {
+		return nil, ErrNetworkDisabled
+	}
+
+	return p.initBuildServices()
+}
+
+// initBuildServices configures the build services, if they were not configured, and the cache
+// scope, if the cache is scoped by the build service
+func (p *Provider) initBuildServices() (*buildServices, error) {
+	p.initMutex.Lock()
+	defer p.initMutex.Unlock()
+
+	if p.buildSrv != nil {
+		return p.buildSrv, nil
+	}
+
+	buildSrv, buildSrvURL, err := newBuildServices(p.config)
+	if err != nil {
+		return nil, NewWrappedError(ErrNotInitialized, err)
+	}
+
+	// the artifacts of all the build services share the scope of the first one
+	p.buildSrv = buildSrv
+	if p.config.CacheScope == "" && p.config.ScopeCacheByBuildService {
+		p.cacheScope = scopeKey(buildSrvURL)
+	}
+
+	return buildSrv, nil
+}
+
+// scope returns the key of the cache scope. See [Provider.resolveScope]
+func (p *Provider) scope() string {
+	p.initMutex.Lock()
+	defer p.initMutex.Unlock()
+
+	return p.cacheScope
+}
+
+// resolveScope configures the build services before accessing the cache, if the cache is scoped
+// by the build service and they were not configured yet, so the binaries of other build services
+// are not used
+func (p *Provider) resolveScope() error {
+	p.initMutex.Lock()
+	pending := p.buildSrv == nil && p.config.CacheScope == "" && p.config.ScopeCacheByBuildService
+	p.initMutex.Unlock()
+
+	if !pending {
+		return nil
+	}
+
+	_, err := p.initBuildServices()
+	return err
+}
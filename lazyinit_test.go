@@ -0,0 +1,67 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6build/pkg/api"
+)
+
+func TestLazyInit(t *testing.T) { //nolint:paralleltest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/capabilities":
+			_ = json.NewEncoder(w).Encode(ServiceCapabilities{APIVersion: "v2"})
+		case "/build":
+			_ = json.NewEncoder(w).Encode(api.BuildResponse{Artifact: k6build.Artifact{ID: "artifact"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("K6_BUILD_SERVICE_URL", "")
+	t.Setenv("K6_BUILD_SERVICE_DISCOVERY_DOMAIN", "")
+
+	// without lazy initialization, the provider cannot be created without a build service
+	if _, err := NewProvider(Config{BinDir: t.TempDir()}); !errors.Is(err, ErrConfig) {
+		t.Fatalf("expected %v got %v", ErrConfig, err)
+	}
+
+	provider, err := NewProvider(Config{BinDir: t.TempDir(), LazyInit: true})
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	t.Cleanup(func() { _ = provider.Close() })
+
+	// the build service URL is not yet available
+	err = provider.Init(context.TODO())
+	if !errors.Is(err, ErrNotInitialized) || !errors.Is(err, ErrConfig) {
+		t.Fatalf("expected %v got %v", ErrNotInitialized, err)
+	}
+
+	_, err = provider.GetArtifact(context.TODO(), nil)
+	if !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("expected %v got %v", ErrNotInitialized, err)
+	}
+
+	// the build service URL is received later
+	t.Setenv("K6_BUILD_SERVICE_URL", srv.URL)
+
+	if err = provider.Init(context.TODO()); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	artifact, err := provider.GetArtifact(context.TODO(), nil)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if artifact.BuildService != srv.URL {
+		t.Fatalf("expected %s got %s", srv.URL, artifact.BuildService)
+	}
+}
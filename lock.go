@@ -1,39 +1,87 @@
-//go:build !windows
-// +build !windows
-
 package k6provider
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
-	"syscall"
+	"time"
+
+	"github.com/grafana/k6provider/lock"
+)
+
+const (
+	// staleLockAge is the time after which an exclusive lock file is considered left by a process
+	// that did not release it
+	staleLockAge = 10 * time.Minute
+	// lockPollInterval is the time between attempts to place a lock held by another process
+	lockPollInterval = 100 * time.Millisecond
+	// lockFileName is the name of the lock file of advisory locks and named mutexes
+	lockFileName = "k6provider.lock"
+	// exclusiveLockFileName is the name of the lock file of exclusive locks
+	exclusiveLockFileName = "k6provider.excl.lock"
 )
 
 var (
-	// errLocked is returned when the file is already locked
-	errLocked = errors.New("file already locked")
 	// errLockFailed is returned when there's an error accessing the lock file
-	errLockFailed = errors.New("failed to lock file")
+	errLockFailed = lock.ErrLockFailed
 	// errUnLockFailed is returned when there's an error unlocking the file
-	errUnLockFailed = errors.New("failed to lock file")
+	errUnLockFailed = lock.ErrUnlockFailed
 )
 
-// A dirLock prevents concurrent access to a directory.
-// Only works on unix-like systems. Windows is not supported.
-// This code is inspired on the golang's filelock package:
-// https://pkg.go.dev/cmd/go/internal/lockedfile/internal/filelock
+// LockMode defines how the processes sharing a binary directory coordinate their access to it
+type LockMode string
+
+const (
+	// LockFile places an advisory lock on a lock file in the directory
+	LockFile LockMode = "file"
+	// LockNamedMutex acquires a named mutex derived from the directory's path. On windows, it
+	// is more robust than LockFile when multiple sessions share the directory, as it does not
+	// keep files open. On other systems, it is emulated with a lock file.
+	LockNamedMutex LockMode = "named-mutex"
+)
+
+// A dirLock prevents concurrent access to a directory by placing an advisory lock on
+// a lock file in it (see [lock.FileLock]), acquiring a named mutex (see [lock.NamedMutex]),
+// or by creating the lock file on network file systems.
 type dirLock struct {
-	mutex    sync.Mutex
-	lockFile string
-	fd       int
+	mutex     sync.Mutex
+	lockFile  string
+	locker    lock.Locker
+	exclusive bool
+	held      bool
 }
 
 func newFileLock(path string) *dirLock {
+	lockFile := filepath.Join(path, lockFileName)
+	return &dirLock{
+		lockFile: lockFile,
+		locker:   lock.New(lockFile),
+	}
+}
+
+// newNamedMutexLock returns a lock that acquires a named mutex for the directory
+func newNamedMutexLock(path string) *dirLock {
+	lockFile := filepath.Join(path, lockFileName)
+	return &dirLock{
+		lockFile: lockFile,
+		locker:   lock.NewNamedMutex(lockFile),
+	}
+}
+
+// newExclusiveFileLock returns a lock that is placed by creating the lock file with O_EXCL
+// and released by removing it, as advisory locks are not reliable on network file systems.
+// The lock file records when it was created, so locks left by processes that did not release
+// them can be detected regardless of the clock of the file server.
+func newExclusiveFileLock(path string) *dirLock {
 	return &dirLock{
-		lockFile: filepath.Join(path, "k6provider.lock"),
-		fd:       -1,
+		lockFile:  filepath.Join(path, exclusiveLockFileName),
+		exclusive: true,
 	}
 }
 
@@ -45,44 +93,112 @@ func (m *dirLock) lock() error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	// file open, assume already locked
-	if m.fd != -1 {
+	if m.exclusive {
+		return m.lockExclusive()
+	}
+
+	return m.locker.TryLock()
+}
+
+// lockWithContext places the lock, waiting until it is released if it is held by another
+// process or the context is done. In this case, returns an error that matches both
+// ErrLocked and the context's error.
+func (m *dirLock) lockWithContext(ctx context.Context) error {
+	for {
+		err := m.lock()
+		if !errors.Is(err, ErrLocked) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrLocked, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (m *dirLock) unlock() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.exclusive {
+		return m.unlockExclusive()
+	}
+
+	return m.locker.Unlock()
+}
+
+// lockExclusive creates the lock file, failing if it exists unless it is stale
+func (m *dirLock) lockExclusive() error {
+	if m.held {
 		return nil
 	}
 
-	fd, err := syscall.Open(m.lockFile, syscall.O_RDWR|syscall.O_CREAT, 0o600)
+	err := m.createLockFile()
+	if errors.Is(err, fs.ErrExist) && m.stale() {
+		_ = os.Remove(m.lockFile)
+		err = m.createLockFile()
+	}
+
+	if errors.Is(err, fs.ErrExist) {
+		return ErrLocked
+	}
 	if err != nil {
 		return fmt.Errorf("%w %w", errLockFailed, err)
 	}
-	err = syscall.Flock(fd, syscall.LOCK_EX|syscall.LOCK_NB)
-	if err == nil {
-		m.fd = fd
-		return nil
+
+	m.held = true
+	return nil
+}
+
+// createLockFile creates the lock file recording the time it was created
+func (m *dirLock) createLockFile() error {
+	f, err := os.OpenFile(m.lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
 	}
 
-	if errors.Is(err, syscall.EWOULDBLOCK) {
-		return errLocked
+	_, err = fmt.Fprintf(f, "%d\n", time.Now().UnixNano())
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(m.lockFile)
 	}
 
-	return fmt.Errorf("%w %w", errLockFailed, err)
+	return err
 }
 
-func (m *dirLock) unlock() error {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+// stale returns true if the lock file was created more than staleLockAge ago. If the time
+// it was created cannot be read, for example, because the process was interrupted while
+// creating it, its modification time is used.
+func (m *dirLock) stale() bool {
+	content, err := os.ReadFile(m.lockFile)
+	if err != nil {
+		return false
+	}
 
-	// if file is not open, assume already unlocked
-	if m.fd == -1 {
-		return nil
+	nanos, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err == nil {
+		return time.Since(time.Unix(0, nanos)) > staleLockAge
 	}
 
-	defer func() {
-		_ = syscall.Close(m.fd)
-		m.fd = -1
-	}()
+	info, err := os.Stat(m.lockFile)
+	return err == nil && time.Since(info.ModTime()) > staleLockAge
+}
 
-	err := syscall.Flock(m.fd, syscall.LOCK_UN)
-	if err != nil {
+// unlockExclusive removes the lock file
+func (m *dirLock) unlockExclusive() error {
+	if !m.held {
+		return nil
+	}
+	m.held = false
+
+	if err := os.Remove(m.lockFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return fmt.Errorf("%w %w", errUnLockFailed, err)
 	}
 	return nil
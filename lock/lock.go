@@ -0,0 +1,147 @@
+// Package lock implements advisory file locks for coordinating processes that share a
+// directory, such as the binary cache of a k6provider.
+//
+// Locks are placed on a lock file using flock(2) on unix-like systems and LockFileEx on
+// windows (see [FileLock]) or, alternatively, using named mutexes on windows (see [NamedMutex]).
+// They are held until released or the process exits.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// pollInterval is the time between attempts to place a lock held by another process
+const pollInterval = 50 * time.Millisecond
+
+var (
+	// ErrLocked is returned when the lock is held by another process or another FileLock
+	ErrLocked = errors.New("file already locked")
+	// ErrLockFailed is returned when there's an error accessing the lock file
+	ErrLockFailed = errors.New("failed to lock file")
+	// ErrUnlockFailed is returned when there's an error releasing the lock
+	ErrUnlockFailed = errors.New("failed to unlock file")
+)
+
+// Locker is a lock shared between processes
+type Locker interface {
+	// TryLock places the lock without waiting. If the lock is held, returns [ErrLocked]
+	TryLock() error
+	// Lock places the lock, waiting until it is released if held or the context is done
+	Lock(ctx context.Context) error
+	// LockWithTimeout places the lock, waiting up to the timeout until it is released if held
+	LockWithTimeout(timeout time.Duration) error
+	// Unlock releases the lock
+	Unlock() error
+}
+
+// FileLock is an advisory lock on a file. It is safe for concurrent use, but it is not
+// reentrant: locking a FileLock already held succeeds without placing a new lock.
+type FileLock struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+}
+
+// New returns a lock on the file at the given path, which is created when locked if it doesn't exist
+func New(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+// Path returns the path to the lock file
+func (l *FileLock) Path() string {
+	return l.path
+}
+
+// TryLock places the lock without waiting. If the lock is held by another process or
+// another FileLock, returns [ErrLocked].
+func (l *FileLock) TryLock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// file open, already locked
+	if l.file != nil {
+		return nil
+	}
+
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLockFailed, err)
+	}
+
+	if err := lockFile(file); err != nil {
+		_ = file.Close()
+		if errors.Is(err, ErrLocked) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrLockFailed, err)
+	}
+
+	l.file = file
+	return nil
+}
+
+// Lock places the lock, waiting until it is released if held by another process or another
+// FileLock. If the context is done before the lock is placed, returns an error that
+// matches both [ErrLocked] and the context's error.
+func (l *FileLock) Lock(ctx context.Context) error {
+	return lockWithContext(ctx, l.TryLock)
+}
+
+// LockWithTimeout places the lock, waiting up to the timeout until it is released if held
+// by another process or another FileLock. See [FileLock.Lock]
+func (l *FileLock) LockWithTimeout(timeout time.Duration) error {
+	return lockWithTimeout(timeout, l.TryLock)
+}
+
+// Unlock releases the lock. Unlocking a lock that is not held has no effect.
+func (l *FileLock) Unlock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// if file is not open, already unlocked
+	if l.file == nil {
+		return nil
+	}
+
+	defer func() {
+		_ = l.file.Close()
+		l.file = nil
+	}()
+
+	if err := unlockFile(l.file); err != nil {
+		return fmt.Errorf("%w: %w", ErrUnlockFailed, err)
+	}
+
+	return nil
+}
+
+// lockWithContext tries to place a lock until it succeeds, fails with an error other than
+// [ErrLocked] or the context is done
+func lockWithContext(ctx context.Context, tryLock func() error) error {
+	for {
+		err := tryLock()
+		if !errors.Is(err, ErrLocked) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrLocked, ctx.Err())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// lockWithTimeout tries to place a lock until it succeeds, fails with an error other than
+// [ErrLocked] or the timeout expires
+func lockWithTimeout(timeout time.Duration, tryLock func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return lockWithContext(ctx, tryLock)
+}
@@ -0,0 +1,154 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTryLock(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	l := New(path)
+
+	// should lock without errors
+	if err := l.TryLock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// locking again should return without errors
+	if err := l.TryLock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// another lock should return ErrLocked
+	if err := New(path).TryLock(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected %v got %v", ErrLocked, err)
+	}
+
+	// unlock should work
+	if err := l.Unlock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// unlocking again should return without errors
+	if err := l.Unlock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// trying another lock again should work now
+	other := New(path)
+	if err := other.TryLock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	t.Cleanup(func() { _ = other.Unlock() })
+
+	// retrying original lock should return ErrLocked
+	if err := l.TryLock(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected %v got %v", ErrLocked, err)
+	}
+
+	// trying to lock in a non-existing dir should fail
+	if err := New(filepath.Join(t.TempDir(), "missing", "test.lock")).TryLock(); !errors.Is(err, ErrLockFailed) {
+		t.Fatalf("expected %v got %v", ErrLockFailed, err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title     string
+		release   time.Duration
+		timeout   time.Duration
+		expectErr error
+	}{
+		{
+			title:     "lock released before timeout",
+			release:   100 * time.Millisecond,
+			timeout:   5 * time.Second,
+			expectErr: nil,
+		},
+		{
+			title:     "timeout",
+			release:   5 * time.Second,
+			timeout:   100 * time.Millisecond,
+			expectErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "test.lock")
+
+			held := New(path)
+			if err := held.TryLock(); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			release := time.AfterFunc(tc.release, func() { _ = held.Unlock() })
+			t.Cleanup(func() {
+				release.Stop()
+				_ = held.Unlock()
+			})
+
+			l := New(path)
+			err := l.LockWithTimeout(tc.timeout)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrLocked) {
+				t.Fatalf("expected %v got %v", ErrLocked, err)
+			}
+			_ = l.Unlock()
+		})
+	}
+}
+
+func TestNamedMutex(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	m := NewNamedMutex(path)
+	if !strings.HasPrefix(m.Name(), `Global\k6provider-`) {
+		t.Fatalf("unexpected name %s", m.Name())
+	}
+
+	if err := m.TryLock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// another mutex for the same path should return ErrLocked
+	if err := NewNamedMutex(path).LockWithTimeout(100 * time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected %v got %v", ErrLocked, err)
+	}
+
+	// a mutex for another path is independent
+	other := NewNamedMutex(filepath.Join(t.TempDir(), "test.lock"))
+	if err := other.TryLock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	t.Cleanup(func() { _ = other.Unlock() })
+
+	if err := m.Unlock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// unlocking again should return without errors
+	if err := m.Unlock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	again := NewNamedMutex(path)
+	if err := again.Lock(context.Background()); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	_ = again.Unlock()
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package lock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile places an exclusive advisory lock on the file without blocking
+func lockFile(file *os.File) error {
+	//nolint:gosec // file descriptors fit in an int
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrLocked
+	}
+	return err
+}
+
+// unlockFile releases the advisory lock on the file
+func unlockFile(file *os.File) error {
+	//nolint:gosec // file descriptors fit in an int
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
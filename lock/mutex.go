@@ -0,0 +1,94 @@
+package lock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NamedMutex is a lock identified by a name, shared by all the processes in the host.
+//
+// On windows, it is a named mutex in the global namespace, which is released by the system
+// when the process holding it exits, even if it is in a different session, and does not keep
+// any file open. On other systems, it is emulated with a [FileLock] in the temporary directory.
+type NamedMutex struct {
+	mutex   sync.Mutex
+	name    string
+	release func() error
+}
+
+// NewNamedMutex returns a named mutex for the given path, named Global\k6provider-<hash>
+// where <hash> is derived from the absolute path, so all the processes locking the same
+// path use the same mutex
+func NewNamedMutex(path string) *NamedMutex {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	// paths are case-insensitive on windows
+	if runtime.GOOS == "windows" {
+		path = strings.ToLower(path)
+	}
+
+	hash := sha256.Sum256([]byte(path))
+
+	return &NamedMutex{name: fmt.Sprintf(`Global\k6provider-%s`, hex.EncodeToString(hash[:8]))}
+}
+
+// Name returns the name of the mutex
+func (m *NamedMutex) Name() string {
+	return m.name
+}
+
+// TryLock acquires the mutex without waiting. If the mutex is held by another process or
+// another NamedMutex, returns [ErrLocked].
+func (m *NamedMutex) TryLock() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// already held
+	if m.release != nil {
+		return nil
+	}
+
+	release, err := acquireMutex(m.name)
+	if err != nil {
+		return err
+	}
+
+	m.release = release
+	return nil
+}
+
+// Lock acquires the mutex, waiting until it is released if held by another process or
+// another NamedMutex. See [FileLock.Lock]
+func (m *NamedMutex) Lock(ctx context.Context) error {
+	return lockWithContext(ctx, m.TryLock)
+}
+
+// LockWithTimeout acquires the mutex, waiting up to the timeout until it is released if
+// held by another process or another NamedMutex. See [FileLock.Lock]
+func (m *NamedMutex) LockWithTimeout(timeout time.Duration) error {
+	return lockWithTimeout(timeout, m.TryLock)
+}
+
+// Unlock releases the mutex. Unlocking a mutex that is not held has no effect.
+func (m *NamedMutex) Unlock() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.release == nil {
+		return nil
+	}
+
+	release := m.release
+	m.release = nil
+
+	return release()
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// acquireMutex acquires the named mutex without waiting, returning the function that releases it.
+// Named mutexes are emulated with a lock file in the temporary directory.
+func acquireMutex(name string) (func() error, error) {
+	path := filepath.Join(os.TempDir(), strings.TrimPrefix(name, `Global\`)+".lock")
+
+	fileLock := New(path)
+	if err := fileLock.TryLock(); err != nil {
+		return nil, err
+	}
+
+	return fileLock.Unlock, nil
+}
@@ -0,0 +1,68 @@
+//go:build windows
+// +build windows
+
+package lock
+
+import (
+	"fmt"
+	"runtime"
+
+	"golang.org/x/sys/windows"
+)
+
+// acquireMutex acquires the named mutex without waiting, returning the function that releases it.
+//
+// A mutex is owned by the thread that acquired it and can only be released by it, so it is
+// acquired and released by a goroutine locked to its thread.
+func acquireMutex(name string) (func() error, error) {
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLockFailed, err)
+	}
+
+	acquired := make(chan error)
+	release := make(chan struct{})
+	released := make(chan error)
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		// the handle is returned along with ERROR_ALREADY_EXISTS if the mutex exists
+		handle, err := windows.CreateMutex(nil, false, namePtr)
+		if handle == 0 {
+			acquired <- fmt.Errorf("%w: %w", ErrLockFailed, err)
+			return
+		}
+		defer windows.CloseHandle(handle) //nolint:errcheck
+
+		event, err := windows.WaitForSingleObject(handle, 0)
+		switch {
+		case err != nil:
+			acquired <- fmt.Errorf("%w: %w", ErrLockFailed, err)
+			return
+		case event == uint32(windows.WAIT_TIMEOUT):
+			acquired <- ErrLocked
+			return
+		}
+
+		// the mutex is acquired (WAIT_OBJECT_0) or was abandoned by a process
+		// that exited without releasing it (WAIT_ABANDONED)
+		acquired <- nil
+
+		<-release
+		released <- windows.ReleaseMutex(handle)
+	}()
+
+	if err := <-acquired; err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		close(release)
+		if err := <-released; err != nil {
+			return fmt.Errorf("%w: %w", ErrUnlockFailed, err)
+		}
+		return nil
+	}, nil
+}
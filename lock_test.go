@@ -1,11 +1,18 @@
-//go:build !windows
-// +build !windows
-
 package k6provider
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
 )
 
 func TestLock(t *testing.T) {
@@ -27,7 +34,7 @@ func TestLock(t *testing.T) {
 	}
 
 	// another lock should return ErrLocked
-	if err := newFileLock(dir).lock(); !errors.Is(err, errLocked) {
+	if err := newFileLock(dir).lock(); !errors.Is(err, ErrLocked) {
 		t.Fatalf("unexpected %v", err)
 	}
 
@@ -52,7 +59,7 @@ func TestLock(t *testing.T) {
 	}
 
 	// retrying original lock should return ErrLocked
-	if err := l.lock(); !errors.Is(err, errLocked) {
+	if err := l.lock(); !errors.Is(err, ErrLocked) {
 		t.Fatalf("unexpected %v", err)
 	}
 
@@ -61,3 +68,145 @@ func TestLock(t *testing.T) {
 		t.Fatalf("unexpected %v", err)
 	}
 }
+
+func TestExclusiveLock(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	l := newExclusiveFileLock(dir)
+
+	if err := l.lock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// locking again should return without errors
+	if err := l.lock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// another lock should return ErrLocked
+	if err := newExclusiveFileLock(dir).lock(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if err := l.unlock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// trying another lock again should work now
+	other := newExclusiveFileLock(dir)
+	if err := other.lock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// a lock left by a process that did not release it is removed
+	stale := fmt.Sprintf("%d\n", time.Now().Add(-2*staleLockAge).UnixNano())
+	if err := os.WriteFile(other.lockFile, []byte(stale), 0o600); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if err := l.lock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// trying to lock a non-existing dir should fails
+	if err := newExclusiveFileLock("/path/to/non/existing/dir").lock(); !errors.Is(err, errLockFailed) {
+		t.Fatalf("unexpected %v", err)
+	}
+}
+
+func TestLockWithContext(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	held := newFileLock(dir)
+	if err := held.lock(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// the lock is not released before the context is done
+	err := newFileLock(dir).lockWithContext(ctx)
+	if !errors.Is(err, ErrLocked) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+
+	// the lock is placed once released
+	time.AfterFunc(200*time.Millisecond, func() { _ = held.unlock() })
+	if err := newFileLock(dir).lockWithContext(context.Background()); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+}
+
+func TestDownloadWaitsForLock(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		mode    LockMode
+		newLock func(string) *dirLock
+	}{
+		{mode: LockFile, newLock: newFileLock},
+		{mode: LockNamedMutex, newLock: newNamedMutexLock},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			t.Parallel()
+
+			testDownloadWaitsForLock(t, tc.mode, tc.newLock)
+		})
+	}
+}
+
+func testDownloadWaitsForLock(t *testing.T, mode LockMode, newLock func(string) *dirLock) {
+	t.Helper()
+
+	downloads := atomic.Int32{}
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		downloads.Add(1)
+		_, _ = w.Write([]byte("k6 binary"))
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", URL: store.URL}, nil
+		},
+	)
+
+	binDir := t.TempDir()
+	provider := newTestProvider(t, buildSrv, binDir)
+	provider.config.LockMode = mode
+
+	// another process is downloading the binary
+	artifactDir := filepath.Join(binDir, "artifact")
+	if err := os.MkdirAll(artifactDir, 0o700); err != nil {
+		t.Fatalf("test setup: creating dir %v", err)
+	}
+	other := newLock(artifactDir)
+	if err := other.lock(); err != nil {
+		t.Fatalf("test setup: locking %v", err)
+	}
+
+	time.AfterFunc(200*time.Millisecond, func() {
+		_ = os.WriteFile(filepath.Join(artifactDir, k6Binary), []byte("k6 binary"), 0o700) //nolint:gosec
+		_ = other.unlock()
+	})
+
+	binary, err := provider.GetBinary(context.TODO(), nil)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if binary.Path != filepath.Join(artifactDir, k6Binary) {
+		t.Fatalf("expected %s got %s", filepath.Join(artifactDir, k6Binary), binary.Path)
+	}
+
+	if downloads.Load() != 0 {
+		t.Fatalf("expected binary downloaded by the other process got %d downloads", downloads.Load())
+	}
+}
@@ -0,0 +1,196 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/grafana/k6deps"
+)
+
+// Lockfile records the artifact resolved for a set of dependencies, so the identical
+// binary can be obtained later using [Provider.GetBinaryFromLockfile]
+type Lockfile struct {
+	// ID of the artifact
+	ID string `json:"id"`
+	// Platform of the binary
+	Platform string `json:"platform"`
+	// Dependencies resolved versions as a map of name: version
+	// e.g. {"k6": "v0.50.0", "k6/x/kubernetes": "v0.9.0"}
+	Dependencies map[string]string `json:"dependencies"`
+	// Checksum of the binary
+	Checksum string `json:"checksum"`
+	// BuildOptions the binary was built with, if any. See [Provider.GetBinaryWithOptions]
+	BuildOptions *BuildOptions `json:"buildOptions,omitempty"`
+	// Catalog used for resolving the dependencies, if any. See [WithCatalog]
+	Catalog string `json:"catalog,omitempty"`
+	// Ref k6 reference the binary was built from, if any. See [Provider.GetBinaryForRef]
+	Ref string `json:"ref,omitempty"`
+}
+
+// Lockfile returns the lockfile for the binary
+func (b K6Binary) Lockfile() Lockfile {
+	return Lockfile{
+		ID:           b.ID,
+		Platform:     b.Platform,
+		Dependencies: b.Dependencies,
+		Checksum:     b.Checksum,
+		BuildOptions: b.BuildOptions,
+		Catalog:      b.Catalog,
+		Ref:          b.Ref,
+	}
+}
+
+// WriteLockfile writes the lockfile for the binary to the given path
+func WriteLockfile(path string, binary K6Binary) error {
+	content, err := json.MarshalIndent(binary.Lockfile(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0o600)
+}
+
+// ReadLockfile reads a lockfile from the given path
+func ReadLockfile(path string) (Lockfile, error) {
+	content, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return Lockfile{}, err
+	}
+
+	lockfile := Lockfile{}
+	if err = json.Unmarshal(content, &lockfile); err != nil {
+		return Lockfile{}, fmt.Errorf("parsing lockfile %w", err)
+	}
+
+	return lockfile, nil
+}
+
+// GetBinaryFromLockfile returns the binary described by a lockfile written by [WriteLockfile].
+//
+// If the binary is in the cache and matches the lockfile's checksum, it is returned without
+// contacting the build service. Otherwise, the artifact is requested from the build service
+// using the exact versions, or the k6 reference, and the catalog and build options in the
+// lockfile, and its binary is downloaded, replacing a cached binary that doesn't match the
+// checksum. If the artifact returned by the build service does not match the lockfile's ID
+// or checksum, an [ErrLockfile] error is returned.
+func (p *Provider) GetBinaryFromLockfile(ctx context.Context, path string) (K6Binary, error) {
+	lockfile, err := ReadLockfile(path)
+	if err != nil {
+		return K6Binary{}, NewWrappedError(ErrLockfile, err)
+	}
+
+	if platform, err := NormalizePlatform(lockfile.Platform); err != nil || platform != p.platform {
+		return K6Binary{}, NewWrappedError(
+			ErrLockfile,
+			fmt.Errorf("lockfile platform %q does not match %q", lockfile.Platform, p.platform),
+		)
+	}
+
+	binary, found, err := p.cachedLockfileBinary(ctx, lockfile)
+	if err != nil || found {
+		return binary, err
+	}
+
+	artifact, err := p.lockfileArtifact(ctx, lockfile)
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	if artifact.ID != lockfile.ID || artifact.Checksum != lockfile.Checksum {
+		return K6Binary{}, NewWrappedError(
+			ErrLockfile,
+			fmt.Errorf("artifact %s (checksum %s) does not match lockfile", artifact.ID, artifact.Checksum),
+		)
+	}
+
+	binary, err = p.binaryFor(ctx, artifact)
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	return p.deliverLockfileBinary(binary, lockfile)
+}
+
+// cachedLockfileBinary returns the binary of the lockfile's artifact from the cache, if it
+// matches the lockfile's checksum. A binary that doesn't match it is discarded, so it is
+// downloaded again. Returns the binary and true if found.
+func (p *Provider) cachedLockfileBinary(ctx context.Context, lockfile Lockfile) (K6Binary, bool, error) {
+	artifact := Artifact{
+		ID:           lockfile.ID,
+		Dependencies: lockfile.Dependencies,
+		Platform:     lockfile.Platform,
+		Checksum:     lockfile.Checksum,
+		BuildOptions: lockfile.BuildOptions,
+	}
+
+	binPath, found, err := p.lookupBinary(artifact)
+	if err != nil || !found {
+		return K6Binary{}, false, err
+	}
+
+	err = p.verifyBinary(binPath, lockfile.Checksum)
+	if err == nil {
+		binary, err := p.deliverLockfileBinary(newCachedBinary(binPath, artifact), lockfile)
+		return binary, err == nil, err
+	}
+	if !errors.Is(err, ErrChecksumMismatch) {
+		return K6Binary{}, false, NewWrappedError(ErrBinary, err)
+	}
+
+	// the binary was corrupted or replaced since the lockfile was written
+	artifactDir := filepath.Dir(binPath)
+	if discardErr := p.discardBinary(ctx, filepath.Dir(artifactDir), artifactDir); discardErr != nil {
+		return K6Binary{}, false, NewWrappedError(ErrBinary, fmt.Errorf("%w: %w", err, discardErr))
+	}
+
+	return K6Binary{}, false, nil
+}
+
+// lockfileArtifact requests the artifact for the exact versions of the lockfile's dependencies,
+// or for its k6 reference, using its catalog and build options, to the build service
+func (p *Provider) lockfileArtifact(ctx context.Context, lockfile Lockfile) (Artifact, error) {
+	if lockfile.Catalog != "" {
+		ctx = WithCatalog(ctx, lockfile.Catalog)
+	}
+	if lockfile.BuildOptions != nil {
+		ctx = WithBuildOptions(ctx, *lockfile.BuildOptions)
+	}
+
+	deps := make(k6deps.Dependencies, len(lockfile.Dependencies))
+	for name, version := range lockfile.Dependencies {
+		// the version of k6 built from a reference cannot be requested
+		if name == k6Module && lockfile.Ref != "" {
+			continue
+		}
+		dep, err := k6deps.NewDependency(name, "="+version)
+		if err != nil {
+			return Artifact{}, NewWrappedError(ErrLockfile, err)
+		}
+		deps[name] = dep
+	}
+
+	k6Constrains, buildDeps := p.buildDeps(deps)
+	if lockfile.Ref != "" {
+		k6Constrains = lockfile.Ref
+	}
+
+	return p.build(ctx, k6Constrains, buildDeps)
+}
+
+// deliverLockfileBinary delivers the binary, recording the catalog and reference of the lockfile,
+// so the lockfile written for it is the same
+func (p *Provider) deliverLockfileBinary(binary K6Binary, lockfile Lockfile) (K6Binary, error) {
+	binary, err := p.deliver(binary)
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	binary.Catalog = lockfile.Catalog
+	binary.Ref = lockfile.Ref
+
+	return binary, nil
+}
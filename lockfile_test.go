@@ -0,0 +1,198 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestGetBinaryFromLockfile(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	binary := K6Binary{
+		ID:           "artifact",
+		Platform:     "linux/amd64",
+		Dependencies: map[string]string{"k6": "v0.50.0"},
+		Checksum:     fmt.Sprintf("%x", sha256.Sum256(content)),
+	}
+
+	testCases := []struct {
+		title         string
+		cached        []byte
+		artifact      k6build.Artifact
+		expectErr     error
+		expectRequest bool
+	}{
+		{
+			title:         "binary in cache",
+			cached:        content,
+			expectErr:     nil,
+			expectRequest: false,
+		},
+		{
+			title:  "corrupted binary in cache",
+			cached: []byte("corrupted"),
+			artifact: k6build.Artifact{
+				ID:           binary.ID,
+				URL:          store.URL,
+				Platform:     binary.Platform,
+				Dependencies: binary.Dependencies,
+				Checksum:     binary.Checksum,
+			},
+			expectErr:     nil,
+			expectRequest: true,
+		},
+		{
+			title: "artifact does not match lockfile",
+			artifact: k6build.Artifact{
+				ID:           "other",
+				Platform:     "linux/amd64",
+				Dependencies: map[string]string{"k6": "v0.50.0"},
+				Checksum:     "other",
+			},
+			expectErr:     ErrLockfile,
+			expectRequest: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			binDir := t.TempDir()
+			lockfile := filepath.Join(t.TempDir(), "k6.lock")
+			if err := WriteLockfile(lockfile, binary); err != nil {
+				t.Fatalf("writing lockfile %v", err)
+			}
+
+			if tc.cached != nil {
+				binPath := filepath.Join(binDir, binary.ID, k6Binary)
+				if err := os.MkdirAll(filepath.Dir(binPath), 0o700); err != nil {
+					t.Fatalf("test setup: creating dir %v", err)
+				}
+				if err := os.WriteFile(binPath, tc.cached, 0o700); err != nil { //nolint:gosec
+					t.Fatalf("test setup: writing binary %v", err)
+				}
+			}
+
+			var requested string
+			buildSrv := buildServiceFunc(
+				func(_ context.Context, _ string, k6 string, _ []k6build.Dependency) (k6build.Artifact, error) {
+					requested = k6
+					return tc.artifact, nil
+				},
+			)
+			provider := newTestProvider(t, buildSrv, binDir)
+
+			k6, err := provider.GetBinaryFromLockfile(context.TODO(), lockfile)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			if tc.expectRequest && requested != "=v0.50.0" {
+				t.Fatalf("expected exact version requested got %q", requested)
+			}
+			if !tc.expectRequest && requested != "" {
+				t.Fatalf("build service should not be called for cached binaries")
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(k6.Lockfile(), binary.Lockfile()) {
+				t.Fatalf("expected %v got %v", binary.Lockfile(), k6.Lockfile())
+			}
+
+			// the binary matches the lockfile
+			if err = provider.verifyBinary(k6.Path, binary.Checksum); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+		})
+	}
+}
+
+func TestGetBinaryFromLockfileRequest(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	binary := K6Binary{
+		ID:           "artifact",
+		Platform:     "linux/amd64",
+		Dependencies: map[string]string{"k6": "v0.50.1-0.20240101000000-abcdef123456", "k6/x/faker": "v0.3.0"},
+		Checksum:     fmt.Sprintf("%x", sha256.Sum256(content)),
+		BuildOptions: &BuildOptions{Tags: []string{"netgo"}, CGO: true},
+		Catalog:      "https://example.com/catalog.json",
+		Ref:          "main",
+	}
+
+	lockfile := filepath.Join(t.TempDir(), "k6.lock")
+	if err := WriteLockfile(lockfile, binary); err != nil {
+		t.Fatalf("writing lockfile %v", err)
+	}
+
+	var (
+		requested string
+		catalog   string
+		options   BuildOptions
+		deps      []k6build.Dependency
+	)
+	buildSrv := buildServiceFunc(
+		func(ctx context.Context, _ string, k6 string, d []k6build.Dependency) (k6build.Artifact, error) {
+			requested, catalog, options, deps = k6, catalogFrom(ctx), buildOptionsFrom(ctx), d
+			return k6build.Artifact{
+				ID:           binary.ID,
+				URL:          store.URL,
+				Platform:     binary.Platform,
+				Dependencies: binary.Dependencies,
+				Checksum:     binary.Checksum,
+			}, nil
+		},
+	)
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+
+	k6, err := provider.GetBinaryFromLockfile(context.TODO(), lockfile)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// the build service receives the same request the binary was built from
+	if requested != binary.Ref {
+		t.Fatalf("expected ref %q requested got %q", binary.Ref, requested)
+	}
+	if catalog != binary.Catalog {
+		t.Fatalf("expected catalog %q got %q", binary.Catalog, catalog)
+	}
+	if !reflect.DeepEqual(options, *binary.BuildOptions) {
+		t.Fatalf("expected options %v got %v", *binary.BuildOptions, options)
+	}
+	expectDeps := []k6build.Dependency{{Name: "k6/x/faker", Constraints: "=v0.3.0"}}
+	if !reflect.DeepEqual(deps, expectDeps) {
+		t.Fatalf("expected dependencies %v got %v", expectDeps, deps)
+	}
+
+	// the binary obtained has the same lockfile
+	if !reflect.DeepEqual(k6.Lockfile(), binary.Lockfile()) {
+		t.Fatalf("expected %v got %v", binary.Lockfile(), k6.Lockfile())
+	}
+}
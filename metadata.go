@@ -0,0 +1,322 @@
+package k6provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/grafana/k6build"
+)
+
+const (
+	// metadataFile is the file in the artifact directory with the artifact's metadata
+	metadataFile = "artifact.json"
+	// resolvedPrecision is the precision of the times the requests were resolved, so the metadata
+	// is not written on every request
+	resolvedPrecision = time.Minute
+)
+
+// artifactMetadata is the metadata of an artifact stored next to its binary
+type artifactMetadata struct {
+	// Artifact as returned by the build service
+	Artifact Artifact `json:"artifact"`
+	// Requests keys of the requests (platform and dependencies) satisfied by the artifact
+	Requests []string `json:"requests,omitempty"`
+	// Resolved last time each request was resolved to the artifact by the build service
+	Resolved map[string]time.Time `json:"resolved,omitempty"`
+	// Updated last time the metadata was updated
+	Updated time.Time `json:"updated"`
+	// Downloaded last time the binary was downloaded, or the store reported its content didn't
+	// change since it was downloaded. Not recorded by previous versions.
+	Downloaded time.Time `json:"downloaded,omitempty"`
+	// Size of the files in the artifact directory, including the binary's companion tools, recorded
+	// the first time its provisioning is recorded in the access log (see [Config.AccessAnalytics])
+	Size int64 `json:"size,omitempty"`
+}
+
+// requestKey returns a key that identifies a build request by its platform, catalog, build options
+// (given by their key) and dependencies
+func requestKey(
+	platform string,
+	catalog string,
+	options string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) string {
+	sorted := slices.Clone(deps)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "%s\n%s %s\n", platform, k6Module, k6Constrains)
+	if catalog != "" {
+		_, _ = fmt.Fprintf(hash, "catalog %s\n", catalog)
+	}
+	if options != "" {
+		_, _ = fmt.Fprintf(hash, "options %s\n", options)
+	}
+	for _, dep := range sorted {
+		_, _ = fmt.Fprintf(hash, "%s %s\n", dep.Name, dep.Constraints)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// readMetadata reads the metadata from an artifact directory
+func readMetadata(artifactDir string) (artifactMetadata, error) {
+	content, err := os.ReadFile(filepath.Join(artifactDir, metadataFile)) //nolint:gosec
+	if err != nil {
+		return artifactMetadata{}, err
+	}
+
+	metadata := artifactMetadata{}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return artifactMetadata{}, err
+	}
+
+	return metadata, nil
+}
+
+// writeMetadata writes the artifact's metadata in the artifact directory, adding the request
+// to the requests already satisfied by the artifact and recording when it was resolved.
+// The file is replaced atomically.
+func writeMetadata(artifactDir string, artifact Artifact, request string) error {
+	metadata, err := readMetadata(artifactDir)
+	if err != nil {
+		// missing or invalid metadata is replaced
+		metadata = artifactMetadata{}
+	}
+
+	// the index is only a hint, the request is found by the metadata if it cannot be indexed
+	_ = indexRequest(artifactDir, request)
+
+	if slices.Contains(metadata.Requests, request) && metadata.Artifact.ID == artifact.ID &&
+		time.Since(metadata.resolved(request)) < resolvedPrecision {
+		return nil
+	}
+
+	metadata.Artifact = artifact
+	if !slices.Contains(metadata.Requests, request) {
+		metadata.Requests = append(metadata.Requests, request)
+	}
+	if metadata.Resolved == nil {
+		metadata.Resolved = map[string]time.Time{}
+	}
+	metadata.Resolved[request] = time.Now()
+
+	return saveMetadata(artifactDir, metadata)
+}
+
+// recordDownload records in the artifact's metadata the binary in the artifact directory was
+// downloaded, or validated as unchanged, now
+func recordDownload(artifactDir string, artifact Artifact) error {
+	metadata, err := readMetadata(artifactDir)
+	if err != nil {
+		// missing or invalid metadata is replaced
+		metadata = artifactMetadata{}
+	}
+
+	metadata.Artifact = artifact
+	metadata.Downloaded = time.Now()
+	// the binary downloaded may have a different size
+	metadata.Size = 0
+
+	return saveMetadata(artifactDir, metadata)
+}
+
+// downloaded returns the last time the binary in the artifact directory was downloaded or
+// validated as unchanged. For metadata that doesn't record it, it is the modification time
+// of the directory, which is modified when the binary is moved into it.
+func downloaded(artifactDir string) (time.Time, error) {
+	if metadata, err := readMetadata(artifactDir); err == nil && !metadata.Downloaded.IsZero() {
+		return metadata.Downloaded, nil
+	}
+
+	info, err := os.Stat(artifactDir)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return info.ModTime(), nil
+}
+
+// resolved returns the last time the request was resolved to the artifact. For metadata that
+// doesn't record it, it is the last time the metadata was updated.
+func (m artifactMetadata) resolved(request string) time.Time {
+	if resolved, found := m.Resolved[request]; found {
+		return resolved
+	}
+	return m.Updated
+}
+
+// forgetRequest removes the request from the requests satisfied by the artifact, so it is not
+// found by [Provider.lookupRequest]
+func forgetRequest(artifactDir string, request string) error {
+	metadata, err := readMetadata(artifactDir)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(metadata.Requests, request) {
+		return nil
+	}
+	metadata.Requests = slices.DeleteFunc(metadata.Requests, func(r string) bool { return r == request })
+	delete(metadata.Resolved, request)
+
+	if err = saveMetadata(artifactDir, metadata); err != nil {
+		return err
+	}
+
+	return unindexRequest(artifactDir, request)
+}
+
+// saveMetadata writes the metadata in the artifact directory, replacing the file atomically
+func saveMetadata(artifactDir string, metadata artifactMetadata) error {
+	metadata.Updated = time.Now()
+
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(artifactDir, "."+metadataFile+"-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(artifactDir, metadataFile))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// lookupRequest looks in the cache directories for the binary of the artifact that most recently
+// satisfied the request in the past, if it was resolved within maxAge. If maxAge is 0, regardless
+// of when it was resolved. Returns the binary and true if found.
+//
+// The artifacts are found using the request index of each directory, reading the metadata of
+// every artifact only if the directory cannot be indexed (e.g. it is read only).
+func (p *Provider) lookupRequest(request string, maxAge time.Duration) (K6Binary, bool) {
+	// the binaries cannot be attributed to a build service until its scope is known
+	if err := p.resolveScope(); err != nil {
+		return K6Binary{}, false
+	}
+
+	var (
+		binary   K6Binary
+		resolved time.Time
+		found    bool
+	)
+
+	for _, dir := range p.binDirs() {
+		names, err := indexedArtifacts(dir, request)
+		if err != nil {
+			names, err = artifactNames(dir)
+		}
+		if err != nil {
+			continue
+		}
+
+		for _, name := range names {
+			artifactDir := filepath.Join(dir, name)
+			metadata, err := readMetadata(artifactDir)
+			if os.IsNotExist(err) {
+				// the artifact was removed, for example, by the pruner
+				_ = unindexRequest(artifactDir, request)
+			}
+			if err != nil || !slices.Contains(metadata.Requests, request) {
+				continue
+			}
+
+			// artifacts from other cache scopes are ignored
+			if p.artifactDir(dir, metadata.Artifact) != artifactDir {
+				continue
+			}
+
+			if maxAge > 0 && time.Since(metadata.resolved(request)) > maxAge {
+				continue
+			}
+
+			// the artifact that satisfied the request most recently is preferred
+			if found && !metadata.resolved(request).After(resolved) {
+				continue
+			}
+
+			binPath := filepath.Join(artifactDir, k6Binary)
+			if _, err := os.Stat(binPath); err != nil {
+				continue
+			}
+
+			binary, resolved, found = newCachedBinary(binPath, metadata.Artifact), metadata.resolved(request), true
+		}
+	}
+
+	if found {
+		p.resolutions.Store(request, resolution{binary: binary, resolved: resolved})
+	}
+
+	return binary, found
+}
+
+// resolution is the binary a request was resolved to and when
+type resolution struct {
+	binary   K6Binary
+	resolved time.Time
+}
+
+// remember records the request was resolved to the binary now
+func (p *Provider) remember(request string, binary K6Binary) {
+	p.resolutions.Store(request, resolution{binary: binary, resolved: time.Now()})
+}
+
+// remembered returns the binary the request was last resolved to, if it was resolved within
+// maxAge (regardless of when if 0) and the binary still exists. Returns the binary and true if found.
+func (p *Provider) remembered(request string, maxAge time.Duration) (K6Binary, bool) {
+	value, _ := p.resolutions.Load(request)
+	last, found := value.(resolution)
+	if !found {
+		return K6Binary{}, false
+	}
+
+	if maxAge > 0 && time.Since(last.resolved) > maxAge {
+		return K6Binary{}, false
+	}
+
+	// the binary may have been pruned, by this or another process
+	if _, err := os.Stat(last.binary.Path); err != nil {
+		p.resolutions.Delete(request)
+		return K6Binary{}, false
+	}
+
+	return last.binary, true
+}
+
+// artifactNames returns the names of the artifact directories in the binary directory
+func artifactNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if isArtifactDir(entry) {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,82 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+func TestOfflineGetBinary(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("k6 binary"))
+	}))
+	t.Cleanup(store.Close)
+
+	available := atomic.Bool{}
+	available.Store(true)
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			if !available.Load() {
+				return k6build.Artifact{}, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+			}
+			return k6build.Artifact{
+				ID:           "artifact",
+				URL:          store.URL,
+				Platform:     "linux/amd64",
+				Dependencies: map[string]string{"k6": "v0.55.0", "k6/x/faker": "v0.4.0"},
+			}, nil
+		},
+	)
+
+	binDir := t.TempDir()
+	provider := newTestProvider(t, buildSrv, binDir)
+	provider.config.StaleIfError = time.Hour
+
+	deps := k6deps.Dependencies{}
+	if err := deps.UnmarshalText([]byte("k6>0.54;k6/x/faker>0.3")); err != nil {
+		t.Fatalf("parsing dependencies %v", err)
+	}
+
+	if _, err := provider.GetBinary(context.TODO(), deps); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	metadata, err := readMetadata(filepath.Join(binDir, "artifact"))
+	if err != nil {
+		t.Fatalf("reading metadata %v", err)
+	}
+	if metadata.Artifact.ID != "artifact" || len(metadata.Requests) != 1 {
+		t.Fatalf("unexpected metadata %v", metadata)
+	}
+
+	available.Store(false)
+
+	// the binary is returned from the cache with its metadata
+	binary, err := provider.GetBinary(context.TODO(), deps)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if binary.ID != "artifact" || binary.Dependencies["k6/x/faker"] != "v0.4.0" {
+		t.Fatalf("expected binary from cache got %v", binary)
+	}
+
+	// other dependencies are not satisfied by the cached binary
+	other := k6deps.Dependencies{}
+	if err := other.UnmarshalText([]byte("k6>0.54")); err != nil {
+		t.Fatalf("parsing dependencies %v", err)
+	}
+	if _, err := provider.GetBinary(context.TODO(), other); !errors.Is(err, ErrBuild) {
+		t.Fatalf("expected %v got %v", ErrBuild, err)
+	}
+}
@@ -0,0 +1,292 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MigrationReport summarizes the migration of a cache directory. See [Provider.MigrateCache]
+type MigrationReport struct {
+	// Migrated artifact directories moved to the new directory
+	Migrated []string
+	// Existing artifact directories not moved because the new directory already has their binary
+	Existing []string
+	// Invalid artifact directories not moved because they don't contain a valid binary
+	Invalid []string
+}
+
+// MigrateCache moves the binaries cached in oldDir to newDir, for example, when switching from
+// a cache in the os' temp dir to a persistent one, so the binaries already downloaded are not
+// downloaded again. Each artifact directory is moved with its metadata, so the binary is found
+// for the requests it satisfied, while holding the locks of both directories, so it is not
+// moved while being downloaded. The binaries are verified against their checksum before being
+// moved. The access log of oldDir is added to that of newDir.
+//
+// Artifact directories without a valid binary, or whose binary already exists in newDir, are left
+// in oldDir. The migration stops when the context is done, leaving the remaining artifacts in oldDir.
+func (p *Provider) MigrateCache(ctx context.Context, oldDir string, newDir string) (MigrationReport, error) {
+	if p.ctx.Err() != nil {
+		return MigrationReport{}, ErrClosed
+	}
+
+	entries, err := os.ReadDir(oldDir)
+	if err != nil {
+		return MigrationReport{}, NewWrappedError(ErrBinary, err)
+	}
+
+	if err = os.MkdirAll(newDir, cacheDirPerm); err != nil {
+		return MigrationReport{}, NewWrappedError(ErrBinary, storageError(err))
+	}
+
+	report := MigrationReport{}
+	errs := []error{}
+	for _, entry := range entries {
+		if !isArtifactDir(entry) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+
+		if err = p.migrateArtifact(ctx, oldDir, newDir, entry.Name(), &report); err != nil {
+			errs = append(errs, fmt.Errorf("migrating %s: %w", entry.Name(), err))
+		}
+	}
+
+	// the accesses are kept for recommending the size of the new cache
+	if err = migrateAccessLog(oldDir, newDir); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return report, NewWrappedError(ErrBinary, errors.Join(errs...))
+	}
+
+	return report, nil
+}
+
+// migrateArtifact moves an artifact directory from the old to the new binary directory,
+// adding the result to the report
+func (p *Provider) migrateArtifact(
+	ctx context.Context,
+	oldDir string,
+	newDir string,
+	name string,
+	report *MigrationReport,
+) error {
+	oldArtifactDir := filepath.Join(oldDir, name)
+	newArtifactDir := filepath.Join(newDir, name)
+
+	oldLock := p.downloadLock(oldDir, oldArtifactDir)
+	if err := oldLock.lockWithContext(ctx); err != nil {
+		return err
+	}
+	defer oldLock.unlock() //nolint:errcheck
+
+	binPath := filepath.Join(oldArtifactDir, k6Binary)
+	if _, err := os.Stat(binPath); err != nil {
+		report.Invalid = append(report.Invalid, oldArtifactDir)
+		return nil //nolint:nilerr
+	}
+
+	// binaries without metadata are not verified, as in VerifyCache
+	if metadata, err := readMetadata(oldArtifactDir); err == nil {
+		if err = p.verifyBinary(binPath, metadata.Artifact.Checksum); err != nil {
+			if !errors.Is(err, ErrChecksumMismatch) {
+				return err
+			}
+			report.Invalid = append(report.Invalid, oldArtifactDir)
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(newArtifactDir, cacheDirPerm); err != nil {
+		return storageError(err)
+	}
+	if err := p.permissions.shareDirs(newDir, newArtifactDir); err != nil {
+		return err
+	}
+
+	newLock := p.downloadLock(newDir, newArtifactDir)
+	if err := newLock.lockWithContext(ctx); err != nil {
+		return err
+	}
+	defer newLock.unlock() //nolint:errcheck
+
+	if _, err := os.Stat(filepath.Join(newArtifactDir, k6Binary)); err == nil {
+		report.Existing = append(report.Existing, oldArtifactDir)
+		return nil
+	}
+
+	if err := moveArtifactFiles(oldArtifactDir, newArtifactDir); err != nil {
+		return storageError(err)
+	}
+
+	newBinPath := filepath.Join(newArtifactDir, k6Binary)
+	if err := p.permissions.shareBinary(newBinPath); err != nil {
+		return err
+	}
+
+	// the binary is found by the requests it satisfied, even if they cannot be indexed
+	_ = indexArtifact(newArtifactDir)
+
+	report.Migrated = append(report.Migrated, oldArtifactDir)
+
+	// the lock file is removed with the directory
+	return os.RemoveAll(oldArtifactDir)
+}
+
+// moveArtifactFiles moves the files of an artifact directory to another directory, except for
+// the lock and partial files. The binary is moved last, as it completes the artifact.
+func moveArtifactFiles(from string, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == k6Binary || isTransientFile(name) {
+			continue
+		}
+
+		if err = moveEntry(filepath.Join(from, name), filepath.Join(to, name)); err != nil {
+			return err
+		}
+	}
+
+	return moveEntry(filepath.Join(from, k6Binary), filepath.Join(to, k6Binary))
+}
+
+// isTransientFile returns true if the file of an artifact directory is only meaningful to the
+// processes using it, such as lock files and partial downloads
+func isTransientFile(name string) bool {
+	return isLockFile(name) || strings.Contains(name, partialSuffix)
+}
+
+// isLockFile returns true if the file is the lock file of an advisory or exclusive lock
+func isLockFile(name string) bool {
+	return strings.HasPrefix(name, "k6provider.") && strings.HasSuffix(name, ".lock")
+}
+
+// moveEntry moves a file or directory, copying it if it cannot be renamed, for example, because
+// the destination is in another file system.
+func moveEntry(from string, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	if err := copyEntry(from, to); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(from)
+}
+
+// copyEntry copies a file or directory. Files are copied to a partial file that is renamed
+// once complete.
+func copyEntry(from string, to string) error {
+	info, err := os.Lstat(from)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return copyDir(from, to, info.Mode().Perm())
+	}
+
+	partial := to + partialSuffix
+	if err = copyFile(from, partial, info.Mode().Perm()); err != nil {
+		_ = os.Remove(partial)
+		return err
+	}
+
+	return os.Rename(partial, to)
+}
+
+// copyDir copies a directory recursively
+func copyDir(from string, to string, perm os.FileMode) error {
+	if err := os.MkdirAll(to, perm); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		src := filepath.Join(from, entry.Name())
+		dst := filepath.Join(to, entry.Name())
+		if entry.IsDir() {
+			err = copyDir(src, dst, info.Mode().Perm())
+		} else {
+			err = copyFile(src, dst, info.Mode().Perm())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies the content of a file to a new file with the given permissions
+func copyFile(from string, to string, perm os.FileMode) error {
+	src, err := os.Open(from) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer src.Close() //nolint:errcheck
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	_, err = defaultBuffers.copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// migrateAccessLog appends the access log of the old binary directory to that of the new one
+// and removes it
+func migrateAccessLog(oldDir string, newDir string) error {
+	oldLog := filepath.Join(oldDir, accessLogFile)
+	src, err := os.Open(oldLog) //nolint:gosec
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer src.Close() //nolint:errcheck
+
+	newLog := filepath.Join(newDir, accessLogFile)
+	dst, err := os.OpenFile(newLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, cacheFilePerm) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	_, err = defaultBuffers.copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(oldLog)
+}
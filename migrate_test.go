@@ -0,0 +1,138 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMigrateCache(t *testing.T) {
+	t.Parallel()
+
+	content := "k6"
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+
+	testCases := []struct {
+		title        string
+		binary       string
+		tools        bool
+		existing     bool
+		expectReport MigrationReport
+	}{
+		{
+			title:        "valid binary",
+			binary:       content,
+			expectReport: MigrationReport{Migrated: []string{"artifact"}},
+		},
+		{
+			title:        "binary with tools",
+			binary:       content,
+			tools:        true,
+			expectReport: MigrationReport{Migrated: []string{"artifact"}},
+		},
+		{
+			title:        "corrupted binary",
+			binary:       "corrupted",
+			expectReport: MigrationReport{Invalid: []string{"artifact"}},
+		},
+		{
+			title:        "missing binary",
+			binary:       "",
+			expectReport: MigrationReport{Invalid: []string{"artifact"}},
+		},
+		{
+			title:        "existing binary",
+			binary:       content,
+			existing:     true,
+			expectReport: MigrationReport{Existing: []string{"artifact"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			oldDir := t.TempDir()
+			newDir := filepath.Join(t.TempDir(), "cache")
+
+			artifactDir := filepath.Join(oldDir, "artifact")
+			if err := os.MkdirAll(filepath.Join(artifactDir, toolsDir), 0o700); err != nil {
+				t.Fatalf("test setup %v", err)
+			}
+			if err := writeMetadata(artifactDir, Artifact{ID: "artifact", Checksum: checksum}, "request"); err != nil {
+				t.Fatalf("test setup %v", err)
+			}
+			if tc.binary != "" {
+				if err := os.WriteFile(filepath.Join(artifactDir, k6Binary), []byte(tc.binary), 0o700); err != nil { //nolint:gosec
+					t.Fatalf("test setup %v", err)
+				}
+			}
+			if tc.tools {
+				tool := filepath.Join(artifactDir, toolsDir, "k6-agent")
+				if err := os.WriteFile(tool, []byte("agent"), 0o700); err != nil { //nolint:gosec
+					t.Fatalf("test setup %v", err)
+				}
+			}
+			if tc.existing {
+				existing := filepath.Join(newDir, "artifact")
+				if err := os.MkdirAll(existing, 0o700); err != nil {
+					t.Fatalf("test setup %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(existing, k6Binary), []byte(content), 0o700); err != nil { //nolint:gosec
+					t.Fatalf("test setup %v", err)
+				}
+			}
+			access := fmt.Sprintf("1 artifact %d\n", len(content))
+			if err := os.WriteFile(filepath.Join(oldDir, accessLogFile), []byte(access), 0o600); err != nil {
+				t.Fatalf("test setup %v", err)
+			}
+
+			provider := newTestProvider(t, nil, newDir)
+			report, err := provider.MigrateCache(context.TODO(), oldDir, newDir)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			expect := MigrationReport{}
+			for _, name := range tc.expectReport.Migrated {
+				expect.Migrated = append(expect.Migrated, filepath.Join(oldDir, name))
+			}
+			for _, name := range tc.expectReport.Existing {
+				expect.Existing = append(expect.Existing, filepath.Join(oldDir, name))
+			}
+			for _, name := range tc.expectReport.Invalid {
+				expect.Invalid = append(expect.Invalid, filepath.Join(oldDir, name))
+			}
+			if !slices.Equal(report.Migrated, expect.Migrated) ||
+				!slices.Equal(report.Existing, expect.Existing) ||
+				!slices.Equal(report.Invalid, expect.Invalid) {
+				t.Fatalf("expected %+v got %+v", expect, report)
+			}
+
+			accesses, err := os.ReadFile(filepath.Join(newDir, accessLogFile))
+			if err != nil || string(accesses) != access {
+				t.Fatalf("expected access log %q got %q (%v)", access, accesses, err)
+			}
+
+			if len(report.Migrated) == 0 {
+				return
+			}
+
+			if _, err = os.Stat(artifactDir); !os.IsNotExist(err) {
+				t.Fatalf("expected old artifact directory to be removed got %v", err)
+			}
+
+			binary, found := provider.lookupRequest("request", 0)
+			if !found {
+				t.Fatalf("expected binary for request to be found in the new directory")
+			}
+			if tc.tools && binary.Tools["k6-agent"] == "" {
+				t.Fatalf("expected tools to be migrated got %v", binary.Tools)
+			}
+		})
+	}
+}
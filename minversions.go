@@ -0,0 +1,95 @@
+package k6provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/grafana/k6build"
+)
+
+// MinimumVersionWarning describes the constraints requested for a dependency that only accept
+// versions older than its minimum version, which were replaced. See [Config.MinimumVersions]
+type MinimumVersionWarning struct {
+	// Dependency name of the dependency
+	Dependency string
+	// Requested constraints requested for the dependency
+	Requested string
+	// Minimum version of the dependency
+	Minimum string
+}
+
+// String returns a description of the warning
+func (w MinimumVersionWarning) String() string {
+	return fmt.Sprintf(
+		"%s%s requested, older than the minimum version %s: using >=%s",
+		w.Dependency, w.Requested, w.Minimum, w.Minimum,
+	)
+}
+
+// loadMinimumVersions returns the minimum versions defined in the configuration
+func loadMinimumVersions(config Config) (map[string]*semver.Version, error) {
+	minimums := make(map[string]*semver.Version, len(config.MinimumVersions))
+	for name, version := range config.MinimumVersions {
+		minimum, err := semver.NewVersion(version)
+		if err != nil {
+			return nil, fmt.Errorf("minimum version for %q: %w", name, err)
+		}
+		minimums[name] = minimum
+	}
+
+	return minimums, nil
+}
+
+// enforceMinimumVersions returns the k6 constrains and the dependencies raised to their
+// minimum versions, if any
+func (p *Provider) enforceMinimumVersions(
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (string, []k6build.Dependency) {
+	if len(p.minimums) == 0 {
+		return k6Constrains, deps
+	}
+
+	k6Constrains = p.raiseConstraints(k6Module, k6Constrains)
+	for i := range deps {
+		deps[i].Constraints = p.raiseConstraints(deps[i].Name, deps[i].Constraints)
+	}
+
+	return k6Constrains, deps
+}
+
+// raiseConstraints returns the constraints of the dependency limited to its minimum version.
+// If the constraints don't accept the minimum version, they are replaced and the warning is
+// reported to the OnMinimumVersion callback.
+func (p *Provider) raiseConstraints(name string, constraints string) string {
+	minimum, found := p.minimums[name]
+	if !found {
+		return constraints
+	}
+
+	floor := ">=" + minimum.Original()
+	if constraints == "" || constraints == "*" {
+		return floor
+	}
+
+	requested, err := semver.NewConstraint(constraints)
+	if err != nil || !requested.Check(minimum) {
+		if p.config.OnMinimumVersion != nil {
+			p.config.OnMinimumVersion(MinimumVersionWarning{
+				Dependency: name,
+				Requested:  constraints,
+				Minimum:    minimum.Original(),
+			})
+		}
+		return floor
+	}
+
+	// the floor is added to each alternative
+	alternatives := strings.Split(constraints, "||")
+	for i, alternative := range alternatives {
+		alternatives[i] = strings.TrimSpace(alternative) + ", " + floor
+	}
+
+	return strings.Join(alternatives, " || ")
+}
@@ -0,0 +1,112 @@
+package k6provider
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/grafana/k6build"
+)
+
+func TestMinimumVersions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title         string
+		k6            string
+		deps          []k6build.Dependency
+		expectK6      string
+		expect        []k6build.Dependency
+		expectWarning []MinimumVersionWarning
+	}{
+		{
+			title:    "any version",
+			k6:       "*",
+			deps:     []k6build.Dependency{{Name: "k6/x/faker", Constraints: "*"}},
+			expectK6: ">=v0.55.1",
+			expect:   []k6build.Dependency{{Name: "k6/x/faker", Constraints: ">=v0.4.0"}},
+		},
+		{
+			title:    "accepts minimum version",
+			k6:       ">=v0.50.0",
+			deps:     []k6build.Dependency{{Name: "k6/x/faker", Constraints: "~v0.4.0"}},
+			expectK6: ">=v0.50.0, >=v0.55.1",
+			expect:   []k6build.Dependency{{Name: "k6/x/faker", Constraints: "~v0.4.0, >=v0.4.0"}},
+		},
+		{
+			title:    "alternatives",
+			k6:       "=v0.50.0 || >=v0.55.0",
+			deps:     []k6build.Dependency{},
+			expectK6: "=v0.50.0, >=v0.55.1 || >=v0.55.0, >=v0.55.1",
+			expect:   []k6build.Dependency{},
+		},
+		{
+			title:    "older version requested",
+			k6:       "=v0.50.0",
+			deps:     []k6build.Dependency{{Name: "k6/x/faker", Constraints: "<v0.4.0"}},
+			expectK6: ">=v0.55.1",
+			expect:   []k6build.Dependency{{Name: "k6/x/faker", Constraints: ">=v0.4.0"}},
+			expectWarning: []MinimumVersionWarning{
+				{Dependency: "k6", Requested: "=v0.50.0", Minimum: "v0.55.1"},
+				{Dependency: "k6/x/faker", Requested: "<v0.4.0", Minimum: "v0.4.0"},
+			},
+		},
+		{
+			title:    "without minimum version",
+			k6:       "*",
+			deps:     []k6build.Dependency{{Name: "k6/x/sql", Constraints: "=v0.1.0"}},
+			expectK6: ">=v0.55.1",
+			expect:   []k6build.Dependency{{Name: "k6/x/sql", Constraints: "=v0.1.0"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			warnings := []MinimumVersionWarning{}
+			config := Config{
+				MinimumVersions:  map[string]string{"k6": "v0.55.1", "k6/x/faker": "v0.4.0"},
+				OnMinimumVersion: func(warning MinimumVersionWarning) { warnings = append(warnings, warning) },
+			}
+
+			minimums, err := loadMinimumVersions(config)
+			if err != nil {
+				t.Fatalf("test setup: %v", err)
+			}
+			provider := &Provider{config: config, minimums: minimums}
+
+			k6, deps := provider.enforceMinimumVersions(tc.k6, tc.deps)
+			if k6 != tc.expectK6 {
+				t.Fatalf("expected %s got %s", tc.expectK6, k6)
+			}
+			if !reflect.DeepEqual(deps, tc.expect) {
+				t.Fatalf("expected %v got %v", tc.expect, deps)
+			}
+			if len(tc.expectWarning) > 0 && !reflect.DeepEqual(warnings, tc.expectWarning) {
+				t.Fatalf("expected %v got %v", tc.expectWarning, warnings)
+			}
+			if len(tc.expectWarning) == 0 && len(warnings) > 0 {
+				t.Fatalf("unexpected %v", warnings)
+			}
+
+			// the constraints are valid
+			if _, err = semver.NewConstraint(k6); err != nil {
+				t.Fatalf("invalid constraints %q: %v", k6, err)
+			}
+		})
+	}
+}
+
+func TestInvalidMinimumVersion(t *testing.T) {
+	t.Parallel()
+
+	err := Config{
+		BuildServiceURL: "http://localhost:8000",
+		MinimumVersions: map[string]string{"k6": "latest"},
+	}.Validate()
+	if !errors.Is(err, ErrConfig) {
+		t.Fatalf("expected %v got %v", ErrConfig, err)
+	}
+}
@@ -0,0 +1,22 @@
+package k6provider
+
+import (
+	"path/filepath"
+)
+
+// isNetworkFS returns true if the directory, or its closest existing parent if it doesn't
+// exist yet, is in a network file system such as NFS or SMB
+func isNetworkFS(dir string) bool {
+	for {
+		network, err := networkFSType(dir)
+		if err == nil {
+			return network
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,31 @@
+//go:build darwin
+// +build darwin
+
+package k6provider
+
+import (
+	"syscall"
+)
+
+// networkFSType returns true if the directory is in a network file system
+func networkFSType(dir string) (bool, error) {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return false, err
+	}
+
+	name := make([]byte, 0, len(stat.Fstypename))
+	for _, c := range stat.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+
+	switch string(name) {
+	case "nfs", "smbfs", "afpfs", "webdav":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,34 @@
+//go:build linux
+// +build linux
+
+package k6provider
+
+import (
+	"syscall"
+)
+
+// magic numbers of the network file systems, as reported by statfs(2)
+const (
+	nfsSuperMagic  = 0x6969
+	smbSuperMagic  = 0x517B
+	cifsSuperMagic = 0xFF534D42
+	smb2SuperMagic = 0xFE534D42
+	afsSuperMagic  = 0x5346414F
+	cephSuperMagic = 0x00C36400
+)
+
+// networkFSType returns true if the directory is in a network file system
+func networkFSType(dir string) (bool, error) {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return false, err
+	}
+
+	//nolint:gosec // the magic numbers are 32 bits, but the type's size depends on the architecture
+	switch uint32(stat.Type) {
+	case nfsSuperMagic, smbSuperMagic, cifsSuperMagic, smb2SuperMagic, afsSuperMagic, cephSuperMagic:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
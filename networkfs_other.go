@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package k6provider
+
+// networkFSType returns true if the directory is in a network file system.
+// Network file systems are not detected in this platform.
+func networkFSType(string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,19 @@
+package k6provider
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIsNetworkFS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	// directories that don't exist yet are checked using their closest existing parent
+	for _, path := range []string{dir, filepath.Join(dir, "not", "created")} {
+		if isNetworkFS(path) != isNetworkFS(dir) {
+			t.Fatalf("expected %s detected as its parent %s", path, dir)
+		}
+	}
+}
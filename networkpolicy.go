@@ -0,0 +1,32 @@
+package k6provider
+
+import (
+	"context"
+	"net"
+)
+
+// NetworkPolicy defines whether the provider can access the network
+type NetworkPolicy string
+
+const (
+	// NetworkAllow the provider accesses the build service, the artifact store and the other
+	// services configured as needed
+	NetworkAllow NetworkPolicy = "allow"
+	// NetworkDeny the provider never accesses the network. The operations that require it, such
+	// as resolving the dependencies with the build service or downloading a binary, fail with an
+	// [ErrNetworkDisabled] error, while those that can be completed from the cache proceed.
+	// Useful for proving a provisioning path is fully offline, for example, in tests or in
+	// regulated environments
+	NetworkDeny NetworkPolicy = "deny"
+)
+
+// networkDenied returns true if the network policy in the configuration denies accessing the network
+func networkDenied(config Config) bool {
+	return config.NetworkPolicy == NetworkDeny
+}
+
+// denyDial is the dial function used when the network policy denies accessing the network,
+// so no connection is made even by the clients that don't check the policy
+func denyDial(context.Context, string, string) (net.Conn, error) {
+	return nil, ErrNetworkDisabled
+}
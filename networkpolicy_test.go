@@ -0,0 +1,178 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+)
+
+func TestNetworkDeny(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	downloads := atomic.Int32{}
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		downloads.Add(1)
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	builds := atomic.Int32{}
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			builds.Add(1)
+			return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+		},
+	)
+
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+	provider.config.StaleIfError = time.Hour
+
+	// populate the cache
+	if _, err := provider.GetBinary(context.TODO(), nil); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	provider.config.NetworkPolicy = NetworkDeny
+	provider.downloader.denied = true
+
+	// the binary is provided from the cache
+	if _, err := provider.GetBinary(context.TODO(), nil); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// other dependencies require the build service
+	_, err := provider.GetBinaryForConstraints(context.TODO(), "k6/x/faker=*")
+	if !errors.Is(err, ErrNetworkDisabled) || !errors.Is(err, ErrBuild) {
+		t.Fatalf("expected %v got %v", ErrNetworkDisabled, err)
+	}
+	if code := Details(err).Code; code != CodeNetworkDisabled {
+		t.Fatalf("expected %s got %s", CodeNetworkDisabled, code)
+	}
+
+	// artifacts not in the cache require downloading them
+	_, err = provider.binaryFor(context.TODO(), Artifact{ID: "other", URL: store.URL, Checksum: checksum})
+	if !errors.Is(err, ErrNetworkDisabled) || !errors.Is(err, ErrDownload) {
+		t.Fatalf("expected %v got %v", ErrNetworkDisabled, err)
+	}
+
+	if builds.Load() != 1 || downloads.Load() != 1 {
+		t.Fatalf("expected 1 build and 1 download got %d and %d", builds.Load(), downloads.Load())
+	}
+}
+
+func TestNetworkDenyConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title     string
+		config    Config
+		expectErr error
+	}{
+		{
+			title: "deny",
+			config: Config{
+				BuildServiceURL: "http://localhost:8000",
+				NetworkPolicy:   NetworkDeny,
+			},
+			expectErr: nil,
+		},
+		{
+			title: "discovery",
+			config: Config{
+				DiscoveryDomain: "example.com",
+				NetworkPolicy:   NetworkDeny,
+			},
+			expectErr: ErrNetworkDisabled,
+		},
+		{
+			title: "unknown policy",
+			config: Config{
+				BuildServiceURL: "http://localhost:8000",
+				NetworkPolicy:   "offline",
+			},
+			expectErr: ErrConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			tc.config.BinDir = t.TempDir()
+
+			err := tc.config.Validate()
+			if err == nil {
+				var provider *Provider
+				provider, err = NewProvider(tc.config)
+				if err == nil {
+					_ = provider.Close()
+				}
+			}
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestNetworkDenyLazyInit(t *testing.T) {
+	t.Parallel()
+
+	denied := atomic.Bool{}
+	denied.Store(true)
+	discoveries := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != discoveryPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if denied.Load() {
+			t.Errorf("discovery endpoint contacted while the network is denied")
+		}
+		discoveries.Add(1)
+		_ = json.NewEncoder(w).Encode(discoveryResponse{URL: "http://localhost:8000"})
+	}))
+	t.Cleanup(srv.Close)
+
+	provider, err := NewProvider(Config{
+		BinDir:          t.TempDir(),
+		DiscoveryDomain: srv.URL,
+		LazyInit:        true,
+		NetworkPolicy:   NetworkDeny,
+	})
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	t.Cleanup(func() { _ = provider.Close() })
+
+	_, err = provider.GetArtifact(context.TODO(), nil)
+	if !errors.Is(err, ErrNetworkDisabled) {
+		t.Fatalf("expected %v got %v", ErrNetworkDisabled, err)
+	}
+	if discoveries.Load() != 0 {
+		t.Fatalf("expected no discovery got %d", discoveries.Load())
+	}
+
+	// the build service is discovered once the network is allowed
+	denied.Store(false)
+	provider.config.NetworkPolicy = NetworkAllow
+
+	if _, err = provider.buildServices(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if discoveries.Load() != 1 {
+		t.Fatalf("expected 1 discovery got %d", discoveries.Load())
+	}
+}
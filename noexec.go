@@ -0,0 +1,60 @@
+package k6provider
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// isNoExec returns true if the directory, or its closest existing parent if it doesn't exist
+// yet, is in a file system that doesn't allow executing binaries, such as a tmpfs mounted noexec
+func isNoExec(dir string) bool {
+	for {
+		noExec, err := noExecMount(dir)
+		if err == nil {
+			return noExec
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// execBinDir returns the binary directory if the binaries can be executed from it. Otherwise,
+// the first candidate directory in the configuration they can be executed from.
+func execBinDir(config Config, binDir string) (string, error) {
+	// compressed binaries are executed from a temporary directory
+	if config.CacheCompression != nil {
+		return binDir, nil
+	}
+
+	return selectExecDir(binDir, config.ExecBinDirs, isNoExec)
+}
+
+// selectExecDir returns the directory, or the first candidate, not detected as noexec
+func selectExecDir(dir string, candidates []string, noExec func(string) bool) (string, error) {
+	if !noExec(dir) {
+		return dir, nil
+	}
+
+	for _, candidate := range candidates {
+		if noExec(candidate) {
+			continue
+		}
+		if err := prepareSafeDir(candidate); err != nil {
+			continue
+		}
+		return candidate, nil
+	}
+
+	if len(candidates) == 0 {
+		return "", NewWrappedError(ErrNoExec, fmt.Errorf("%s is mounted noexec", dir))
+	}
+
+	return "", NewWrappedError(
+		ErrNoExec,
+		fmt.Errorf("%s is mounted noexec and no usable candidate in %v", dir, candidates),
+	)
+}
@@ -0,0 +1,21 @@
+//go:build darwin
+// +build darwin
+
+package k6provider
+
+import (
+	"syscall"
+)
+
+// mntNoExec is the flag of the file systems mounted noexec, as reported by statfs(2)
+const mntNoExec = 0x4
+
+// noExecMount returns true if the directory is in a file system mounted noexec
+func noExecMount(dir string) (bool, error) {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return false, err
+	}
+
+	return stat.Flags&mntNoExec != 0, nil
+}
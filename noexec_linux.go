@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package k6provider
+
+import (
+	"syscall"
+)
+
+// stNoExec is the flag of the file systems mounted noexec, as reported by statfs(2)
+const stNoExec = 0x8
+
+// noExecMount returns true if the directory is in a file system mounted noexec
+func noExecMount(dir string) (bool, error) {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return false, err
+	}
+
+	//nolint:unconvert // the type of the flags depends on the architecture
+	return int64(stat.Flags)&stNoExec != 0, nil
+}
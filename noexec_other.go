@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package k6provider
+
+// noExecMount returns true if the directory is in a file system mounted noexec.
+// Mount options are not detected in this platform.
+func noExecMount(string) (bool, error) {
+	return false, nil
+}
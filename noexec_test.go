@@ -0,0 +1,78 @@
+package k6provider
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsNoExec(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	// directories that don't exist yet are checked using their closest existing parent
+	for _, path := range []string{dir, filepath.Join(dir, "not", "created")} {
+		if isNoExec(path) != isNoExec(dir) {
+			t.Fatalf("expected %s detected as its parent %s", path, dir)
+		}
+	}
+}
+
+func TestSelectExecDir(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	binDir := filepath.Join(root, "bin")
+	noExecDir := filepath.Join(root, "noexec")
+	execDir := filepath.Join(root, "exec")
+
+	noExec := func(dir string) bool { return dir == binDir || dir == noExecDir }
+
+	testCases := []struct {
+		title      string
+		dir        string
+		candidates []string
+		expect     string
+		expectErr  error
+	}{
+		{
+			title:     "exec dir",
+			dir:       execDir,
+			expect:    execDir,
+			expectErr: nil,
+		},
+		{
+			title:     "noexec without candidates",
+			dir:       binDir,
+			expectErr: ErrNoExec,
+		},
+		{
+			title:      "first exec candidate",
+			dir:        binDir,
+			candidates: []string{noExecDir, execDir},
+			expect:     execDir,
+			expectErr:  nil,
+		},
+		{
+			title:      "noexec candidates",
+			dir:        binDir,
+			candidates: []string{noExecDir},
+			expectErr:  ErrNoExec,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			dir, err := selectExecDir(tc.dir, tc.candidates, noExec)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if dir != tc.expect {
+				t.Fatalf("expected %q got %q", tc.expect, dir)
+			}
+		})
+	}
+}
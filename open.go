@@ -0,0 +1,87 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/grafana/k6deps"
+)
+
+// OpenBinary returns a custom k6 binary that satisfies the given set of dependencies, like
+// [Provider.GetBinary], and a read-only handle to the binary's file.
+//
+// The binary is validated through the handle, checking it is a regular file that matches the
+// artifact's checksum, so callers that execute the binary using the handle (e.g. fexecve or
+// passing the file descriptor to a sandbox) are not affected by changes to the binary's path
+// between its validation and its execution. The handle remains valid if the binary is pruned
+// from the cache.
+//
+// The handle is positioned at the start of the file and must be closed by the caller.
+func (p *Provider) OpenBinary(ctx context.Context, deps k6deps.Dependencies) (K6Binary, *os.File, error) {
+	binary, err := p.GetBinary(ctx, deps)
+	if err != nil {
+		return K6Binary{}, nil, err
+	}
+
+	file, err := openBinary(binary)
+	// the binary was pruned before it was opened, get it again
+	if errors.Is(err, os.ErrNotExist) {
+		binary, err = p.GetBinary(ctx, deps)
+		if err != nil {
+			return K6Binary{}, nil, err
+		}
+		file, err = openBinary(binary)
+	}
+	if err != nil {
+		return K6Binary{}, nil, p.recordError(NewWrappedError(ErrBinary, err))
+	}
+
+	return binary, file, nil
+}
+
+// openBinary opens the binary and validates it using the opened file
+func openBinary(binary K6Binary) (*os.File, error) {
+	file, err := os.Open(binary.Path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateOpenBinary(file, binary.Checksum); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// validateOpenBinary checks the file is a regular file matching the checksum, if any,
+// and positions it at the start
+func validateOpenBinary(file *os.File, checksum string) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Size() == 0 {
+		return fmt.Errorf("%s is not a valid binary", file.Name())
+	}
+
+	if checksum == "" {
+		return nil
+	}
+
+	hash := sha256.New()
+	if _, err = defaultBuffers.copy(hash, file); err != nil {
+		return err
+	}
+
+	if err = verifyChecksum(checksum, hash); err != nil {
+		return err
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	return err
+}
@@ -0,0 +1,71 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestOpenBinary(t *testing.T) {
+	t.Parallel()
+
+	binary := []byte("k6 binary")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(binary)
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+		},
+	)
+
+	binDir := t.TempDir()
+	provider := newTestProvider(t, buildSrv, binDir)
+
+	k6, file, err := provider.OpenBinary(context.TODO(), nil)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+
+	if file.Name() != k6.Path {
+		t.Fatalf("expected %s got %s", k6.Path, file.Name())
+	}
+
+	// the handle remains valid if the binary is pruned
+	if err = os.RemoveAll(filepath.Join(binDir, "artifact")); err != nil {
+		t.Fatalf("pruning cache %v", err)
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil || string(content) != string(binary) {
+		t.Fatalf("expected %q got %q %v", binary, content, err)
+	}
+
+	// a tampered binary is detected through the handle
+	if _, err = provider.GetBinary(context.TODO(), nil); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if err = os.WriteFile(k6.Path, []byte("tampered"), 0o600); err != nil {
+		t.Fatalf("tampering binary %v", err)
+	}
+
+	_, _, err = provider.OpenBinary(context.TODO(), nil)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected %v got %v", ErrChecksumMismatch, err)
+	}
+}
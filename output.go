@@ -0,0 +1,70 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/grafana/k6deps"
+)
+
+// GetBinaryTo builds the custom k6 binary that satisfies the given set of dependencies and
+// downloads it to the given path, verifying its checksum. The binary is written to the path
+// only once completely downloaded and verified, replacing any existing file.
+//
+// Unlike [Provider.GetBinary], the cache is not used: the binary is always downloaded and nothing
+// else is written, so the path only depends on the caller, as required by build systems such as
+// Bazel (e.g. as the backend of a repository rule or a genrule).
+//
+// The returned binary's Path is the given path. Companion tools in the artifact are not provided.
+func (p *Provider) GetBinaryTo(ctx context.Context, deps k6deps.Dependencies, path string) (K6Binary, error) {
+	if p.ctx.Err() != nil {
+		return K6Binary{}, ErrClosed
+	}
+
+	if path == "" {
+		return K6Binary{}, NewWrappedError(ErrInvalidParameters, errors.New("empty output path"))
+	}
+
+	artifact, err := p.GetArtifact(ctx, deps)
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	// cancel the download if the provider is closed
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := p.cancelOnClose(cancel)
+	defer stop()
+
+	if err = p.startDownload(); err != nil {
+		return K6Binary{}, err
+	}
+
+	// download to a temporary file in the same directory, so it can be moved to the path
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		p.downloads.Done()
+		return K6Binary{}, p.recordError(NewWrappedError(ErrBinary, storageError(err)))
+	}
+	_ = tmp.Close()
+
+	_, err = p.downloadPartial(ctx, artifact, tmp.Name())
+	p.downloads.Done()
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+		if err != nil {
+			err = NewWrappedError(ErrBinary, storageError(err))
+		}
+	}
+	// companion tools are only provided from the cache and interrupted downloads are not resumed
+	_ = os.RemoveAll(tmp.Name() + toolsSuffix)
+	_ = os.Remove(tmp.Name() + resumeSuffix)
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return K6Binary{}, p.recordError(withArtifact(err, artifact.ID))
+	}
+
+	return newK6Binary(path, artifact), nil
+}
@@ -0,0 +1,89 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestGetBinaryTo(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	testCases := []struct {
+		title     string
+		checksum  string
+		expectErr error
+	}{
+		{title: "binary downloaded", checksum: checksum, expectErr: nil},
+		{title: "checksum mismatch", checksum: fmt.Sprintf("%x", sha256.Sum256([]byte("other"))), expectErr: ErrDownload},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: tc.checksum}, nil
+				},
+			)
+
+			binDir := t.TempDir()
+			provider := newTestProvider(t, buildSrv, binDir)
+
+			outDir := t.TempDir()
+			output := filepath.Join(outDir, "k6-custom")
+
+			binary, err := provider.GetBinaryTo(context.TODO(), nil, output)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			// nothing is written but the output
+			for dir, expected := range map[string]int{binDir: 0, outDir: 1} {
+				entries, err := os.ReadDir(dir)
+				if err != nil {
+					t.Fatalf("reading %s %v", dir, err)
+				}
+				if tc.expectErr != nil {
+					expected = 0
+				}
+				if len(entries) != expected {
+					t.Fatalf("expected %d entries in %s got %d", expected, dir, len(entries))
+				}
+			}
+
+			if tc.expectErr != nil {
+				return
+			}
+
+			if binary.Path != output {
+				t.Fatalf("expected %s got %s", output, binary.Path)
+			}
+
+			got, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatalf("reading binary %v", err)
+			}
+			if string(got) != string(content) {
+				t.Fatalf("expected %q got %q", content, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package k6provider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+// dependencyOverride replaces the name or the constraints of a dependency
+type dependencyOverride struct {
+	// name of the replacing extension, if the extension is replaced
+	name string
+	// constraints of the dependency, if overridden
+	constraints string
+}
+
+// loadOverrides returns the dependency overrides defined in the configuration and in the
+// K6_DEPENDENCY_OVERRIDES environment variable, as a list of name:override pairs separated by ";"
+// (e.g. "k6:=v0.55.0;k6/x/sql:k6/x/sql-fork"). Overrides defined in the configuration take
+// precedence over the ones in the environment.
+func loadOverrides(config Config) (map[string]dependencyOverride, error) {
+	defined := map[string]string{}
+
+	for _, pair := range strings.Split(os.Getenv("K6_DEPENDENCY_OVERRIDES"), ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, override, found := strings.Cut(pair, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid dependency override %q in K6_DEPENDENCY_OVERRIDES", pair)
+		}
+		defined[strings.TrimSpace(name)] = override
+	}
+
+	for name, override := range config.DependencyOverrides {
+		defined[name] = override
+	}
+
+	overrides := make(map[string]dependencyOverride, len(defined))
+	for name, value := range defined {
+		override, err := parseOverride(value)
+		if err != nil {
+			return nil, fmt.Errorf("dependency override for %q: %w", name, err)
+		}
+		overrides[name] = override
+	}
+
+	return overrides, nil
+}
+
+// parseOverride parses the override of a dependency: its constraints (e.g. "=v0.55.0") or the
+// name of the replacing extension, which contains a "/", followed by its optional constraints
+// (e.g. "k6/x/sql-fork >=v1.2.0")
+func parseOverride(value string) (dependencyOverride, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return dependencyOverride{}, errors.New("empty override")
+	}
+
+	override := dependencyOverride{constraints: value}
+	if name, constraints, _ := strings.Cut(value, " "); strings.Contains(name, "/") {
+		override = dependencyOverride{name: name, constraints: strings.TrimSpace(constraints)}
+	}
+
+	if override.constraints != "" {
+		// constraints are validated as the dependencies declared by the scripts
+		if _, err := k6deps.NewDependency(k6Module, override.constraints); err != nil {
+			return dependencyOverride{}, err
+		}
+	}
+
+	return override, nil
+}
+
+// applyOverrides returns the k6 constrains and the dependencies with the overrides applied.
+// Replacing an extension keeps its constraints, unless they are also overridden. If an extension
+// is replaced by another one in the dependencies, the replacement takes precedence.
+func applyOverrides(
+	overrides map[string]dependencyOverride,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (string, []k6build.Dependency) {
+	if len(overrides) == 0 {
+		return k6Constrains, deps
+	}
+
+	if override, found := overrides[k6Module]; found && override.constraints != "" {
+		k6Constrains = override.constraints
+	}
+
+	// replacing extensions take precedence over the same extensions in the dependencies
+	replacements := map[string]bool{}
+	for _, dep := range deps {
+		if override, found := overrides[dep.Name]; found && override.name != "" {
+			replacements[override.name] = true
+		}
+	}
+
+	applied := make([]k6build.Dependency, 0, len(deps))
+	for _, dep := range deps {
+		override, found := overrides[dep.Name]
+		if !found {
+			if !replacements[dep.Name] {
+				applied = append(applied, dep)
+			}
+			continue
+		}
+
+		if override.name != "" {
+			dep.Name = override.name
+		}
+		if override.constraints != "" {
+			dep.Constraints = override.constraints
+		}
+		applied = append(applied, dep)
+	}
+
+	return k6Constrains, applied
+}
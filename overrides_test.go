@@ -0,0 +1,140 @@
+package k6provider
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestLoadOverrides(t *testing.T) { //nolint:paralleltest
+	// the environment is global state, so this test cannot run in parallel
+	t.Setenv("K6_DEPENDENCY_OVERRIDES", "k6:=v0.55.0; k6/x/sql:k6/x/sql-fork")
+
+	testCases := []struct {
+		title     string
+		config    Config
+		expect    map[string]dependencyOverride
+		expectErr error
+	}{
+		{
+			title: "from environment",
+			expect: map[string]dependencyOverride{
+				"k6":       {constraints: "=v0.55.0"},
+				"k6/x/sql": {name: "k6/x/sql-fork"},
+			},
+		},
+		{
+			title: "config overrides environment",
+			config: Config{DependencyOverrides: map[string]string{
+				"k6":         ">=v0.56.0",
+				"k6/x/faker": "github.com/example/xk6-faker-fork =v0.4.0",
+			}},
+			expect: map[string]dependencyOverride{
+				"k6":         {constraints: ">=v0.56.0"},
+				"k6/x/sql":   {name: "k6/x/sql-fork"},
+				"k6/x/faker": {name: "github.com/example/xk6-faker-fork", constraints: "=v0.4.0"},
+			},
+		},
+		{
+			title:     "invalid constraints",
+			config:    Config{DependencyOverrides: map[string]string{"k6": "latest"}},
+			expectErr: ErrConfig,
+		},
+		{
+			title:     "empty override",
+			config:    Config{DependencyOverrides: map[string]string{"k6": ""}},
+			expectErr: ErrConfig,
+		},
+	}
+
+	for _, tc := range testCases { //nolint:paralleltest
+		t.Run(tc.title, func(t *testing.T) {
+			overrides, err := loadOverrides(tc.config)
+			if err != nil {
+				err = NewWrappedError(ErrConfig, err)
+			}
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(overrides, tc.expect) {
+				t.Fatalf("expected %v got %v", tc.expect, overrides)
+			}
+		})
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	t.Parallel()
+
+	overrides := map[string]dependencyOverride{
+		"k6":         {constraints: "=v0.55.0"},
+		"k6/x/sql":   {name: "k6/x/sql-fork"},
+		"k6/x/faker": {name: "k6/x/faker-fork", constraints: "=v0.4.0"},
+		"k6/x/kafka": {constraints: "=v0.27.0"},
+	}
+
+	testCases := []struct {
+		title    string
+		k6       string
+		deps     []k6build.Dependency
+		expectK6 string
+		expect   []k6build.Dependency
+	}{
+		{
+			title:    "k6 version forced",
+			k6:       "*",
+			deps:     []k6build.Dependency{},
+			expectK6: "=v0.55.0",
+			expect:   []k6build.Dependency{},
+		},
+		{
+			title: "extensions overridden",
+			k6:    ">=v0.50.0",
+			deps: []k6build.Dependency{
+				{Name: "k6/x/sql", Constraints: ">=v1.0.0"},
+				{Name: "k6/x/faker", Constraints: "*"},
+				{Name: "k6/x/kafka", Constraints: "*"},
+				{Name: "k6/x/kubernetes", Constraints: "*"},
+			},
+			expectK6: "=v0.55.0",
+			expect: []k6build.Dependency{
+				{Name: "k6/x/sql-fork", Constraints: ">=v1.0.0"},
+				{Name: "k6/x/faker-fork", Constraints: "=v0.4.0"},
+				{Name: "k6/x/kafka", Constraints: "=v0.27.0"},
+				{Name: "k6/x/kubernetes", Constraints: "*"},
+			},
+		},
+		{
+			title: "replacement takes precedence",
+			k6:    "*",
+			deps: []k6build.Dependency{
+				{Name: "k6/x/sql-fork", Constraints: "*"},
+				{Name: "k6/x/sql", Constraints: "=v1.0.0"},
+			},
+			expectK6: "=v0.55.0",
+			expect: []k6build.Dependency{
+				{Name: "k6/x/sql-fork", Constraints: "=v1.0.0"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			k6, deps := applyOverrides(overrides, tc.k6, tc.deps)
+			if k6 != tc.expectK6 {
+				t.Fatalf("expected %s got %s", tc.expectK6, k6)
+			}
+			if !reflect.DeepEqual(deps, tc.expect) {
+				t.Fatalf("expected %v got %v", tc.expect, deps)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package k6provider
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+)
+
+// permissions of the files and directories in the binary cache, which are only accessible to
+// the owner unless the cache is shared
+const (
+	cacheDirPerm    os.FileMode = 0o700
+	cacheBinaryPerm os.FileMode = 0o700
+	cacheFilePerm   os.FileMode = 0o600
+)
+
+// cachePermissions defines how the files in the binary cache are shared with other users:
+// the permissions added for the group and other users, and the group assigned to them
+type cachePermissions struct {
+	dir    os.FileMode
+	binary os.FileMode
+	file   os.FileMode
+	gid    int
+}
+
+// newCachePermissions returns the permissions for the binary cache in the configuration
+func newCachePermissions(config Config) (cachePermissions, error) {
+	perms := cachePermissions{gid: -1}
+
+	// sharing relies on unix permissions
+	if runtime.GOOS == "windows" {
+		return perms, nil
+	}
+
+	if config.CacheGroup != "" {
+		gid, err := lookupGroup(config.CacheGroup)
+		if err != nil {
+			return cachePermissions{}, NewWrappedError(ErrConfig, err)
+		}
+
+		// new files in the directories inherit their group (setgid)
+		perms.gid = gid
+		perms.dir |= 0o050 | os.ModeSetgid
+		perms.binary |= 0o050
+		perms.file |= 0o040
+	}
+
+	if config.SharedCache {
+		perms.dir |= 0o055
+		perms.binary |= 0o055
+		perms.file |= 0o044
+	}
+
+	return perms, nil
+}
+
+// lookupGroup returns the id of the group given by name or id
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+
+	found, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("cache group: %w", err)
+	}
+
+	return strconv.Atoi(found.Gid)
+}
+
+// shared returns true if the cache is shared with other users
+func (c cachePermissions) shared() bool {
+	return c.dir != 0 || c.gid >= 0
+}
+
+// apply sets the permissions and the group of the file, regardless of the process' umask
+func (c cachePermissions) apply(path string, perm os.FileMode) error {
+	if !c.shared() {
+		return nil
+	}
+
+	if c.gid >= 0 {
+		if err := os.Lchown(path, -1, c.gid); err != nil {
+			return err
+		}
+	}
+
+	return os.Chmod(path, perm)
+}
+
+// shareDirs makes the binary directory and the artifact directory in it accessible
+// to the users the cache is shared with
+func (c cachePermissions) shareDirs(binDir string, artifactDir string) error {
+	if err := c.apply(binDir, cacheDirPerm|c.dir); err != nil {
+		return err
+	}
+	return c.apply(artifactDir, cacheDirPerm|c.dir)
+}
+
+// shareBinary makes the binary readable and executable by the users the cache is shared with
+func (c cachePermissions) shareBinary(path string) error {
+	return c.apply(path, cacheBinaryPerm|c.binary)
+}
+
+// shareFile makes the file readable by the users the cache is shared with
+func (c cachePermissions) shareFile(path string) error {
+	return c.apply(path, cacheFilePerm|c.file)
+}
@@ -0,0 +1,118 @@
+//go:build !windows
+// +build !windows
+
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestSharedCache(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("k6 binary"))
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", URL: store.URL}, nil
+		},
+	)
+
+	gid := os.Getgid()
+
+	testCases := []struct {
+		title        string
+		config       Config
+		expectDir    os.FileMode
+		expectBinary os.FileMode
+		expectFile   os.FileMode
+	}{
+		{
+			title:        "private cache",
+			config:       Config{},
+			expectDir:    0o700,
+			expectBinary: 0o700,
+			expectFile:   0o600,
+		},
+		{
+			title:        "group readable",
+			config:       Config{CacheGroup: strconv.Itoa(gid)},
+			expectDir:    0o750 | os.ModeSetgid,
+			expectBinary: 0o750,
+			expectFile:   0o640,
+		},
+		{
+			title:        "shared cache",
+			config:       Config{SharedCache: true},
+			expectDir:    0o755,
+			expectBinary: 0o755,
+			expectFile:   0o644,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			permissions, err := newCachePermissions(tc.config)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			binDir := filepath.Join(t.TempDir(), "cache")
+			provider := newTestProvider(t, buildSrv, binDir)
+			provider.permissions = permissions
+
+			binary, err := provider.GetBinary(context.TODO(), nil)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			expected := map[string]os.FileMode{
+				binDir:                    tc.expectDir,
+				filepath.Dir(binary.Path): tc.expectDir,
+				binary.Path:               tc.expectBinary,
+				filepath.Join(filepath.Dir(binary.Path), metadataFile): tc.expectFile,
+			}
+
+			for path, mode := range expected {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatalf("unexpected %v", err)
+				}
+
+				perm := info.Mode() & (os.ModePerm | os.ModeSetgid)
+				if perm != mode {
+					t.Fatalf("%s: expected %v got %v", path, mode, perm)
+				}
+
+				stat, ok := info.Sys().(*syscall.Stat_t)
+				if ok && tc.config.CacheGroup != "" && int(stat.Gid) != gid {
+					t.Fatalf("%s: expected group %d got %d", path, gid, stat.Gid)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheGroupValidation(t *testing.T) {
+	t.Parallel()
+
+	_, err := newCachePermissions(Config{CacheGroup: "no-such-group-k6provider"})
+	if !errors.Is(err, ErrConfig) {
+		t.Fatalf("expected %v got %v", ErrConfig, err)
+	}
+}
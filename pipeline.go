@@ -0,0 +1,94 @@
+package k6provider
+
+import (
+	"errors"
+	"hash"
+	"io"
+	"os"
+)
+
+// DownloadStage is a stage of the pipeline the content of an artifact's binary goes through as it
+// is downloaded. The download request is authorized (see [DownloadConfig.Authorization] and
+// [DownloadConfig.RequestSigner]) and sent to the artifact store, and the content received is passed
+// through the stages in order, then hashed for verifying its checksum and written to the cache.
+// Stages can verify the content, such as checking its signature, or transform it, such as
+// decompressing it. The content is received as served by the store, which can be an archive
+// (see [DownloadConfig.ArchiveMember]).
+//
+// The stage returns the writer that receives the content and writes the result of processing it
+// to next. The writer is closed when the download ends, for flushing its output or completing its
+// verification. An error returned by the stage, or by its writer, fails the download with an
+// [ErrDownloadPermanent] error that wraps it.
+//
+// Downloads that pass through stages are not resumed: an interrupted download starts over.
+type DownloadStage func(artifact Artifact, next io.Writer) (io.WriteCloser, error)
+
+// pipeline passes the content written to it through the stages, in order, to the sink
+type pipeline struct {
+	first  io.Writer
+	stages []io.WriteCloser
+	sink   *trackingWriter
+}
+
+// newPipeline returns the pipeline for the content of the artifact's binary
+func newPipeline(artifact Artifact, stages []DownloadStage, sink io.Writer) (*pipeline, error) {
+	p := &pipeline{
+		stages: make([]io.WriteCloser, len(stages)),
+		sink:   &trackingWriter{writer: sink},
+	}
+
+	// each stage writes to the next one, so they are created from the last one
+	next := io.Writer(p.sink)
+	for i := len(stages) - 1; i >= 0; i-- {
+		stage, err := stages[i](artifact, next)
+		if err != nil {
+			p.stages = p.stages[i+1:]
+			_ = p.Close()
+			return nil, NewWrappedError(ErrDownloadPermanent, err)
+		}
+		p.stages[i] = stage
+		next = stage
+	}
+	p.first = next
+
+	return p, nil
+}
+
+func (p *pipeline) Write(b []byte) (int, error) {
+	n, err := p.first.Write(b)
+	return n, p.stageError(err)
+}
+
+// Close closes the stages in order, so each one flushes its output to the next
+func (p *pipeline) Close() error {
+	errs := []error{}
+	for _, stage := range p.stages {
+		if err := stage.Close(); err != nil {
+			errs = append(errs, p.stageError(err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// stageError returns the error as an [ErrDownloadPermanent] error if it was caused by a stage
+// and not by writing to the sink
+func (p *pipeline) stageError(err error) error {
+	if err == nil || (p.sink.err != nil && errors.Is(err, p.sink.err)) {
+		return err
+	}
+
+	return NewWrappedError(ErrDownloadPermanent, err)
+}
+
+// resume prepares the partial file for resuming an interrupted download (see [resumePartial]).
+// Downloads that pass through stages are not resumed, as the content written to the partial
+// file is not the content received.
+func (d *downloader) resume(partialPath string, hash hash.Hash) resumeState {
+	if len(d.stages) > 0 {
+		_ = os.Remove(partialPath + resumeSuffix)
+		return resumeState{}
+	}
+
+	return resumePartial(partialPath, hash)
+}
@@ -0,0 +1,152 @@
+package k6provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// gunzipStage decompresses the content when all of it is received
+func gunzipStage(_ Artifact, next io.Writer) (io.WriteCloser, error) {
+	return &bufferedStage{next: next, process: func(content []byte, next io.Writer) error {
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(next, reader) //nolint:gosec
+		return err
+	}}, nil
+}
+
+// verifyStage checks the content received is the expected one
+func verifyStage(expected []byte) DownloadStage {
+	return func(_ Artifact, next io.Writer) (io.WriteCloser, error) {
+		return &bufferedStage{next: next, process: func(content []byte, next io.Writer) error {
+			if !bytes.Equal(content, expected) {
+				return errInvalidSignature
+			}
+			_, err := next.Write(content)
+			return err
+		}}, nil
+	}
+}
+
+type bufferedStage struct {
+	bytes.Buffer
+	next    io.Writer
+	process func([]byte, io.Writer) error
+}
+
+func (s *bufferedStage) Close() error {
+	return s.process(s.Bytes(), s.next)
+}
+
+func TestDownloadStages(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	_, _ = writer.Write(content)
+	_ = writer.Close()
+
+	testCases := []struct {
+		title     string
+		served    []byte
+		stages    []DownloadStage
+		expectErr error
+	}{
+		{
+			title:     "no stages",
+			served:    content,
+			stages:    nil,
+			expectErr: nil,
+		},
+		{
+			title:     "transform and verify",
+			served:    compressed.Bytes(),
+			stages:    []DownloadStage{gunzipStage, verifyStage(content)},
+			expectErr: nil,
+		},
+		{
+			title:     "verify before transform",
+			served:    compressed.Bytes(),
+			stages:    []DownloadStage{verifyStage(content), gunzipStage},
+			expectErr: errInvalidSignature,
+		},
+		{
+			title:  "checksum of transformed content",
+			served: content,
+			stages: []DownloadStage{
+				func(_ Artifact, next io.Writer) (io.WriteCloser, error) {
+					return &bufferedStage{next: next, process: func(content []byte, next io.Writer) error {
+						_, err := next.Write(append(content, '\n'))
+						return err
+					}}, nil
+				},
+			},
+			expectErr: ErrChecksumMismatch,
+		},
+		{
+			title:  "stage failed",
+			served: content,
+			stages: []DownloadStage{
+				func(Artifact, io.Writer) (io.WriteCloser, error) { return nil, errInvalidSignature },
+			},
+			expectErr: errInvalidSignature,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write(tc.served)
+			}))
+			t.Cleanup(store.Close)
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+				},
+			)
+
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+			provider.downloader.stages = tc.stages
+
+			binary, err := provider.GetBinary(context.TODO(), nil)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if errors.Is(tc.expectErr, errInvalidSignature) && !errors.Is(err, ErrDownloadPermanent) {
+				t.Fatalf("expected %v got %v", ErrDownloadPermanent, err)
+			}
+			if err != nil {
+				return
+			}
+
+			stored, err := os.ReadFile(binary.Path)
+			if err != nil {
+				t.Fatalf("reading binary %v", err)
+			}
+			if !bytes.Equal(stored, content) {
+				t.Fatalf("expected %q got %q", content, stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package k6provider
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+// platformOS are the operating systems of the platforms accepted in the configuration
+var platformOS = []string{"linux", "darwin", "windows"} //nolint:gochecknoglobals
+
+// platformArch are the architectures of the platforms accepted in the configuration
+var platformArch = []string{"amd64", "arm64", "arm", "386", "ppc64le", "s390x", "riscv64"} //nolint:gochecknoglobals
+
+// platformAliases maps common names of operating systems and architectures to their Go names
+var platformAliases = map[string]string{ //nolint:gochecknoglobals
+	"macos":   "darwin",
+	"mac":     "darwin",
+	"osx":     "darwin",
+	"win":     "windows",
+	"win32":   "windows",
+	"win64":   "windows",
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"x64":     "amd64",
+	"aarch64": "arm64",
+	"armv8":   "arm64",
+	"x86":     "386",
+	"i386":    "386",
+	"i686":    "386",
+}
+
+// armAliases maps names of 32-bit ARM architectures that include the variant to the variant
+var armAliases = map[string]string{ //nolint:gochecknoglobals
+	"armv5":  "v5",
+	"armv6":  "v6",
+	"armv6l": "v6",
+	"armv7":  "v7",
+	"armv7l": "v7",
+	"armhf":  "v7",
+}
+
+// NormalizePlatform returns the platform in the form used by the build service,
+// "<os>/<arch>[/<variant>][/musl]" (see [DetectPlatform]), accepting common aliases of the
+// operating systems and architectures, in any case, and "-" or "_" as separator of the os and
+// architecture. e.g. "Linux/x86_64" is "linux/amd64", "darwin-arm64" is "darwin/arm64" and
+// "linux/armv7l" is "linux/arm/v7".
+//
+// Returns an error describing the expected form if the platform is not known.
+func NormalizePlatform(platform string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(platform))
+
+	parts := strings.Split(value, "/")
+	if len(parts) == 1 {
+		if i := strings.IndexAny(value, "-_"); i > 0 {
+			parts = []string{value[:i], value[i+1:]}
+		}
+	}
+
+	invalid := fmt.Errorf(
+		"invalid platform %q: expected <os>/<arch>[/<variant>][/musl] with os one of %s and arch one of %s",
+		platform,
+		strings.Join(platformOS, ", "),
+		strings.Join(platformArch, ", "),
+	)
+
+	if len(parts) < 2 {
+		return "", invalid
+	}
+
+	goos, goarch := normalizePlatformPart(parts[0]), normalizePlatformPart(parts[1])
+	if !slices.Contains(platformOS, goos) {
+		return "", invalid
+	}
+
+	normalized := []string{goos, goarch}
+	if variant, found := armAliases[goarch]; found {
+		normalized = []string{goos, "arm", variant}
+	}
+	if !slices.Contains(platformArch, normalized[1]) {
+		return "", invalid
+	}
+
+	for _, part := range parts[2:] {
+		switch {
+		case normalized[1] == "arm" && len(normalized) == 2 && slices.Contains([]string{"v5", "v6", "v7"}, part):
+			normalized = append(normalized, part)
+		case goos == "linux" && part == "musl":
+			normalized = append(normalized, part)
+		default:
+			return "", invalid
+		}
+	}
+
+	return strings.Join(normalized, "/"), nil
+}
+
+// normalizePlatformPart returns the Go name of an os or architecture
+func normalizePlatformPart(part string) string {
+	if name, found := platformAliases[part]; found {
+		return name
+	}
+	return part
+}
+
+// DetectPlatform returns the platform of the running system as "<os>/<arch>[/<variant>][/musl]",
+// refining the platform given by GOOS and GOARCH with:
+//   - the variant of 32-bit ARM processors ("v6" or "v7"), as binaries for ARMv7 don't run on ARMv6
+//   - the musl libc flavor on Linux distributions such as Alpine, where binaries linked with
+//     glibc may not run
+//
+// e.g. "linux/amd64", "linux/arm/v6", "linux/amd64/musl".
+//
+// The build service must support the detected platform. See [Config.DetectPlatform]
+func DetectPlatform() string {
+	return detectPlatform(runtime.GOOS, runtime.GOARCH, goarm(), os.DirFS("/"))
+}
+
+// detectPlatform returns the platform for the os and architecture, looking up the ARM
+// variant and the libc flavor in the root file system. goarm is the ARM version the
+// running binary was built for, used if the processor's version cannot be detected.
+func detectPlatform(goos string, goarch string, goarm string, root fs.FS) string {
+	platform := []string{goos, goarch}
+
+	if goos != "linux" {
+		return strings.Join(platform, "/")
+	}
+
+	if goarch == "arm" {
+		if variant := armVariant(root, goarm); variant != "" {
+			platform = append(platform, variant)
+		}
+	}
+
+	if isMusl(root) {
+		platform = append(platform, "musl")
+	}
+
+	return strings.Join(platform, "/")
+}
+
+// armVariant returns the variant of a 32-bit ARM processor from its architecture in
+// /proc/cpuinfo, or from goarm if it cannot be read. ARMv8 processors running in 32-bit
+// mode run ARMv7 binaries.
+func armVariant(root fs.FS, goarm string) string {
+	version := goarm
+
+	if cpuinfo, err := root.Open("proc/cpuinfo"); err == nil {
+		defer cpuinfo.Close() //nolint:errcheck
+
+		scanner := bufio.NewScanner(cpuinfo)
+		for scanner.Scan() {
+			key, value, found := strings.Cut(scanner.Text(), ":")
+			if found && strings.TrimSpace(key) == "CPU architecture" {
+				version = strings.TrimSpace(value)
+				break
+			}
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(version, "5"):
+		return "v5"
+	case strings.HasPrefix(version, "6"):
+		return "v6"
+	case strings.HasPrefix(version, "7"), strings.HasPrefix(version, "8"):
+		return "v7"
+	default:
+		return ""
+	}
+}
+
+// isMusl returns true if the system uses the musl libc, which is detected by its dynamic loader
+func isMusl(root fs.FS) bool {
+	loaders, err := fs.Glob(root, "lib/ld-musl-*.so.1")
+	return err == nil && len(loaders) > 0
+}
+
+// goarm returns the ARM version the running binary was built for, if any
+func goarm() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "GOARM" {
+			return setting.Value
+		}
+	}
+
+	return ""
+}
+
+// defaultPlatform returns the platform for the binaries when none is configured
+func defaultPlatform(config Config) string {
+	if config.DetectPlatform {
+		return DetectPlatform()
+	}
+	return fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,118 @@
+package k6provider
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	t.Parallel()
+
+	muslLoader := fstest.MapFile{Data: []byte("loader")}
+	cpuinfo := func(arch string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte("processor\t: 0\nCPU architecture: " + arch + "\nFeatures\t: half thumb\n")}
+	}
+
+	testCases := []struct {
+		title  string
+		goos   string
+		goarch string
+		goarm  string
+		files  fstest.MapFS
+		expect string
+	}{
+		{
+			title:  "glibc",
+			goos:   "linux",
+			goarch: "amd64",
+			files:  fstest.MapFS{"lib64/ld-linux-x86-64.so.2": &fstest.MapFile{}},
+			expect: "linux/amd64",
+		},
+		{
+			title:  "musl",
+			goos:   "linux",
+			goarch: "amd64",
+			files:  fstest.MapFS{"lib/ld-musl-x86_64.so.1": &muslLoader},
+			expect: "linux/amd64/musl",
+		},
+		{
+			title:  "armv6",
+			goos:   "linux",
+			goarch: "arm",
+			goarm:  "7",
+			files:  fstest.MapFS{"proc/cpuinfo": cpuinfo("6")},
+			expect: "linux/arm/v6",
+		},
+		{
+			title:  "armv8 in 32-bit mode with musl",
+			goos:   "linux",
+			goarch: "arm",
+			files:  fstest.MapFS{"proc/cpuinfo": cpuinfo("8"), "lib/ld-musl-armhf.so.1": &muslLoader},
+			expect: "linux/arm/v7/musl",
+		},
+		{
+			title:  "arm variant from build",
+			goos:   "linux",
+			goarch: "arm",
+			goarm:  "7",
+			files:  fstest.MapFS{},
+			expect: "linux/arm/v7",
+		},
+		{
+			title:  "not linux",
+			goos:   "darwin",
+			goarch: "arm64",
+			files:  fstest.MapFS{"lib/ld-musl-aarch64.so.1": &muslLoader},
+			expect: "darwin/arm64",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			platform := detectPlatform(tc.goos, tc.goarch, tc.goarm, tc.files)
+			if platform != tc.expect {
+				t.Fatalf("expected %s got %s", tc.expect, platform)
+			}
+		})
+	}
+}
+
+func TestNormalizePlatform(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		platform  string
+		expect    string
+		expectErr bool
+	}{
+		{platform: "linux/amd64", expect: "linux/amd64"},
+		{platform: "Linux/x86_64", expect: "linux/amd64"},
+		{platform: "darwin-arm64", expect: "darwin/arm64"},
+		{platform: "macos_aarch64", expect: "darwin/arm64"},
+		{platform: "win64/x64", expect: "windows/amd64"},
+		{platform: "linux/armv7l", expect: "linux/arm/v7"},
+		{platform: "linux/arm/v6/musl", expect: "linux/arm/v6/musl"},
+		{platform: "linux/x86_64/musl", expect: "linux/amd64/musl"},
+		{platform: "linux", expectErr: true},
+		{platform: "linux/mips", expectErr: true},
+		{platform: "amiga/amd64", expectErr: true},
+		{platform: "darwin/arm64/musl", expectErr: true},
+		{platform: "linux/amd64/v7", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.platform, func(t *testing.T) {
+			t.Parallel()
+
+			platform, err := NormalizePlatform(tc.platform)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %v got %v", tc.expectErr, err)
+			}
+			if platform != tc.expect {
+				t.Fatalf("expected %q got %q", tc.expect, platform)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package k6provider
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grafana/k6deps"
+)
+
+const (
+	// defaultPrefetchConcurrency default number of binaries prefetched concurrently
+	defaultPrefetchConcurrency = 2
+	// defaultPrefetchQueue default number of pending prefetch requests
+	defaultPrefetchQueue = 100
+	// prefetchedTTL is the time the dependencies prefetched are remembered for deduplicating
+	// the requests for them
+	prefetchedTTL = time.Hour
+)
+
+// PrefetchConfig defines the constraints for prefetching binaries
+type PrefetchConfig struct {
+	// Concurrency maximum number of binaries prefetched concurrently. Defaults to 2
+	Concurrency int
+	// QueueSize maximum number of pending prefetch requests. When the queue is full
+	// [Prefetcher.Submit] blocks until there is room. Defaults to 100
+	QueueSize int
+	// Bandwidth maximum bytes per second used for downloading binaries, shared by all
+	// the prefetches. If 0, the bandwidth is not limited
+	Bandwidth int64
+	// DiskBudget maximum bytes of binaries downloaded by the prefetcher. Once exceeded,
+	// the pending prefetches are skipped. If 0, the disk used is not limited
+	DiskBudget int64
+}
+
+// PrefetchStats are the counters of a prefetcher
+type PrefetchStats struct {
+	// Prefetched binaries downloaded
+	Prefetched int
+	// Cached binaries already in the cache
+	Cached int
+	// Deduplicated requests for dependencies already queued or prefetched
+	Deduplicated int
+	// Skipped requests not prefetched because the disk budget was exceeded
+	Skipped int
+	// Failed requests that failed
+	Failed int
+	// Downloaded bytes downloaded
+	Downloaded int64
+}
+
+// Prefetcher downloads binaries for upcoming sets of dependencies in the background,
+// subject to concurrency, bandwidth and disk budget constraints. See [Provider.NewPrefetcher]
+type Prefetcher struct {
+	provider *Provider
+	config   PrefetchConfig
+	queue    chan k6deps.Dependencies
+	limiter  *rateLimiter
+	ctx      context.Context
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
+	mutex    sync.Mutex
+	seen     map[string]time.Time
+	seenTTL  time.Duration
+	stats    PrefetchStats
+	closed   bool
+}
+
+// NewPrefetcher returns a prefetcher that downloads binaries using the provider.
+// The prefetcher must be closed when no longer needed.
+func (p *Provider) NewPrefetcher(config PrefetchConfig) *Prefetcher {
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultPrefetchConcurrency
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultPrefetchQueue
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	f := &Prefetcher{
+		provider: p,
+		config:   config,
+		queue:    make(chan k6deps.Dependencies, config.QueueSize),
+		limiter:  newRateLimiter(config.Bandwidth),
+		ctx:      ctx,
+		cancel:   cancel,
+		seen:     map[string]time.Time{},
+		seenTTL:  prefetchedTTL,
+	}
+
+	for range config.Concurrency {
+		f.workers.Add(1)
+		go f.work()
+	}
+
+	return f
+}
+
+// Submit queues the prefetch of the binary for the dependencies. If the queue is full, it blocks
+// until there is room or the context is done. Dependencies already queued, or prefetched within
+// the last hour, are ignored.
+func (f *Prefetcher) Submit(ctx context.Context, deps k6deps.Dependencies) error {
+	accepted, err := f.accept(deps)
+	if !accepted {
+		return err
+	}
+
+	select {
+	case f.queue <- deps:
+		return nil
+	case <-ctx.Done():
+		f.forget(deps)
+		return ctx.Err()
+	case <-f.ctx.Done():
+		return ErrClosed
+	}
+}
+
+// TrySubmit queues the prefetch of the binary for the dependencies if there is room in the queue.
+// Returns false if the queue is full or the prefetcher is closed.
+func (f *Prefetcher) TrySubmit(deps k6deps.Dependencies) bool {
+	accepted, err := f.accept(deps)
+	if !accepted {
+		return err == nil
+	}
+
+	select {
+	case f.queue <- deps:
+		return true
+	default:
+		f.forget(deps)
+		return false
+	}
+}
+
+// accept returns true if the dependencies are not queued and were not prefetched within seenTTL,
+// recording them as queued. Returns an [ErrClosed] error if the prefetcher is closed.
+func (f *Prefetcher) accept(deps k6deps.Dependencies) (bool, error) {
+	key := f.key(deps)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return false, ErrClosed
+	}
+
+	// dependencies queued or in progress are recorded without the time they were prefetched
+	if prefetched, found := f.seen[key]; found && (prefetched.IsZero() || time.Since(prefetched) < f.seenTTL) {
+		f.stats.Deduplicated++
+		return false, nil
+	}
+	f.seen[key] = time.Time{}
+
+	return true, nil
+}
+
+// forget removes the dependencies from the ones seen, so they can be submitted again
+func (f *Prefetcher) forget(deps k6deps.Dependencies) {
+	key := f.key(deps)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.seen, key)
+}
+
+// prefetched records the dependencies were prefetched now, removing those prefetched more
+// than seenTTL ago, so the dependencies seen don't grow indefinitely
+func (f *Prefetcher) prefetched(key string) {
+	f.seen[key] = time.Now()
+
+	for seenKey, prefetched := range f.seen {
+		if !prefetched.IsZero() && time.Since(prefetched) >= f.seenTTL {
+			delete(f.seen, seenKey)
+		}
+	}
+}
+
+func (f *Prefetcher) key(deps k6deps.Dependencies) string {
+	k6Constrains, buildDeps := f.provider.buildDeps(deps)
+	return requestKey(f.provider.platform, "", "", k6Constrains, buildDeps)
+}
+
+// Stats returns the counters of the prefetcher
+func (f *Prefetcher) Stats() PrefetchStats {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.stats
+}
+
+// Close stops the prefetcher, cancelling the prefetches in progress and discarding the pending ones
+func (f *Prefetcher) Close() error {
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return nil
+	}
+	f.closed = true
+	f.mutex.Unlock()
+
+	f.cancel()
+	f.workers.Wait()
+
+	return nil
+}
+
+func (f *Prefetcher) work() {
+	defer f.workers.Done()
+
+	for {
+		select {
+		case <-f.ctx.Done():
+			return
+		case deps := <-f.queue:
+			f.prefetch(deps)
+		}
+	}
+}
+
+// prefetch gets the binary for the dependencies, unless the disk budget was exceeded
+func (f *Prefetcher) prefetch(deps k6deps.Dependencies) {
+	key := f.key(deps)
+
+	f.mutex.Lock()
+	overBudget := f.config.DiskBudget > 0 && f.stats.Downloaded >= f.config.DiskBudget
+	if overBudget {
+		f.stats.Skipped++
+		// allow prefetching later if room is made in the cache
+		delete(f.seen, key)
+	}
+	f.mutex.Unlock()
+
+	if overBudget {
+		return
+	}
+
+	ctx := withRateLimiter(WithTiming(f.ctx), f.limiter)
+	binary, err := f.provider.GetBinary(ctx, deps)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err != nil {
+		f.stats.Failed++
+		delete(f.seen, key)
+		return
+	}
+
+	f.prefetched(key)
+
+	// the binary was downloaded if time was spent downloading it
+	if timing, _ := TimingFromContext(ctx); timing.Download == 0 {
+		f.stats.Cached++
+		return
+	}
+
+	f.stats.Prefetched++
+	if info, err := os.Stat(binary.Path); err == nil {
+		f.stats.Downloaded += info.Size()
+	}
+}
+
+// rateLimiter limits the bytes per second written by all the writers sharing it
+type rateLimiter struct {
+	mutex       sync.Mutex
+	bytesPerSec int64
+	next        time.Time
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &rateLimiter{bytesPerSec: bytesPerSec}
+}
+
+// wait blocks until n bytes can be written without exceeding the rate
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSec))
+	l.mutex.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type rateLimiterKey struct{}
+
+// withRateLimiter returns a context that limits the bandwidth used by the downloads
+func withRateLimiter(ctx context.Context, limiter *rateLimiter) context.Context {
+	if limiter == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rateLimiterKey{}, limiter)
+}
+
+// rateLimitedWriter limits the rate of writes using the context's rate limiter, if any
+func rateLimitedWriter(ctx context.Context, writer io.Writer) io.Writer {
+	limiter, ok := ctx.Value(rateLimiterKey{}).(*rateLimiter)
+	if !ok {
+		return writer
+	}
+	return &limitedWriter{ctx: ctx, writer: writer, limiter: limiter}
+}
+
+type limitedWriter struct {
+	ctx     context.Context //nolint:containedctx
+	writer  io.Writer
+	limiter *rateLimiter
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if err := w.limiter.wait(w.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return w.writer.Write(p)
+}
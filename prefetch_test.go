@@ -0,0 +1,208 @@
+package k6provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+// waitPrefetch waits until the prefetcher has processed the given number of requests
+func waitPrefetch(t *testing.T, prefetcher *Prefetcher, requests int) PrefetchStats {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		stats := prefetcher.Stats()
+		if stats.Prefetched+stats.Cached+stats.Skipped+stats.Failed >= requests {
+			return stats
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("timeout waiting for prefetches: %+v", prefetcher.Stats())
+	return PrefetchStats{}
+}
+
+func TestPrefetcher(t *testing.T) {
+	t.Parallel()
+
+	binary := []byte("k6 binary")
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(binary)
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(_ context.Context, _ string, k6 string, _ []k6build.Dependency) (k6build.Artifact, error) {
+			id := strings.NewReplacer("=", "", ".", "").Replace(k6)
+			return k6build.Artifact{ID: id, URL: store.URL}, nil
+		},
+	)
+
+	testCases := []struct {
+		title    string
+		config   PrefetchConfig
+		versions []string
+		cached   []string
+		requests int
+		expect   PrefetchStats
+	}{
+		{
+			title:    "prefetch distinct dependencies",
+			versions: []string{"v0.50.0", "v0.51.0"},
+			requests: 2,
+			expect:   PrefetchStats{Prefetched: 2, Downloaded: 2 * int64(len(binary))},
+		},
+		{
+			title:    "deduplicate dependencies",
+			versions: []string{"v0.50.0", "v0.50.0", "v0.51.0"},
+			requests: 2,
+			expect:   PrefetchStats{Prefetched: 2, Deduplicated: 1, Downloaded: 2 * int64(len(binary))},
+		},
+		{
+			title:    "skip cached binaries",
+			versions: []string{"v0.50.0", "v0.51.0"},
+			cached:   []string{"v0.50.0"},
+			requests: 2,
+			expect:   PrefetchStats{Prefetched: 1, Cached: 1, Downloaded: int64(len(binary))},
+		},
+		{
+			title:    "disk budget exceeded",
+			config:   PrefetchConfig{Concurrency: 1, DiskBudget: 1},
+			versions: []string{"v0.50.0", "v0.51.0"},
+			requests: 2,
+			expect:   PrefetchStats{Prefetched: 1, Skipped: 1, Downloaded: int64(len(binary))},
+		},
+		{
+			title:    "limited bandwidth",
+			config:   PrefetchConfig{Bandwidth: 1024},
+			versions: []string{"v0.50.0"},
+			requests: 1,
+			expect:   PrefetchStats{Prefetched: 1, Downloaded: int64(len(binary))},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+
+			for _, version := range tc.cached {
+				deps := k6deps.Dependencies{}
+				_ = deps.UnmarshalText([]byte("k6=" + version))
+				if _, err := provider.GetBinary(context.TODO(), deps); err != nil {
+					t.Fatalf("test setup: %v", err)
+				}
+			}
+
+			prefetcher := provider.NewPrefetcher(tc.config)
+			t.Cleanup(func() { _ = prefetcher.Close() })
+
+			for _, version := range tc.versions {
+				deps := k6deps.Dependencies{}
+				_ = deps.UnmarshalText([]byte("k6=" + version))
+				if err := prefetcher.Submit(context.TODO(), deps); err != nil {
+					t.Fatalf("unexpected %v", err)
+				}
+			}
+
+			stats := waitPrefetch(t, prefetcher, tc.requests)
+			if stats != tc.expect {
+				t.Fatalf("expected %+v got %+v", tc.expect, stats)
+			}
+		})
+	}
+}
+
+func TestPrefetcherClosed(t *testing.T) {
+	t.Parallel()
+
+	provider := newTestProvider(t, nil, t.TempDir())
+	prefetcher := provider.NewPrefetcher(PrefetchConfig{})
+
+	if err := prefetcher.Close(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if prefetcher.TrySubmit(k6deps.Dependencies{}) {
+		t.Fatalf("expected submit to be rejected after close")
+	}
+}
+
+func TestPrefetcherForgetsPrefetched(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("k6 binary"))
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", URL: store.URL}, nil
+		},
+	)
+
+	testCases := []struct {
+		title   string
+		seenTTL time.Duration
+		expect  PrefetchStats
+		seen    int
+	}{
+		{
+			title:   "prefetched recently",
+			seenTTL: time.Hour,
+			expect:  PrefetchStats{Prefetched: 1, Deduplicated: 1, Downloaded: 9},
+			seen:    1,
+		},
+		{
+			title:   "prefetched long ago",
+			seenTTL: 0,
+			expect:  PrefetchStats{Prefetched: 1, Cached: 1, Downloaded: 9},
+			seen:    0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+			prefetcher := provider.NewPrefetcher(PrefetchConfig{})
+			prefetcher.seenTTL = tc.seenTTL
+			t.Cleanup(func() { _ = prefetcher.Close() })
+
+			deps := k6deps.Dependencies{}
+			_ = deps.UnmarshalText([]byte("k6=v0.50.0"))
+
+			// the same dependencies are submitted once the first prefetch completes
+			if err := prefetcher.Submit(context.TODO(), deps); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			waitPrefetch(t, prefetcher, 1)
+			if err := prefetcher.Submit(context.TODO(), deps); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			stats := waitPrefetch(t, prefetcher, 2-tc.expect.Deduplicated)
+			if stats != tc.expect {
+				t.Fatalf("expected %+v got %+v", tc.expect, stats)
+			}
+
+			prefetcher.mutex.Lock()
+			seen := len(prefetcher.seen)
+			prefetcher.mutex.Unlock()
+			if seen != tc.seen {
+				t.Fatalf("expected %d dependencies seen got %d", tc.seen, seen)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/grafana/k6deps"
+)
+
+// GetBinaryPrivateCopy returns a custom k6 binary that satisfies the given set of dependencies,
+// like [Provider.GetBinary], but the binary's path is a private copy in a temporary directory
+// owned by the caller, so the binary is not affected if the cache is pruned while in use.
+//
+// The copy is a hardlink to the cached binary if possible, so it doesn't use additional space.
+// The temporary directory is created in [Config.PrivateCopyDir], or the os' temp dir if not set.
+//
+// The returned function removes the private copy. It must be called when the binary is no
+// longer needed.
+func (p *Provider) GetBinaryPrivateCopy(
+	ctx context.Context,
+	deps k6deps.Dependencies,
+) (K6Binary, func() error, error) {
+	binary, err := p.GetBinary(ctx, deps)
+	if err != nil {
+		return K6Binary{}, nil, err
+	}
+
+	private, release, err := p.privateCopy(binary)
+	// the binary was pruned before the copy was made, get it again
+	if errors.Is(err, os.ErrNotExist) {
+		binary, err = p.GetBinary(ctx, deps)
+		if err != nil {
+			return K6Binary{}, nil, err
+		}
+		private, release, err = p.privateCopy(binary)
+	}
+	if err != nil {
+		return K6Binary{}, nil, p.recordError(NewWrappedError(ErrBinary, err))
+	}
+
+	return private, release, nil
+}
+
+// privateCopy links or copies the binary into a new temporary directory
+func (p *Provider) privateCopy(binary K6Binary) (K6Binary, func() error, error) {
+	privateDir, err := os.MkdirTemp(p.config.PrivateCopyDir, "k6provider-")
+	if err != nil {
+		return K6Binary{}, nil, err
+	}
+
+	release := func() error {
+		return os.RemoveAll(privateDir)
+	}
+
+	privatePath := filepath.Join(privateDir, filepath.Base(binary.Path))
+	if err = linkOrCopy(binary.Path, privatePath); err != nil {
+		_ = release()
+		return K6Binary{}, nil, err
+	}
+
+	binary.Path = privatePath
+
+	return binary, release, nil
+}
+
+// linkOrCopy creates a hardlink to the file or, if not possible (e.g. the target is in another
+// filesystem or the filesystem does not support hardlinks), a copy
+func linkOrCopy(source string, target string) error {
+	if _, err := os.Stat(source); err != nil {
+		return err
+	}
+
+	if err := os.Link(source, target); err == nil {
+		return nil
+	}
+
+	from, err := os.Open(source) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer from.Close() //nolint:errcheck
+
+	to, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o700) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(to, from)
+	if closeErr := to.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
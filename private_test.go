@@ -0,0 +1,58 @@
+package k6provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestGetBinaryPrivateCopy(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("k6 binary"))
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", URL: store.URL}, nil
+		},
+	)
+
+	binDir := t.TempDir()
+	provider := newTestProvider(t, buildSrv, binDir)
+	provider.config.PrivateCopyDir = t.TempDir()
+
+	binary, release, err := provider.GetBinaryPrivateCopy(context.TODO(), nil)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if filepath.Dir(filepath.Dir(binary.Path)) != provider.config.PrivateCopyDir {
+		t.Fatalf("expected private copy in %s got %s", provider.config.PrivateCopyDir, binary.Path)
+	}
+
+	// the private copy is not affected by pruning the cache
+	if err = os.RemoveAll(filepath.Join(binDir, "artifact")); err != nil {
+		t.Fatalf("pruning cache %v", err)
+	}
+
+	content, err := os.ReadFile(binary.Path)
+	if err != nil || string(content) != "k6 binary" {
+		t.Fatalf("expected private copy got %q %v", content, err)
+	}
+
+	if err = release(); err != nil {
+		t.Fatalf("releasing %v", err)
+	}
+
+	if _, err = os.Stat(binary.Path); !os.IsNotExist(err) {
+		t.Fatalf("expected private copy removed got %v", err)
+	}
+}
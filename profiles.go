@@ -0,0 +1,67 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/grafana/k6deps"
+)
+
+// loadProfiles returns the dependency profiles defined in the configuration, including
+// the ones defined in the profiles file, if any. Profiles defined in the configuration
+// take precedence over the ones in the profiles file.
+func loadProfiles(config Config) (map[string]k6deps.Dependencies, error) {
+	definitions := map[string]string{}
+
+	if config.ProfilesFile != "" {
+		content, err := os.ReadFile(config.ProfilesFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading profiles file %w", err)
+		}
+		if err = json.Unmarshal(content, &definitions); err != nil {
+			return nil, fmt.Errorf("parsing profiles file %w", err)
+		}
+	}
+
+	for name, constraints := range config.Profiles {
+		definitions[name] = constraints
+	}
+
+	profiles := make(map[string]k6deps.Dependencies, len(definitions))
+	for name, constraints := range definitions {
+		deps := make(k6deps.Dependencies)
+		if err := deps.UnmarshalText([]byte(constraints)); err != nil {
+			return nil, fmt.Errorf("parsing profile %q %w", name, err)
+		}
+		profiles[name] = deps
+	}
+
+	return profiles, nil
+}
+
+// Profiles returns the names of the dependency profiles known by the provider, sorted
+func (p *Provider) Profiles() []string {
+	names := make([]string, 0, len(p.profiles))
+	for name := range p.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// GetBinaryForProfile returns a custom k6 binary that satisfies the dependencies of a
+// named profile. See [Config.Profiles] and [Config.ProfilesFile] for defining profiles.
+//
+// If the profile is not defined, an [ErrConfig] error is returned.
+func (p *Provider) GetBinaryForProfile(ctx context.Context, profile string) (K6Binary, error) {
+	deps, found := p.profiles[profile]
+	if !found {
+		return K6Binary{}, NewWrappedError(ErrConfig, fmt.Errorf("unknown profile %q", profile))
+	}
+
+	return p.GetBinary(ctx, deps)
+}
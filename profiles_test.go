@@ -0,0 +1,117 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestGetBinaryForProfile(t *testing.T) {
+	t.Parallel()
+
+	profilesFile := filepath.Join(t.TempDir(), "profiles.json")
+	profiles := `{"browser-suite": "k6=v0.50.0", "faker": "k6=v0.50.0;k6/x/faker=v0.3.0"}`
+	if err := os.WriteFile(profilesFile, []byte(profiles), 0o600); err != nil {
+		t.Fatalf("test setup: writing profiles %v", err)
+	}
+
+	config := Config{
+		Profiles:     map[string]string{"browser-suite": "k6=v0.52.0"},
+		ProfilesFile: profilesFile,
+	}
+
+	testCases := []struct {
+		title         string
+		profile       string
+		expectErr     error
+		expectK6      string
+		expectDepsLen int
+	}{
+		{
+			title:         "profile from config overrides profiles file",
+			profile:       "browser-suite",
+			expectErr:     ErrBuild,
+			expectK6:      "=v0.52.0",
+			expectDepsLen: 0,
+		},
+		{
+			title:         "profile from profiles file",
+			profile:       "faker",
+			expectErr:     ErrBuild,
+			expectK6:      "=v0.50.0",
+			expectDepsLen: 1,
+		},
+		{
+			title:     "unknown profile",
+			profile:   "unknown",
+			expectErr: ErrConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			loaded, err := loadProfiles(config)
+			if err != nil {
+				t.Fatalf("loading profiles %v", err)
+			}
+
+			var (
+				k6Constrains string
+				deps         []k6build.Dependency
+			)
+			provider := &Provider{
+				buildSrv: newTestBuildServices(
+					buildServiceFunc(
+						func(_ context.Context, _ string, k6 string, d []k6build.Dependency) (k6build.Artifact, error) {
+							k6Constrains, deps = k6, d
+							return k6build.Artifact{}, errors.New("build failed")
+						},
+					),
+				),
+				profiles: loaded,
+				ctx:      context.Background(),
+			}
+
+			_, err = provider.GetBinaryForProfile(context.TODO(), tc.profile)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			if k6Constrains != tc.expectK6 {
+				t.Fatalf("expected k6 constrains %q got %q", tc.expectK6, k6Constrains)
+			}
+
+			if len(deps) != tc.expectDepsLen {
+				t.Fatalf("expected %d dependencies got %v", tc.expectDepsLen, deps)
+			}
+		})
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	t.Parallel()
+
+	loaded, err := loadProfiles(Config{
+		Profiles: map[string]string{"faker": "k6=v0.50.0", "browser-suite": "k6=v0.52.0", "sql": "k6/x/sql>0.4"},
+	})
+	if err != nil {
+		t.Fatalf("loading profiles %v", err)
+	}
+
+	provider := &Provider{profiles: loaded}
+
+	// the names are sorted, regardless of the order of the map
+	expected := []string{"browser-suite", "faker", "sql"}
+	for range 10 {
+		if names := provider.Profiles(); !slices.Equal(names, expected) {
+			t.Fatalf("expected %v got %v", expected, names)
+		}
+	}
+}
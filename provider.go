@@ -5,23 +5,30 @@ package k6provider
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
-	"runtime"
+	"sort"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/grafana/k6build"
-	"github.com/grafana/k6build/pkg/client"
 	"github.com/grafana/k6deps"
+	"github.com/grafana/k6provider/lock"
 )
 
 const (
 	k6Module             = "k6"
 	defaultPruneInterval = time.Hour
+	defaultMutableRefTTL = 24 * time.Hour
 )
 
 var (
@@ -31,12 +38,67 @@ var (
 	ErrBuild = errors.New("building binary")
 	// ErrConfig is produced by invalid configuration
 	ErrConfig = errors.New("invalid configuration")
+	// ErrDependencies indicates an error analyzing the dependencies of a script or archive
+	ErrDependencies = errors.New("analyzing dependencies")
+	// ErrDiscovery indicates the build service URL could not be discovered. See [Config.DiscoveryDomain]
+	ErrDiscovery = errors.New("discovering build service")
 	// ErrDownload indicates an error downloading binary
 	ErrDownload = errors.New("downloading binary")
+	// ErrDownloadTemporary indicates a download failure that may succeed if retried later,
+	// such as the store being unavailable or a network timeout. Returned wrapped in an [ErrDownload]
+	ErrDownloadTemporary = errors.New("temporary download failure")
+	// ErrDownloadPermanent indicates a download failure that will not succeed if retried,
+	// such as a missing artifact or an authorization failure. Returned wrapped in an [ErrDownload]
+	ErrDownloadPermanent = errors.New("permanent download failure")
 	// ErrInvalidParameters is produced by invalid build parameters
 	ErrInvalidParameters = errors.New("invalid build parameters")
 	// ErrPruningCache indicates an error pruning the binary cache
 	ErrPruningCache = errors.New("pruning cache")
+	// ErrNoSpace indicates there is no space left for storing the binary
+	ErrNoSpace = errors.New("no space left for binary")
+	// ErrReadOnly indicates the binary directory is in a read-only file system
+	ErrReadOnly = errors.New("binary directory is read-only")
+	// ErrNoExec indicates the binaries cannot be executed from the binary directory, because it is
+	// in a file system mounted noexec. See [Config.ExecBinDirs]
+	ErrNoExec = errors.New("binary directory does not allow executing binaries")
+	// ErrClosed is returned when using a provider that has been closed
+	ErrClosed = errors.New("provider closed")
+	// ErrChecksumMismatch indicates the checksum of the binary does not match the expected one
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrLockfile indicates an invalid lockfile or a binary that doesn't match it
+	ErrLockfile = errors.New("lockfile mismatch")
+	// ErrIntegrity indicates the build service returned an artifact whose checksum doesn't match
+	// the expected one. See [WithExpectedChecksums]
+	ErrIntegrity = errors.New("artifact integrity check failed")
+	// ErrAttestation indicates the attestation of an artifact is missing or could not be verified.
+	// See [Config.Attestations]
+	ErrAttestation = errors.New("verifying attestation")
+	// ErrPlatformUnsupported indicates the build services cannot build binaries for the platform
+	ErrPlatformUnsupported = errors.New("platform not supported")
+	// ErrServiceUnavailable indicates the build service or the artifact store cannot be reached
+	// or failed to respond. Retrying the request later may succeed
+	ErrServiceUnavailable = errors.New("service unavailable")
+	// ErrOffline indicates the network is not available, such as the network being unreachable
+	// or the failure to resolve host names
+	ErrOffline = errors.New("network unavailable")
+	// ErrQuotaExceeded indicates the build service or the artifact store rejected the request because
+	// a rate limit or a quota was exceeded. See [ErrorDetails.RetryAfter]
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrLocked indicates the binary is locked by another process and the lock could not be
+	// acquired before the context was done
+	ErrLocked = lock.ErrLocked
+	// ErrVersionMismatch indicates the output of the binary's version command was rejected by the
+	// version verifier. See [Config.VersionVerifier]
+	ErrVersionMismatch = errors.New("binary version verification failed")
+	// ErrNotInitialized indicates the build services could not be configured when first used,
+	// for example, because the build service URL is not yet available. See [Config.LazyInit]
+	ErrNotInitialized = errors.New("provider not initialized")
+	// ErrDeviceCodeDenied indicates the user denied the authorization requested by [DeviceCodeAuth]
+	// or the code expired before being authorized
+	ErrDeviceCodeDenied = errors.New("device authorization denied")
+	// ErrNetworkDisabled indicates the operation requires accessing the network, which is denied
+	// by the network policy. See [Config.NetworkPolicy]
+	ErrNetworkDisabled = errors.New("network access disabled")
 )
 
 // WrappedError defines a custom error type that allows creating an error
@@ -88,32 +150,129 @@ func AsWrappedError(err error) (WrappedError, bool) {
 // K6Binary defines the attributes of a k6 binary
 type K6Binary struct {
 	// Path to the binary
-	Path string
+	Path string `json:"path,omitempty"`
+	// ID of the artifact the binary was obtained from
+	ID string `json:"id,omitempty"`
+	// Platform of the binary
+	Platform string `json:"platform,omitempty"`
 	// Dependencies as a map of name: version
 	// e.g. {"k6": "v0.50.0", "k6/x/kubernetes": "v0.9.0"}
-	Dependencies map[string]string
+	Dependencies map[string]string `json:"dependencies,omitempty"`
 	// Checksum of the binary
-	Checksum string
+	Checksum string `json:"checksum,omitempty"`
+	// Tools companion tools shipped with the binary in the artifact, such as agents or browsers,
+	// as a map of name: path. The name is the tool's file name, without the ".exe" extension.
+	// Only the binary is verified against the artifact's checksum
+	Tools map[string]string `json:"tools,omitempty"`
+	// Attestation verified provenance of the binary, if attestations are enabled and the build
+	// service publishes them. See [Config.Attestations]
+	Attestation *Attestation `json:"attestation,omitempty"`
+	// BuildOptions the binary was built with, if any. See [Provider.GetBinaryWithOptions]
+	BuildOptions *BuildOptions `json:"buildOptions,omitempty"`
+	// Catalog used for resolving the dependencies, if any. See [WithCatalog]
+	Catalog string `json:"catalog,omitempty"`
+	// Ref k6 reference the binary was built from, if any. See [Provider.GetBinaryForRef]
+	Ref string `json:"ref,omitempty"`
 }
 
-// UnmarshalDeps returns the dependencies as a list of name:version pairs separated by ";"
-func (b K6Binary) UnmarshalDeps() string {
+// FormatDeps returns the dependencies as a list of name:"version" pairs separated by ";"
+// sorted by name. e.g. k6:"v0.50.0";k6/x/kubernetes:"v0.9.0";
+func (b K6Binary) FormatDeps() string {
+	names := make([]string, 0, len(b.Dependencies))
+	for dep := range b.Dependencies {
+		names = append(names, dep)
+	}
+	sort.Strings(names)
+
 	buffer := &bytes.Buffer{}
-	for dep, version := range b.Dependencies {
-		buffer.WriteString(fmt.Sprintf("%s:%q;", dep, version))
+	for _, dep := range names {
+		buffer.WriteString(fmt.Sprintf("%s:%q;", dep, b.Dependencies[dep]))
 	}
 	return buffer.String()
 }
 
+// UnmarshalDeps returns the dependencies as a list of name:version pairs separated by ";"
+//
+// Deprecated: despite its name, UnmarshalDeps formats the dependencies. Use [K6Binary.FormatDeps].
+func (b K6Binary) UnmarshalDeps() string {
+	return b.FormatDeps()
+}
+
 // Config defines the configuration of the Provider.
 type Config struct {
-	// Platform for the binaries. Defaults to the current platform
+	// Platform for the binaries. Defaults to the current platform. Common aliases are accepted,
+	// such as "Linux/x86_64" or "darwin-arm64" (see [NormalizePlatform])
 	Platform string
-	// BinDir path to binary directory. Defaults to the os' tmp dir
+	// DetectPlatform uses the platform detected from the running system, including the ARM
+	// variant and the libc flavor, if Platform is not set. See [DetectPlatform]
+	DetectPlatform bool
+	// BinDir path to binary directory. Defaults to the "k6provider" directory in the user's cache
+	// directory (see [os.UserCacheDir]), such as $XDG_CACHE_HOME or ~/.cache in linux,
+	// ~/Library/Caches in macOS and %LocalAppData% in windows, so the binaries survive reboots
+	// and are not shared with other users. The os' temp dir is used if it is not available
 	BinDir string
+	// TempBinDir uses the "k6provider/cache" directory in the os' temp dir as the default BinDir,
+	// instead of the user's cache directory, as previous versions did
+	TempBinDir bool
+	// MemoryTier upper tier of the cache for small, frequently used binaries, for example, in a
+	// memory-backed directory (tmpfs) for runners with slow persistent disks. It is checked before
+	// BinDir and the binaries obtained from the other tiers or downloaded are copied into it, if
+	// not larger than its MaxBinarySize
+	MemoryTier CacheTier
+	// SharedTier lower tier of the cache shared with other hosts, for example, in a network file
+	// system. It is checked after BinDir and FallbackBinDirs and the binaries found in it are
+	// copied into BinDir. Downloaded binaries are copied into it, so other hosts can use them
+	SharedTier CacheTier
+	// FallbackBinDirs alternative binary directories, tried in order when the binary
+	// cannot be stored in BinDir because it is full or read-only
+	FallbackBinDirs []string
+	// ExecBinDirs candidate binary directories used, in order, instead of BinDir if it is in a file
+	// system mounted noexec, such as /tmp in many hardened containers. If the binaries cannot be
+	// executed from BinDir nor any candidate, creating the provider fails with [ErrNoExec].
+	// Only detected in linux and macOS
+	ExecBinDirs []string
+	// PrivateCopyDir directory where private copies of the binaries are created.
+	// Defaults to the os' tmp dir. See [Provider.GetBinaryPrivateCopy]
+	PrivateCopyDir string
 	// BuildServiceURL URL of the k6 build service
-	// If not specified the value from K6_BUILD_SERVICE_URL environment variable is used
+	// If not specified the value from K6_BUILD_SERVICE_URL environment variable is used.
+	// It can be a comma-separated list of URLs. The first is the primary build service and
+	// the others are tried in order, before those in BuildServiceURLs, if it fails.
 	BuildServiceURL string
+	// BuildServiceURLs URLs of additional build services, such as a global fallback for a regional
+	// service. Build services are tried in order, starting with BuildServiceURL, if specified.
+	// Services that failed recently are tried last.
+	BuildServiceURLs []string
+	// PlatformServiceMap maps platforms (e.g. "windows/amd64") to the URL of the build service
+	// used for building their binaries, for example, when a platform is only supported by some
+	// deployments. Other platforms are built by the build services above.
+	PlatformServiceMap map[string]string
+	// BuildServiceReplicas URLs of replicas of the build service at BuildServiceURL.
+	// Requests are distributed across the build service and its replicas (see BuildServiceBalancing)
+	BuildServiceReplicas []string
+	// ResolveBuildServiceReplicas uses each address the host of BuildServiceURL resolves to as a replica
+	ResolveBuildServiceReplicas bool
+	// BuildServiceBalancing strategy for distributing requests across replicas. Defaults to [RoundRobin]
+	BuildServiceBalancing BalancingStrategy
+	// BuildRetry defines how the build requests that fail with a transient error are retried.
+	// By default, they are not retried
+	BuildRetry BuildRetryPolicy
+	// AsyncBuilds uses the asynchronous build protocol, which allows builds that take longer than
+	// the timeout of the HTTP requests. See [Provider.StartBuild] and [Provider.WaitForArtifact]
+	AsyncBuilds bool
+	// BuildPollInterval time between requests for the status of an async build, unless the build
+	// service requests a different one. Defaults to 2s
+	BuildPollInterval time.Duration
+	// OnBuildStatus is called when the status of an async build changes
+	OnBuildStatus func(BuildStatus) `json:"-"`
+	// OnBuildProgress is called when the progress of an async build reported by the build service,
+	// such as its position in the queue or the estimated time until it is ready, changes
+	OnBuildProgress func(BuildProgress) `json:"-"`
+	// DiscoveryDomain domain used for discovering the build service URL when BuildServiceURL is not
+	// specified, using the well-known endpoint https://<domain>/.well-known/k6build or the DNS
+	// records _k6build.<domain> (TXT, with value "url=<url>") or _k6build._tcp.<domain> (SRV).
+	// If not specified the value from K6_BUILD_SERVICE_DISCOVERY_DOMAIN environment variable is used
+	DiscoveryDomain string
 	// BuildServiceAuthType type of passed in the header "Authorization: <type> <auth>".
 	// Can be used to set the type as "Basic", "Token" or any custom type. Default to "Bearer"
 	BuildServiceAuthType string
@@ -125,6 +284,27 @@ type Config struct {
 	BuildServiceAuth string
 	// BuildServiceHeaders HTTP headers for the k6 build service
 	BuildServiceHeaders map[string]string
+	// InteractiveAuth if set, is called for obtaining a token for the build service when it rejects
+	// a request as unauthorized (401), for example, by asking the user to log in with a browser
+	// (see [DeviceCodeAuth]). The request is retried with the token, which is used for the following
+	// requests instead of BuildServiceAuth and saved in the CredentialSource, if any
+	InteractiveAuth InteractiveAuthHandler `json:"-"`
+	// CredentialSource source of the credentials not specified in the configuration or the
+	// environment (see BuildServiceAuth and DownloadConfig.Authorization). Currently, only the
+	// system's keyring is supported, as "keyring:<service>". See [SaveCredential]
+	CredentialSource string
+	// BuildServiceProxyURL URL of the proxy for the build service requests. Allows routing the
+	// build service requests and the downloads (see DownloadConfig.ProxyURL) through different proxies.
+	// If not specified the value of K6_BUILD_SERVICE_PROXY is used.
+	// If no value is defined, the proxy is selected using the BuildServiceProxy function or, if
+	// not set, from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	BuildServiceProxyURL string
+	// BuildServiceNoProxy comma-separated list of hosts, domains (e.g. ".example.com"), IP addresses
+	// or CIDR blocks of build services that are accessed without using the proxy in BuildServiceProxyURL
+	BuildServiceNoProxy string
+	// BuildServiceProxy selects the proxy for each build service request, for example, using a
+	// proxy auto-config (PAC) file. Ignored if a proxy URL is specified (see BuildServiceProxyURL)
+	BuildServiceProxy ProxyFunc `json:"-"`
 	// HighWaterMark is the upper limit of cache size to trigger a prune.
 	// If 0 (default) the cache is not pruned.
 	// This option is ignored when running in windows systems
@@ -132,8 +312,191 @@ type Config struct {
 	HighWaterMark int64
 	// PruneInterval minimum time between prune attempts. Defaults to 1h
 	PruneInterval time.Duration
+	// KeepPerFamily maximum number of binaries kept for each family of dependency sets that
+	// differ only in the k6 patch version (e.g. k6 v0.55.0 and v0.55.1 with the same extensions).
+	// The least recently used binaries are removed when the cache is pruned. If 0 (default) the
+	// number of binaries is not limited. This option is ignored when running in windows systems
+	KeepPerFamily int
+	// TrashGracePeriod if set, pruned binaries are moved to a trash directory where they are kept
+	// for this period before being removed. A binary in the trash is restored if it is requested
+	// again during the grace period, instead of downloading it again.
+	// This option is ignored when running in windows systems
+	TrashGracePeriod time.Duration
+	// MutableRefTTL time after which binaries built from a mutable k6 reference, such as a
+	// branch or "nightly", are downloaded again. Defaults to 24h. See [Provider.GetBinaryForRef]
+	MutableRefTTL time.Duration
+	// CacheScope identifies the source of the binaries, such as a build service deployment.
+	// If set, it is included in the cache path, so artifacts with the same ID obtained from
+	// different sources (e.g. staging and production build services) don't collide.
+	CacheScope string
+	// ScopeCacheByBuildService uses the build service URL as CacheScope, if CacheScope is not set.
+	// With several build services (see BuildServiceURLs), the URL of the first one is used, so the
+	// artifacts obtained from the others when it fails share its scope. They are expected to be
+	// replicas or fallbacks that return the same artifacts, as the artifacts are looked up in the
+	// cache before knowing which build service would resolve them.
+	ScopeCacheByBuildService bool
+	// CacheGroup name or id of the group assigned to the binary directories and the binaries in
+	// them, which are made readable (and executable, for binaries) by the group, regardless of
+	// the process' umask. This option is ignored when running in windows systems
+	CacheGroup string
+	// SharedCache makes the binary directories and the binaries in them readable (and executable,
+	// for binaries) by all users, for example, so multiple users can run the binaries provided by
+	// a daemon user. Binaries are only made readable once they are completely downloaded and
+	// are never writable by other users. This option is ignored when running in windows systems
+	SharedCache bool
+	// StrictPermissions fails creating the provider if BinDir is unsafe: if it, or any directory
+	// in its path, can be replaced or modified by other users, for example, if it was pre-created
+	// by another user in a shared temporary directory. BinDir is always created without following
+	// symbolic links in public directories, and if the default BinDir is unsafe, a directory
+	// private to the user is used instead. This option is ignored when running in windows systems
+	StrictPermissions bool
+	// LockMode defines how processes downloading the same binary coordinate their access to its
+	// directory. Defaults to [LockFile]
+	LockMode LockMode
+	// NetworkBinDir handles BinDir as being in a network file system, such as NFS or SMB, even if
+	// it is not detected as such. In network file systems, the binary directory is locked by
+	// creating a lock file instead of using advisory locks, the binaries are synced to the file
+	// server after being written, and their last use is recorded in a file instead of relying
+	// on their modification time
+	NetworkBinDir bool
+	// ShutdownGracePeriod time the downloads in progress when the provider is closed are given
+	// to complete, so the binaries are stored in the cache. Downloads that don't complete within
+	// this period are cancelled, keeping their content for resuming them (see [Provider.GetBinary]).
+	// Defaults to 0 (no grace period)
+	ShutdownGracePeriod time.Duration
+	// ChecksumLayout stores each binary in a directory named after its checksum, as
+	// <BinDir>/<checksum>/k6, instead of the artifact ID, so the path of a binary only depends on
+	// its content, for example, for build systems that require deterministic paths (e.g. Bazel).
+	// CacheScope is not used with this layout, as the checksum identifies the binary's content
+	ChecksumLayout bool
+	// VersionVerifier if set, is called with the output of the "k6 version" command of each binary
+	// the first time it is provided, and the binary's dependencies as name: version, for checking
+	// the extensions built into the binary match those declared by the artifact. If it returns
+	// an error, the binary is not provided and an [ErrVersionMismatch] error is returned
+	VersionVerifier func(versionOutput string, deps map[string]string) error `json:"-"`
+	// CanaryVerification verifies the first binary provided from each version of a build service,
+	// as reported by its capabilities, by running its version command and checking the extensions
+	// built into it match those declared by the artifact, for detecting a build service upgrade
+	// that produces bad binaries. The results are recorded in BinDir (see [Provider.CanaryResults])
+	// and the binary is provided regardless of the result
+	CanaryVerification bool
+	// OnCanaryFailure is called when the canary verification of a build service version fails
+	OnCanaryFailure func(CanaryResult) `json:"-"`
+	// CacheCompression compresses the binaries stored in the cache, for example, in agents with
+	// limited disk space. The binaries are decompressed when requested into a temporary directory
+	// in PrivateCopyDir, or the os' temp dir if not set, which is removed when the provider is
+	// closed. Binaries stored uncompressed can still be used. Defaults to nil (not compressed)
+	CacheCompression Compression `json:"-"`
+	// ReconcileCache repairs the binary directories when the provider is created, removing
+	// partial files and artifact directories without a valid binary left by interrupted downloads
+	// and exclusive lock files left by processes that did not release them, and rebuilding the
+	// request index
+	ReconcileCache bool
+	// StaticHosts maps host names to the IP address used for connecting to them instead of
+	// resolving their names, for example, for reaching internal services in air-gapped
+	// environments without DNS entries for them. e.g. {"k6build.internal": "10.0.0.5"}.
+	// Applies to the build service and download requests
+	StaticHosts map[string]string
+	// Resolver used for resolving the host names of the build service and the artifact store.
+	// Defaults to the system's resolver
+	Resolver *net.Resolver `json:"-"`
+	// DNSOverHTTPSURL URL of a DNS over HTTPS (RFC 8484) endpoint used for resolving the host names
+	// of the build service and the artifact store, if Resolver is not set, for environments where the
+	// system's DNS cannot resolve them. e.g. "https://1.1.1.1/dns-query". See [NewDoHResolver]
+	DNSOverHTTPSURL string
+	// DialContext connects to the build service and the artifact store, or to their proxies.
+	// Defaults to a [net.Dialer] using the Resolver
+	DialContext DialFunc `json:"-"`
+	// NetworkPolicy defines whether the provider can access the network. With [NetworkDeny], only
+	// the binaries in the cache are provided (see StaleIfError), the update checks and the telemetry
+	// are not performed, and the discovery of the build service fails. Defaults to [NetworkAllow]
+	NetworkPolicy NetworkPolicy
+	// HTTPDebug hooks for observing the requests sent to the build service and the artifact store,
+	// with the credentials redacted, for diagnosing proxy or authentication issues
+	HTTPDebug HTTPDebugConfig `json:"-"`
+	// LazyInit defers configuring the build services until they are first used or [Provider.Init] is
+	// called, so the provider can be created before the build service URL or its credentials are
+	// available, for example, in pods that receive them later
+	LazyInit bool
 	// Download configuration
 	DownloadConfig DownloadConfig
+	// Attestations configuration for retrieving and verifying the provenance of the binaries
+	Attestations AttestationConfig
+	// Telemetry configuration for reporting usage statistics. Disabled by default
+	Telemetry TelemetryConfig
+	// UpdateCheck if set, [Provider.GetBinary] returns the binary that satisfied the same dependencies
+	// before from the cache without waiting for the build service, which is asked in the background
+	// whether the dependencies resolve to a different artifact, such as one with newer versions that
+	// satisfy the constraints. If so, an [EventUpdateAvailable] event is published, OnUpdateAvailable
+	// is called, and the next request for the dependencies obtains the new artifact.
+	UpdateCheck bool
+	// OnUpdateAvailable if set, is called when the update check finds a newer artifact for the
+	// dependencies of a binary provided from the cache. See UpdateCheck
+	OnUpdateAvailable func(UpdateNotice) `json:"-"`
+	// StaleWhileRevalidate if set, [Provider.GetBinary] returns the binary that satisfied the same
+	// dependencies from the cache without waiting for the build service, if the build service resolved
+	// them to it within this time, and resolves them again in the background, as with UpdateCheck.
+	// Useful when the build service is slow. Default to 0 (the dependencies are always resolved first)
+	StaleWhileRevalidate time.Duration
+	// StaleIfError if set, [Provider.GetBinary] returns the binary that satisfied the same dependencies
+	// from the cache when the build service is unavailable (see [ErrServiceUnavailable]), the network
+	// is unavailable or disabled (see [ErrOffline] and [ErrNetworkDisabled]) or resolving them exceeds
+	// its budget (see PhaseBudget), if the build service resolved them to it within this time. Other
+	// errors, such as authentication failures or invalid requests, are always returned.
+	// Default to 0 (the errors are returned)
+	StaleIfError time.Duration
+	// AccessAnalytics records the binaries provisioned from the binary directory and their size, so
+	// [Provider.RecommendHWM] can recommend a high-water mark from the binaries used
+	AccessAnalytics bool
+	// UsageAnalytics records the dependencies of the binaries provisioned in the binary directory,
+	// so which extensions and versions are used can be analyzed. See [Provider.UsageReport]
+	UsageAnalytics bool
+	// OnDependencyUsage if set, is called with the dependencies of each binary provisioned,
+	// for example, for sending them to an analytics system. It must not block
+	OnDependencyUsage func(DependencyUsage) `json:"-"`
+	// DepsOptions options for analyzing the dependencies of scripts and archives, such as the
+	// manifest, the environment variable with dependencies or how to lookup the environment.
+	// The script and archive sources are set by each function. See [Provider.Analyze]
+	DepsOptions *k6deps.Options `json:"-"`
+	// Profiles defines named sets of dependencies as name: constraints
+	// e.g. {"browser-suite": "k6>=0.52;k6/x/faker>0.3"}
+	// See [Provider.GetBinaryForProfile]
+	Profiles map[string]string
+	// ProfilesFile path to a JSON file with named sets of dependencies, using the same
+	// format as Profiles. Profiles defined in Profiles take precedence.
+	ProfilesFile string
+	// ExtensionAliases defines short names for extensions as alias: extension name, which are
+	// replaced by the extension name before requesting the binary to the build service
+	// e.g. {"kubernetes": "k6/x/kubernetes", "sql": "github.com/example/xk6-sql-fork"}
+	ExtensionAliases map[string]string
+	// ExtensionAliasesFile path to a JSON file with extension aliases, using the same format as
+	// ExtensionAliases. Aliases defined in ExtensionAliases take precedence.
+	ExtensionAliasesFile string
+	// ResolveExtensionAlias is called for resolving the name of each extension in the dependencies.
+	// It returns the name of the extension and true if the name is an alias. Otherwise, the
+	// aliases in ExtensionAliases and ExtensionAliasesFile are used.
+	ResolveExtensionAlias func(name string) (string, bool) `json:"-"`
+	// DependencyOverrides overrides the dependencies declared by the scripts before requesting
+	// the binary to the build service, for example, for forcing a vetted version of k6 or
+	// replacing an extension with an internal fork, as dependency: override. The override is
+	// the constraints of the dependency, or the name of the replacing extension followed by its
+	// constraints, if overridden. Aliases are resolved before applying the overrides.
+	// e.g. {"k6": "=v0.55.0", "k6/x/sql": "github.com/example/xk6-sql-fork >=v1.2.0"}
+	// Overrides are also taken from the K6_DEPENDENCY_OVERRIDES environment variable, as a list
+	// of dependency:override pairs separated by ";". Overrides defined here take precedence.
+	DependencyOverrides map[string]string
+	// MinimumVersions minimum versions of the dependencies, as dependency: version, for example,
+	// the first version of k6 with a security fix. The constraints requested for a dependency are
+	// limited to its minimum version, after applying the DependencyOverrides, and replaced by it
+	// if they only accept older versions (e.g. "=v0.50.0" is replaced by ">=v0.55.1").
+	// e.g. {"k6": "v0.55.1"}
+	MinimumVersions map[string]string
+	// OnMinimumVersion if set, is called when the constraints requested for a dependency only
+	// accept versions older than its minimum version and were replaced. See MinimumVersions
+	OnMinimumVersion func(MinimumVersionWarning) `json:"-"`
+	// PhaseBudget splits the time until the deadline of the requests between resolving the
+	// dependencies and downloading the binary. Defaults to no budget (the phases share the deadline)
+	PhaseBudget PhaseBudget
 }
 
 // Provider implements an interface for providing custom k6 binaries
@@ -141,12 +504,41 @@ type Config struct {
 //
 // [k6build]: https://github.com/grafana/k6build
 type Provider struct {
-	client     *http.Client
-	downloader *downloader
-	binDir     string
-	buildSrv   k6build.BuildService
-	platform   string
-	pruner     *Pruner
+	config      Config
+	errors      errorLog
+	client      *http.Client
+	downloader  *downloader
+	binDir      string
+	fallbacks   []string
+	buildSrv    *buildServices
+	builds      pendingBuilds
+	events      eventBus
+	platform    string
+	networkFS   bool
+	permissions cachePermissions
+	pruner      *Pruner
+	refTTL      time.Duration
+	cacheScope  string
+	profiles    map[string]k6deps.Dependencies
+	aliases     map[string]string
+	overrides   map[string]dependencyOverride
+	minimums    map[string]*semver.Version
+	telemetry   *telemetry
+	exec        execDir
+	memoryTier  *cacheTier
+	sharedTier  *cacheTier
+	canaryMutex sync.Mutex
+	initMutex   sync.Mutex
+	updating    sync.Map
+	resolutions sync.Map
+	ctx         context.Context
+	cancel      context.CancelFunc
+	tasks       sync.WaitGroup
+	taskMutex   sync.Mutex
+	closing     bool
+	downloads   sync.WaitGroup
+	closeOnce   sync.Once
+	closeErr    error
 }
 
 // NewDefaultProvider returns a Provider with default settings
@@ -162,75 +554,198 @@ func NewDefaultProvider() (*Provider, error) {
 // If BuildServiceURL is not set, it will use the K6_BUILD_SERVICE_URL environment variable
 // If DownloadProxyURL is not set, it will use the K6_DOWNLOAD_PROXY environment variable
 func NewProvider(config Config) (*Provider, error) {
-	binDir := config.BinDir
-	if binDir == "" {
-		binDir = filepath.Join(os.TempDir(), "k6provider", "cache")
+	config, err := withCredentials(config)
+	if err != nil {
+		return nil, err
 	}
 
-	httpClient := http.DefaultClient
+	config, err = withDoHResolver(config)
+	if err != nil {
+		return nil, err
+	}
 
-	buildSrvURL := config.BuildServiceURL
-	if buildSrvURL == "" {
-		buildSrvURL = os.Getenv("K6_BUILD_SERVICE_URL")
+	binDir, err := prepareBinDirs(config)
+	if err != nil {
+		return nil, err
 	}
-	if buildSrvURL == "" {
-		return nil, NewWrappedError(ErrConfig, fmt.Errorf("build service URL is required"))
+	binDir, err = execBinDir(config, binDir)
+	if err != nil {
+		return nil, err
 	}
+	networkFS := config.NetworkBinDir || isNetworkFS(binDir)
 
-	buildSrvAuth := config.BuildServiceAuth
-	if buildSrvAuth == "" {
-		buildSrvAuth = os.Getenv("K6_BUILD_SERVICE_AUTH")
+	permissions, err := newCachePermissions(config)
+	if err != nil {
+		return nil, err
 	}
 
-	buildSrv, err := client.NewBuildServiceClient(
-		client.BuildServiceClientConfig{
-			URL:               buildSrvURL,
-			Authorization:     buildSrvAuth,
-			AuthorizationType: config.BuildServiceAuthType,
-			Headers:           config.BuildServiceHeaders,
-		},
-	)
+	// with lazy initialization, the build services are configured when first used
+	var buildSrv *buildServices
+	buildSrvURL := ""
+	if !config.LazyInit {
+		buildSrv, buildSrvURL, err = newBuildServices(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	platform := defaultPlatform(config)
+	if config.Platform != "" {
+		platform, err = NormalizePlatform(config.Platform)
+		if err != nil {
+			return nil, NewWrappedError(ErrConfig, err)
+		}
+	}
+
+	dial, err := newDialFunc(config)
+	if err != nil {
+		return nil, NewWrappedError(ErrConfig, err)
+	}
+
+	downloader, err := newDownloader(config.DownloadConfig, dial)
 	if err != nil {
 		return nil, NewWrappedError(ErrConfig, err)
 	}
+	downloader.client = withHTTPDebug(downloader.client, config.HTTPDebug, HTTPTargetStore)
+	downloader.denied = networkDenied(config)
 
-	platform := config.Platform
-	if platform == "" {
-		platform = fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	// the artifacts of all the build services share the scope of the first one
+	cacheScope := config.CacheScope
+	if cacheScope == "" && config.ScopeCacheByBuildService {
+		cacheScope = buildSrvURL
 	}
 
-	pruneInterval := config.PruneInterval
-	if config.HighWaterMark > 0 && pruneInterval == 0 {
-		pruneInterval = defaultPruneInterval
+	refTTL := config.MutableRefTTL
+	if refTTL == 0 {
+		refTTL = defaultMutableRefTTL
+	}
+
+	profiles, err := loadProfiles(config)
+	if err != nil {
+		return nil, NewWrappedError(ErrConfig, err)
+	}
+
+	aliases, err := loadAliases(config)
+	if err != nil {
+		return nil, NewWrappedError(ErrConfig, err)
+	}
+
+	overrides, err := loadOverrides(config)
+	if err != nil {
+		return nil, NewWrappedError(ErrConfig, err)
 	}
 
-	downloader, err := newDownloader(config.DownloadConfig)
+	minimums, err := loadMinimumVersions(config)
 	if err != nil {
 		return nil, NewWrappedError(ErrConfig, err)
 	}
 
+	// telemetry is not reported if the network is denied
+	var reporter *telemetry
+	if !networkDenied(config) {
+		reporter = newTelemetry(config.Telemetry, platform)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Provider{
-		client:     httpClient,
-		downloader: downloader,
-		binDir:     binDir,
-		buildSrv:   buildSrv,
-		platform:   platform,
-		pruner:     NewPruner(binDir, config.HighWaterMark, pruneInterval),
+		config:      config,
+		client:      http.DefaultClient,
+		downloader:  downloader,
+		binDir:      binDir,
+		fallbacks:   config.FallbackBinDirs,
+		buildSrv:    buildSrv,
+		platform:    platform,
+		networkFS:   networkFS,
+		permissions: permissions,
+		pruner:      newPruner(config, binDir, networkFS),
+		memoryTier:  newCacheTier(config.MemoryTier),
+		sharedTier:  newCacheTier(config.SharedTier),
+		refTTL:      refTTL,
+		cacheScope:  scopeKey(cacheScope),
+		profiles:    profiles,
+		aliases:     aliases,
+		overrides:   overrides,
+		minimums:    minimums,
+		telemetry:   reporter,
+		ctx:         ctx,
+		cancel:      cancel,
 	}, nil
 }
 
+// prepareBinDirs returns the binary directory in the configuration or the default one,
+// reconciling the binary directories if requested
+func prepareBinDirs(config Config) (string, error) {
+	binDir := config.BinDir
+	if binDir == "" {
+		binDir = defaultBinDir(config)
+	}
+
+	if err := prepareSafeDir(binDir); err != nil {
+		switch {
+		case config.StrictPermissions:
+			return "", NewWrappedError(ErrConfig, err)
+		case config.BinDir == "":
+			// the default directory may have been pre-created by another user, use a private one
+			binDir = filepath.Join(os.TempDir(), fmt.Sprintf("k6provider-%d", os.Getuid()), "cache")
+			if err = prepareSafeDir(binDir); err != nil {
+				return "", NewWrappedError(ErrConfig, err)
+			}
+		}
+	}
+
+	if config.ReconcileCache {
+		for _, dir := range append([]string{binDir}, config.FallbackBinDirs...) {
+			if err := reconcileBinDir(dir); err != nil {
+				return "", NewWrappedError(ErrBinary, err)
+			}
+		}
+	}
+
+	return binDir, nil
+}
+
+// defaultBinDir returns the directory in the user's cache directory, or the os' temp dir if
+// not available or requested in the configuration
+func defaultBinDir(config Config) string {
+	if !config.TempBinDir {
+		if cacheDir, err := os.UserCacheDir(); err == nil {
+			return filepath.Join(cacheDir, "k6provider")
+		}
+	}
+
+	return filepath.Join(os.TempDir(), "k6provider", "cache")
+}
+
+// newPruner returns the pruner for the binary directory using the options in the configuration
+func newPruner(config Config, binDir string, networkFS bool) *Pruner {
+	pruneInterval := config.PruneInterval
+	if (config.HighWaterMark > 0 || config.KeepPerFamily > 0) && pruneInterval == 0 {
+		pruneInterval = defaultPruneInterval
+	}
+
+	return NewPruner(binDir, config.HighWaterMark, pruneInterval).
+		withRetention(config.KeepPerFamily).
+		withTrash(config.TrashGracePeriod).
+		withNetworkFS(networkFS)
+}
+
 // Artifact defines the artifact returned by the build service
 type Artifact struct {
 	// Unique id. Binaries satisfying the same set of dependencies have the same ID
-	ID string
+	ID string `json:"id,omitempty"`
 	// URL to fetch the artifact's binary
-	URL string
+	URL string `json:"url,omitempty"`
 	// List of dependencies that the artifact provides
-	Dependencies map[string]string
+	Dependencies map[string]string `json:"dependencies,omitempty"`
 	// platform
-	Platform string
+	Platform string `json:"platform,omitempty"`
 	// binary checksum (sha256)
-	Checksum string
+	Checksum string `json:"checksum,omitempty"`
+	// URL of the build service that produced the artifact
+	BuildService string `json:"buildService,omitempty"`
+	// options the binary was built with, if any. See [Provider.GetBinaryWithOptions]
+	BuildOptions *BuildOptions `json:"buildOptions,omitempty"`
 }
 
 // GetArtifact returns a custom k6 artifact that satisfies the given a set of dependencies.
@@ -240,30 +755,79 @@ func (p *Provider) GetArtifact(
 	ctx context.Context,
 	deps k6deps.Dependencies,
 ) (Artifact, error) {
-	k6Constrains, buildDeps := buildDeps(deps)
+	if p.ctx.Err() != nil {
+		return Artifact{}, ErrClosed
+	}
 
-	artifact, err := p.buildSrv.Build(ctx, p.platform, k6Constrains, buildDeps)
+	k6Constrains, buildDeps := p.buildDeps(deps)
+
+	return p.build(ctx, k6Constrains, buildDeps)
+}
+
+// build requests the artifact that satisfies the k6 constrains and dependencies from the build service
+func (p *Provider) build(ctx context.Context, k6Constrains string, deps []k6build.Dependency) (Artifact, error) {
+	return p.buildFor(ctx, p.platform, k6Constrains, deps)
+}
+
+// buildFor requests the artifact for the platform that satisfies the k6 constrains and dependencies
+// from the build service
+func (p *Provider) buildFor(
+	ctx context.Context,
+	platform string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (Artifact, error) {
+	p.events.publish(Event{Type: EventResolveStarted})
+	defer recordTiming(ctx, phaseResolve, time.Now())
+
+	// the build service receives the options for the platform
+	options := buildOptionsFrom(ctx).forPlatform(platform)
+	ctx = WithBuildOptions(ctx, options)
+
+	buildSrv, err := p.buildServices()
 	if err != nil {
-		if !errors.Is(err, ErrInvalidParameters) {
-			return Artifact{}, NewWrappedError(ErrBuild, err)
-		}
+		return Artifact{}, p.buildError(err)
+	}
 
-		// it is an invalid build parameters, we are interested in the
-		// root cause
-		cause := errors.Unwrap(err)
-		for errors.Unwrap(cause) != nil {
-			cause = errors.Unwrap(cause)
-		}
-		return Artifact{}, NewWrappedError(ErrInvalidParameters, cause)
+	artifact, buildSrvURL, err := buildSrv.build(ctx, platform, k6Constrains, deps)
+	if err != nil {
+		return Artifact{}, p.buildError(err)
 	}
 
+	result := newArtifact(artifact, buildSrvURL)
+	if !options.IsZero() {
+		result.BuildOptions = &options
+	}
+	if err := verifyIntegrity(ctx, result); err != nil {
+		return Artifact{}, withArtifact(err, result.ID)
+	}
+
+	return result, nil
+}
+
+// buildError returns the error for a failed build request
+func (p *Provider) buildError(err error) error {
+	// for invalid build parameters, we are interested in the reason reported
+	// by the build service
+	if reason, ok := invalidParameters(err); ok {
+		return p.recordError(NewWrappedError(ErrInvalidParameters, reason))
+	}
+	if errors.Is(err, ErrConfig) {
+		return p.recordError(err)
+	}
+	return p.recordError(NewWrappedError(ErrBuild, classifyServiceError(err)))
+}
+
+// newArtifact returns the Artifact for an artifact returned by the build service
+func newArtifact(artifact k6build.Artifact, buildSrvURL string) Artifact {
 	return Artifact{
 		ID:           artifact.ID,
 		URL:          artifact.URL,
 		Dependencies: artifact.Dependencies,
 		Platform:     artifact.Platform,
 		Checksum:     artifact.Checksum,
-	}, nil
+		BuildService: buildSrvURL,
+	}
 }
 
 // GetBinary returns a custom k6 binary that satisfies the given a set of dependencies.
@@ -275,8 +839,17 @@ func (p *Provider) GetArtifact(
 //
 // If the binary exists, it will be returned from the cache.
 //
+// If enabled (see [Config.StaleIfError]) and the build service cannot be reached, the binary that
+// satisfied the same dependencies previously is returned from the cache, if any.
+//
+// If the download of the binary is cancelled or interrupted, the content already downloaded
+// is kept, so the next call only downloads the remainder, if the server supports range requests
+// and the content didn't change, as reported by its ETag or Last-Modified headers. Otherwise, the
+// download starts over. The content of downloads not resumed is removed after 10 minutes.
+//
 // The returned K6Binary has the path to the custom k6 binary, the list of
-// dependencies and the checksum of the binary.
+// dependencies and the checksum of the binary. It can also be written to a result file
+// for other processes using [WithResultFile].
 //
 // If any error occurs while building, downloading or checking the binary,
 // an [WrappedError] will be returned. This error will be one of the errors
@@ -285,62 +858,603 @@ func (p *Provider) GetBinary(
 	ctx context.Context,
 	deps k6deps.Dependencies,
 ) (K6Binary, error) {
-	artifact, err := p.GetArtifact(ctx, deps)
+	if p.ctx.Err() != nil {
+		return K6Binary{}, ErrClosed
+	}
+
+	if path := resultFileFrom(ctx); path != "" {
+		return p.getBinaryWithResult(ctx, deps, path)
+	}
+
+	return p.getBinary(ctx, deps)
+}
+
+// getBinary returns the binary that satisfies the dependencies. See [Provider.GetBinary].
+// The catalog used is recorded in the binary, so it can be obtained again from its lockfile.
+func (p *Provider) getBinary(ctx context.Context, deps k6deps.Dependencies) (K6Binary, error) {
+	binary, err := p.provisionBinary(ctx, deps)
 	if err != nil {
 		return K6Binary{}, err
 	}
+	binary.Catalog = catalogFrom(ctx)
 
-	artifactDir := filepath.Join(p.binDir, artifact.ID)
-	binPath := filepath.Join(artifactDir, k6Binary)
-	_, err = os.Stat(binPath)
+	return binary, nil
+}
 
-	// binary already exists
-	if err == nil {
-		go p.pruner.Touch(binPath)
+// provisionBinary returns the binary that satisfies the dependencies from the cache or
+// downloading it
+func (p *Provider) provisionBinary(ctx context.Context, deps k6deps.Dependencies) (K6Binary, error) {
+	if binary, found := p.lookupWithUpdateCheck(ctx, deps); found {
+		recordSource(ctx, ResultSourceCache)
+		return p.deliver(binary)
+	}
 
-		return K6Binary{
-			Path:         binPath,
-			Dependencies: artifact.Dependencies,
-			Checksum:     artifact.Checksum,
-		}, nil
+	artifact, request, cached, err := p.resolve(ctx, deps)
+	if err != nil {
+		return K6Binary{}, err
+	}
+	if cached != nil {
+		recordSource(ctx, ResultSourceFallback)
+		return p.deliver(*cached)
 	}
 
-	// other error
-	if !os.IsNotExist(err) {
-		return K6Binary{}, NewWrappedError(ErrBinary, err)
+	budget := p.config.PhaseBudget.remaining(ctx)
+	binary, err := p.binaryForRequest(ctx, artifact, request)
+
+	return binary, phaseDeadline(ctx, err, "download", budget)
+}
+
+// resolve requests the artifact that satisfies the dependencies to the build service. Returns the
+// artifact and the key of the request. If the build service is not available (see staleTolerable),
+// the binary that satisfied the same request previously is returned from the cache instead, if any
+// and it was resolved within the configured StaleIfError. The build service is given the fraction
+// of the time until the context's deadline in the configured PhaseBudget, if any.
+func (p *Provider) resolve(ctx context.Context, deps k6deps.Dependencies) (Artifact, string, *K6Binary, error) {
+	k6Constrains, buildDeps := p.buildDeps(deps)
+	options := buildOptionsFrom(ctx).forPlatform(p.platform)
+	request := requestKey(p.platform, catalogFrom(ctx), options.key(), k6Constrains, buildDeps)
+
+	// the build service is given a fraction of the time, if budgeted
+	resolveCtx, budget, cancel := p.config.PhaseBudget.resolveContext(ctx)
+	defer cancel()
+
+	artifact, err := p.build(resolveCtx, k6Constrains, buildDeps)
+	if err != nil {
+		err = phaseDeadline(resolveCtx, err, "resolve", budget)
+		// the build service is not available
+		if p.config.StaleIfError > 0 && staleTolerable(err) && ctx.Err() == nil {
+			if binary, found := p.lookupRequest(request, p.config.StaleIfError); found {
+				return Artifact{}, request, &binary, nil
+			}
+		}
+		return Artifact{}, "", nil, err
 	}
 
-	// binary doesn't exists
-	err = os.MkdirAll(artifactDir, 0o700)
+	return artifact, request, nil, nil
+}
+
+// staleTolerable returns true if the error resolving the dependencies allows providing the binary
+// that satisfied them before from the cache, because the build service or the network is not
+// available or it did not respond in time. Errors caused by the request, such as invalid parameters
+// or authentication failures, are not tolerable, as another request is needed to fix them.
+func staleTolerable(err error) bool {
+	var deadline *PhaseDeadlineError
+	if errors.As(err, &deadline) {
+		return deadline.Phase == "resolve"
+	}
+
+	return errors.Is(err, ErrServiceUnavailable) ||
+		errors.Is(err, ErrOffline) ||
+		errors.Is(err, ErrNetworkDisabled)
+}
+
+// binaryForRequest returns the binary for the artifact that satisfied the request, recording
+// the request in the artifact's metadata
+func (p *Provider) binaryForRequest(ctx context.Context, artifact Artifact, request string) (K6Binary, error) {
+	binary, err := p.binaryFor(ctx, artifact)
 	if err != nil {
-		return K6Binary{}, NewWrappedError(ErrBinary, err)
+		return K6Binary{}, err
 	}
 
-	target, err := os.OpenFile( //nolint:gosec
-		binPath,
-		os.O_WRONLY|os.O_CREATE,
-		syscall.S_IRUSR|syscall.S_IXUSR|syscall.S_IWUSR,
-	)
+	// the artifact is stored with the binary, so if the build service cannot be reached
+	// later, the binary can be returned from the cache with its full metadata.
+	// Failing to write it is not an error.
+	if err := writeMetadata(filepath.Dir(binary.Path), artifact, request); err == nil {
+		_ = p.permissions.shareFile(filepath.Join(filepath.Dir(binary.Path), metadataFile))
+	}
+	p.remember(request, binary)
+
+	binary, err = p.deliver(binary)
 	if err != nil {
-		return K6Binary{}, NewWrappedError(ErrBinary, err)
+		return K6Binary{}, err
+	}
+
+	p.canary(artifact, binary)
+
+	return binary, nil
+}
+
+// binaryFor returns the binary for an artifact, downloading it if it is not in the cache
+func (p *Provider) binaryFor(ctx context.Context, artifact Artifact) (K6Binary, error) {
+	// cancel the download if the provider is closed
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := p.cancelOnClose(cancel)
+	defer stop()
+
+	lookupStart := time.Now()
+	binPath, found, err := p.lookupTiered(ctx, artifact)
+	recordTiming(ctx, phaseLookup, lookupStart)
+	if err != nil {
+		return K6Binary{}, p.recordError(withArtifact(err, artifact.ID))
+	}
+
+	// binary already exists
+	if found {
+		p.events.publish(Event{Type: EventCacheHit, ArtifactID: artifact.ID})
+		return p.provisioned(ctx, binPath, artifact, true)
 	}
 
-	err = p.downloader.download(ctx, artifact.URL, target)
-	_ = target.Close()
+	// binary doesn't exists
+	if err = p.startDownload(); err != nil {
+		return K6Binary{}, err
+	}
+	binPath, err = p.store(ctx, artifact)
+	p.downloads.Done()
 	if err != nil {
-		_ = os.RemoveAll(artifactDir)
-		return K6Binary{}, NewWrappedError(ErrDownload, err)
+		return K6Binary{}, p.recordError(withArtifact(err, artifact.ID))
 	}
 
+	p.storeTiered(binPath, artifact)
+
 	// start pruning in background
-	// TODO: handle case the calling process is cancelled
-	go p.pruner.Prune() //nolint:errcheck
+	p.background(func() {
+		err := p.pruner.Prune()
+		if p.pruner.state().Enabled {
+			p.events.publish(Event{Type: EventPruneCompleted, Err: err})
+		}
+	})
+
+	return p.provisioned(ctx, binPath, artifact, false)
+}
 
+// provisioned returns the binary provisioned for the artifact, attesting its provenance
+func (p *Provider) provisioned(ctx context.Context, binPath string, artifact Artifact, cached bool) (K6Binary, error) {
+	binary, err := p.attest(ctx, newCachedBinary(binPath, artifact), artifact)
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	if cached {
+		recordSource(ctx, ResultSourceCache)
+	} else {
+		recordSource(ctx, ResultSourceDownload)
+	}
+
+	p.recordUsage(binary, cached)
+	p.recordDependencyUsage(binary, cached)
+	p.recordAccess(binPath, artifact.ID)
+
+	return binary, nil
+}
+
+// lookupBinary looks for the binary of an artifact in the cache directories, once its scope
+// is known. Returns the path to the binary and true if found.
+func (p *Provider) lookupBinary(artifact Artifact) (string, bool, error) {
+	if err := p.resolveScope(); err != nil {
+		return "", false, err
+	}
+
+	for _, dir := range p.binDirs() {
+		binPath := filepath.Join(p.artifactDir(dir, artifact), k6Binary)
+		_, err := os.Stat(binPath)
+
+		// the binary may have been pruned recently
+		if os.IsNotExist(err) && dir == p.binDir && p.pruner.restore(filepath.Dir(binPath)) {
+			_, err = os.Stat(binPath)
+		}
+
+		// binary already exists
+		if err == nil {
+			if dir == p.binDir {
+				p.background(func() { p.pruner.Touch(binPath) })
+			}
+			return binPath, true, nil
+		}
+
+		// other error
+		if !os.IsNotExist(err) {
+			return "", false, NewWrappedError(ErrBinary, err)
+		}
+	}
+
+	return "", false, nil
+}
+
+// newK6Binary returns the K6Binary for an artifact's binary
+func newK6Binary(binPath string, artifact Artifact) K6Binary {
 	return K6Binary{
 		Path:         binPath,
+		ID:           artifact.ID,
+		Platform:     artifact.Platform,
 		Dependencies: artifact.Dependencies,
 		Checksum:     artifact.Checksum,
-	}, nil
+		BuildOptions: artifact.BuildOptions,
+	}
+}
+
+// cancelOnClose calls the cancel function when the provider is closed, once the grace period
+// for completing the downloads in progress expires. Returns a function that stops waiting for
+// the provider to be closed.
+func (p *Provider) cancelOnClose(cancel context.CancelFunc) func() {
+	grace := p.config.ShutdownGracePeriod
+	if grace <= 0 {
+		stop := context.AfterFunc(p.ctx, cancel)
+		return func() { stop() }
+	}
+
+	var (
+		mutex sync.Mutex
+		timer *time.Timer
+	)
+	stop := context.AfterFunc(p.ctx, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		timer = time.AfterFunc(grace, cancel)
+	})
+
+	return func() {
+		stop()
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// background runs a task in the background. Close waits for background tasks to complete.
+// Tasks are not run once the provider starts closing, as Close may be already waiting for them.
+func (p *Provider) background(task func()) {
+	p.taskMutex.Lock()
+	defer p.taskMutex.Unlock()
+	if p.closing {
+		return
+	}
+
+	p.tasks.Add(1)
+	go func() {
+		defer p.tasks.Done()
+		task()
+	}()
+}
+
+// startDownload registers a download, so it is waited for when the provider is closed.
+// Returns ErrClosed if the provider is being closed.
+func (p *Provider) startDownload() error {
+	p.taskMutex.Lock()
+	defer p.taskMutex.Unlock()
+	if p.closing {
+		return ErrClosed
+	}
+
+	p.downloads.Add(1)
+	return nil
+}
+
+// Close releases the resources held by the provider. It cancels any download in progress,
+// once the grace period for completing them expires (see [Config.ShutdownGracePeriod]),
+// waits for background tasks such as pruning to complete, releases any file lock held by
+// the provider and removes the orphaned partial files left by interrupted downloads and the binaries
+// decompressed from the cache (see [Config.CacheCompression]).
+//
+// The provider cannot be used after it is closed. Calling Close more than once has no effect.
+func (p *Provider) Close() error {
+	p.closeOnce.Do(func() {
+		// no background task or download is started after this point, so they can be waited for
+		p.taskMutex.Lock()
+		p.closing = true
+		p.taskMutex.Unlock()
+
+		p.cancel()
+		p.downloads.Wait()
+		p.tasks.Wait()
+		defer p.events.close()
+
+		errs := []error{}
+		if err := p.pruner.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		for _, tier := range []*cacheTier{p.memoryTier, p.sharedTier} {
+			if tier == nil {
+				continue
+			}
+			if err := tier.pruner.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, dir := range p.binDirs() {
+			if err := reapPartialFiles(dir); err != nil {
+				errs = append(errs, NewWrappedError(ErrBinary, err))
+			}
+		}
+		if err := p.exec.remove(); err != nil {
+			errs = append(errs, NewWrappedError(ErrBinary, err))
+		}
+		p.closeErr = errors.Join(errs...)
+	})
+
+	return p.closeErr
+}
+
+// binDirs returns the binary directory followed by the fallback directories
+func (p *Provider) binDirs() []string {
+	return append([]string{p.binDir}, p.fallbacks...)
+}
+
+// store downloads the artifact's binary into the first binary directory that can hold it.
+// If the binary directory is full, an emergency prune is attempted before trying the
+// fallback directories.
+func (p *Provider) store(ctx context.Context, artifact Artifact) (string, error) {
+	var err error
+	for _, dir := range p.binDirs() {
+		var binPath string
+		binPath, err = p.downloadTo(ctx, artifact, dir)
+		if err == nil {
+			return binPath, nil
+		}
+
+		if errors.Is(err, ErrNoSpace) && dir == p.binDir {
+			if freed, _ := p.pruner.EmergencyPrune(); freed > 0 {
+				binPath, err = p.downloadTo(ctx, artifact, dir)
+				if err == nil {
+					return binPath, nil
+				}
+			}
+		}
+
+		if !errors.Is(err, ErrNoSpace) && !errors.Is(err, ErrReadOnly) {
+			return "", err
+		}
+	}
+
+	return "", err
+}
+
+// downloadTo downloads the artifact's binary into the given binary directory
+func (p *Provider) downloadTo(ctx context.Context, artifact Artifact, dir string) (string, error) {
+	artifactDir := p.artifactDir(dir, artifact)
+	binPath := filepath.Join(artifactDir, k6Binary)
+
+	err := os.MkdirAll(artifactDir, cacheDirPerm)
+	if err != nil {
+		return "", NewWrappedError(ErrBinary, storageError(err))
+	}
+
+	if err = p.permissions.shareDirs(dir, artifactDir); err != nil {
+		return "", NewWrappedError(ErrBinary, err)
+	}
+
+	// wait for any other process downloading the binary
+	downloadLock := p.downloadLock(dir, artifactDir)
+	if err = downloadLock.lockWithContext(ctx); err != nil {
+		return "", NewWrappedError(ErrBinary, err)
+	}
+	defer downloadLock.unlock() //nolint:errcheck
+
+	// the binary was downloaded while waiting
+	if _, err = os.Stat(binPath); err == nil {
+		return binPath, nil
+	}
+
+	// download to a partial file that is moved to the final location when completed
+	partialPath := binPath + partialSuffix
+	downloaded, err := p.downloadPartial(ctx, artifact, partialPath)
+	if err != nil {
+		// the content of an interrupted download is kept, so the next download resumes it
+		if _, resumeErr := os.Stat(partialPath + resumeSuffix); resumeErr != nil {
+			removePartial(partialPath)
+		}
+		return "", err
+	}
+
+	// the validators allow checking if the content changed without downloading it again.
+	// Failing to write them is not an error.
+	_ = writeValidators(artifactDir, downloaded)
+
+	if err = p.compressPartial(partialPath); err != nil {
+		removePartial(partialPath)
+		return "", NewWrappedError(ErrBinary, storageError(err))
+	}
+
+	// the companion tools are in place before the binary, which completes the artifact
+	if err = p.moveTools(partialPath, artifactDir); err != nil {
+		removePartial(partialPath)
+		return "", NewWrappedError(ErrBinary, storageError(err))
+	}
+
+	// a binary downloaded again must pass the version verification again
+	_ = os.Remove(filepath.Join(artifactDir, versionVerifiedFile))
+
+	err = os.Rename(partialPath, binPath)
+	if err != nil {
+		removePartial(partialPath)
+		return "", NewWrappedError(ErrBinary, storageError(err))
+	}
+
+	// the binary is only shared once complete
+	if err = p.permissions.shareBinary(binPath); err != nil {
+		return "", NewWrappedError(ErrBinary, err)
+	}
+
+	// the binaries of mutable references expire some time after being downloaded.
+	// Failing to record it is not an error.
+	if err = recordDownload(artifactDir, artifact); err == nil {
+		_ = p.permissions.shareFile(filepath.Join(artifactDir, metadataFile))
+	}
+
+	return binPath, nil
+}
+
+// discardBinary removes the binary in the artifact directory, and the files describing it, holding
+// the artifact's download lock, so it is not removed while another process downloads or verifies
+// it. The lock files are kept, as processes waiting for the lock may have opened them.
+func (p *Provider) discardBinary(ctx context.Context, dir string, artifactDir string) error {
+	downloadLock := p.downloadLock(dir, artifactDir)
+	if err := downloadLock.lockWithContext(ctx); err != nil {
+		return err
+	}
+	defer downloadLock.unlock() //nolint:errcheck
+
+	entries, err := os.ReadDir(artifactDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if isLockFile(entry.Name()) {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(artifactDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// downloadLock returns the lock for coordinating the download of the binary to the artifact
+// directory with other processes. On network file systems, the lock file is created, as
+// named mutexes and advisory locks are not shared across hosts.
+func (p *Provider) downloadLock(dir string, artifactDir string) *dirLock {
+	switch {
+	case p.networkFS && dir == p.binDir:
+		return newExclusiveFileLock(artifactDir)
+	case p.config.LockMode == LockNamedMutex:
+		return newNamedMutexLock(artifactDir)
+	default:
+		return newFileLock(artifactDir)
+	}
+}
+
+// downloadPartial downloads the artifact's binary to the partial file verifying its checksum.
+// If the checksum does not match, the download is retried up to the configured checksum retries.
+// If the download stalls, it is resumed up to the configured retries.
+// Returns the validators of the downloaded content.
+func (p *Provider) downloadPartial(ctx context.Context, artifact Artifact, partialPath string) (validators, error) {
+	stalls := 0
+	for attempt := 0; ; {
+		downloaded, err := p.downloadFile(ctx, artifact, partialPath)
+		switch {
+		case stalled(err) && stalls < p.downloader.maxRetries():
+			stalls++
+		case errors.Is(err, ErrChecksumMismatch) && attempt < p.downloader.checksumRetries:
+			attempt++
+		default:
+			return downloaded, err
+		}
+
+		// keep a record of the failure before retrying
+		p.recordError(err)
+	}
+}
+
+// downloadFile downloads the artifact's binary to the given path and verifies its checksum.
+// Returns the validators of the downloaded content.
+func (p *Provider) downloadFile(ctx context.Context, artifact Artifact, path string) (validators, error) {
+	// continue an interrupted download, reusing the content already downloaded
+	hash := &countingHash{Hash: sha256.New()}
+	resumed := p.downloader.resume(path, hash)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resumed.Size > 0 {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	target, err := os.OpenFile(path, flags, syscall.S_IRUSR|syscall.S_IXUSR|syscall.S_IWUSR) //nolint:gosec
+	if err != nil {
+		return validators{}, NewWrappedError(ErrBinary, storageError(err))
+	}
+
+	// the content received passes through the configured stages before being hashed and written
+	content, err := newPipeline(artifact, p.downloader.stages, io.MultiWriter(target, hash))
+	if err != nil {
+		_ = target.Close()
+		return validators{}, NewWrappedError(ErrDownload, err)
+	}
+
+	p.events.publish(Event{Type: EventDownloadStarted, ArtifactID: artifact.ID, Size: -1})
+
+	progress := &progressWriter{
+		writer:     rateLimitedWriter(ctx, content),
+		events:     &p.events,
+		artifact:   artifact.ID,
+		size:       -1,
+		downloaded: resumed.Size,
+		resumed:    resumed,
+		onRestart: func() error {
+			hash.Reset()
+			return target.Truncate(0)
+		},
+	}
+	downloadStart := time.Now()
+	err = p.downloader.download(ctx, artifact.URL, progress)
+	recordTiming(ctx, phaseDownload, downloadStart)
+	if closeErr := content.Close(); err == nil {
+		err = closeErr
+	}
+	// ensure the binary is written to the file server before it is visible to other hosts
+	if err == nil && p.networkFS {
+		err = target.Sync()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// keep track of the content downloaded, so the download can be resumed
+		if interrupted(err) && len(p.downloader.stages) == 0 {
+			recordInterrupted(path, progress, hash)
+		}
+		return validators{}, NewWrappedError(ErrDownload, storageError(err))
+	}
+
+	verifyStart := time.Now()
+	err = p.verifyDownload(artifact, path, hash)
+	recordTiming(ctx, phaseVerify, verifyStart)
+	if err != nil {
+		return validators{}, NewWrappedError(ErrDownload, err)
+	}
+
+	p.events.publish(Event{
+		Type:       EventDownloadCompleted,
+		ArtifactID: artifact.ID,
+		Downloaded: progress.downloaded,
+		Size:       progress.size,
+	})
+
+	return progress.validators, nil
+}
+
+// verifyDownload verifies the checksum of the binary downloaded to the given path. If the
+// artifact was served as an archive, it is replaced by the binary extracted from it, which
+// is the one verified.
+func (p *Provider) verifyDownload(artifact Artifact, path string, hash hash.Hash) error {
+	format, err := detectArchive(path)
+	if err != nil {
+		return storageError(err)
+	}
+
+	if format != formatBinary {
+		hash, err = p.downloader.extractBinary(path, format, p.downloader.archiveMember, p.networkFS)
+		if err != nil {
+			return err
+		}
+	}
+
+	return verifyChecksum(artifact.Checksum, hash)
 }
 
 // buildDeps takes a set of k6 dependencies and returns a string representing
@@ -3,19 +3,74 @@ package k6provider
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
 	"net/url"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/grafana/k6build"
 	"github.com/grafana/k6build/pkg/testutils"
 	"github.com/grafana/k6deps"
 )
 
+// buildServiceFunc is a k6build.BuildService implemented by a function
+type buildServiceFunc func(
+	ctx context.Context,
+	platform string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (k6build.Artifact, error)
+
+func (f buildServiceFunc) Build(
+	ctx context.Context,
+	platform string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) (k6build.Artifact, error) {
+	return f(ctx, platform, k6Constrains, deps)
+}
+
+// newTestProvider returns a provider that uses the given build service and stores binaries in binDir
+// newTestBuildServices returns the build services, using their position as URL
+func newTestBuildServices(srvs ...k6build.BuildService) *buildServices {
+	tiers := make([]*buildTier, 0, len(srvs))
+	for i, srv := range srvs {
+		tiers = append(tiers, &buildTier{replicas: []*buildEndpoint{newBuildEndpoint(fmt.Sprint(i), srv)}})
+	}
+	return &buildServices{tiers: tiers, strategy: RoundRobin}
+}
+
+func newTestProvider(t *testing.T, buildSrv k6build.BuildService, binDir string) *Provider {
+	t.Helper()
+
+	downloader, err := newDownloader(DownloadConfig{}, nil)
+	if err != nil {
+		t.Fatalf("creating downloader %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return &Provider{
+		downloader: downloader,
+		binDir:     binDir,
+		buildSrv:   newTestBuildServices(buildSrv),
+		platform:   "linux/amd64",
+		pruner:     NewPruner(binDir, 0, 0),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
 // checks request has the correct Authorization header
 func newAuthorizationProxy(buildSrv string, header string, authorization string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -237,3 +292,207 @@ func Test_Provider(t *testing.T) { //nolint:tparallel
 		})
 	}
 }
+
+func TestDefaultBinDir(t *testing.T) { //nolint:paralleltest
+	if runtime.GOOS != "linux" {
+		t.Skip("the user's cache directory is set by environment variables in linux")
+	}
+
+	cacheDir := t.TempDir()
+	tempBinDir := filepath.Join(os.TempDir(), "k6provider", "cache")
+
+	testCases := []struct {
+		title    string
+		cacheDir string
+		home     string
+		config   Config
+		expect   string
+	}{
+		{
+			title:    "user cache dir",
+			cacheDir: cacheDir,
+			config:   Config{},
+			expect:   filepath.Join(cacheDir, "k6provider"),
+		},
+		{
+			title:    "temp bin dir",
+			cacheDir: cacheDir,
+			config:   Config{TempBinDir: true},
+			expect:   tempBinDir,
+		},
+		{
+			title:    "no user cache dir",
+			cacheDir: "",
+			home:     "",
+			config:   Config{},
+			expect:   tempBinDir,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", tc.cacheDir)
+			t.Setenv("HOME", tc.home)
+
+			if binDir := defaultBinDir(tc.config); binDir != tc.expect {
+				t.Fatalf("expected %s got %s", tc.expect, binDir)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	binDir := t.TempDir()
+	provider, err := NewProvider(Config{BinDir: binDir, BuildServiceURL: "http://localhost:8000"})
+	if err != nil {
+		t.Fatalf("initializing provider %v", err)
+	}
+
+	// an orphaned partial file and a download that may still be in progress
+	stale := filepath.Join(binDir, "stale", k6Binary+partialSuffix)
+	recent := filepath.Join(binDir, "recent", k6Binary+partialSuffix)
+	for _, partial := range []string{stale, recent} {
+		if err = os.MkdirAll(filepath.Dir(partial), 0o700); err != nil {
+			t.Fatalf("test setup: creating dir %v", err)
+		}
+		if err = os.WriteFile(partial, []byte("k6"), 0o600); err != nil {
+			t.Fatalf("test setup: writing file %v", err)
+		}
+	}
+	staleTime := time.Now().Add(-2 * partialFileTTL)
+	if err = os.Chtimes(stale, staleTime, staleTime); err != nil {
+		t.Fatalf("test setup: changing mod timestamp %v", err)
+	}
+
+	if err = provider.Close(); err != nil {
+		t.Fatalf("closing provider %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Dir(stale)); !os.IsNotExist(err) {
+		t.Fatalf("expected stale artifact dir to be removed, got %v", err)
+	}
+
+	if _, err = os.Stat(recent); err != nil {
+		t.Fatalf("expected recent partial file to be kept, got %v", err)
+	}
+
+	// closing again has no effect
+	if err = provider.Close(); err != nil {
+		t.Fatalf("closing provider again %v", err)
+	}
+
+	_, err = provider.GetBinary(context.TODO(), k6deps.Dependencies{})
+	if !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected %v got %v", ErrClosed, err)
+	}
+}
+
+func TestCloseStopsDownloads(t *testing.T) {
+	t.Parallel()
+
+	downloads := atomic.Int32{}
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		downloads.Add(1)
+		_, _ = w.Write([]byte("k6"))
+	}))
+	t.Cleanup(store.Close)
+
+	provider := newTestProvider(t, nil, t.TempDir())
+	if err := provider.Close(); err != nil {
+		t.Fatalf("closing provider %v", err)
+	}
+
+	// a request that started before the provider was closed doesn't download once it is closing
+	_, err := provider.binaryFor(context.TODO(), Artifact{ID: "artifact", URL: store.URL})
+	if !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected %v got %v", ErrClosed, err)
+	}
+	if downloads.Load() != 0 {
+		t.Fatalf("expected no downloads got %d", downloads.Load())
+	}
+}
+
+func TestCloseGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title        string
+		grace        time.Duration
+		expectBinary bool
+	}{
+		{
+			title:        "download completes within grace period",
+			grace:        10 * time.Second,
+			expectBinary: true,
+		},
+		{
+			title:        "download cancelled",
+			grace:        0,
+			expectBinary: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("k6 "))
+				w.(http.Flusher).Flush()
+				close(started)
+
+				select {
+				case <-release:
+					_, _ = w.Write([]byte("binary"))
+				case <-r.Context().Done():
+				}
+			}))
+			t.Cleanup(store.Close)
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Platform: "linux/amd64"}, nil
+				},
+			)
+
+			binDir := t.TempDir()
+			provider := newTestProvider(t, buildSrv, binDir)
+			provider.config.ShutdownGracePeriod = tc.grace
+
+			result := make(chan error, 1)
+			go func() {
+				_, err := provider.GetBinary(context.TODO(), k6deps.Dependencies{})
+				result <- err
+			}()
+
+			<-started
+			go func() {
+				// give Close time to cancel the download if there's no grace period
+				time.Sleep(100 * time.Millisecond)
+				close(release)
+			}()
+
+			if err := provider.Close(); err != nil {
+				t.Fatalf("closing provider %v", err)
+			}
+
+			err := <-result
+			if (err == nil) != tc.expectBinary {
+				t.Fatalf("expected binary %v got %v", tc.expectBinary, err)
+			}
+
+			binPath := filepath.Join(binDir, "artifact", k6Binary)
+			if _, err = os.Stat(binPath); (err == nil) != tc.expectBinary {
+				t.Fatalf("expected binary %v got %v", tc.expectBinary, err)
+			}
+
+			if _, err = os.Stat(binPath + partialSuffix); !os.IsNotExist(err) {
+				t.Fatalf("expected partial file to be removed got %v", err)
+			}
+		})
+	}
+}
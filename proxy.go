@@ -0,0 +1,109 @@
+package k6provider
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyFunc returns the URL of the proxy for a request, or nil if the request must not use a proxy.
+// It has the semantics of [http.Transport]'s Proxy and can be used, for example, for selecting
+// the proxy using a proxy auto-config (PAC) file.
+type ProxyFunc func(*http.Request) (*url.URL, error)
+
+// newHTTPClient returns a client that uses the default transport settings, selects the proxy
+// using the given function and connects using the dial function, if any. If the proxy function
+// is nil, the proxy is selected from the environment variables HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY (or their lowercase versions).
+func newHTTPClient(proxy ProxyFunc, dial DialFunc) *http.Client {
+	transport := &http.Transport{}
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	}
+
+	transport.Proxy = proxy
+	if proxy == nil {
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+
+	if dial != nil {
+		transport.DialContext = dial
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// selectProxy returns the function for selecting the proxy of a component. If a proxy URL is
+// given, it is used for all the requests except those to the hosts in noProxy. Otherwise,
+// the proxy function is used.
+func selectProxy(proxyURL string, noProxy string, proxy ProxyFunc) (ProxyFunc, error) {
+	if proxyURL == "" {
+		return proxy, nil
+	}
+
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := parseNoProxy(noProxy)
+
+	return func(req *http.Request) (*url.URL, error) {
+		if excluded.matches(req.URL) {
+			return nil, nil //nolint:nilnil
+		}
+		return parsed, nil
+	}, nil
+}
+
+// noProxy is a list of hosts that must not use a proxy, with the format of the NO_PROXY
+// environment variable
+type noProxy []string
+
+// parseNoProxy parses a comma-separated list of host names, domains (e.g. ".example.com"),
+// IP addresses or CIDR blocks, optionally with a port. "*" matches all hosts.
+func parseNoProxy(value string) noProxy {
+	entries := noProxy{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// matches returns true if the URL's host is excluded from using a proxy
+func (n noProxy) matches(target *url.URL) bool {
+	host := strings.ToLower(target.Hostname())
+	port := target.Port()
+
+	for _, entry := range n {
+		if entry == "*" {
+			return true
+		}
+
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			entryHost, entryPort = entry, ""
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+
+		domain := strings.TrimPrefix(strings.TrimPrefix(entryHost, "*"), ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
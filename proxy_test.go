@@ -0,0 +1,112 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDownloadProxy(t *testing.T) {
+	t.Parallel()
+
+	// the proxy answers the requests instead of forwarding them
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("proxied"))
+	}))
+	t.Cleanup(proxy.Close)
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("direct"))
+	}))
+	t.Cleanup(store.Close)
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	toProxy := func(*http.Request) (*url.URL, error) { return proxyURL, nil }
+	noProxy := func(*http.Request) (*url.URL, error) { return nil, nil } //nolint:nilnil
+
+	testCases := []struct {
+		title  string
+		config DownloadConfig
+		expect string
+	}{
+		{
+			title:  "proxy function",
+			config: DownloadConfig{Proxy: toProxy},
+			expect: "proxied",
+		},
+		{
+			title:  "proxy function without proxy",
+			config: DownloadConfig{Proxy: noProxy},
+			expect: "direct",
+		},
+		{
+			title:  "host excluded from proxy URL",
+			config: DownloadConfig{ProxyURL: proxy.URL, NoProxy: "127.0.0.1"},
+			expect: "direct",
+		},
+		{
+			title:  "proxy URL takes precedence",
+			config: DownloadConfig{Proxy: noProxy, ProxyURL: proxy.URL},
+			expect: "proxied",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			downloader, err := newDownloader(tc.config, nil)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			out := &bytes.Buffer{}
+			if err = downloader.download(context.TODO(), store.URL, out); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if out.String() != tc.expect {
+				t.Fatalf("expected %v got %v", tc.expect, out.String())
+			}
+		})
+	}
+}
+
+func TestNoProxy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title   string
+		noProxy string
+		url     string
+		expect  bool
+	}{
+		{title: "empty", noProxy: "", url: "https://build.example.com", expect: false},
+		{title: "all hosts", noProxy: "*", url: "https://build.example.com", expect: true},
+		{title: "host", noProxy: "build.example.com", url: "https://build.example.com", expect: true},
+		{title: "other host", noProxy: "store.example.com", url: "https://build.example.com", expect: false},
+		{title: "domain", noProxy: ".example.com", url: "https://build.example.com", expect: true},
+		{title: "domain without dot", noProxy: "example.com", url: "https://build.example.com", expect: true},
+		{title: "suffix is not a domain", noProxy: "ample.com", url: "https://build.example.com", expect: false},
+		{title: "list", noProxy: "localhost, .internal", url: "http://build.internal:8000", expect: true},
+		{title: "port", noProxy: "build.internal:8000", url: "http://build.internal:8000", expect: true},
+		{title: "other port", noProxy: "build.internal:8000", url: "http://build.internal:9000", expect: false},
+		{title: "ip", noProxy: "10.0.0.1", url: "http://10.0.0.1", expect: true},
+		{title: "cidr", noProxy: "10.0.0.0/8", url: "http://10.1.2.3", expect: true},
+		{title: "outside cidr", noProxy: "10.0.0.0/8", url: "http://192.168.0.1", expect: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			target, _ := url.Parse(tc.url)
+			if got := parseNoProxy(tc.noProxy).matches(target); got != tc.expect {
+				t.Fatalf("expected %v got %v", tc.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package k6provider
+
+import (
+	"context"
+	"time"
+)
+
+// PruneOptions defines which binaries are removed by [Provider.Prune]
+type PruneOptions struct {
+	// TargetSize the least recently used binaries are removed until the size of the cache is
+	// not above this size. If 0, the binaries are not removed for the size of the cache
+	TargetSize int64
+	// MaxAge binaries not used for longer than this time are removed. If 0, the binaries are
+	// not removed for their age
+	MaxAge time.Duration
+	// DryRun reports the binaries that would be removed without removing them
+	DryRun bool
+}
+
+// PrunedBinary describes a binary removed (or that would be removed in a dry run) by [Provider.Prune]
+type PrunedBinary struct {
+	// ArtifactID id of the binary's artifact, if known, or else, the name of its directory
+	ArtifactID string `json:"artifactId"`
+	// Dir artifact directory of the binary
+	Dir string `json:"dir"`
+	// Size of the artifact directory
+	Size int64 `json:"size"`
+	// LastUsed time the binary was last used
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// PruneReport is the result of [Provider.Prune]
+type PruneReport struct {
+	// Pruned binaries removed, least recently used first
+	Pruned []PrunedBinary `json:"pruned"`
+	// Freed bytes freed by removing the binaries
+	Freed int64 `json:"freed"`
+	// Size of the cache after removing the binaries
+	Size int64 `json:"size"`
+	// DryRun the binaries were not removed
+	DryRun bool `json:"dryRun"`
+}
+
+// Prune removes the binaries from the cache as requested by the options, regardless of the
+// configured high-water-mark and prune interval, so operators can run controlled cleanups.
+// Binaries are removed least recently used first and moved to the trash if it is enabled
+// (see [Config.TrashGracePeriod]). With [PruneOptions.DryRun], the binaries that would be removed
+// are reported without removing them.
+//
+// Waits for any prune in progress, including those of other processes sharing the cache, until
+// the context is done. Pruning is not supported in windows systems, where an empty report is
+// returned.
+func (p *Provider) Prune(ctx context.Context, opts PruneOptions) (PruneReport, error) {
+	if p.ctx.Err() != nil {
+		return PruneReport{}, ErrClosed
+	}
+
+	report, err := p.pruner.pruneWith(ctx, opts)
+	if !opts.DryRun {
+		p.events.publish(Event{Type: EventPruneCompleted, Err: err})
+	}
+
+	return report, p.recordError(err)
+}
@@ -0,0 +1,103 @@
+//go:build !windows
+// +build !windows
+
+package k6provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProviderPrune(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title        string
+		opts         PruneOptions
+		expectPruned []string
+		expectKept   []string
+	}{
+		{
+			title:        "target size",
+			opts:         PruneOptions{TargetSize: 256 * 2},
+			expectPruned: []string{"binary-4", "binary-3"},
+			expectKept:   []string{"binary-1", "binary-2"},
+		},
+		{
+			title:        "max age",
+			opts:         PruneOptions{MaxAge: 90 * time.Minute},
+			expectPruned: []string{"binary-4", "binary-3"},
+			expectKept:   []string{"binary-1", "binary-2"},
+		},
+		{
+			title:        "target size and max age",
+			opts:         PruneOptions{TargetSize: 256 * 3, MaxAge: 150 * time.Minute},
+			expectPruned: []string{"binary-4"},
+			expectKept:   []string{"binary-1", "binary-2", "binary-3"},
+		},
+		{
+			title:        "dry run",
+			opts:         PruneOptions{TargetSize: 256, DryRun: true},
+			expectPruned: []string{"binary-4", "binary-3", "binary-2"},
+			expectKept:   []string{"binary-1", "binary-2", "binary-3", "binary-4"},
+		},
+		{
+			title:        "nothing to prune",
+			opts:         PruneOptions{},
+			expectPruned: []string{},
+			expectKept:   []string{"binary-1", "binary-2", "binary-3", "binary-4"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			binDir := t.TempDir()
+			for i, age := range []time.Duration{0, time.Hour, 2 * time.Hour, 3 * time.Hour} {
+				artifactDir := filepath.Join(binDir, fmt.Sprintf("binary-%d", i+1))
+				if err := os.MkdirAll(artifactDir, 0o750); err != nil {
+					t.Fatalf("test setup: creating dir %v", err)
+				}
+				binPath := filepath.Join(artifactDir, k6Binary)
+				if err := os.WriteFile(binPath, make([]byte, 256), 0o600); err != nil {
+					t.Fatalf("test setup writing file %v", err)
+				}
+				modTime := time.Now().Add(-age)
+				if err := os.Chtimes(binPath, modTime, modTime); err != nil {
+					t.Fatalf("test setup changing mod timestamp %v", err)
+				}
+			}
+
+			provider := newTestProvider(t, nil, binDir)
+
+			report, err := provider.Prune(context.TODO(), tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			pruned := []string{}
+			for _, binary := range report.Pruned {
+				pruned = append(pruned, binary.ArtifactID)
+			}
+			if !reflect.DeepEqual(pruned, tc.expectPruned) {
+				t.Fatalf("expected pruned %v got %v", tc.expectPruned, pruned)
+			}
+
+			if report.Freed != int64(256*len(tc.expectPruned)) {
+				t.Fatalf("expected freed %d got %d", 256*len(tc.expectPruned), report.Freed)
+			}
+
+			for _, binary := range tc.expectKept {
+				if _, err = os.Stat(filepath.Join(binDir, binary, k6Binary)); err != nil {
+					t.Fatalf("expected %s kept %v", binary, err)
+				}
+			}
+		})
+	}
+}
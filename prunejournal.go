@@ -0,0 +1,121 @@
+//go:build !windows
+// +build !windows
+
+package k6provider
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// pruneJournalFile is the file in the binary directory that records the prunes of the
+	// pruners sharing it, one JSON object per line
+	pruneJournalFile = ".prune-journal.jsonl"
+	// maxPruneJournalSize is the size of the prune journal above which its oldest half is discarded
+	maxPruneJournalSize = 1 << 20
+)
+
+// pruneReason is what triggered a prune
+type pruneReason string
+
+const (
+	pruneScheduled pruneReason = "scheduled"
+	pruneEmergency pruneReason = "emergency"
+	pruneRequested pruneReason = "requested"
+)
+
+// pruneRecord records a prune in the prune journal, so the pruners sharing the directory,
+// in this or other processes, know when it was last pruned and what was removed
+type pruneRecord struct {
+	// Time when the prune started
+	Time time.Time `json:"time"`
+	// Host where the pruner runs
+	Host string `json:"host,omitempty"`
+	// PID of the process running the pruner
+	PID int `json:"pid"`
+	// Reason what triggered the prune
+	Reason pruneReason `json:"reason"`
+	// Freed bytes freed by removing artifact directories
+	Freed int64 `json:"freed"`
+	// Removed names of the artifact directories removed
+	Removed []string `json:"removed,omitempty"`
+	// Error message if the prune failed
+	Error string `json:"error,omitempty"`
+}
+
+// newPruneRecord returns the record of a prune starting now
+func newPruneRecord(reason pruneReason) *pruneRecord {
+	host, _ := os.Hostname()
+	return &pruneRecord{Time: time.Now(), Host: host, PID: os.Getpid(), Reason: reason}
+}
+
+// removed records the artifact directory of the target was removed
+func (r *pruneRecord) removed(target pruneTarget) {
+	r.Removed = append(r.Removed, filepath.Base(target.path))
+	r.Freed += target.size
+}
+
+// appendPruneRecord appends the record of the prune to the journal in the directory, recording
+// the error, if any. The journal must be written with the directory locked.
+func appendPruneRecord(dir string, record *pruneRecord, err error) error {
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	journalPath := filepath.Join(dir, pruneJournalFile)
+	file, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, cacheFilePerm) //nolint:gosec
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(file, "%s\n", line)
+	info, statErr := file.Stat()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if statErr == nil && info.Size() > maxPruneJournalSize {
+		return truncateLog(journalPath)
+	}
+
+	return nil
+}
+
+// lastPruneRecord returns the record of the most recent prune for the reason in the journal in
+// the directory. Returns false if no prune was recorded.
+func lastPruneRecord(dir string, reason pruneReason) (pruneRecord, bool) {
+	file, err := os.Open(filepath.Join(dir, pruneJournalFile)) //nolint:gosec
+	if err != nil {
+		return pruneRecord{}, false
+	}
+	defer file.Close() //nolint:errcheck
+
+	last := pruneRecord{}
+	found := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := pruneRecord{}
+		// lines partially written by an interrupted process or truncated are ignored
+		if json.Unmarshal(scanner.Bytes(), &record) != nil || record.Reason != reason {
+			continue
+		}
+		if !found || record.Time.After(last.Time) {
+			last, found = record, true
+		}
+	}
+
+	return last, found
+}
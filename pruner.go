@@ -4,15 +4,22 @@
 package k6provider
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// lastUsedFile is the file in the artifact directory that records when the binary was last
+// used on network file systems
+const lastUsedFile = ".last-used"
+
 // Pruner prunes binaries suing a LRU policy to enforce a limit
 // defined in a high-water-mark.
 type Pruner struct {
@@ -20,8 +27,11 @@ type Pruner struct {
 	dirLock       *dirLock
 	dir           string
 	hwm           int64
+	keepPerFamily int
+	trashGrace    time.Duration
 	pruneInterval time.Duration
 	lastPrune     time.Time
+	networkFS     bool
 }
 
 type pruneTarget struct {
@@ -41,18 +51,106 @@ func NewPruner(dir string, hwm int64, pruneInterval time.Duration) *Pruner {
 	}
 }
 
+// withRetention sets the maximum number of binaries kept for each family of artifacts
+// (see artifactFamily). If 0, the number of binaries is not limited.
+func (p *Pruner) withRetention(keepPerFamily int) *Pruner {
+	p.keepPerFamily = keepPerFamily
+	return p
+}
+
+// withTrash moves the pruned artifact directories to the trash, where they are kept for the
+// grace period and can be restored. If 0, pruned artifact directories are removed.
+func (p *Pruner) withTrash(grace time.Duration) *Pruner {
+	p.trashGrace = grace
+	return p
+}
+
+// withNetworkFS adapts the pruner to a directory in a network file system: the directory is
+// locked by creating a lock file instead of using advisory locks and the last use of the
+// binaries is recorded in a file, instead of relying on their modification time.
+func (p *Pruner) withNetworkFS(networkFS bool) *Pruner {
+	p.networkFS = networkFS
+	if networkFS {
+		p.dirLock = newExclusiveFileLock(p.dir)
+	}
+	return p
+}
+
 // Touch update access time because reading the file not always updates it
 func (p *Pruner) Touch(binPath string) {
-	if p.hwm > 0 {
+	if p.hwm > 0 || p.keepPerFamily > 0 {
 		p.pruneLock.Lock()
 		defer p.pruneLock.Unlock()
+
+		if p.networkFS {
+			_ = writeLastUsed(filepath.Dir(binPath), time.Now())
+			return
+		}
 		_ = os.Chtimes(binPath, time.Now(), time.Now())
 	}
 }
 
-// Prune the cache of least recently used files
+// writeLastUsed records the time the binary in the artifact directory was last used
+func writeLastUsed(artifactDir string, lastUsed time.Time) error {
+	return os.WriteFile(
+		filepath.Join(artifactDir, lastUsedFile),
+		[]byte(strconv.FormatInt(lastUsed.UnixNano(), 10)),
+		0o600,
+	)
+}
+
+// lastUsed returns the time the binary in the artifact directory was last used. On network
+// file systems, it is read from the last used file, if recorded, as the modification times are
+// set by the file server. Otherwise, it is the binary's modification time.
+func (p *Pruner) lastUsed(artifactDir string, binInfo os.FileInfo) time.Time {
+	if !p.networkFS {
+		return binInfo.ModTime()
+	}
+
+	content, err := os.ReadFile(filepath.Join(artifactDir, lastUsedFile)) //nolint:gosec
+	if err != nil {
+		return binInfo.ModTime()
+	}
+
+	nanos, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return binInfo.ModTime()
+	}
+
+	return time.Unix(0, nanos)
+}
+
+// state returns the state of the pruner
+func (p *Pruner) state() prunerState {
+	p.pruneLock.Lock()
+	defer p.pruneLock.Unlock()
+
+	return prunerState{
+		Enabled:       p.hwm > 0 || p.keepPerFamily > 0,
+		HighWaterMark: p.hwm,
+		KeepPerFamily: p.keepPerFamily,
+		PruneInterval: p.pruneInterval.String(),
+		LastPrune:     p.lastPrune,
+		NetworkFS:     p.networkFS,
+	}
+}
+
+// Close waits for any prune in progress and releases the lock on the cache directory
+func (p *Pruner) Close() error {
+	p.pruneLock.Lock()
+	defer p.pruneLock.Unlock()
+
+	return p.dirLock.unlock()
+}
+
+// Prune the cache of least recently used files.
+//
+// The pruners sharing the directory, in this or other processes, coordinate using the
+// directory's lock and a journal of the prunes in the directory: only one of them prunes
+// at a time and the prune interval is respected across all of them. Emergency prunes and
+// those requested with [Provider.Prune] don't delay the prunes of other pruners.
 func (p *Pruner) Prune() error {
-	if p.hwm == 0 {
+	if p.hwm == 0 && p.keepPerFamily == 0 {
 		return nil
 	}
 
@@ -65,13 +163,12 @@ func (p *Pruner) Prune() error {
 	if time.Since(p.lastPrune) < p.pruneInterval {
 		return nil
 	}
-	p.lastPrune = time.Now()
 
 	// prevent concurrent prune to the directory
 	err := p.dirLock.lock()
 	if err != nil {
 		// is locked, another pruner must be running (maybe another process)
-		if errors.Is(err, errLocked) {
+		if errors.Is(err, ErrLocked) {
 			return nil
 		}
 		return fmt.Errorf("%w: %w", ErrPruningCache, err)
@@ -80,55 +177,395 @@ func (p *Pruner) Prune() error {
 		_ = p.dirLock.unlock()
 	}()
 
+	// another pruner sharing the directory pruned it recently
+	if last, found := lastPruneRecord(p.dir, pruneScheduled); found && time.Since(last.Time) < p.pruneInterval {
+		p.lastPrune = last.Time
+		return nil
+	}
+	p.lastPrune = time.Now()
+
+	record := newPruneRecord(pruneScheduled)
+	err = p.prune(record)
+	_ = appendPruneRecord(p.dir, record, err)
+
+	return err
+}
+
+// prune empties the trash, prunes the families of artifacts and prunes the cache to the
+// high-water-mark, recording the artifact directories removed. The directory must be locked.
+func (p *Pruner) prune(record *pruneRecord) error {
+	if err := p.emptyTrash(p.trashGrace); err != nil {
+		return err
+	}
+
+	if err := p.pruneFamilies(record); err != nil {
+		return err
+	}
+
+	if p.hwm == 0 {
+		return nil
+	}
+
+	_, err := p.pruneTo(p.hwm, false, record)
+	return err
+}
+
+// pruneFamilies removes the least recently used binaries of each family of artifacts
+// exceeding the number of binaries kept per family. Binaries without metadata are kept.
+// The directory must be locked.
+func (p *Pruner) pruneFamilies(record *pruneRecord) error {
+	if p.keepPerFamily == 0 {
+		return nil
+	}
+
 	binaries, err := os.ReadDir(p.dir)
 	if err != nil {
 		return fmt.Errorf("%w: %w", ErrPruningCache, err)
 	}
 
-	errs := []error{ErrPruningCache}
+	families := map[string][]pruneTarget{}
+	for _, binDir := range binaries {
+		if !isArtifactDir(binDir) {
+			continue
+		}
+
+		artifactDir := filepath.Join(p.dir, binDir.Name())
+		metadata, err := readMetadata(artifactDir)
+		if err != nil {
+			continue
+		}
+
+		binInfo, err := os.Stat(filepath.Join(artifactDir, k6Binary))
+		if err != nil {
+			continue
+		}
+
+		// artifacts in different cache scopes belong to different families. Directories named
+		// after the binary's checksum are not scoped
+		scope, found := strings.CutPrefix(binDir.Name(), metadata.Artifact.ID)
+		if !found {
+			scope = ""
+		}
+		family := artifactFamily(metadata.Artifact) + scope
+		families[family] = append(families[family], pruneTarget{
+			path:      artifactDir,
+			timestamp: p.lastUsed(artifactDir, binInfo),
+		})
+	}
+
+	errs := []error{}
+	for _, targets := range families {
+		if len(targets) <= p.keepPerFamily {
+			continue
+		}
+
+		// most recently used first
+		sort.Slice(targets, func(i, j int) bool {
+			return targets[i].timestamp.After(targets[j].timestamp)
+		})
+
+		for _, target := range targets[p.keepPerFamily:] {
+			if err := p.remove(target.path, false); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			record.removed(target)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrPruningCache, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// EmergencyPrune frees space when the device holding the cache is full. Contrary to
+// [Pruner.Prune] it does not respect the prune interval and prunes the cache down to
+// half of the high-water-mark. Returns the number of bytes freed.
+func (p *Pruner) EmergencyPrune() (int64, error) {
+	if p.hwm == 0 {
+		return 0, nil
+	}
+
+	// wait for any prune in progress to finish
+	p.pruneLock.Lock()
+	defer p.pruneLock.Unlock()
+
+	p.lastPrune = time.Now()
+
+	// prevent concurrent prune to the directory
+	err := p.dirLock.lock()
+	if err != nil {
+		// is locked, another pruner must be running (maybe another process)
+		if errors.Is(err, ErrLocked) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("%w: %w", ErrPruningCache, err)
+	}
+	defer func() {
+		_ = p.dirLock.unlock()
+	}()
+
+	record := newPruneRecord(pruneEmergency)
+
+	// the space used by the trash is needed
+	freed := int64(0)
+	err = p.emptyTrash(0)
+	if err == nil {
+		freed, err = p.pruneTo(p.hwm/2, true, record)
+	}
+	_ = appendPruneRecord(p.dir, record, err)
+
+	return freed, err
+}
+
+// pruneWith removes the binaries as requested by the options, waiting for any prune in progress
+// until the context is done. Returns the binaries removed, or that would be removed in a dry run.
+func (p *Pruner) pruneWith(ctx context.Context, opts PruneOptions) (PruneReport, error) {
+	p.pruneLock.Lock()
+	defer p.pruneLock.Unlock()
+
+	if err := p.dirLock.lockWithContext(ctx); err != nil {
+		return PruneReport{}, fmt.Errorf("%w: %w", ErrPruningCache, err)
+	}
+	defer func() {
+		_ = p.dirLock.unlock()
+	}()
+
+	pruneTargets, cacheSize, errs, err := p.pruneTargets(!opts.DryRun)
+	if err != nil {
+		return PruneReport{}, err
+	}
+
+	record := newPruneRecord(pruneRequested)
+	report := PruneReport{Pruned: []PrunedBinary{}, Size: cacheSize, DryRun: opts.DryRun}
+	for _, target := range pruneTargets {
+		expired := opts.MaxAge > 0 && time.Since(target.timestamp) > opts.MaxAge
+		oversized := opts.TargetSize > 0 && report.Size > opts.TargetSize
+		if !expired && !oversized {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		if !opts.DryRun {
+			if err := p.remove(target.path, false); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			record.removed(target)
+		}
+
+		report.Pruned = append(report.Pruned, prunedBinary(target))
+		report.Freed += target.size
+		report.Size -= target.size
+	}
+
+	if len(errs) > 0 {
+		err = fmt.Errorf("%w: %w", ErrPruningCache, errors.Join(errs...))
+	}
+
+	if !opts.DryRun {
+		p.lastPrune = time.Now()
+		_ = appendPruneRecord(p.dir, record, err)
+	}
+
+	return report, err
+}
+
+// prunedBinary describes the binary in the artifact directory of the prune target
+func prunedBinary(target pruneTarget) PrunedBinary {
+	id := filepath.Base(target.path)
+	if metadata, err := readMetadata(target.path); err == nil && metadata.Artifact.ID != "" {
+		id = metadata.Artifact.ID
+	}
+
+	return PrunedBinary{ArtifactID: id, Dir: target.path, Size: target.size, LastUsed: target.timestamp}
+}
+
+// pruneTo removes the least recently used binaries until the cache size is below the
+// given limit. Returns the number of bytes freed. If permanent is true, the binaries are
+// removed even if the trash is enabled. The directory must be locked.
+func (p *Pruner) pruneTo(limit int64, permanent bool, record *pruneRecord) (int64, error) {
+	pruneTargets, cacheSize, errs, err := p.pruneTargets(true)
+	if err != nil {
+		return 0, err
+	}
+	errs = append([]error{ErrPruningCache}, errs...)
+
+	if cacheSize <= limit {
+		return 0, nil
+	}
+
+	freed := int64(0)
+	for _, target := range pruneTargets {
+		if err := p.remove(target.path, permanent); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		record.removed(target)
+
+		freed += target.size
+		if cacheSize-freed <= limit {
+			return freed, nil
+		}
+	}
+
+	return freed, fmt.Errorf("%w cache could not be pruned", errors.Join(errs...))
+}
+
+// pruneTargets returns the artifact directories in the cache, least recently used first, and
+// the size of the cache. If collectOrphans is true, directories left without a binary by failed
+// downloads are removed. Returns the errors found inspecting the artifact directories.
+func (p *Pruner) pruneTargets(collectOrphans bool) ([]pruneTarget, int64, []error, error) {
+	binaries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("%w: %w", ErrPruningCache, err)
+	}
+
+	errs := []error{}
 	cacheSize := int64(0)
 	pruneTargets := []pruneTarget{}
 	for _, binDir := range binaries {
 		// skip any spurious file, each binary is in a directory
-		if !binDir.IsDir() {
+		if !isArtifactDir(binDir) {
 			continue
 		}
 
 		binPath := filepath.Join(p.dir, binDir.Name(), k6Binary)
 		binInfo, err := os.Stat(binPath)
+		if os.IsNotExist(err) {
+			// remove directories left without a binary by failed downloads
+			if !collectOrphans {
+				continue
+			}
+			if _, err := collectOrphan(filepath.Dir(binPath)); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
-		cacheSize += binInfo.Size()
+		// we are going to prune the whole directory, including any other file besides the binary
+		dirSize, err := artifactDirSize(filepath.Dir(binPath))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		cacheSize += dirSize
 		pruneTargets = append(
 			pruneTargets,
 			pruneTarget{
-				path:      filepath.Dir(binPath), // we are going to prune the directory
-				size:      binInfo.Size(),
-				timestamp: binInfo.ModTime(),
+				path:      filepath.Dir(binPath),
+				size:      dirSize,
+				timestamp: p.lastUsed(filepath.Dir(binPath), binInfo),
 			})
 	}
 
-	if cacheSize <= p.hwm {
-		return nil
-	}
-
 	sort.Slice(pruneTargets, func(i, j int) bool {
 		return pruneTargets[i].timestamp.Before(pruneTargets[j].timestamp)
 	})
 
-	for _, target := range pruneTargets {
-		if err := os.RemoveAll(target.path); err != nil {
-			errs = append(errs, err)
+	return pruneTargets, cacheSize, errs, nil
+}
+
+// remove removes an artifact directory, moving it to the trash if enabled, unless permanent is true
+func (p *Pruner) remove(artifactDir string, permanent bool) error {
+	if p.trashGrace == 0 || permanent {
+		return os.RemoveAll(artifactDir)
+	}
+
+	trash := filepath.Join(p.dir, trashDir)
+	if err := os.MkdirAll(trash, 0o700); err != nil {
+		return err
+	}
+
+	// the time it was trashed is added to the name, so it can be removed after the grace period
+	trashed := fmt.Sprintf("%s.%d", filepath.Base(artifactDir), time.Now().UnixNano())
+
+	return os.Rename(artifactDir, filepath.Join(trash, trashed))
+}
+
+// emptyTrash removes the artifact directories in the trash for longer than the grace period
+func (p *Pruner) emptyTrash(grace time.Duration) error {
+	entries, err := os.ReadDir(filepath.Join(p.dir, trashDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("%w: %w", ErrPruningCache, err)
+	}
+
+	errs := []error{}
+	for _, entry := range entries {
+		_, trashed, ok := parseTrashed(entry.Name())
+		if ok && time.Since(trashed) < grace {
 			continue
 		}
 
-		cacheSize -= target.size
-		if cacheSize <= p.hwm {
-			return nil
+		if err := os.RemoveAll(filepath.Join(p.dir, trashDir, entry.Name())); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
-	return fmt.Errorf("%w cache could not be pruned", errors.Join(errs...))
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrPruningCache, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// restore moves the artifact directory back from the trash, if it was trashed during the grace
+// period. Returns true if the artifact directory was restored.
+func (p *Pruner) restore(artifactDir string) bool {
+	if p.trashGrace == 0 || filepath.Dir(artifactDir) != p.dir {
+		return false
+	}
+
+	entries, err := os.ReadDir(filepath.Join(p.dir, trashDir))
+	if err != nil {
+		return false
+	}
+
+	// restore the most recently trashed
+	latest := ""
+	latestTime := time.Time{}
+	for _, entry := range entries {
+		name, trashed, ok := parseTrashed(entry.Name())
+		if !ok || name != filepath.Base(artifactDir) || time.Since(trashed) >= p.trashGrace {
+			continue
+		}
+		if trashed.After(latestTime) {
+			latest, latestTime = entry.Name(), trashed
+		}
+	}
+
+	if latest == "" {
+		return false
+	}
+
+	return os.Rename(filepath.Join(p.dir, trashDir, latest), artifactDir) == nil
+}
+
+// parseTrashed returns the name of the artifact directory and the time it was trashed
+// from the name of an entry in the trash
+func parseTrashed(entry string) (string, time.Time, bool) {
+	idx := strings.LastIndex(entry, ".")
+	if idx < 0 {
+		return "", time.Time{}, false
+	}
+
+	nanos, err := strconv.ParseInt(entry[idx+1:], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	return entry[:idx], time.Unix(0, nanos), true
 }
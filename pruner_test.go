@@ -5,6 +5,7 @@ package k6provider
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -119,3 +120,368 @@ func TestPruner(t *testing.T) {
 		})
 	}
 }
+
+func TestEmergencyPrune(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	for i, age := range []time.Duration{0, time.Hour, 2 * time.Hour, 3 * time.Hour} {
+		binDir := filepath.Join(tmpDir, fmt.Sprintf("binary-%d", i+1))
+		if err := os.MkdirAll(binDir, 0o750); err != nil {
+			t.Fatalf("test setup: creating dir %v", err)
+		}
+		binPath := filepath.Join(binDir, k6Binary)
+		if err := os.WriteFile(binPath, make([]byte, 256), 0o600); err != nil {
+			t.Fatalf("test setup writing file %v", err)
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(binPath, modTime, modTime); err != nil {
+			t.Fatalf("test setup changing mod timestamp %v", err)
+		}
+	}
+
+	pruner := NewPruner(tmpDir, 256*4, time.Hour)
+	// emergency prune must ignore the prune interval
+	pruner.lastPrune = time.Now()
+
+	freed, err := pruner.EmergencyPrune()
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if freed != 256*2 {
+		t.Fatalf("expected %d bytes freed got %d", 256*2, freed)
+	}
+
+	for _, binary := range []string{"binary-1", "binary-2"} {
+		if _, err = os.Stat(filepath.Join(tmpDir, binary)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPruneFamilies(t *testing.T) {
+	t.Parallel()
+
+	artifacts := []struct {
+		id   string
+		k6   string
+		age  time.Duration
+		meta bool
+	}{
+		{id: "v0.55.0", k6: "v0.55.0", age: 3 * time.Hour, meta: true},
+		{id: "v0.55.1", k6: "v0.55.1", age: 2 * time.Hour, meta: true},
+		{id: "v0.55.2", k6: "v0.55.2", age: time.Hour, meta: true},
+		{id: "v0.56.0", k6: "v0.56.0", age: 4 * time.Hour, meta: true},
+		{id: "no-metadata", age: 5 * time.Hour},
+	}
+
+	tmpDir := t.TempDir()
+	for _, a := range artifacts {
+		artifactDir := filepath.Join(tmpDir, a.id)
+		if err := os.MkdirAll(artifactDir, 0o750); err != nil {
+			t.Fatalf("test setup: creating dir %v", err)
+		}
+		binPath := filepath.Join(artifactDir, k6Binary)
+		if err := os.WriteFile(binPath, make([]byte, 256), 0o600); err != nil {
+			t.Fatalf("test setup writing file %v", err)
+		}
+		if a.meta {
+			artifact := Artifact{
+				ID:           a.id,
+				Platform:     "linux/amd64",
+				Dependencies: map[string]string{"k6": a.k6, "k6/x/faker": "v0.4.0"},
+			}
+			if err := writeMetadata(artifactDir, artifact, "request"); err != nil {
+				t.Fatalf("test setup writing metadata %v", err)
+			}
+		}
+		modTime := time.Now().Add(-a.age)
+		if err := os.Chtimes(binPath, modTime, modTime); err != nil {
+			t.Fatalf("test setup changing mod timestamp %v", err)
+		}
+	}
+
+	pruner := NewPruner(tmpDir, 0, 0).withRetention(2)
+	if err := pruner.Prune(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	for _, a := range artifacts {
+		_, err := os.Stat(filepath.Join(tmpDir, a.id))
+		// only the least recently used of the v0.55 family is removed
+		if pruned := a.id == "v0.55.0"; pruned != os.IsNotExist(err) {
+			t.Fatalf("%s: expected pruned %t got %v", a.id, pruned, err)
+		}
+	}
+}
+
+func TestPruneNetworkFS(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	pruner := NewPruner(tmpDir, 300, 0).withNetworkFS(true)
+
+	// the modification times set by the file server don't reflect the last use
+	for _, id := range []string{"used", "unused"} {
+		artifactDir := filepath.Join(tmpDir, id)
+		if err := os.MkdirAll(artifactDir, 0o750); err != nil {
+			t.Fatalf("test setup: creating dir %v", err)
+		}
+		binPath := filepath.Join(artifactDir, k6Binary)
+		if err := os.WriteFile(binPath, make([]byte, 256), 0o600); err != nil {
+			t.Fatalf("test setup writing file %v", err)
+		}
+		if err := writeLastUsed(artifactDir, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("test setup writing last use %v", err)
+		}
+	}
+
+	pruner.Touch(filepath.Join(tmpDir, "used", k6Binary))
+
+	if err := pruner.Prune(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "used")); err != nil {
+		t.Fatalf("expected used binary kept got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "unused")); !os.IsNotExist(err) {
+		t.Fatalf("expected unused binary pruned got %v", err)
+	}
+
+	if _, err := os.Stat(pruner.dirLock.lockFile); !os.IsNotExist(err) {
+		t.Fatalf("expected lock file removed got %v", err)
+	}
+}
+
+func TestPruneOrphans(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	old := time.Now().Add(-2 * partialFileTTL)
+	for _, dir := range []string{"binary", "orphan", "starting"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0o750); err != nil {
+			t.Fatalf("test setup: creating dir %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "binary", k6Binary), make([]byte, 256), 0o600); err != nil {
+		t.Fatalf("test setup writing file %v", err)
+	}
+	for _, dir := range []string{"binary", "orphan"} {
+		if err := os.Chtimes(filepath.Join(tmpDir, dir), old, old); err != nil {
+			t.Fatalf("test setup changing mod timestamp %v", err)
+		}
+	}
+
+	// the cache is below the high-water-mark, but orphans are removed
+	pruner := NewPruner(tmpDir, 1024, time.Hour)
+	if err := pruner.Prune(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	for dir, expectPruned := range map[string]bool{"binary": false, "orphan": true, "starting": false} {
+		_, err := os.Stat(filepath.Join(tmpDir, dir))
+		if expectPruned != os.IsNotExist(err) {
+			t.Fatalf("%s: expected pruned %t got %v", dir, expectPruned, err)
+		}
+	}
+}
+
+func TestPruneMixedContent(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	for i, age := range []time.Duration{0, time.Hour, 2 * time.Hour} {
+		artifactDir := filepath.Join(tmpDir, fmt.Sprintf("binary-%d", i+1))
+		files := map[string]int{
+			k6Binary:                      256,
+			metadataFile:                  128,
+			filepath.Join("sbom", "spdx"): 128,
+		}
+		for file, size := range files {
+			path := filepath.Join(artifactDir, file)
+			if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+				t.Fatalf("test setup: creating dir %v", err)
+			}
+			if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+				t.Fatalf("test setup writing file %v", err)
+			}
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(artifactDir, k6Binary), modTime, modTime); err != nil {
+			t.Fatalf("test setup changing mod timestamp %v", err)
+		}
+	}
+
+	// each artifact directory has 512 bytes. Considering only the binaries the
+	// cache (768 bytes) would be below the high-water-mark.
+	pruner := NewPruner(tmpDir, 1024, time.Hour)
+
+	freed, err := pruner.EmergencyPrune()
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if freed != 1024 {
+		t.Fatalf("expected %d bytes freed got %d", 1024, freed)
+	}
+
+	for dir, expectPruned := range map[string]bool{"binary-1": false, "binary-2": true, "binary-3": true} {
+		_, err := os.Stat(filepath.Join(tmpDir, dir))
+		if expectPruned != os.IsNotExist(err) {
+			t.Fatalf("%s: expected pruned %t got %v", dir, expectPruned, err)
+		}
+	}
+
+	// the whole directory is considered for the high-water-mark
+	pruner = NewPruner(tmpDir, 256, time.Hour)
+	if err := pruner.Prune(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "binary-1")); !os.IsNotExist(err) {
+		t.Fatalf("expected binary-1 pruned got %v", err)
+	}
+}
+
+func TestPruneToTrash(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	for i, age := range []time.Duration{0, time.Hour} {
+		artifactDir := filepath.Join(tmpDir, fmt.Sprintf("binary-%d", i+1))
+		if err := os.MkdirAll(artifactDir, 0o750); err != nil {
+			t.Fatalf("test setup: creating dir %v", err)
+		}
+		binPath := filepath.Join(artifactDir, k6Binary)
+		if err := os.WriteFile(binPath, make([]byte, 256), 0o600); err != nil {
+			t.Fatalf("test setup writing file %v", err)
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(binPath, modTime, modTime); err != nil {
+			t.Fatalf("test setup changing mod timestamp %v", err)
+		}
+	}
+
+	pruner := NewPruner(tmpDir, 256, 0).withTrash(time.Hour)
+	if err := pruner.Prune(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	binary2 := filepath.Join(tmpDir, "binary-2")
+	if _, err := os.Stat(binary2); !os.IsNotExist(err) {
+		t.Fatalf("expected binary-2 pruned got %v", err)
+	}
+
+	// the trash is not considered for pruning
+	if err := pruner.Prune(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "binary-1")); err != nil {
+		t.Fatalf("expected binary-1 kept got %v", err)
+	}
+
+	// restored during the grace period
+	if !pruner.restore(binary2) {
+		t.Fatalf("expected binary-2 restored")
+	}
+	if _, err := os.Stat(filepath.Join(binary2, k6Binary)); err != nil {
+		t.Fatalf("expected binary-2 restored got %v", err)
+	}
+
+	// removed from the trash after the grace period
+	pruner.withTrash(time.Nanosecond)
+	if err := pruner.Prune(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if pruner.restore(binary2) {
+		t.Fatalf("expected binary-2 not restored after grace period")
+	}
+	if err := pruner.emptyTrash(time.Nanosecond); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if trashed, _ := os.ReadDir(filepath.Join(tmpDir, trashDir)); len(trashed) > 0 {
+		t.Fatalf("expected trash emptied got %v", trashed)
+	}
+}
+
+func TestPruneCoordination(t *testing.T) {
+	t.Parallel()
+
+	createBinaries := func(t *testing.T, dir string, names ...string) {
+		t.Helper()
+
+		for i, name := range names {
+			binPath := filepath.Join(dir, name, k6Binary)
+			if err := os.MkdirAll(filepath.Dir(binPath), 0o750); err != nil {
+				t.Fatalf("test setup: creating dir %v", err)
+			}
+			if err := os.WriteFile(binPath, make([]byte, 256), 0o600); err != nil {
+				t.Fatalf("test setup writing file %v", err)
+			}
+			// the first is the least recently used
+			modTime := time.Now().Add(time.Duration(i-len(names)) * time.Hour)
+			if err := os.Chtimes(binPath, modTime, modTime); err != nil {
+				t.Fatalf("test setup changing mod timestamp %v", err)
+			}
+		}
+	}
+
+	t.Run("prune interval is shared", func(t *testing.T) {
+		t.Parallel()
+
+		tmpDir := t.TempDir()
+		createBinaries(t, tmpDir, "binary-1", "binary-2", "binary-3")
+
+		if err := NewPruner(tmpDir, 256*2, time.Hour).Prune(); err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+
+		record, found := lastPruneRecord(tmpDir, pruneScheduled)
+		if !found {
+			t.Fatalf("expected prune recorded")
+		}
+		if len(record.Removed) != 1 || record.Removed[0] != "binary-1" || record.Freed != 256 {
+			t.Fatalf("expected binary-1 removed got %+v", record)
+		}
+
+		// another pruner sharing the directory must respect the prune interval
+		createBinaries(t, tmpDir, "binary-0")
+		other := NewPruner(tmpDir, 256*2, time.Hour)
+		if err := other.Prune(); err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(tmpDir, "binary-0")); err != nil {
+			t.Fatalf("expected binary-0 not pruned got %v", err)
+		}
+		if !other.lastPrune.Equal(record.Time) {
+			t.Fatalf("expected last prune %v got %v", record.Time, other.lastPrune)
+		}
+	})
+
+	t.Run("directory locked by another pruner", func(t *testing.T) {
+		t.Parallel()
+
+		tmpDir := t.TempDir()
+		createBinaries(t, tmpDir, "binary-1", "binary-2", "binary-3")
+
+		locked := newFileLock(tmpDir)
+		if err := locked.lock(); err != nil {
+			t.Fatalf("test setup: locking %v", err)
+		}
+		t.Cleanup(func() { _ = locked.unlock() })
+
+		if err := NewPruner(tmpDir, 256*2, time.Hour).Prune(); err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(tmpDir, "binary-1")); err != nil {
+			t.Fatalf("expected binary-1 not pruned got %v", err)
+		}
+		if _, found := lastPruneRecord(tmpDir, pruneScheduled); found {
+			t.Fatalf("unexpected prune recorded")
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package k6provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/grafana/k6deps"
+)
+
+var (
+	// commitRef matches a (possibly abbreviated) commit SHA
+	commitRef = regexp.MustCompile(`^[0-9a-f]{7,40}$`) //nolint:gochecknoglobals
+	// versionRef matches a release tag
+	versionRef = regexp.MustCompile(`^v?\d+\.\d+\.\d+`) //nolint:gochecknoglobals
+)
+
+// isMutableRef returns true if the k6 reference can point to different sources over time,
+// such as a branch or "nightly". Commit SHAs and release tags are immutable.
+func isMutableRef(ref string) bool {
+	return !commitRef.MatchString(ref) && !versionRef.MatchString(ref)
+}
+
+// GetBinaryForRef returns a custom k6 binary built from a k6 reference that is not a
+// release, such as a commit SHA, a branch or "nightly", with the given extension dependencies.
+// The k6 dependency in deps, if any, is ignored.
+//
+// The reference is passed as is to the build service, which must support it.
+//
+// As mutable references (branches, "nightly") point to different sources over time, the
+// binaries built from them are downloaded again when they are older than [Config.MutableRefTTL],
+// unless the store reports their content didn't change using the ETag or Last-Modified headers
+// of the previous download. In this case, the binary is kept for another period.
+func (p *Provider) GetBinaryForRef(ctx context.Context, ref string, deps k6deps.Dependencies) (K6Binary, error) {
+	if p.ctx.Err() != nil {
+		return K6Binary{}, ErrClosed
+	}
+
+	if ref == "" || strings.ContainsAny(ref, " ;") {
+		return K6Binary{}, NewWrappedError(ErrInvalidParameters, fmt.Errorf("invalid k6 reference %q", ref))
+	}
+
+	_, buildDeps := p.buildDeps(deps)
+	artifact, err := p.build(ctx, ref, buildDeps)
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	if isMutableRef(ref) {
+		if err = p.expireBinary(ctx, artifact, p.refTTL); err != nil {
+			return K6Binary{}, err
+		}
+	}
+
+	binary, err := p.binaryFor(ctx, artifact)
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	binary, err = p.deliver(binary)
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	// the reference is recorded so the binary can be obtained again from its lockfile
+	binary.Ref = ref
+
+	return binary, nil
+}
+
+// expireBinary removes the cached binary of an artifact if it was downloaded or validated more
+// than ttl ago, unless the store reports its content didn't change since it was downloaded.
+// The binary is removed holding its download lock (see [Provider.discardBinary]).
+func (p *Provider) expireBinary(ctx context.Context, artifact Artifact, ttl time.Duration) error {
+	for _, dir := range p.binDirs() {
+		artifactDir := p.artifactDir(dir, artifact)
+
+		at, err := downloaded(artifactDir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return NewWrappedError(ErrBinary, err)
+		}
+
+		if time.Since(at) < ttl {
+			continue
+		}
+
+		// the binary is valid for another ttl
+		if p.unchanged(ctx, artifactDir, artifact.URL) {
+			_ = recordDownload(artifactDir, artifact)
+			continue
+		}
+
+		if err = p.discardBinary(ctx, dir, artifactDir); err != nil {
+			return NewWrappedError(ErrBinary, err)
+		}
+	}
+
+	return nil
+}
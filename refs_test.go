@@ -0,0 +1,194 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+)
+
+func TestIsMutableRef(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]bool{
+		"nightly":   true,
+		"master":    true,
+		"feature-x": true,
+		"v0.55.0":   false,
+		"0.55.0":    false,
+		"a1b2c3d":   false,
+		"a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2": false,
+	}
+
+	for ref, expected := range testCases {
+		if isMutableRef(ref) != expected {
+			t.Errorf("ref %q: expected mutable=%t", ref, expected)
+		}
+	}
+}
+
+func TestGetBinaryForRef(t *testing.T) {
+	t.Parallel()
+
+	downloads := atomic.Int32{}
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		downloads.Add(1)
+		_, _ = w.Write([]byte("k6"))
+	}))
+	t.Cleanup(store.Close)
+
+	var requested string
+	buildSrv := buildServiceFunc(
+		func(_ context.Context, _ string, k6 string, _ []k6build.Dependency) (k6build.Artifact, error) {
+			requested = k6
+			return k6build.Artifact{ID: "nightly-build", URL: store.URL}, nil
+		},
+	)
+	binDir := t.TempDir()
+	provider := newTestProvider(t, buildSrv, binDir)
+	provider.refTTL = time.Hour
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.GetBinaryForRef(context.TODO(), "nightly", nil); err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+	}
+
+	if requested != "nightly" {
+		t.Fatalf("expected reference passed to the build service, got %q", requested)
+	}
+
+	if downloads.Load() != 1 {
+		t.Fatalf("expected binary to be cached, got %d downloads", downloads.Load())
+	}
+
+	// after the ttl, the binary must be downloaded again, even if the artifact directory
+	// was modified since, for example, when writing other files
+	artifactDir := filepath.Join(binDir, "nightly-build")
+	setDownloaded(t, artifactDir, time.Now().Add(-2*time.Hour))
+	now := time.Now()
+	if err := os.Chtimes(artifactDir, now, now); err != nil {
+		t.Fatalf("test setup: changing mod timestamp %v", err)
+	}
+
+	if _, err := provider.GetBinaryForRef(context.TODO(), "nightly", nil); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if downloads.Load() != 2 {
+		t.Fatalf("expected expired binary to be downloaded, got %d downloads", downloads.Load())
+	}
+
+	// the expired binary is not removed while it is locked, for example, while being verified
+	setDownloaded(t, artifactDir, time.Now().Add(-2*time.Hour))
+	downloadLock := newFileLock(artifactDir)
+	if err := downloadLock.lock(); err != nil {
+		t.Fatalf("test setup: locking %v", err)
+	}
+	defer downloadLock.unlock() //nolint:errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockPollInterval)
+	defer cancel()
+	if _, err := provider.GetBinaryForRef(ctx, "nightly", nil); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected %v got %v", ErrLocked, err)
+	}
+	if _, err := os.Stat(filepath.Join(artifactDir, k6Binary)); err != nil {
+		t.Fatalf("expected locked binary to be kept %v", err)
+	}
+}
+
+func TestGetBinaryForRefConditional(t *testing.T) {
+	t.Parallel()
+
+	downloads := atomic.Int32{}
+	etag := atomic.Value{}
+	etag.Store(`"v1"`)
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current, _ := etag.Load().(string)
+		w.Header().Set("ETag", current)
+		switch r.Header.Get("If-None-Match") {
+		case current:
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case "":
+			downloads.Add(1)
+		}
+		_, _ = w.Write([]byte("k6 " + current))
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "latest-build", URL: store.URL}, nil
+		},
+	)
+	binDir := t.TempDir()
+	provider := newTestProvider(t, buildSrv, binDir)
+	provider.refTTL = time.Hour
+
+	artifactDir := filepath.Join(binDir, "latest-build")
+	expire := func() {
+		setDownloaded(t, artifactDir, time.Now().Add(-2*time.Hour))
+	}
+
+	testCases := []struct {
+		title           string
+		etag            string
+		expectDownloads int32
+	}{
+		{title: "first download", etag: `"v1"`, expectDownloads: 1},
+		{title: "content not modified", etag: `"v1"`, expectDownloads: 1},
+		{title: "content modified", etag: `"v2"`, expectDownloads: 2},
+	}
+
+	// the cases are sequential, as each depends on the cache left by the previous
+	for _, tc := range testCases {
+		etag.Store(tc.etag)
+
+		binary, err := provider.GetBinaryForRef(context.TODO(), "latest", nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected %v", tc.title, err)
+		}
+
+		if downloads.Load() != tc.expectDownloads {
+			t.Fatalf("%s: expected %d downloads got %d", tc.title, tc.expectDownloads, downloads.Load())
+		}
+
+		content, err := os.ReadFile(binary.Path)
+		if err != nil {
+			t.Fatalf("%s: reading binary %v", tc.title, err)
+		}
+		if string(content) != "k6 "+tc.etag {
+			t.Fatalf("%s: expected %q got %q", tc.title, "k6 "+tc.etag, content)
+		}
+
+		expire()
+	}
+}
+
+// setDownloaded records in the artifact's metadata the binary was downloaded at the given time
+func setDownloaded(t *testing.T, artifactDir string, at time.Time) {
+	t.Helper()
+
+	metadata, err := readMetadata(artifactDir)
+	if err != nil {
+		t.Fatalf("test setup: reading metadata %v", err)
+	}
+	metadata.Downloaded = at
+
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatalf("test setup: marshaling metadata %v", err)
+	}
+	if err = os.WriteFile(filepath.Join(artifactDir, metadataFile), content, 0o600); err != nil {
+		t.Fatalf("test setup: writing metadata %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grafana/k6deps"
+)
+
+// ResultSource describes where the binary provisioned was obtained from
+type ResultSource string
+
+const (
+	// ResultSourceDownload the binary was downloaded from the build service's store
+	ResultSourceDownload ResultSource = "download"
+	// ResultSourceCache the binary was found in the cache
+	ResultSourceCache ResultSource = "cache"
+	// ResultSourceFallback the build service could not be reached and the binary that satisfied
+	// the same dependencies before was provided from the cache
+	ResultSourceFallback ResultSource = "fallback"
+)
+
+// ProvisionResult is the result of provisioning a binary written to the result file.
+// See [WithResultFile]
+type ProvisionResult struct {
+	// Binary provisioned, if any
+	Binary *K6Binary `json:"binary,omitempty"`
+	// Source where the binary was obtained from
+	Source ResultSource `json:"source,omitempty"`
+	// Time when the provisioning started
+	Time time.Time `json:"time"`
+	// Duration of the provisioning, in nanoseconds
+	Duration time.Duration `json:"duration"`
+	// Timing time spent in each phase of the provisioning
+	Timing Timing `json:"timing"`
+	// Error message if the binary could not be provisioned
+	Error string `json:"error,omitempty"`
+}
+
+type resultFileKey struct{}
+
+// resultRecorder records where the binary provisioned was obtained from
+type resultRecorder struct {
+	source ResultSource
+}
+
+type resultRecorderKey struct{}
+
+// WithResultFile returns a context that makes [Provider.GetBinary] and the functions that use it
+// write the result of the provisioning to the file at the given path as a JSON [ProvisionResult],
+// so other processes (e.g. later steps in a CI pipeline) can use the binary without invoking
+// the provider again. If the binary cannot be provisioned, the result has the error instead.
+//
+// The file is replaced atomically. Failing to write it is an [ErrBinary] error.
+func WithResultFile(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, resultFileKey{}, path)
+}
+
+// resultFileFrom returns the path to the result file in the context, if any
+func resultFileFrom(ctx context.Context) string {
+	path, _ := ctx.Value(resultFileKey{}).(string)
+	return path
+}
+
+// recordSource records where the binary was obtained from, if the context records the result
+func recordSource(ctx context.Context, source ResultSource) {
+	if recorder, ok := ctx.Value(resultRecorderKey{}).(*resultRecorder); ok {
+		recorder.source = source
+	}
+}
+
+// getBinaryWithResult gets the binary, writing the result of the provisioning to the path
+func (p *Provider) getBinaryWithResult(ctx context.Context, deps k6deps.Dependencies, path string) (K6Binary, error) {
+	// only the time spent in this call is reported, if the context is used for other calls
+	if _, ok := TimingFromContext(ctx); !ok {
+		ctx = WithTiming(ctx)
+	}
+	before, _ := TimingFromContext(ctx)
+
+	recorder := &resultRecorder{}
+	ctx = context.WithValue(ctx, resultRecorderKey{}, recorder)
+
+	start := time.Now()
+	binary, err := p.getBinary(ctx, deps)
+
+	timing, _ := TimingFromContext(ctx)
+	result := ProvisionResult{
+		Time:     start.UTC(),
+		Duration: time.Since(start),
+		Timing: Timing{
+			Resolve:  timing.Resolve - before.Resolve,
+			Lookup:   timing.Lookup - before.Lookup,
+			Download: timing.Download - before.Download,
+			Verify:   timing.Verify - before.Verify,
+		},
+	}
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Binary = &binary
+		result.Source = recorder.source
+	}
+
+	if writeErr := writeResultFile(path, result); writeErr != nil && err == nil {
+		return K6Binary{}, p.recordError(NewWrappedError(ErrBinary, storageError(writeErr)))
+	}
+
+	return binary, err
+}
+
+// writeResultFile writes the result to a temporary file in the same directory and moves it to
+// the path, so readers never see a partially written result
+func writeResultFile(path string, result ProvisionResult) error {
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,123 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+)
+
+func TestResultFile(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	available := atomic.Bool{}
+	available.Store(true)
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			if !available.Load() {
+				return k6build.Artifact{}, errors.New("503 Service Unavailable")
+			}
+			return k6build.Artifact{
+				ID:           "artifact",
+				URL:          store.URL,
+				Dependencies: map[string]string{"k6": "v0.55.0"},
+				Checksum:     checksum,
+			}, nil
+		},
+	)
+
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+	provider.config.StaleIfError = time.Hour
+
+	resultPath := filepath.Join(t.TempDir(), "result.json")
+	ctx := WithResultFile(context.TODO(), resultPath)
+
+	testCases := []struct {
+		title     string
+		available bool
+		source    ResultSource
+	}{
+		{
+			title:     "downloaded",
+			available: true,
+			source:    ResultSourceDownload,
+		},
+		{
+			title:     "from cache",
+			available: true,
+			source:    ResultSourceCache,
+		},
+		{
+			title:     "build service unavailable",
+			available: false,
+			source:    ResultSourceFallback,
+		},
+	}
+
+	// the cases are not independent, as each one uses the cache populated by the previous ones
+	for _, tc := range testCases {
+		available.Store(tc.available)
+
+		binary, err := provider.GetBinary(ctx, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected %v", tc.title, err)
+		}
+
+		result := readResultFile(t, resultPath)
+		if result.Source != tc.source {
+			t.Fatalf("%s: expected %s got %s", tc.title, tc.source, result.Source)
+		}
+		if result.Binary == nil || !reflect.DeepEqual(*result.Binary, binary) {
+			t.Fatalf("%s: expected %+v got %+v", tc.title, binary, result.Binary)
+		}
+		if result.Duration <= 0 || result.Time.IsZero() {
+			t.Fatalf("%s: expected duration and time got %+v", tc.title, result)
+		}
+	}
+
+	// the binary cannot be provided for other dependencies
+	_, err := provider.GetBinaryForConstraints(ctx, "k6/x/faker=*")
+	if !errors.Is(err, ErrBuild) {
+		t.Fatalf("expected %v got %v", ErrBuild, err)
+	}
+
+	result := readResultFile(t, resultPath)
+	if result.Error == "" || result.Binary != nil {
+		t.Fatalf("expected error got %+v", result)
+	}
+}
+
+func readResultFile(t *testing.T, path string) ProvisionResult {
+	t.Helper()
+
+	content, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		t.Fatalf("reading result file %v", err)
+	}
+
+	result := ProvisionResult{}
+	if err = json.Unmarshal(content, &result); err != nil {
+		t.Fatalf("parsing result file %v", err)
+	}
+
+	return result
+}
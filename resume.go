@@ -0,0 +1,181 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// resumeSuffix is the suffix of the file with the state of an interrupted download,
+// kept next to its partial file
+const resumeSuffix = ".resume"
+
+// resumableWriter is a download destination that may already have part of the content,
+// so only the remainder is requested
+type resumableWriter interface {
+	// resumeFrom returns the length of the content already written and the validator
+	// of the content it was downloaded from
+	resumeFrom() (int64, string)
+	// restart discards the content already written
+	restart() error
+}
+
+// setRange requests the remainder of the content not written to the destination yet,
+// if the content didn't change since it was downloaded. Returns the offset requested.
+func setRange(req *http.Request, dest resumableWriter) int64 {
+	offset, ifRange := dest.resumeFrom()
+	if offset <= 0 || ifRange == "" {
+		return 0
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	req.Header.Set("If-Range", ifRange)
+
+	return offset
+}
+
+// rangeStart returns the start of the range in a partial content response,
+// or -1 if it is not valid. e.g. "Content-Range: bytes 100-199/200"
+func rangeStart(resp *http.Response) int64 {
+	contentRange, found := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	if !found {
+		return -1
+	}
+
+	start, _, _ := strings.Cut(contentRange, "-")
+	offset, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return offset
+}
+
+// ifRange returns the validator used for resuming a download of the content, or an empty
+// string if it cannot be resumed. Weak ETags are not valid for ranges.
+func (v validators) ifRange() string {
+	if v.ETag != "" && !strings.HasPrefix(v.ETag, "W/") {
+		return v.ETag
+	}
+
+	return v.LastModified
+}
+
+// resumeState describes the content written to a partial file by an interrupted download
+type resumeState struct {
+	// Validator of the content downloaded
+	Validator string `json:"validator"`
+	// Size of the content written to the partial file
+	Size int64 `json:"size"`
+	// Checksum of the content written to the partial file
+	Checksum string `json:"checksum"`
+}
+
+// interrupted returns true if the error interrupted a download that may be resumed later
+func interrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, ErrDownloadTemporary)
+}
+
+// resumePartial prepares a partial file for resuming an interrupted download, adding its
+// content to the hash. The content is only reused if it matches the checksum recorded
+// when the download was interrupted, otherwise it is discarded.
+// Returns the state of the download or an empty state if it cannot be resumed.
+func resumePartial(partialPath string, hash hash.Hash) resumeState {
+	state, err := readResume(partialPath)
+	// the state is only valid for the content written when it was recorded
+	_ = os.Remove(partialPath + resumeSuffix)
+	if err != nil || state.Size <= 0 || state.Validator == "" {
+		return resumeState{}
+	}
+
+	partial, err := os.Open(partialPath) //nolint:gosec
+	if err != nil {
+		return resumeState{}
+	}
+	defer partial.Close() //nolint:errcheck
+
+	hash.Reset()
+	if _, err = defaultBuffers.copy(hash, io.LimitReader(partial, state.Size)); err != nil ||
+		hex.EncodeToString(hash.Sum(nil)) != state.Checksum {
+		hash.Reset()
+		return resumeState{}
+	}
+
+	// any content written after the state was recorded is discarded
+	if err = os.Truncate(partialPath, state.Size); err != nil {
+		hash.Reset()
+		return resumeState{}
+	}
+
+	return state
+}
+
+// readResume reads the state of the interrupted download of a partial file
+func readResume(partialPath string) (resumeState, error) {
+	content, err := os.ReadFile(partialPath + resumeSuffix) //nolint:gosec
+	if err != nil {
+		return resumeState{}, err
+	}
+
+	state := resumeState{}
+	if err := json.Unmarshal(content, &state); err != nil {
+		return resumeState{}, err
+	}
+
+	return state, nil
+}
+
+// writeResume records the state of the interrupted download of a partial file
+func writeResume(partialPath string, state resumeState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(partialPath+resumeSuffix, content, cacheFilePerm)
+}
+
+// countingHash is a hash that counts the bytes written to it
+type countingHash struct {
+	hash.Hash
+	size int64
+}
+
+func (h *countingHash) Write(p []byte) (int, error) {
+	n, err := h.Hash.Write(p)
+	h.size += int64(n)
+	return n, err
+}
+
+func (h *countingHash) Reset() {
+	h.Hash.Reset()
+	h.size = 0
+}
+
+// recordInterrupted records the state of the interrupted download of a partial file, if it
+// can be resumed. Failing to record it is not an error, the download just starts over.
+func recordInterrupted(partialPath string, progress *progressWriter, hash *countingHash) {
+	validator := progress.validators.ifRange()
+	// interrupted before receiving a response, the content is that of the resumed download
+	if progress.validators.empty() {
+		validator = progress.resumed.Validator
+	}
+
+	if hash.size == 0 || validator == "" {
+		return
+	}
+
+	_ = writeResume(partialPath, resumeState{
+		Validator: validator,
+		Size:      hash.size,
+		Checksum:  hex.EncodeToString(hash.Sum(nil)),
+	})
+}
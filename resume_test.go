@@ -0,0 +1,167 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+func TestResumeDownload(t *testing.T) {
+	t.Parallel()
+
+	original := bytes.Repeat([]byte("k6 binary "), 100)
+	changed := bytes.Repeat([]byte("k6 changed "), 100)
+
+	testCases := []struct {
+		title       string
+		etag        string
+		resumedEtag string
+		content     []byte
+		expectRange string
+	}{
+		{
+			title:       "download resumed",
+			etag:        `"v1"`,
+			resumedEtag: `"v1"`,
+			content:     original,
+			expectRange: fmt.Sprintf("bytes=%d-", len(original)/2),
+		},
+		{
+			title:       "content changed",
+			etag:        `"v1"`,
+			resumedEtag: `"v2"`,
+			content:     changed,
+			expectRange: fmt.Sprintf("bytes=%d-", len(original)/2),
+		},
+		{
+			title:       "weak validator",
+			etag:        `W/"v1"`,
+			resumedEtag: `W/"v1"`,
+			content:     original,
+			expectRange: "",
+		},
+		{
+			title:       "no validators",
+			etag:        "",
+			resumedEtag: "",
+			content:     original,
+			expectRange: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			// the first request is interrupted after sending half of the original content
+			var (
+				mutex     sync.Mutex
+				requests  int
+				gotRange  string
+				interrupt = make(chan struct{})
+			)
+			store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mutex.Lock()
+				requests++
+				first := requests == 1
+				if !first {
+					gotRange = r.Header.Get("Range")
+				}
+				mutex.Unlock()
+
+				if first {
+					if tc.etag != "" {
+						w.Header().Set("ETag", tc.etag)
+					}
+					w.Header().Set("Content-Length", fmt.Sprint(len(original)))
+					_, _ = w.Write(original[:len(original)/2])
+					w.(http.Flusher).Flush()
+					select {
+					case <-interrupt:
+					case <-r.Context().Done():
+					}
+					return
+				}
+
+				if tc.resumedEtag != "" {
+					w.Header().Set("ETag", tc.resumedEtag)
+				}
+				http.ServeContent(w, r, "k6", time.Time{}, bytes.NewReader(tc.content))
+			}))
+			t.Cleanup(store.Close)
+			t.Cleanup(func() { close(interrupt) })
+
+			checksum := fmt.Sprintf("%x", sha256.Sum256(tc.content))
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Platform: "linux/amd64", Checksum: checksum}, nil
+				},
+			)
+
+			binDir := t.TempDir()
+			provider := newTestProvider(t, buildSrv, binDir)
+			partialPath := filepath.Join(binDir, "artifact", k6Binary+partialSuffix)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			result := make(chan error, 1)
+			go func() {
+				_, err := provider.GetBinary(ctx, k6deps.Dependencies{})
+				result <- err
+			}()
+
+			// cancel once half of the content is written to the partial file
+			for {
+				info, err := os.Stat(partialPath)
+				if err == nil && info.Size() == int64(len(original)/2) {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			cancel()
+
+			if err := <-result; !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected %v got %v", context.Canceled, err)
+			}
+
+			binary, err := provider.GetBinary(context.Background(), k6deps.Dependencies{})
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if gotRange != tc.expectRange {
+				t.Fatalf("expected range %q got %q", tc.expectRange, gotRange)
+			}
+
+			content, err := os.ReadFile(binary.Path)
+			if err != nil {
+				t.Fatalf("reading binary %v", err)
+			}
+			if !bytes.Equal(content, tc.content) {
+				t.Fatalf("expected content %q got %q", tc.content, content)
+			}
+
+			entries, err := os.ReadDir(filepath.Dir(binary.Path))
+			if err != nil {
+				t.Fatalf("reading artifact dir %v", err)
+			}
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), k6Binary+partialSuffix) {
+					t.Fatalf("expected partial files to be removed got %s", entry.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package k6provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// artifactFamily returns the key of the family of an artifact: artifacts for the same platform
+// with the same dependencies, except for the k6 patch version, belong to the same family
+func artifactFamily(artifact Artifact) string {
+	names := make([]string, 0, len(artifact.Dependencies))
+	for name := range artifact.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	family := &strings.Builder{}
+	family.WriteString(artifact.Platform)
+	for _, name := range names {
+		version := artifact.Dependencies[name]
+		if name == k6Module {
+			version = minorVersion(version)
+		}
+		_, _ = fmt.Fprintf(family, ";%s:%s", name, version)
+	}
+
+	return family.String()
+}
+
+// minorVersion returns the version without its patch number, e.g. v0.55 for v0.55.2.
+// Versions that are not major.minor.patch are returned unchanged.
+func minorVersion(version string) string {
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 || strings.ContainsAny(parts[2], "-+") {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"io"
+	"sync"
+)
+
+// tailBuffer keeps the most recent bytes written to it, up to its size
+type tailBuffer struct {
+	mutex sync.Mutex
+	size  int
+	data  []byte
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+// Write implements the io.Writer interface, discarding the oldest bytes above the size
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.data = append(b.data, p...)
+	if excess := len(b.data) - b.size; excess > 0 {
+		b.data = append(b.data[:0], b.data[excess:]...)
+	}
+
+	return len(p), nil
+}
+
+// Bytes returns a copy of the bytes kept
+func (b *tailBuffer) Bytes() []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return append([]byte{}, b.data...)
+}
+
+// teeWriter returns a writer to the buffer and to the writer, if any
+func teeWriter(buffer *tailBuffer, writer io.Writer) io.Writer {
+	if writer == nil {
+		return buffer
+	}
+	return io.MultiWriter(buffer, writer)
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package runner
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// terminate asks the process to exit
+func terminate(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
+
+// maxRSS returns the maximum resident set size of the process, in bytes
+func maxRSS(state *os.ProcessState) int64 {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+
+	//nolint:unconvert // the type of the field depends on the architecture
+	rss := int64(usage.Maxrss)
+
+	// reported in bytes in macOS and in kilobytes in other systems
+	if runtime.GOOS == "darwin" {
+		return rss
+	}
+	return rss * 1024
+}
@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package runner
+
+import (
+	"os"
+)
+
+// terminate kills the process, as windows doesn't support asking it to exit
+func terminate(process *os.Process) error {
+	return process.Kill()
+}
+
+// maxRSS returns the maximum resident set size of the process, in bytes.
+// It is not reported in windows.
+func maxRSS(*os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,227 @@
+// Package runner runs the k6 binaries provided by a k6provider, managing the lifecycle of their
+// processes: their output is captured, their exit code is classified, they are stopped
+// gracefully and their resource usage is reported.
+//
+//	binary, err := provider.GetBinary(ctx, deps)
+//	...
+//	result, err := runner.Run(ctx, binary, runner.Options{Args: []string{"run", "script.js"}})
+//	...
+//	if result.Status == runner.StatusThresholdsFailed {
+//	    ...
+//	}
+//
+// The processes are stopped by sending them SIGTERM, so k6 can stop the test and report the
+// results, and they are killed if they don't exit within a grace period. In windows, where
+// SIGTERM is not supported, they are killed.
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/k6provider"
+)
+
+const (
+	// DefaultGracePeriod time a process has for exiting after being stopped, before being killed
+	DefaultGracePeriod = 30 * time.Second
+	// DefaultMaxOutput maximum number of bytes of each output of a process kept in its result
+	DefaultMaxOutput = 1 << 20
+)
+
+var (
+	// ErrStart is returned when the binary cannot be started
+	ErrStart = errors.New("starting binary")
+	// ErrRun is returned when the process of the binary cannot be waited for
+	ErrRun = errors.New("running binary")
+)
+
+// Options for running a binary
+type Options struct {
+	// Args arguments for the binary, such as "run", "script.js"
+	Args []string
+	// Env environment variables, as "KEY=value", added to the environment of the current process
+	Env []string
+	// Dir working directory of the process. Defaults to the working directory of the current process
+	Dir string
+	// Stdin standard input of the process. Defaults to no input
+	Stdin io.Reader
+	// Stdout if set, receives the standard output of the process as it is produced, besides
+	// being captured in the result
+	Stdout io.Writer
+	// Stderr if set, receives the standard error of the process as it is produced, besides
+	// being captured in the result
+	Stderr io.Writer
+	// GracePeriod time the process has for exiting after being stopped, before being killed.
+	// Defaults to DefaultGracePeriod
+	GracePeriod time.Duration
+	// MaxOutput maximum number of bytes of each output kept in the result, the most recent ones.
+	// Defaults to DefaultMaxOutput
+	MaxOutput int
+}
+
+// Usage is the resource usage of a process
+type Usage struct {
+	// UserTime CPU time spent in user mode
+	UserTime time.Duration
+	// SystemTime CPU time spent in kernel mode
+	SystemTime time.Duration
+	// MaxRSS maximum resident set size, in bytes. Zero if not reported by the os
+	MaxRSS int64
+}
+
+// Result is the result of running a binary
+type Result struct {
+	// ExitCode of the process, or -1 if it was terminated by a signal
+	ExitCode int
+	// Status classification of the exit code
+	Status Status
+	// Stdout standard output of the process, up to Options.MaxOutput bytes
+	Stdout []byte
+	// Stderr standard error of the process, up to Options.MaxOutput bytes
+	Stderr []byte
+	// Duration time since the process was started until it exited
+	Duration time.Duration
+	// Usage resource usage of the process
+	Usage Usage
+}
+
+// Process is a running binary
+type Process struct {
+	binary  k6provider.K6Binary
+	cmd     *exec.Cmd
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stdout  *tailBuffer
+	stderr  *tailBuffer
+	started time.Time
+	stopped atomic.Bool
+	done    chan struct{}
+	result  Result
+	err     error
+}
+
+// Run runs the binary with the options and waits for its process to exit. The process is
+// stopped if the context is done. See [Process.Stop]
+//
+// Exiting with a non-zero exit code is not an error, it is reported in the result.
+func Run(ctx context.Context, binary k6provider.K6Binary, options Options) (Result, error) {
+	process, err := Start(ctx, binary, options)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return process.Wait()
+}
+
+// Start starts the binary with the options. The process is stopped if the context is done
+// before it exits. See [Process.Stop]
+//
+// If the binary cannot be executed, the failure is reported to the cache it was provided from,
+// so a corrupted binary is eventually evicted (see [k6provider.K6Binary.ReportFailure]).
+func Start(ctx context.Context, binary k6provider.K6Binary, options Options) (*Process, error) {
+	if options.GracePeriod == 0 {
+		options.GracePeriod = DefaultGracePeriod
+	}
+	if options.MaxOutput == 0 {
+		options.MaxOutput = DefaultMaxOutput
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	process := &Process{
+		binary: binary,
+		ctx:    ctx,
+		cancel: cancel,
+		stdout: newTailBuffer(options.MaxOutput),
+		stderr: newTailBuffer(options.MaxOutput),
+		done:   make(chan struct{}),
+	}
+
+	cmd := binary.Command(ctx, options.Args...)
+	cmd.Env = append(os.Environ(), options.Env...)
+	cmd.Dir = options.Dir
+	cmd.Stdin = options.Stdin
+	cmd.Stdout = teeWriter(process.stdout, options.Stdout)
+	cmd.Stderr = teeWriter(process.stderr, options.Stderr)
+	// when the context is done, the process is terminated and, if it doesn't exit within the
+	// grace period, killed
+	cmd.Cancel = func() error { return terminate(cmd.Process) }
+	cmd.WaitDelay = options.GracePeriod
+
+	process.started = time.Now()
+	if err := cmd.Start(); err != nil {
+		cancel()
+		binary.ReportFailure(err)
+		return nil, fmt.Errorf("%w: %w", ErrStart, err)
+	}
+	process.cmd = cmd
+
+	go process.wait()
+
+	return process, nil
+}
+
+// Pid returns the id of the process
+func (p *Process) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+// Stop stops the process gracefully, sending it SIGTERM and killing it if it doesn't exit
+// within the grace period. Returns without waiting for the process to exit. See [Process.Wait]
+func (p *Process) Stop() {
+	p.stopped.Store(true)
+	p.cancel()
+}
+
+// Done returns a channel that is closed when the process exits
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for the process to exit and returns its result
+func (p *Process) Wait() (Result, error) {
+	<-p.done
+	return p.result, p.err
+}
+
+// wait waits for the process to exit and records its result
+func (p *Process) wait() {
+	defer close(p.done)
+
+	err := p.cmd.Wait()
+	stopped := p.stopped.Load() || p.ctx.Err() != nil
+	p.cancel()
+
+	state := p.cmd.ProcessState
+	if state == nil {
+		p.err = fmt.Errorf("%w: %w", ErrRun, err)
+		return
+	}
+
+	p.result = Result{
+		ExitCode: state.ExitCode(),
+		Stdout:   p.stdout.Bytes(),
+		Stderr:   p.stderr.Bytes(),
+		Duration: time.Since(p.started),
+		Usage: Usage{
+			UserTime:   state.UserTime(),
+			SystemTime: state.SystemTime(),
+			MaxRSS:     maxRSS(state),
+		},
+	}
+	p.result.Status = classify(p.result.ExitCode, stopped)
+
+	// the process exited, but its output could not be copied completely. Failing to close the
+	// output after the grace period, because a child process kept it open, is not an error
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay) {
+		p.err = fmt.Errorf("%w: %w", ErrRun, err)
+	}
+}
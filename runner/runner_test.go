@@ -0,0 +1,211 @@
+//go:build !windows
+// +build !windows
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6provider"
+)
+
+// fakeBinary returns a binary that runs the shell script
+func fakeBinary(t *testing.T, script string) k6provider.K6Binary {
+	t.Helper()
+
+	binPath := filepath.Join(t.TempDir(), "k6")
+	if err := os.WriteFile(binPath, []byte("#!/bin/sh\n"+script+"\n"), 0o700); err != nil { //nolint:gosec
+		t.Fatalf("test setup: %v", err)
+	}
+
+	return k6provider.K6Binary{Path: binPath}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title        string
+		script       string
+		maxOutput    int
+		expectCode   int
+		expectStatus Status
+		expectStdout string
+		expectStderr string
+	}{
+		{
+			title:        "success",
+			script:       `echo "$1 $2"; echo warning >&2`,
+			expectCode:   0,
+			expectStatus: StatusSuccess,
+			expectStdout: "run script.js\n",
+			expectStderr: "warning\n",
+		},
+		{
+			title:        "thresholds failed",
+			script:       "exit 99",
+			expectCode:   99,
+			expectStatus: StatusThresholdsFailed,
+		},
+		{
+			title:        "script exception",
+			script:       "echo exception >&2; exit 107",
+			expectCode:   107,
+			expectStatus: StatusScriptError,
+			expectStderr: "exception\n",
+		},
+		{
+			title:        "other failure",
+			script:       "exit 1",
+			expectCode:   1,
+			expectStatus: StatusFailed,
+		},
+		{
+			title:        "output truncated",
+			script:       "printf 0123456789",
+			maxOutput:    4,
+			expectCode:   0,
+			expectStatus: StatusSuccess,
+			expectStdout: "6789",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			binary := fakeBinary(t, tc.script)
+			options := Options{Args: []string{"run", "script.js"}, MaxOutput: tc.maxOutput}
+
+			result, err := Run(context.TODO(), binary, options)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if result.ExitCode != tc.expectCode {
+				t.Fatalf("expected %d got %d", tc.expectCode, result.ExitCode)
+			}
+			if result.Status != tc.expectStatus {
+				t.Fatalf("expected %s got %s", tc.expectStatus, result.Status)
+			}
+			if string(result.Stdout) != tc.expectStdout {
+				t.Fatalf("expected %q got %q", tc.expectStdout, result.Stdout)
+			}
+			if string(result.Stderr) != tc.expectStderr {
+				t.Fatalf("expected %q got %q", tc.expectStderr, result.Stderr)
+			}
+			if result.Duration <= 0 {
+				t.Fatalf("expected duration got %v", result.Duration)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title      string
+		script     string
+		expectCode int
+	}{
+		{
+			title:      "exits when terminated",
+			script:     "trap 'exit 105' TERM; echo started; while true; do sleep 0.1; done",
+			expectCode: 105,
+		},
+		{
+			title:      "killed after grace period",
+			script:     "trap '' TERM; echo started; while true; do sleep 0.1; done",
+			expectCode: -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			started := make(chan struct{})
+			options := Options{GracePeriod: 500 * time.Millisecond, Stdout: startedWriter(started)}
+
+			process, err := Start(context.TODO(), fakeBinary(t, tc.script), options)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			<-started
+			process.Stop()
+
+			result, err := process.Wait()
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if result.ExitCode != tc.expectCode {
+				t.Fatalf("expected %d got %d", tc.expectCode, result.ExitCode)
+			}
+			if result.Status != StatusStopped {
+				t.Fatalf("expected %s got %s", StatusStopped, result.Status)
+			}
+		})
+	}
+}
+
+func TestStartError(t *testing.T) {
+	t.Parallel()
+
+	binary := k6provider.K6Binary{Path: filepath.Join(t.TempDir(), "missing")}
+
+	_, err := Run(context.TODO(), binary, Options{})
+	if !errors.Is(err, ErrStart) {
+		t.Fatalf("expected %v got %v", ErrStart, err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		exitCode int
+		stopped  bool
+		expect   Status
+	}{
+		{exitCode: 0, stopped: true, expect: StatusSuccess},
+		{exitCode: 99, stopped: true, expect: StatusStopped},
+		{exitCode: -1, stopped: false, expect: StatusKilled},
+		{exitCode: 100, stopped: false, expect: StatusTimeout},
+		{exitCode: 104, stopped: false, expect: StatusInvalidConfig},
+		{exitCode: 105, stopped: false, expect: StatusAborted},
+		{exitCode: 97, stopped: false, expect: StatusCloudFailed},
+		{exitCode: 109, stopped: false, expect: StatusCrashed},
+	}
+
+	for _, tc := range testCases {
+		if status := classify(tc.exitCode, tc.stopped); status != tc.expect {
+			t.Fatalf("exit code %d stopped %v: expected %s got %s", tc.exitCode, tc.stopped, tc.expect, status)
+		}
+	}
+}
+
+// startedWriter returns a writer that closes the channel when first written
+func startedWriter(started chan struct{}) writerFunc {
+	return func(p []byte) (int, error) {
+		select {
+		case <-started:
+		default:
+			close(started)
+		}
+		return len(p), nil
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
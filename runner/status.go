@@ -0,0 +1,76 @@
+package runner
+
+// Status is the classification of the exit code of a k6 process
+type Status string
+
+const (
+	// StatusSuccess the process exited successfully
+	StatusSuccess Status = "success"
+	// StatusThresholdsFailed the test ran, but some thresholds failed
+	StatusThresholdsFailed Status = "thresholds_failed"
+	// StatusTimeout the setup, the teardown or another operation of the test timed out
+	StatusTimeout Status = "timeout"
+	// StatusScriptError the script threw an exception
+	StatusScriptError Status = "script_error"
+	// StatusAborted the test was aborted by the script or by an external signal
+	StatusAborted Status = "aborted"
+	// StatusInvalidConfig the options or the arguments are invalid
+	StatusInvalidConfig Status = "invalid_config"
+	// StatusCloudFailed the test run in the cloud failed or its progress could not be obtained
+	StatusCloudFailed Status = "cloud_failed"
+	// StatusCrashed the process crashed
+	StatusCrashed Status = "crashed"
+	// StatusStopped the process was stopped before completing. See [Process.Stop]
+	StatusStopped Status = "stopped"
+	// StatusKilled the process was terminated by a signal it was not sent by the runner
+	StatusKilled Status = "killed"
+	// StatusFailed the process failed for any other reason
+	StatusFailed Status = "failed"
+)
+
+// exit codes of k6, as defined in go.k6.io/k6/errext/exitcodes
+const (
+	exitCloudTestRunFailed       = 97
+	exitCloudFailedToGetProgress = 98
+	exitThresholdsHaveFailed     = 99
+	exitSetupTimeout             = 100
+	exitTeardownTimeout          = 101
+	exitGenericTimeout           = 102
+	exitInvalidConfig            = 104
+	exitExternalAbort            = 105
+	exitScriptException          = 107
+	exitScriptAborted            = 108
+	exitGoPanic                  = 109
+)
+
+// classify returns the status of the exit code. If the process was stopped, any
+// exit code but success is reported as stopped.
+func classify(exitCode int, stopped bool) Status {
+	switch {
+	case exitCode == 0:
+		return StatusSuccess
+	case stopped:
+		return StatusStopped
+	}
+
+	switch exitCode {
+	case -1:
+		return StatusKilled
+	case exitThresholdsHaveFailed:
+		return StatusThresholdsFailed
+	case exitSetupTimeout, exitTeardownTimeout, exitGenericTimeout:
+		return StatusTimeout
+	case exitScriptException:
+		return StatusScriptError
+	case exitExternalAbort, exitScriptAborted:
+		return StatusAborted
+	case exitInvalidConfig:
+		return StatusInvalidConfig
+	case exitCloudTestRunFailed, exitCloudFailedToGetProgress:
+		return StatusCloudFailed
+	case exitGoPanic:
+		return StatusCrashed
+	default:
+		return StatusFailed
+	}
+}
@@ -0,0 +1,128 @@
+//go:build !windows
+// +build !windows
+
+package k6provider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// errUnsafeDir indicates a directory can be replaced or modified by other users
+var errUnsafeDir = errors.New("unsafe directory")
+
+// prepareSafeDir creates the directory and any missing parent without following symbolic
+// links, and checks it cannot be replaced or modified by other users:
+//   - every directory in its path writable by other users must have the sticky bit set (e.g. /tmp)
+//   - the entries in those directories must not be symbolic links and must be owned by the
+//     current user or root, so they cannot be pre-created by other users
+//   - the directory must be owned by the current user and not be writable by other users
+//
+// Symbolic links in directories not writable by other users are followed.
+// Failing to create the directory is not an error, as it is reported when it is used.
+func prepareSafeDir(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	path := string(filepath.Separator)
+	parent, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range strings.Split(strings.TrimPrefix(dir, path), string(filepath.Separator)) {
+		if name == "" {
+			continue
+		}
+
+		// other users can't replace the entries of public directories with the sticky bit
+		public := parent.Mode().Perm()&0o002 != 0
+		if public && parent.Mode()&os.ModeSticky == 0 {
+			return fmt.Errorf("%w: %s is writable by other users", errUnsafeDir, path)
+		}
+
+		entry := filepath.Join(path, name)
+		info, err := lstatOrCreate(entry)
+		if err != nil {
+			// the directory cannot be used, which is reported when using it
+			return nil //nolint:nilerr
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if public {
+				return fmt.Errorf("%w: %s is a symbolic link in a public directory", errUnsafeDir, entry)
+			}
+			if entry, err = filepath.EvalSymlinks(entry); err != nil {
+				return err
+			}
+			if info, err = os.Lstat(entry); err != nil {
+				return err
+			}
+		}
+
+		if owner := fileOwner(info); public && owner != os.Getuid() && owner != 0 {
+			return fmt.Errorf("%w: %s is owned by another user", errUnsafeDir, entry)
+		}
+
+		path, parent = entry, info
+	}
+
+	return checkDirOwner(path)
+}
+
+// lstatOrCreate returns the information of the entry, creating it as a directory if it
+// doesn't exist. Symbolic links are not followed.
+func lstatOrCreate(entry string) (os.FileInfo, error) {
+	info, err := os.Lstat(entry)
+	if !os.IsNotExist(err) {
+		return info, err
+	}
+
+	// mkdir fails if the entry exists, even as a symbolic link
+	if err = os.Mkdir(entry, cacheDirPerm); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+
+	return os.Lstat(entry)
+}
+
+// checkDirOwner checks the directory is owned by the current user and is not writable by other
+// users. The directory is opened without following symbolic links, so it cannot be replaced by
+// one after being checked.
+func checkDirOwner(dir string) error {
+	file, err := os.OpenFile(dir, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_DIRECTORY, 0) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUnsafeDir, err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if fileOwner(info) != os.Getuid() {
+		return fmt.Errorf("%w: %s is not owned by the current user", errUnsafeDir, dir)
+	}
+	if info.Mode().Perm()&0o002 != 0 {
+		return fmt.Errorf("%w: %s is writable by other users", errUnsafeDir, dir)
+	}
+
+	return nil
+}
+
+// fileOwner returns the id of the user owning the file, or -1 if it is not known
+func fileOwner(info os.FileInfo) int {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1
+	}
+
+	return int(stat.Uid)
+}
@@ -0,0 +1,139 @@
+//go:build !windows
+// +build !windows
+
+package k6provider
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareSafeDir(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title     string
+		setup     func(parent string) error
+		dir       string
+		expectErr error
+	}{
+		{
+			title:     "new directory",
+			setup:     func(string) error { return nil },
+			dir:       "k6provider/cache",
+			expectErr: nil,
+		},
+		{
+			title:     "public parent with sticky bit",
+			setup:     func(parent string) error { return os.Chmod(parent, 0o777|os.ModeSticky) },
+			dir:       "k6provider/cache",
+			expectErr: nil,
+		},
+		{
+			title:     "public parent without sticky bit",
+			setup:     func(parent string) error { return os.Chmod(parent, 0o777) }, //nolint:gosec
+			dir:       "k6provider/cache",
+			expectErr: errUnsafeDir,
+		},
+		{
+			title: "symbolic link in public parent",
+			setup: func(parent string) error {
+				if err := os.Chmod(parent, 0o777|os.ModeSticky); err != nil {
+					return err
+				}
+				if err := os.Mkdir(filepath.Join(parent, "target"), 0o700); err != nil {
+					return err
+				}
+				return os.Symlink(filepath.Join(parent, "target"), filepath.Join(parent, "k6provider"))
+			},
+			dir:       "k6provider/cache",
+			expectErr: errUnsafeDir,
+		},
+		{
+			title: "symbolic link in private parent",
+			setup: func(parent string) error {
+				if err := os.Mkdir(filepath.Join(parent, "target"), 0o700); err != nil {
+					return err
+				}
+				return os.Symlink(filepath.Join(parent, "target"), filepath.Join(parent, "k6provider"))
+			},
+			dir:       "k6provider/cache",
+			expectErr: nil,
+		},
+		{
+			title:     "public directory",
+			setup:     func(parent string) error { return os.Mkdir(filepath.Join(parent, "cache"), 0o777) },
+			dir:       "cache",
+			expectErr: errUnsafeDir,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			parent := t.TempDir()
+			if err := tc.setup(parent); err != nil {
+				t.Fatalf("test setup %v", err)
+			}
+			// mkdir is affected by the umask
+			if err := os.Chmod(filepath.Join(parent, "cache"), 0o777); err != nil && !os.IsNotExist(err) {
+				t.Fatalf("test setup %v", err)
+			}
+
+			dir := filepath.Join(parent, tc.dir)
+			err := prepareSafeDir(dir)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				t.Fatalf("expected directory %s to be created got %v", dir, err)
+			}
+		})
+	}
+}
+
+func TestStrictPermissions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title     string
+		strict    bool
+		expectErr error
+	}{
+		{title: "strict", strict: true, expectErr: ErrConfig},
+		{title: "not strict", strict: false, expectErr: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			binDir := filepath.Join(t.TempDir(), "cache")
+			if err := os.Mkdir(binDir, 0o700); err != nil {
+				t.Fatalf("test setup %v", err)
+			}
+			if err := os.Chmod(binDir, 0o777); err != nil { //nolint:gosec
+				t.Fatalf("test setup %v", err)
+			}
+
+			provider, err := NewProvider(Config{
+				BinDir:            binDir,
+				BuildServiceURL:   "http://localhost:8000",
+				StrictPermissions: tc.strict,
+			})
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err == nil {
+				_ = provider.Close()
+			}
+		})
+	}
+}
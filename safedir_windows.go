@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package k6provider
+
+// prepareSafeDir is not supported in windows, where the temporary directory is private to each user
+func prepareSafeDir(string) error {
+	return nil
+}
@@ -0,0 +1,55 @@
+package k6provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+)
+
+// scopeKeyLen is the length of the key used for identifying a cache scope in the cache path
+const scopeKeyLen = 12
+
+// scopeKey returns the key for a cache scope. The key is a hash of the scope, so it can
+// be used safely in a path regardless of the scope's content.
+func scopeKey(scope string) string {
+	if scope == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(scope))
+	return hex.EncodeToString(hash[:])[:scopeKeyLen]
+}
+
+// artifactDir returns the directory of an artifact in a binary directory. If the cache
+// is scoped, the scope's key is added to the artifact ID, followed by the key of the
+// options the artifact was built with, if any.
+//
+// With the checksum layout, the directory is named after the artifact's checksum, which
+// identifies its content regardless of the scope. Artifacts without a valid checksum use the
+// default layout.
+func (p *Provider) artifactDir(dir string, artifact Artifact) string {
+	if p.config.ChecksumLayout && isChecksum(artifact.Checksum) {
+		return filepath.Join(dir, strings.ToLower(artifact.Checksum))
+	}
+
+	name := artifact.ID
+	if scope := p.scope(); scope != "" {
+		name += "-" + scope
+	}
+	if options := artifact.BuildOptions; options != nil && !options.IsZero() {
+		if channel := options.channel(); channel != "" {
+			name += "-" + channel
+		}
+		name += "-" + options.key()
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// isChecksum returns true if the checksum is a sha256 checksum in hex, so it can be used
+// safely in a path
+func isChecksum(checksum string) bool {
+	decoded, err := hex.DecodeString(checksum)
+	return err == nil && len(decoded) == sha256.Size
+}
@@ -0,0 +1,172 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestCacheScope(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("k6"))
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", URL: store.URL}, nil
+		},
+	)
+
+	binDir := t.TempDir()
+	paths := map[string]string{}
+	for _, scope := range []string{"", "https://staging.example.com", "https://prod.example.com"} {
+		provider := newTestProvider(t, buildSrv, binDir)
+		provider.cacheScope = scopeKey(scope)
+
+		binary, err := provider.GetBinary(context.TODO(), nil)
+		if err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+
+		if other, found := paths[binary.Path]; found {
+			t.Fatalf("scopes %q and %q share path %s", scope, other, binary.Path)
+		}
+		paths[binary.Path] = scope
+
+		if filepath.Dir(filepath.Dir(binary.Path)) != binDir {
+			t.Fatalf("expected binary in %s got %s", binDir, binary.Path)
+		}
+	}
+}
+
+func TestChecksumLayout(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	testCases := []struct {
+		title    string
+		checksum string
+		expect   string
+	}{
+		{title: "valid checksum", checksum: strings.ToUpper(checksum), expect: checksum},
+		{title: "no checksum", checksum: "", expect: "artifact"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: tc.checksum}, nil
+				},
+			)
+
+			binDir := t.TempDir()
+			provider := newTestProvider(t, buildSrv, binDir)
+			provider.config.ChecksumLayout = true
+			provider.cacheScope = scopeKey("https://staging.example.com")
+
+			binary, err := provider.GetBinary(context.TODO(), nil)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			expected := filepath.Join(binDir, tc.expect, k6Binary)
+			if tc.checksum == "" {
+				expected = filepath.Join(binDir, tc.expect+"-"+provider.cacheScope, k6Binary)
+			}
+			if binary.Path != expected {
+				t.Fatalf("expected %s got %s", expected, binary.Path)
+			}
+		})
+	}
+}
+
+func TestLazyCacheScope(t *testing.T) {
+	t.Parallel()
+
+	binDir := t.TempDir()
+	buildSrvURL := "http://localhost:8000"
+
+	// a binary cached without scope
+	if err := os.MkdirAll(filepath.Join(binDir, "artifact"), 0o700); err != nil {
+		t.Fatalf("test setup: creating dir %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "artifact", k6Binary), []byte("k6"), 0o600); err != nil {
+		t.Fatalf("test setup: writing binary %v", err)
+	}
+
+	provider, err := NewProvider(Config{
+		BinDir:                   binDir,
+		BuildServiceURL:          buildSrvURL,
+		LazyInit:                 true,
+		ScopeCacheByBuildService: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	t.Cleanup(func() { _ = provider.Close() })
+
+	// the build services are configured concurrently with the lookups
+	group := sync.WaitGroup{}
+	for range 4 {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			_, found, lookupErr := provider.lookupBinary(Artifact{ID: "artifact"})
+			if lookupErr != nil || found {
+				t.Errorf("expected binary not found got %t %v", found, lookupErr)
+			}
+		}()
+	}
+	group.Wait()
+
+	if scope := provider.scope(); scope != scopeKey(buildSrvURL) {
+		t.Fatalf("expected scope %s got %s", scopeKey(buildSrvURL), scope)
+	}
+}
+
+func TestFallbackCacheScope(t *testing.T) {
+	t.Parallel()
+
+	binDir := t.TempDir()
+	primary := "http://primary:8000"
+
+	provider, err := NewProvider(Config{
+		BinDir:                   binDir,
+		BuildServiceURL:          primary,
+		BuildServiceURLs:         []string{"http://fallback:8000"},
+		ScopeCacheByBuildService: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	t.Cleanup(func() { _ = provider.Close() })
+
+	// the artifacts obtained from the fallback share the scope of the primary
+	artifact := Artifact{ID: "artifact", BuildService: "http://fallback:8000"}
+	expected := filepath.Join(binDir, "artifact-"+scopeKey(primary))
+	if artifactDir := provider.artifactDir(binDir, artifact); artifactDir != expected {
+		t.Fatalf("expected %s got %s", expected, artifactDir)
+	}
+}
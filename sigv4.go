@@ -0,0 +1,268 @@
+package k6provider
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// sigV4Algorithm is the signing algorithm of AWS Signature Version 4
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	// sigV4DateFormat is the format of the time of the signature
+	sigV4DateFormat = "20060102T150405Z"
+	// emptyPayloadHash is the sha256 checksum of an empty body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// awsCredentials are the credentials used for signing requests to AWS
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// sigV4Signer signs requests with AWS Signature Version 4
+type sigV4Signer struct {
+	service     string
+	region      string
+	credentials func() (awsCredentials, error)
+	now         func() time.Time
+}
+
+// NewSigV4Signer returns a request signer (see [DownloadConfig.RequestSigner]) that signs the
+// requests with AWS Signature Version 4, for artifact stores such as S3 buckets or API gateways
+// that require it. The service is the AWS service's signing name (e.g. "s3"). If region is
+// empty, the AWS_REGION or AWS_DEFAULT_REGION environment variable is used.
+//
+// The ambient AWS credentials are used: those in the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_SESSION_TOKEN environment variables, or the profile selected by AWS_PROFILE ("default"
+// if not set) in the shared credentials file (AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials).
+// The credentials are read when signing each request, so rotated credentials are used.
+func NewSigV4Signer(service string, region string) (func(*http.Request) error, error) {
+	if service == "" {
+		return nil, NewWrappedError(ErrConfig, errors.New("sigv4: service not specified"))
+	}
+
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, NewWrappedError(ErrConfig, errors.New("sigv4: region not specified"))
+	}
+
+	if _, err := ambientAWSCredentials(); err != nil {
+		return nil, NewWrappedError(ErrConfig, err)
+	}
+
+	signer := &sigV4Signer{
+		service:     service,
+		region:      region,
+		credentials: ambientAWSCredentials,
+		now:         time.Now,
+	}
+
+	return signer.sign, nil
+}
+
+// sign adds the signature of the request in its Authorization header. Requests are signed
+// without body, as download requests don't have one.
+func (s *sigV4Signer) sign(req *http.Request) error {
+	credentials, err := s.credentials()
+	if err != nil {
+		return err
+	}
+
+	now := s.now().UTC()
+	amzDate := now.Format(sigV4DateFormat)
+	scope := strings.Join([]string{now.Format("20060102"), s.region, s.service, "aws4_request"}, "/")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.sessionToken)
+	}
+	// s3 requires the checksum of the payload
+	if s.service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	}
+
+	canonicalHeaders, signedHeaders := sigV4Headers(req)
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		sigV4Query(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + credentials.secretAccessKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, credentials.accessKeyID, scope, signedHeaders, signature,
+	))
+
+	return nil
+}
+
+// sigV4Headers returns the canonical headers of the request and the list of signed headers:
+// the host and the headers set by the signer
+func sigV4Headers(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonical := strings.Builder{}
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// sigV4Query returns the canonical query string: the parameters sorted by name and value,
+// encoded as in RFC 3986
+func sigV4Query(query url.Values) string {
+	params := []string{}
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, sigV4Escape(name)+"="+sigV4Escape(value))
+		}
+	}
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// sigV4Escape encodes all the characters except the unreserved ones in RFC 3986
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// ambientAWSCredentials returns the AWS credentials from the environment or from the
+// shared credentials file
+func ambientAWSCredentials() (awsCredentials, error) {
+	credentials := awsCredentials{
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if credentials.accessKeyID != "" && credentials.secretAccessKey != "" {
+		return credentials, nil
+	}
+
+	credentialsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, fmt.Errorf("sigv4: no credentials found: %w", err)
+		}
+		credentialsFile = filepath.Join(home, ".aws", "credentials")
+	}
+
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	return readAWSCredentials(credentialsFile, profile)
+}
+
+// readAWSCredentials reads the credentials of a profile from a shared credentials file
+func readAWSCredentials(path string, profile string) (awsCredentials, error) {
+	file, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("sigv4: no credentials found: %w", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	credentials := awsCredentials{}
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found || section != profile {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			credentials.accessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			credentials.secretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			credentials.sessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return awsCredentials{}, err
+	}
+
+	if credentials.accessKeyID == "" || credentials.secretAccessKey == "" {
+		return awsCredentials{}, fmt.Errorf("sigv4: no credentials for profile %q in %s", profile, path)
+	}
+
+	return credentials, nil
+}
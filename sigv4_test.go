@@ -0,0 +1,169 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigV4Signer(t *testing.T) {
+	t.Parallel()
+
+	// test vectors from the AWS Signature Version 4 test suite
+	testCases := []struct {
+		title           string
+		url             string
+		expectSignature string
+	}{
+		{
+			title:           "get vanilla",
+			url:             "https://example.amazonaws.com/",
+			expectSignature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			title:           "query order",
+			url:             "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			expectSignature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			signer := &sigV4Signer{
+				service: "service",
+				region:  "us-east-1",
+				credentials: func() (awsCredentials, error) {
+					return awsCredentials{
+						accessKeyID:     "AKIDEXAMPLE",
+						secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+					}, nil
+				},
+				now: func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+			}
+
+			req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, tc.url, nil)
+			if err != nil {
+				t.Fatalf("test setup %v", err)
+			}
+
+			if err = signer.sign(req); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			expect := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=" + tc.expectSignature
+			if auth := req.Header.Get("Authorization"); auth != expect {
+				t.Fatalf("expected %q got %q", expect, auth)
+			}
+		})
+	}
+}
+
+func TestReadAWSCredentials(t *testing.T) {
+	t.Parallel()
+
+	credentialsFile := filepath.Join(t.TempDir(), "credentials")
+	content := strings.Join([]string{
+		"[default]",
+		"aws_access_key_id = default-key",
+		"aws_secret_access_key = default-secret",
+		"",
+		"# ci profile",
+		"[ci]",
+		"aws_access_key_id=ci-key",
+		"aws_secret_access_key=ci-secret",
+		"aws_session_token=ci-token",
+	}, "\n")
+	if err := os.WriteFile(credentialsFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("test setup %v", err)
+	}
+
+	testCases := []struct {
+		title     string
+		profile   string
+		expect    awsCredentials
+		expectErr bool
+	}{
+		{
+			title:   "default profile",
+			profile: "default",
+			expect:  awsCredentials{accessKeyID: "default-key", secretAccessKey: "default-secret"},
+		},
+		{
+			title:   "profile with session token",
+			profile: "ci",
+			expect:  awsCredentials{accessKeyID: "ci-key", secretAccessKey: "ci-secret", sessionToken: "ci-token"},
+		},
+		{
+			title:     "missing profile",
+			profile:   "other",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			credentials, err := readAWSCredentials(credentialsFile, tc.profile)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %v got %v", tc.expectErr, err)
+			}
+			if credentials != tc.expect {
+				t.Fatalf("expected %+v got %+v", tc.expect, credentials)
+			}
+		})
+	}
+}
+
+func TestRequestSigner(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title     string
+		signerErr error
+		expectErr error
+	}{
+		{title: "request signed", signerErr: nil, expectErr: nil},
+		{title: "signing fails", signerErr: errors.New("no credentials"), expectErr: ErrDownloadPermanent},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "signed" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				_, _ = w.Write([]byte("k6"))
+			}))
+			t.Cleanup(store.Close)
+
+			downloader, err := newDownloader(DownloadConfig{
+				RequestSigner: func(req *http.Request) error {
+					req.Header.Set("Authorization", "signed")
+					return tc.signerErr
+				},
+			}, nil)
+			if err != nil {
+				t.Fatalf("test setup %v", err)
+			}
+
+			err = downloader.download(context.TODO(), store.URL, io.Discard)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
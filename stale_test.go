@@ -0,0 +1,184 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+)
+
+func TestStaleIfError(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	unavailable := errors.New("503 Service Unavailable")
+
+	testCases := []struct {
+		title        string
+		staleIfError time.Duration
+		resolvedAgo  time.Duration
+		buildErr     error
+		expectErr    error
+	}{
+		{
+			title:        "disabled",
+			staleIfError: 0,
+			resolvedAgo:  time.Minute,
+			buildErr:     unavailable,
+			expectErr:    ErrServiceUnavailable,
+		},
+		{
+			title:        "resolved within limit",
+			staleIfError: time.Hour,
+			resolvedAgo:  time.Minute,
+			buildErr:     unavailable,
+			expectErr:    nil,
+		},
+		{
+			title:        "resolved before limit",
+			staleIfError: time.Hour,
+			resolvedAgo:  2 * time.Hour,
+			buildErr:     unavailable,
+			expectErr:    ErrServiceUnavailable,
+		},
+		{
+			title:        "network unreachable",
+			staleIfError: time.Hour,
+			resolvedAgo:  time.Minute,
+			buildErr:     &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			expectErr:    nil,
+		},
+		{
+			title:        "authentication failure",
+			staleIfError: time.Hour,
+			resolvedAgo:  time.Minute,
+			buildErr:     errors.New("401 Unauthorized"),
+			expectErr:    ErrBuild,
+		},
+		{
+			title:        "invalid request",
+			staleIfError: time.Hour,
+			resolvedAgo:  time.Minute,
+			buildErr:     NewWrappedError(ErrInvalidParameters, errors.New("unknown extension")),
+			expectErr:    ErrInvalidParameters,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write(content)
+			}))
+			t.Cleanup(store.Close)
+
+			available := atomic.Bool{}
+			available.Store(true)
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					if !available.Load() {
+						return k6build.Artifact{}, tc.buildErr
+					}
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+				},
+			)
+
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+			provider.config.StaleIfError = tc.staleIfError
+
+			binary, err := provider.GetBinary(context.TODO(), nil)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			// set when the request was resolved
+			artifactDir := filepath.Dir(binary.Path)
+			metadata, err := readMetadata(artifactDir)
+			if err != nil {
+				t.Fatalf("reading metadata %v", err)
+			}
+			for request := range metadata.Resolved {
+				metadata.Resolved[request] = time.Now().Add(-tc.resolvedAgo)
+			}
+			if err = saveMetadata(artifactDir, metadata); err != nil {
+				t.Fatalf("writing metadata %v", err)
+			}
+
+			available.Store(false)
+
+			_, err = provider.GetBinary(context.TODO(), nil)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	builds := atomic.Int32{}
+	release := make(chan struct{})
+	buildSrv := buildServiceFunc(
+		func(ctx context.Context, _ string, _ string, _ []k6build.Dependency) (k6build.Artifact, error) {
+			// the build service is slow after the first request
+			if builds.Add(1) > 1 {
+				select {
+				case <-release:
+				case <-ctx.Done():
+					return k6build.Artifact{}, ctx.Err()
+				}
+			}
+			return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+		},
+	)
+
+	binDir := t.TempDir()
+	provider := newTestProvider(t, buildSrv, binDir)
+	provider.config.StaleWhileRevalidate = time.Hour
+
+	if _, err := provider.GetBinary(context.TODO(), nil); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// the binary is provided without waiting for the build service
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := provider.GetBinary(ctx, nil); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// the dependencies are resolved again in the background
+	close(release)
+	provider.tasks.Wait()
+
+	if builds.Load() != 2 {
+		t.Fatalf("expected 2 builds got %d", builds.Load())
+	}
+
+	// the artifact was not changed
+	if _, err := os.Stat(filepath.Join(binDir, "artifact", k6Binary)); err != nil {
+		t.Fatalf("expected binary %v", err)
+	}
+}
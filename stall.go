@@ -0,0 +1,51 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// errDownloadStalled is the cause of downloads cancelled for not receiving content
+var errDownloadStalled = errors.New("download stalled")
+
+// stallReader cancels the download if no content is read from the body for the stall timeout,
+// for example, from a half-open connection that never delivers the rest of the response
+type stallReader struct {
+	reader  io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+// newStallReader returns a reader that cancels the download with a stall error as cause if no
+// content is read for the timeout. The reader must be stopped when the download ends.
+func newStallReader(reader io.Reader, timeout time.Duration, cancel context.CancelCauseFunc) *stallReader {
+	return &stallReader{
+		reader:  reader,
+		timeout: timeout,
+		timer: time.AfterFunc(timeout, func() {
+			cancel(fmt.Errorf("%w: no content received for %s", errDownloadStalled, timeout))
+		}),
+	}
+}
+
+func (r *stallReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+
+	return n, err
+}
+
+// stop stops the watchdog
+func (r *stallReader) stop() {
+	r.timer.Stop()
+}
+
+// stalled returns true if the download was aborted because it stalled
+func stalled(err error) bool {
+	return errors.Is(err, errDownloadStalled)
+}
@@ -0,0 +1,127 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+)
+
+func TestStallDetection(t *testing.T) {
+	t.Parallel()
+
+	content := bytes.Repeat([]byte("k6"), 1024)
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	testCases := []struct {
+		title       string
+		stallFirst  bool
+		expectRange string
+	}{
+		{
+			title:       "stalled download resumed",
+			stallFirst:  true,
+			expectRange: fmt.Sprintf("bytes=%d-", len(content)/2),
+		},
+		{
+			title:       "slow download not aborted",
+			stallFirst:  false,
+			expectRange: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			requests := atomic.Int32{}
+			gotRange := atomic.Value{}
+			gotRange.Store("")
+			store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) > 1 {
+					gotRange.Store(r.Header.Get("Range"))
+					w.Header().Set("ETag", `"k6"`)
+					http.ServeContent(w, r, "k6", time.Time{}, bytes.NewReader(content))
+					return
+				}
+
+				w.Header().Set("ETag", `"k6"`)
+				w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+				half := len(content) / 2
+				_, _ = w.Write(content[:half])
+				w.(http.Flusher).Flush()
+
+				if tc.stallFirst {
+					// a connection that never delivers the rest of the content
+					<-r.Context().Done()
+					return
+				}
+
+				// slow, taking longer than the stall timeout, but progressing within it
+				for offset := half; offset < len(content); offset += 64 {
+					time.Sleep(20 * time.Millisecond)
+					_, _ = w.Write(content[offset:min(offset+64, len(content))])
+					w.(http.Flusher).Flush()
+				}
+			}))
+			t.Cleanup(store.Close)
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+				},
+			)
+
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+			provider.downloader.stallTimeout = 200 * time.Millisecond
+
+			binary, err := provider.GetBinary(context.TODO(), nil)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+
+			if gotRange.Load() != tc.expectRange {
+				t.Fatalf("expected range %q got %q", tc.expectRange, gotRange.Load())
+			}
+
+			downloaded, err := os.ReadFile(binary.Path)
+			if err != nil {
+				t.Fatalf("reading binary %v", err)
+			}
+			if !bytes.Equal(downloaded, content) {
+				t.Fatalf("expected content %d bytes got %d", len(content), len(downloaded))
+			}
+		})
+	}
+}
+
+func TestStalledDownloadError(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+		_, _ = w.Write([]byte("k6"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(store.Close)
+
+	downloader, err := newDownloader(DownloadConfig{StallTimeout: 100 * time.Millisecond}, nil)
+	if err != nil {
+		t.Fatalf("creating downloader %v", err)
+	}
+
+	err = downloader.download(context.TODO(), store.URL, &bytes.Buffer{})
+	if !errors.Is(err, ErrDownloadTemporary) || !stalled(err) {
+		t.Fatalf("expected %v got %v", errDownloadStalled, err)
+	}
+}
@@ -0,0 +1,156 @@
+package k6provider
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// maxRecentErrors is the number of recent errors kept by the provider
+const maxRecentErrors = 20
+
+// errorRecord records an error returned by the provider
+type errorRecord struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// messageURLs matches the URLs in error messages
+var messageURLs = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`) //nolint:gochecknoglobals
+
+// redactMessageURLs returns the message with the passwords and the query values of its URLs
+// redacted, as they may be the tokens of pre-signed artifact URLs
+func redactMessageURLs(message string) string {
+	return messageURLs.ReplaceAllStringFunc(message, func(match string) string {
+		parsed, err := url.Parse(match)
+		if err != nil {
+			return redacted
+		}
+
+		if parsed.RawQuery != "" {
+			query := parsed.Query()
+			for name := range query {
+				query.Set(name, redacted)
+			}
+			parsed.RawQuery = query.Encode()
+		}
+
+		return parsed.Redacted()
+	})
+}
+
+// errorLog keeps the most recent errors returned by the provider
+type errorLog struct {
+	mutex   sync.Mutex
+	records []errorRecord
+}
+
+// record adds an error to the log, with the URLs in its message redacted, discarding the oldest
+// error if the log is full
+func (l *errorLog) record(err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.records) == maxRecentErrors {
+		l.records = l.records[1:]
+	}
+	l.records = append(l.records, errorRecord{Time: time.Now(), Error: redactMessageURLs(err.Error())})
+}
+
+// recent returns a copy of the recorded errors, from the oldest to the most recent
+func (l *errorLog) recent() []errorRecord {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return append([]errorRecord{}, l.records...)
+}
+
+// recordError records the error, if any, and returns it
+func (p *Provider) recordError(err error) error {
+	if err != nil {
+		p.errors.record(err)
+		p.events.publish(Event{Type: EventError, Err: err})
+	}
+	return err
+}
+
+// cacheEntry describes an artifact in the binary cache
+type cacheEntry struct {
+	Dir     string    `json:"dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Valid   bool      `json:"valid"`
+}
+
+// prunerState describes the state of the pruner
+type prunerState struct {
+	Enabled       bool      `json:"enabled"`
+	HighWaterMark int64     `json:"highWaterMark,omitempty"`
+	KeepPerFamily int       `json:"keepPerFamily,omitempty"`
+	PruneInterval string    `json:"pruneInterval,omitempty"`
+	LastPrune     time.Time `json:"lastPrune"`
+	NetworkFS     bool      `json:"networkFS,omitempty"`
+}
+
+// providerState is a snapshot of the provider's state
+type providerState struct {
+	Time     time.Time     `json:"time"`
+	Config   Config        `json:"config"`
+	Platform string        `json:"platform"`
+	Cache    []cacheEntry  `json:"cache"`
+	Pruner   prunerState   `json:"pruner"`
+	Errors   []errorRecord `json:"errors"`
+}
+
+// DumpState writes a JSON snapshot of the provider's state, including its configuration
+// with the credentials redacted, the entries in the binary cache, the state of the pruner
+// and the most recent errors, with the credentials and query values of their URLs redacted.
+//
+// It is intended for troubleshooting and its format may change between versions.
+func (p *Provider) DumpState(w io.Writer) error {
+	state := providerState{
+		Time:     time.Now(),
+		Config:   p.config.Redacted(),
+		Platform: p.platform,
+		Cache:    p.cacheEntries(),
+		Pruner:   p.pruner.state(),
+		Errors:   p.errors.recent(),
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(state)
+}
+
+// cacheEntries returns the entries in the binary directories
+func (p *Provider) cacheEntries() []cacheEntry {
+	entries := []cacheEntry{}
+	for _, dir := range p.binDirs() {
+		artifacts, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, artifact := range artifacts {
+			if !isArtifactDir(artifact) {
+				continue
+			}
+
+			entry := cacheEntry{Dir: filepath.Join(dir, artifact.Name())}
+			binInfo, err := os.Stat(filepath.Join(entry.Dir, k6Binary))
+			if err == nil {
+				entry.Size = binInfo.Size()
+				entry.ModTime = binInfo.ModTime()
+				entry.Valid = binInfo.Mode().IsRegular() && binInfo.Size() > 0
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,112 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/grafana/k6deps"
+)
+
+// BinaryProvider provides custom k6 binaries that satisfy a set of dependencies.
+// It is implemented by [Provider] and [StaticProvider], so callers can replace the build
+// service with pre-placed binaries, for example, in tests.
+type BinaryProvider interface {
+	GetBinary(ctx context.Context, deps k6deps.Dependencies) (K6Binary, error)
+}
+
+var (
+	_ BinaryProvider = (*Provider)(nil)
+	_ BinaryProvider = (*StaticProvider)(nil)
+)
+
+// DependenciesHash returns the hash that identifies a set of dependencies in a [StaticProvider].
+// The hash doesn't depend on the order of the dependencies, and a set without k6 has the
+// same hash as the set with the k6 constrain "*".
+func DependenciesHash(deps k6deps.Dependencies) string {
+	k6Constrains, buildDeps := buildDeps(deps)
+	return requestKey("", "", "", k6Constrains, buildDeps)
+}
+
+// StaticProvider provides pre-placed binaries for sets of dependencies, without accessing the
+// network, for hermetic build systems (e.g. Bazel) that don't allow network access when running
+// tests. Binaries are not built, downloaded nor cached: requesting a set of dependencies without
+// a binary returns an [ErrBinary] error.
+type StaticProvider struct {
+	binaries map[string]string
+}
+
+// NewStaticProvider returns a [StaticProvider] for the binaries given as a map of the hash of
+// a set of dependencies (see [DependenciesHash]) to the path of its binary
+func NewStaticProvider(binaries map[string]string) *StaticProvider {
+	return &StaticProvider{binaries: maps.Clone(binaries)}
+}
+
+// NewStaticProviderFromDir returns a [StaticProvider] for the binaries in a directory, which has
+// a subdirectory named after the hash of each set of dependencies (see [DependenciesHash])
+// with its binary. e.g. <dir>/<hash>/k6 (k6.exe on windows)
+func NewStaticProviderFromDir(dir string) (*StaticProvider, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, NewWrappedError(ErrConfig, err)
+	}
+
+	binaries := map[string]string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		binPath := filepath.Join(dir, entry.Name(), k6Binary)
+		if _, err := os.Stat(binPath); err != nil {
+			continue
+		}
+		binaries[entry.Name()] = binPath
+	}
+
+	return &StaticProvider{binaries: binaries}, nil
+}
+
+// GetBinary returns the binary for the given set of dependencies. The ID of the returned binary
+// is the hash of the dependencies. Its dependencies and platform are not known.
+func (s *StaticProvider) GetBinary(_ context.Context, deps k6deps.Dependencies) (K6Binary, error) {
+	hash := DependenciesHash(deps)
+
+	binPath, found := s.binaries[hash]
+	if !found {
+		return K6Binary{}, NewWrappedError(
+			ErrBinary,
+			fmt.Errorf("no binary for dependencies %q (hash %s)", deps.String(), hash),
+		)
+	}
+
+	checksum, err := fileChecksum(binPath)
+	if err != nil {
+		return K6Binary{}, NewWrappedError(ErrBinary, err)
+	}
+
+	return K6Binary{
+		Path:     binPath,
+		ID:       hash,
+		Checksum: checksum,
+	}, nil
+}
+
+// fileChecksum returns the sha256 checksum of the file
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return "", err
+	}
+	defer file.Close() //nolint:errcheck
+
+	hash := sha256.New()
+	if _, err := defaultBuffers.copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,109 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/k6deps"
+)
+
+func TestDependenciesHash(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title  string
+		a      string
+		b      string
+		expect bool
+	}{
+		{title: "same dependencies", a: "k6>0.50;k6/x/faker>0.3", b: "k6>0.50;k6/x/faker>0.3", expect: true},
+		{title: "different order", a: "k6/x/faker>0.3;k6>0.50", b: "k6>0.50;k6/x/faker>0.3", expect: true},
+		{title: "default k6", a: "k6/x/faker>0.3", b: "k6*;k6/x/faker>0.3", expect: true},
+		{title: "different constrains", a: "k6>0.50", b: "k6>0.51", expect: false},
+		{title: "different extensions", a: "k6/x/faker>0.3", b: "k6/x/sql>0.3", expect: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			a := parseDeps(t, tc.a)
+			b := parseDeps(t, tc.b)
+
+			if equal := DependenciesHash(a) == DependenciesHash(b); equal != tc.expect {
+				t.Fatalf("expected equal %v got %v", tc.expect, equal)
+			}
+		})
+	}
+}
+
+func TestStaticProvider(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	deps := parseDeps(t, "k6>0.50;k6/x/faker>0.3")
+	hash := DependenciesHash(deps)
+
+	binDir := filepath.Join(dir, hash)
+	if err := os.MkdirAll(binDir, 0o700); err != nil {
+		t.Fatalf("test setup: %v", err)
+	}
+	binPath := filepath.Join(binDir, k6Binary)
+	if err := os.WriteFile(binPath, []byte("k6"), 0o700); err != nil { //nolint:gosec
+		t.Fatalf("test setup: %v", err)
+	}
+
+	fromDir, err := NewStaticProviderFromDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	providers := map[string]BinaryProvider{
+		"map":       NewStaticProvider(map[string]string{hash: binPath}),
+		"directory": fromDir,
+	}
+
+	testCases := []struct {
+		title     string
+		deps      string
+		expectErr error
+	}{
+		{title: "binary found", deps: "k6/x/faker>0.3;k6>0.50", expectErr: nil},
+		{title: "binary not found", deps: "k6>0.50", expectErr: ErrBinary},
+	}
+
+	for name, provider := range providers {
+		for _, tc := range testCases {
+			t.Run(name+" "+tc.title, func(t *testing.T) {
+				t.Parallel()
+
+				binary, err := provider.GetBinary(context.TODO(), parseDeps(t, tc.deps))
+				if !errors.Is(err, tc.expectErr) {
+					t.Fatalf("expected %v got %v", tc.expectErr, err)
+				}
+				if err != nil {
+					return
+				}
+
+				if binary.Path != binPath || binary.ID != hash || binary.Checksum == "" {
+					t.Fatalf("expected binary %s got %+v", binPath, binary)
+				}
+			})
+		}
+	}
+}
+
+// parseDeps parses dependencies in the text format, failing the test if they are not valid
+func parseDeps(t *testing.T, text string) k6deps.Dependencies {
+	t.Helper()
+
+	deps := k6deps.Dependencies{}
+	if err := deps.UnmarshalText([]byte(text)); err != nil {
+		t.Fatalf("test setup: parsing dependencies %v", err)
+	}
+
+	return deps
+}
@@ -0,0 +1,20 @@
+package k6provider
+
+import (
+	"errors"
+	"syscall"
+)
+
+// storageError classifies an error storing a binary. Errors caused by a full device
+// or a read-only file system are wrapped as ErrNoSpace and ErrReadOnly respectively.
+// Other errors are returned unchanged.
+func storageError(err error) error {
+	switch {
+	case errors.Is(err, syscall.ENOSPC):
+		return NewWrappedError(ErrNoSpace, err)
+	case errors.Is(err, syscall.EROFS):
+		return NewWrappedError(ErrReadOnly, err)
+	default:
+		return err
+	}
+}
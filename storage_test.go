@@ -0,0 +1,56 @@
+package k6provider
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"testing"
+)
+
+func TestStorageError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		title     string
+		err       error
+		expectErr error
+	}{
+		{
+			title:     "no space left on device",
+			err:       &fs.PathError{Op: "write", Path: "k6", Err: syscall.ENOSPC},
+			expectErr: ErrNoSpace,
+		},
+		{
+			title:     "read-only file system",
+			err:       &fs.PathError{Op: "open", Path: "k6", Err: syscall.EROFS},
+			expectErr: ErrReadOnly,
+		},
+		{
+			title:     "wrapped no space error",
+			err:       fmt.Errorf("copying: %w", syscall.ENOSPC),
+			expectErr: ErrNoSpace,
+		},
+		{
+			title:     "other error",
+			err:       fs.ErrPermission,
+			expectErr: fs.ErrPermission,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			err := storageError(tc.err)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+
+			// the original cause must be preserved
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected cause %v in %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/grafana/k6deps"
+)
+
+// binaryPerm are the permissions of the binaries provisioned into a [BinaryFS]
+const binaryPerm fs.FileMode = 0o700
+
+// BinaryFS is a writable file system binaries can be provisioned into, such as the directory
+// of a sandbox. See [Provider.ProvisionInto].
+//
+// [DirFS] and, on Go 1.24 and later, [RootFS] return a BinaryFS for a directory. Other file
+// systems, such as afero's, can be used by implementing this interface.
+type BinaryFS interface {
+	// Create creates or truncates the named file with the given permissions, for writing
+	Create(name string, perm fs.FileMode) (io.WriteCloser, error)
+	// Remove removes the named file
+	Remove(name string) error
+}
+
+// dirFS is a BinaryFS for a directory
+type dirFS string
+
+// DirFS returns a [BinaryFS] for the given directory
+func DirFS(dir string) BinaryFS {
+	return dirFS(dir)
+}
+
+func (d dirFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	return os.OpenFile(filepath.Join(string(d), name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm) //nolint:gosec
+}
+
+func (d dirFS) Remove(name string) error {
+	return os.Remove(filepath.Join(string(d), name))
+}
+
+// ProvisionInto writes the custom k6 binary that satisfies the given set of dependencies to the
+// named file in the target file system, so sandboxed or chrooted runners can receive binaries
+// from a directory handle without having access to the cache. The binary is obtained as with
+// [Provider.GetBinary] and verified against its checksum while it is written.
+//
+// The returned binary's Path is the name of the file in the target file system.
+func (p *Provider) ProvisionInto(
+	ctx context.Context,
+	deps k6deps.Dependencies,
+	target BinaryFS,
+	name string,
+) (K6Binary, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return K6Binary{}, NewWrappedError(ErrInvalidParameters, fmt.Errorf("invalid binary name %q", name))
+	}
+
+	binary, err := p.GetBinary(ctx, deps)
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	err = writeInto(binary, target, name)
+	// the binary was pruned before it was copied, get it again
+	if errors.Is(err, os.ErrNotExist) {
+		binary, err = p.GetBinary(ctx, deps)
+		if err != nil {
+			return K6Binary{}, err
+		}
+		err = writeInto(binary, target, name)
+	}
+	if err != nil {
+		return K6Binary{}, p.recordError(NewWrappedError(ErrBinary, err))
+	}
+
+	binary.Path = name
+	return binary, nil
+}
+
+// writeInto copies the binary to the named file in the target file system, verifying its checksum.
+// The file is removed if the copy fails.
+func writeInto(binary K6Binary, target BinaryFS, name string) error {
+	source, err := os.Open(binary.Path) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer source.Close() //nolint:errcheck
+
+	dest, err := target.Create(name, binaryPerm)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	_, err = defaultBuffers.copy(io.MultiWriter(dest, hash), source)
+	if closeErr := dest.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = verifyChecksum(binary.Checksum, hash)
+	}
+	if err != nil {
+		_ = target.Remove(name)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build go1.24
+
+package k6provider
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// rootFS is a BinaryFS for an [os.Root]
+type rootFS struct {
+	root *os.Root
+}
+
+// RootFS returns a [BinaryFS] for the directory opened as the given root. Files are created
+// relative to the root's directory handle, so the provider doesn't require access to its path.
+func RootFS(root *os.Root) BinaryFS {
+	return rootFS{root: root}
+}
+
+func (r rootFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	return r.root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+}
+
+func (r rootFS) Remove(name string) error {
+	return r.root.Remove(name)
+}
@@ -0,0 +1,45 @@
+//go:build go1.24
+
+package k6provider
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestProvisionIntoRoot(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("k6 binary"))
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", URL: store.URL}, nil
+		},
+	)
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+
+	root, err := os.OpenRoot(t.TempDir())
+	if err != nil {
+		t.Fatalf("test setup: %v", err)
+	}
+	t.Cleanup(func() { _ = root.Close() })
+
+	if _, err = provider.ProvisionInto(context.TODO(), nil, RootFS(root), "k6"); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	content, err := fs.ReadFile(root.FS(), "k6")
+	if err != nil || string(content) != "k6 binary" {
+		t.Fatalf("expected %q got %q %v", "k6 binary", content, err)
+	}
+}
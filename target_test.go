@@ -0,0 +1,75 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestProvisionInto(t *testing.T) {
+	t.Parallel()
+
+	binary := []byte("k6 binary")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(binary)
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+		},
+	)
+
+	testCases := []struct {
+		title     string
+		name      string
+		expectErr error
+	}{
+		{title: "binary in target dir", name: "k6"},
+		{title: "binary in target subdir", name: "bin/k6"},
+		{title: "name outside target", name: "../k6", expectErr: ErrInvalidParameters},
+		{title: "missing subdir", name: "missing/k6", expectErr: ErrBinary},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			targetDir := t.TempDir()
+			if err := os.Mkdir(filepath.Join(targetDir, "bin"), 0o700); err != nil {
+				t.Fatalf("test setup: %v", err)
+			}
+
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+
+			k6, err := provider.ProvisionInto(context.TODO(), nil, DirFS(targetDir), tc.name)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if k6.Path != tc.name {
+				t.Fatalf("expected %v got %v", tc.name, k6.Path)
+			}
+
+			content, err := os.ReadFile(filepath.Join(targetDir, tc.name))
+			if err != nil || string(content) != string(binary) {
+				t.Fatalf("expected %q got %q %v", binary, content, err)
+			}
+		})
+	}
+}
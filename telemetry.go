@@ -0,0 +1,228 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTelemetryInterval time between usage reports
+	DefaultTelemetryInterval = time.Hour
+	// telemetryTimeout is the maximum time for sending a usage report
+	telemetryTimeout = 10 * time.Second
+)
+
+// TelemetryConfig defines the reporting of usage statistics, which is disabled unless an URL is set.
+//
+// The statistics are periodically posted as JSON to the URL, and when the provider is closed.
+// They don't include any information that identifies the user, the host or the scripts:
+//   - instance: random id of the provider, which changes every time it is created
+//   - platform: platform of the binaries
+//   - start and end: period of the report
+//   - provisioned: number of binaries provisioned
+//   - cacheHits: number of binaries found in the cache
+//   - cacheHitRatio: ratio of binaries found in the cache
+//   - dependencies: number of binaries provisioned with each extension, identified by the first 16
+//     characters of the hexadecimal SHA-256 hash of its name, so the names can be matched with
+//     those in the catalog but custom extensions are not revealed
+type TelemetryConfig struct {
+	// URL of the endpoint the usage statistics are posted to
+	URL string
+	// Interval time between reports. Defaults to 1h
+	Interval time.Duration
+	// Headers HTTP headers for the reports, such as credentials for the endpoint
+	Headers map[string]string
+}
+
+// telemetryReport is the usage report posted to the telemetry endpoint
+type telemetryReport struct {
+	Instance      string           `json:"instance"`
+	Platform      string           `json:"platform"`
+	Start         time.Time        `json:"start"`
+	End           time.Time        `json:"end"`
+	Provisioned   int64            `json:"provisioned"`
+	CacheHits     int64            `json:"cacheHits"`
+	CacheHitRatio float64          `json:"cacheHitRatio"`
+	Dependencies  map[string]int64 `json:"dependencies"`
+}
+
+// telemetry collects the usage statistics of a provider and reports them
+type telemetry struct {
+	config   TelemetryConfig
+	client   *http.Client
+	instance string
+	platform string
+	started  sync.Once
+
+	mutex        sync.Mutex
+	start        time.Time
+	provisioned  int64
+	cacheHits    int64
+	dependencies map[string]int64
+}
+
+// newTelemetry returns the telemetry for the configuration or nil if it is not enabled
+func newTelemetry(config TelemetryConfig, platform string) *telemetry {
+	if config.URL == "" {
+		return nil
+	}
+
+	if config.Interval == 0 {
+		config.Interval = DefaultTelemetryInterval
+	}
+
+	instance := make([]byte, 8)
+	_, _ = rand.Read(instance)
+
+	return &telemetry{
+		config:       config,
+		client:       &http.Client{Timeout: telemetryTimeout},
+		instance:     hex.EncodeToString(instance),
+		platform:     platform,
+		start:        time.Now(),
+		dependencies: map[string]int64{},
+	}
+}
+
+// anonymize returns an identifier for the name of a dependency that doesn't reveal it
+func anonymize(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+// record records the provisioning of a binary
+func (t *telemetry) record(binary K6Binary, cached bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.provisioned++
+	if cached {
+		t.cacheHits++
+	}
+
+	for name := range binary.Dependencies {
+		if name == k6Module {
+			continue
+		}
+		t.dependencies[anonymize(name)]++
+	}
+}
+
+// run reports the usage periodically until the context is done, reporting the remaining usage then
+func (t *telemetry) run(ctx context.Context) {
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = t.report(ctx)
+		case <-ctx.Done():
+			// the provider is closing, so the report cannot use its context
+			reportCtx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+			_ = t.report(reportCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// report posts the usage since the last report, if any. If the report fails, the usage is
+// included in the next one.
+func (t *telemetry) report(ctx context.Context) error {
+	report, ok := t.snapshot()
+	if !ok {
+		return nil
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for h, v := range t.config.Headers {
+		req.Header.Add(h, v)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+
+	t.reset(report)
+
+	return nil
+}
+
+// snapshot returns the report of the usage since the last report and false if there was no usage
+func (t *telemetry) snapshot() (telemetryReport, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.provisioned == 0 {
+		return telemetryReport{}, false
+	}
+
+	dependencies := make(map[string]int64, len(t.dependencies))
+	for id, count := range t.dependencies {
+		dependencies[id] = count
+	}
+
+	return telemetryReport{
+		Instance:      t.instance,
+		Platform:      t.platform,
+		Start:         t.start,
+		End:           time.Now(),
+		Provisioned:   t.provisioned,
+		CacheHits:     t.cacheHits,
+		CacheHitRatio: float64(t.cacheHits) / float64(t.provisioned),
+		Dependencies:  dependencies,
+	}, true
+}
+
+// reset discounts the reported usage, keeping the usage recorded while reporting
+func (t *telemetry) reset(report telemetryReport) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.start = report.End
+	t.provisioned -= report.Provisioned
+	t.cacheHits -= report.CacheHits
+	for id, count := range report.Dependencies {
+		t.dependencies[id] -= count
+		if t.dependencies[id] == 0 {
+			delete(t.dependencies, id)
+		}
+	}
+}
+
+// recordUsage records the provisioning of the binary, if telemetry is enabled, starting
+// the reports the first time
+func (p *Provider) recordUsage(binary K6Binary, cached bool) {
+	if p.telemetry == nil {
+		return
+	}
+
+	p.telemetry.record(binary, cached)
+	p.telemetry.started.Do(func() {
+		p.background(func() { p.telemetry.run(p.ctx) })
+	})
+}
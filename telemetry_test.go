@@ -0,0 +1,102 @@
+package k6provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+func TestTelemetry(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("k6 binary"))
+	}))
+	t.Cleanup(store.Close)
+
+	mutex := sync.Mutex{}
+	reports := []telemetryReport{}
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		report := telemetryReport{}
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mutex.Lock()
+		reports = append(reports, report)
+		mutex.Unlock()
+	}))
+	t.Cleanup(endpoint.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{
+				ID:           "artifact",
+				URL:          store.URL,
+				Platform:     "linux/amd64",
+				Dependencies: map[string]string{"k6": "v0.55.0", "k6/x/faker": "v0.4.0"},
+			}, nil
+		},
+	)
+
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+	provider.telemetry = newTelemetry(
+		TelemetryConfig{
+			URL:      endpoint.URL,
+			Interval: time.Hour,
+			Headers:  map[string]string{"Authorization": "Bearer token"},
+		},
+		provider.platform,
+	)
+
+	for range 4 {
+		if _, err := provider.GetBinary(context.TODO(), k6deps.Dependencies{}); err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+	}
+
+	// the remaining usage is reported when the provider is closed
+	if err := provider.Close(); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report got %d", len(reports))
+	}
+
+	report := reports[0]
+	if report.Provisioned != 4 || report.CacheHits != 3 || report.CacheHitRatio != 0.75 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	expected := map[string]int64{anonymize("k6/x/faker"): 4}
+	if len(report.Dependencies) != 1 || report.Dependencies[anonymize("k6/x/faker")] != 4 {
+		t.Fatalf("expected dependencies %v got %v", expected, report.Dependencies)
+	}
+
+	// the usage is discounted once reported
+	if _, ok := provider.telemetry.snapshot(); ok {
+		t.Fatalf("expected no usage after report")
+	}
+}
+
+func TestTelemetryDisabled(t *testing.T) {
+	t.Parallel()
+
+	if newTelemetry(TelemetryConfig{}, "linux/amd64") != nil {
+		t.Fatalf("expected telemetry to be disabled")
+	}
+}
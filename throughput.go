@@ -0,0 +1,110 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMinDownloadTimeout minimum timeout of a download when adaptive timeouts are enabled
+	DefaultMinDownloadTimeout = 30 * time.Second
+	// throughputSmoothing weight of each new sample in the moving average of the throughput
+	throughputSmoothing = 0.3
+	// minThroughputSample minimum size of a download used as a sample of the throughput, as the
+	// time of smaller downloads is dominated by the latency
+	minThroughputSample = 64 << 10
+)
+
+// errDownloadTimeout is the cause of downloads cancelled for exceeding their expected time
+var errDownloadTimeout = errors.New("download timed out")
+
+// throughputTracker keeps the exponential moving average of the throughput of the downloads
+// from each host, in bytes per second
+type throughputTracker struct {
+	mutex sync.Mutex
+	hosts map[string]float64
+}
+
+func newThroughputTracker() *throughputTracker {
+	return &throughputTracker{hosts: map[string]float64{}}
+}
+
+// record adds the throughput of a download from the host to its moving average
+func (t *throughputTracker) record(host string, size int64, elapsed time.Duration) {
+	if size < minThroughputSample || elapsed <= 0 {
+		return
+	}
+
+	sample := float64(size) / elapsed.Seconds()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	average, found := t.hosts[host]
+	if !found {
+		t.hosts[host] = sample
+		return
+	}
+	t.hosts[host] = throughputSmoothing*sample + (1-throughputSmoothing)*average
+}
+
+// estimate returns the average throughput of the downloads from the host, if any was observed
+func (t *throughputTracker) estimate(host string) (float64, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	average, found := t.hosts[host]
+	return average, found
+}
+
+// timeout returns the timeout for downloading the given number of bytes from the host: the time
+// expected from the host's average throughput multiplied by the timeout factor, and not less than
+// the minimum timeout. Returns 0 (no timeout) if adaptive timeouts are disabled, the size is
+// unknown or no throughput was observed for the host.
+func (d *downloader) timeout(host string, size int64) time.Duration {
+	if d.timeoutFactor <= 0 || size < 0 {
+		return 0
+	}
+
+	throughput, found := d.throughput.estimate(host)
+	if !found {
+		return 0
+	}
+
+	expected := time.Duration(float64(size) / throughput * d.timeoutFactor * float64(time.Second))
+
+	return max(expected, d.minTimeout)
+}
+
+// copyBody copies the response body to the writer, cancelling the download with a timeout error
+// as cause if it exceeds its timeout or stalls, and records the throughput of the download
+func (d *downloader) copyBody(cancel context.CancelCauseFunc, resp *http.Response, writer io.Writer) error {
+	host := resp.Request.URL.Host
+
+	if timeout := d.timeout(host, resp.ContentLength); timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			cancel(fmt.Errorf("%w after %s", errDownloadTimeout, timeout))
+		})
+		defer timer.Stop()
+	}
+
+	var body io.Reader = resp.Body
+	if d.stallTimeout > 0 {
+		watchdog := newStallReader(resp.Body, d.stallTimeout, cancel)
+		defer watchdog.stop()
+		body = watchdog
+	}
+
+	start := time.Now()
+	size, err := d.buffers.copy(writer, body)
+	if err == nil {
+		d.throughput.record(host, size, time.Since(start))
+	}
+
+	return err
+}
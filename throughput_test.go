@@ -0,0 +1,103 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDownloadTimeout(t *testing.T) {
+	t.Parallel()
+
+	const size = 1 << 20
+
+	testCases := []struct {
+		title         string
+		timeoutFactor float64
+		samples       []time.Duration
+		expect        time.Duration
+	}{
+		{
+			title:         "adaptive timeouts disabled",
+			timeoutFactor: 0,
+			samples:       []time.Duration{time.Second},
+			expect:        0,
+		},
+		{
+			title:         "no throughput observed",
+			timeoutFactor: 2,
+			expect:        0,
+		},
+		{
+			title:         "expected time multiplied by factor",
+			timeoutFactor: 2,
+			samples:       []time.Duration{10 * time.Second},
+			expect:        20 * time.Second,
+		},
+		{
+			title:         "moving average",
+			timeoutFactor: 1,
+			// 1MiB/s averaged with a 0.5MiB/s sample: 0.85MiB/s
+			samples: []time.Duration{time.Second, 2 * time.Second},
+			expect:  time.Second * 100 / 85,
+		},
+		{
+			title:         "minimum timeout",
+			timeoutFactor: 2,
+			samples:       []time.Duration{time.Millisecond},
+			expect:        time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			config := DownloadConfig{TimeoutFactor: tc.timeoutFactor, MinTimeout: time.Second}
+			downloader, err := newDownloader(config, nil)
+			if err != nil {
+				t.Fatalf("creating downloader %v", err)
+			}
+
+			for _, elapsed := range tc.samples {
+				downloader.throughput.record("host", size, elapsed)
+			}
+
+			timeout := downloader.timeout("host", size)
+			if timeout.Round(time.Millisecond) != tc.expect.Round(time.Millisecond) {
+				t.Fatalf("expected %v got %v", tc.expect, timeout)
+			}
+		})
+	}
+}
+
+func TestStalledDownload(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(minThroughputSample))
+		_, _ = w.Write([]byte("k6"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	downloader, err := newDownloader(DownloadConfig{TimeoutFactor: 2, MinTimeout: 100 * time.Millisecond}, nil)
+	if err != nil {
+		t.Fatalf("creating downloader %v", err)
+	}
+
+	srvURL, _ := url.Parse(srv.URL)
+	downloader.throughput.record(srvURL.Host, minThroughputSample, time.Millisecond)
+
+	err = downloader.download(context.TODO(), srv.URL, &bytes.Buffer{})
+	if !errors.Is(err, ErrDownloadTemporary) || !errors.Is(err, errDownloadTimeout) {
+		t.Fatalf("expected %v got %v", errDownloadTimeout, err)
+	}
+}
@@ -0,0 +1,197 @@
+package k6provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// CacheTier is a binary directory used as an additional tier of the cache.
+// See [Config.MemoryTier] and [Config.SharedTier]
+type CacheTier struct {
+	// Dir binary directory of the tier. If empty, the tier is not used
+	Dir string
+	// MaxSize maximum size of the binaries stored in the tier. The least recently used binaries
+	// are removed when it is exceeded. If 0 (default) the size is not limited.
+	// This option is ignored when running in windows systems
+	MaxSize int64
+	// MaxBinarySize binaries larger than this size are not stored in the tier.
+	// If 0 (default) the size of the binaries is not limited
+	MaxBinarySize int64
+}
+
+// cacheTier is a tier of the cache with the pruner that enforces its size limit
+type cacheTier struct {
+	CacheTier
+	pruner    *Pruner
+	networkFS bool
+}
+
+// newCacheTier returns the tier in the configuration, or nil if it is not used
+func newCacheTier(config CacheTier) *cacheTier {
+	if config.Dir == "" {
+		return nil
+	}
+
+	networkFS := isNetworkFS(config.Dir)
+
+	return &cacheTier{
+		CacheTier: config,
+		pruner:    NewPruner(config.Dir, config.MaxSize, 0).withNetworkFS(networkFS),
+		networkFS: networkFS,
+	}
+}
+
+// admits returns true if a binary of the given size can be stored in the tier
+func (t *cacheTier) admits(size int64) bool {
+	return t.MaxBinarySize == 0 || size <= t.MaxBinarySize
+}
+
+// lookupTiered looks for the binary of an artifact in the memory tier, the binary directories and
+// the shared tier, in this order. Binaries found in the shared tier are copied into the binary
+// directory and binaries not found in the memory tier are copied into it in the background, so
+// the following requests find them in the fastest tier.
+func (p *Provider) lookupTiered(ctx context.Context, artifact Artifact) (string, bool, error) {
+	if err := p.resolveScope(); err != nil {
+		return "", false, err
+	}
+
+	if tier := p.memoryTier; tier != nil {
+		binPath := filepath.Join(p.artifactDir(tier.Dir, artifact), k6Binary)
+		if _, err := os.Stat(binPath); err == nil {
+			p.background(func() { tier.pruner.Touch(binPath) })
+			return binPath, true, nil
+		}
+	}
+
+	binPath, found, err := p.lookupBinary(artifact)
+	if err != nil {
+		return "", false, err
+	}
+
+	if !found && p.sharedTier != nil {
+		binPath, found = p.fromSharedTier(ctx, artifact)
+	}
+
+	if found && p.memoryTier != nil {
+		p.background(func() { _ = p.copyToTier(p.ctx, p.memoryTier, binPath, artifact) })
+	}
+
+	return binPath, found, nil
+}
+
+// fromSharedTier copies the artifact's binary from the shared tier into the binary directory,
+// returning its path. Binaries that don't match the artifact's checksum are ignored.
+func (p *Provider) fromSharedTier(ctx context.Context, artifact Artifact) (string, bool) {
+	sharedPath := filepath.Join(p.artifactDir(p.sharedTier.Dir, artifact), k6Binary)
+	if _, err := os.Stat(sharedPath); err != nil {
+		return "", false
+	}
+	p.background(func() { p.sharedTier.pruner.Touch(sharedPath) })
+
+	artifactDir := p.artifactDir(p.binDir, artifact)
+	if err := p.copyArtifact(ctx, p.binDir, filepath.Dir(sharedPath), artifactDir); err != nil {
+		return "", false
+	}
+
+	binPath := filepath.Join(artifactDir, k6Binary)
+	if err := p.verifyBinary(binPath, artifact.Checksum); err != nil {
+		_ = os.RemoveAll(artifactDir)
+		return "", false
+	}
+
+	return binPath, true
+}
+
+// storeTiered copies the binary downloaded into the binary directory to the memory tier and
+// to the shared tier, so other hosts can use it, in the background
+func (p *Provider) storeTiered(binPath string, artifact Artifact) {
+	p.background(func() {
+		_ = p.copyToTier(p.ctx, p.memoryTier, binPath, artifact)
+		_ = p.copyToTier(p.ctx, p.sharedTier, binPath, artifact)
+	})
+}
+
+// copyToTier copies the binary's artifact directory into the tier, if the tier admits it,
+// and prunes the tier to enforce its size limit
+func (p *Provider) copyToTier(ctx context.Context, tier *cacheTier, binPath string, artifact Artifact) error {
+	if tier == nil {
+		return nil
+	}
+
+	info, err := os.Stat(binPath)
+	if err != nil {
+		return err
+	}
+	if !tier.admits(info.Size()) {
+		return nil
+	}
+
+	if err = p.copyArtifact(ctx, tier.Dir, filepath.Dir(binPath), p.artifactDir(tier.Dir, artifact)); err != nil {
+		return err
+	}
+
+	return tier.pruner.Prune()
+}
+
+// copyArtifact copies an artifact directory into the artifact directory of a binary directory,
+// unless it already has the binary. The destination is locked as for downloads, so concurrent
+// copies and downloads of the same artifact are not mixed.
+func (p *Provider) copyArtifact(ctx context.Context, dir string, from string, to string) error {
+	if err := os.MkdirAll(to, cacheDirPerm); err != nil {
+		return storageError(err)
+	}
+	if err := p.permissions.shareDirs(dir, to); err != nil {
+		return err
+	}
+
+	lock := p.tierLock(dir, to)
+	if err := lock.lockWithContext(ctx); err != nil {
+		return err
+	}
+	defer lock.unlock() //nolint:errcheck
+
+	binPath := filepath.Join(to, k6Binary)
+	if _, err := os.Stat(binPath); err == nil {
+		return nil
+	}
+
+	if err := copyArtifactFiles(from, to); err != nil {
+		return storageError(err)
+	}
+
+	return p.permissions.shareBinary(binPath)
+}
+
+// tierLock returns the lock for an artifact directory in a binary directory, which may be a tier
+func (p *Provider) tierLock(dir string, artifactDir string) *dirLock {
+	for _, tier := range []*cacheTier{p.memoryTier, p.sharedTier} {
+		if tier != nil && tier.Dir == dir && tier.networkFS {
+			return newExclusiveFileLock(artifactDir)
+		}
+	}
+
+	return p.downloadLock(dir, artifactDir)
+}
+
+// copyArtifactFiles copies the files of an artifact directory to another directory, except for
+// the lock and partial files. The binary is copied last, as it completes the artifact.
+func copyArtifactFiles(from string, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == k6Binary || isTransientFile(name) {
+			continue
+		}
+
+		if err = copyEntry(filepath.Join(from, name), filepath.Join(to, name)); err != nil {
+			return err
+		}
+	}
+
+	return copyEntry(filepath.Join(from, k6Binary), filepath.Join(to, k6Binary))
+}
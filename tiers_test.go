@@ -0,0 +1,104 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestCacheTiers(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	testCases := []struct {
+		title          string
+		maxBinarySize  int64
+		expectInMemory bool
+	}{
+		{
+			title:          "small binary pinned in memory",
+			maxBinarySize:  int64(len(content)),
+			expectInMemory: true,
+		},
+		{
+			title:          "large binary not pinned in memory",
+			maxBinarySize:  int64(len(content)) - 1,
+			expectInMemory: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			downloads := atomic.Int32{}
+			store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				downloads.Add(1)
+				_, _ = w.Write(content)
+			}))
+			t.Cleanup(store.Close)
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+				},
+			)
+
+			sharedDir := t.TempDir()
+			newHost := func() *Provider {
+				provider := newTestProvider(t, buildSrv, t.TempDir())
+				provider.memoryTier = newCacheTier(CacheTier{Dir: t.TempDir(), MaxBinarySize: tc.maxBinarySize})
+				provider.sharedTier = newCacheTier(CacheTier{Dir: sharedDir})
+				return provider
+			}
+
+			// the binary is downloaded and copied to the shared tier
+			first := newHost()
+			if _, err := first.GetBinary(context.TODO(), nil); err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			_ = first.Close()
+
+			if _, err := os.Stat(filepath.Join(sharedDir, "artifact", k6Binary)); err != nil {
+				t.Fatalf("expected binary in shared tier %v", err)
+			}
+
+			// another host obtains the binary from the shared tier into its binary directory
+			second := newHost()
+			t.Cleanup(func() { _ = second.Close() })
+
+			binary, err := second.GetBinary(context.TODO(), nil)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			if !strings.HasPrefix(binary.Path, second.binDir) {
+				t.Fatalf("expected binary in binary directory got %s", binary.Path)
+			}
+			if downloads.Load() != 1 {
+				t.Fatalf("expected 1 download got %d", downloads.Load())
+			}
+
+			// wait for the copy to the memory tier
+			second.tasks.Wait()
+
+			binary, err = second.GetBinary(context.TODO(), nil)
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			if inMemory := strings.HasPrefix(binary.Path, second.memoryTier.Dir); inMemory != tc.expectInMemory {
+				t.Fatalf("expected in memory %v got %s", tc.expectInMemory, binary.Path)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package k6provider
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Timing is the breakdown of the time spent in each phase of the provisioning of a binary.
+// The durations are encoded in JSON in nanoseconds. See [WithTiming]
+type Timing struct {
+	// Resolve time spent requesting the artifact to the build service
+	Resolve time.Duration `json:"resolve"`
+	// Lookup time spent looking for the binary in the cache
+	Lookup time.Duration `json:"lookup"`
+	// Download time spent downloading the binary
+	Download time.Duration `json:"download"`
+	// Verify time spent verifying the binary's checksum
+	Verify time.Duration `json:"verify"`
+}
+
+// timingPhase identifies a phase of the provisioning
+type timingPhase int
+
+const (
+	phaseResolve timingPhase = iota
+	phaseLookup
+	phaseDownload
+	phaseVerify
+)
+
+// timingRecorder accumulates the time spent in each phase
+type timingRecorder struct {
+	mutex  sync.Mutex
+	timing Timing
+}
+
+type timingKey struct{}
+
+// WithTiming returns a context that records the time spent in each phase of the provisioning
+// of a binary by the provider's functions called with it. The breakdown is obtained using
+// [TimingFromContext]. The time of each phase is accumulated if the context is used in more
+// than one call.
+func WithTiming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, timingKey{}, &timingRecorder{})
+}
+
+// TimingFromContext returns the time spent in each phase of the provisioning of binaries with
+// the context. Returns false if the context was not created with [WithTiming].
+func TimingFromContext(ctx context.Context) (Timing, bool) {
+	recorder, ok := ctx.Value(timingKey{}).(*timingRecorder)
+	if !ok {
+		return Timing{}, false
+	}
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.timing, true
+}
+
+// recordTiming adds the time since start to the phase, if the context records the timing
+func recordTiming(ctx context.Context, phase timingPhase, start time.Time) {
+	recorder, ok := ctx.Value(timingKey{}).(*timingRecorder)
+	if !ok {
+		return
+	}
+
+	elapsed := time.Since(start)
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	switch phase {
+	case phaseResolve:
+		recorder.timing.Resolve += elapsed
+	case phaseLookup:
+		recorder.timing.Lookup += elapsed
+	case phaseDownload:
+		recorder.timing.Download += elapsed
+	case phaseVerify:
+		recorder.timing.Verify += elapsed
+	}
+}
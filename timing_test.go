@@ -0,0 +1,55 @@
+package k6provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+)
+
+func TestTiming(t *testing.T) {
+	t.Parallel()
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("k6 binary"))
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			time.Sleep(10 * time.Millisecond)
+			return k6build.Artifact{ID: "artifact", URL: store.URL}, nil
+		},
+	)
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+
+	if _, ok := TimingFromContext(context.TODO()); ok {
+		t.Fatalf("expected no timing without WithTiming")
+	}
+
+	ctx := WithTiming(context.TODO())
+	if _, err := provider.GetBinary(ctx, nil); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	timing, ok := TimingFromContext(ctx)
+	if !ok {
+		t.Fatalf("expected timing")
+	}
+
+	if timing.Resolve < 10*time.Millisecond {
+		t.Fatalf("expected resolve time recorded got %v", timing.Resolve)
+	}
+
+	if timing.Download < 20*time.Millisecond {
+		t.Fatalf("expected download time recorded got %v", timing.Download)
+	}
+
+	if timing.Lookup == 0 {
+		t.Fatalf("expected lookup time recorded got %+v", timing)
+	}
+}
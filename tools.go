@@ -0,0 +1,76 @@
+package k6provider
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// toolsDir is the directory in the artifact directory with the companion tools shipped with
+// the binary. See [K6Binary.Tools]
+const toolsDir = "tools"
+
+// newCachedBinary returns the K6Binary for an artifact's binary in the cache, with the
+// companion tools stored with it
+func newCachedBinary(binPath string, artifact Artifact) K6Binary {
+	binary := newK6Binary(binPath, artifact)
+	binary.Tools = readTools(filepath.Dir(binPath))
+
+	return binary
+}
+
+// readTools returns the companion tools in the artifact directory as name: path.
+// Returns nil if there are none.
+func readTools(artifactDir string) map[string]string {
+	dir := filepath.Join(artifactDir, toolsDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	tools := map[string]string{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		tools[strings.TrimSuffix(entry.Name(), ".exe")] = filepath.Join(dir, entry.Name())
+	}
+
+	if len(tools) == 0 {
+		return nil
+	}
+
+	return tools
+}
+
+// moveTools moves the companion tools extracted with the partial file to the artifact
+// directory, replacing any left by a previous download, and shares them with the binary
+func (p *Provider) moveTools(partialPath string, artifactDir string) error {
+	dir := filepath.Join(artifactDir, toolsDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	extracted := partialPath + toolsSuffix
+	if _, err := os.Stat(extracted); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.Rename(extracted, dir); err != nil {
+		return err
+	}
+
+	if !p.permissions.shared() {
+		return nil
+	}
+
+	if err := p.permissions.apply(dir, cacheDirPerm|p.permissions.dir); err != nil {
+		return err
+	}
+	for _, tool := range readTools(artifactDir) {
+		if err := p.permissions.shareBinary(tool); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestCompanionTools(t *testing.T) {
+	t.Parallel()
+
+	content := "k6"
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+
+	testCases := []struct {
+		title       string
+		artifact    []byte
+		expectTools map[string]string
+	}{
+		{
+			title:       "binary",
+			artifact:    []byte(content),
+			expectTools: nil,
+		},
+		{
+			title: "tar.gz with tools",
+			artifact: newTarGz(t, map[string]string{
+				"dist/" + k6Binary:  content,
+				"dist/k6-agent":     "agent",
+				"dist/.hidden-tool": "hidden",
+			}),
+			expectTools: map[string]string{"k6-agent": "agent"},
+		},
+		{
+			title: "zip with tools",
+			artifact: newZip(t, map[string]string{
+				k6Binary:      content,
+				"browser.exe": "browser",
+				"README.md":   "not executable",
+			}),
+			expectTools: map[string]string{"browser": "browser"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write(tc.artifact)
+			}))
+			t.Cleanup(store.Close)
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+				},
+			)
+			binDir := t.TempDir()
+			provider := newTestProvider(t, buildSrv, binDir)
+
+			// the tools are returned both when the binary is downloaded and from the cache
+			for range 2 {
+				binary, err := provider.GetBinary(context.TODO(), nil)
+				if err != nil {
+					t.Fatalf("unexpected %v", err)
+				}
+
+				if len(binary.Tools) != len(tc.expectTools) {
+					t.Fatalf("expected tools %v got %v", tc.expectTools, binary.Tools)
+				}
+
+				for name, expected := range tc.expectTools {
+					path, found := binary.Tools[name]
+					if !found {
+						t.Fatalf("expected tool %s got %v", name, binary.Tools)
+					}
+					if filepath.Dir(filepath.Dir(path)) != filepath.Dir(binary.Path) {
+						t.Fatalf("expected tool in artifact directory got %s", path)
+					}
+
+					got, err := os.ReadFile(path)
+					if err != nil {
+						t.Fatalf("reading tool %v", err)
+					}
+					if string(got) != expected {
+						t.Fatalf("expected %q got %q", expected, got)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package k6provider
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+// UpdateNotice describes a newer artifact that satisfies the dependencies of a binary provided
+// from the cache. See [Config.UpdateCheck]
+type UpdateNotice struct {
+	// Current binary provided from the cache
+	Current K6Binary
+	// Latest artifact that satisfies the dependencies
+	Latest Artifact
+}
+
+// lookupWithUpdateCheck returns the binary that satisfied the same dependencies before from the
+// cache, if the update check is enabled or if it was resolved within the stale-while-revalidate
+// time, and checks in the background if the build service resolves them to a different artifact.
+// The binary the dependencies were last resolved to by the provider is returned if it still
+// exists, so the cache is looked up (see [Provider.lookupRequest]) only the first time.
+// Returns the binary and true if found.
+func (p *Provider) lookupWithUpdateCheck(ctx context.Context, deps k6deps.Dependencies) (K6Binary, bool) {
+	if !p.config.UpdateCheck && p.config.StaleWhileRevalidate == 0 {
+		return K6Binary{}, false
+	}
+
+	maxAge := p.config.StaleWhileRevalidate
+	if p.config.UpdateCheck {
+		maxAge = 0
+	}
+
+	k6Constrains, buildDeps := p.buildDeps(deps)
+	options := buildOptionsFrom(ctx).forPlatform(p.platform)
+	request := requestKey(p.platform, catalogFrom(ctx), options.key(), k6Constrains, buildDeps)
+
+	binary, found := p.remembered(request, maxAge)
+	if !found {
+		binary, found = p.lookupRequest(request, maxAge)
+	}
+	if !found {
+		return K6Binary{}, false
+	}
+
+	p.events.publish(Event{Type: EventCacheHit, ArtifactID: binary.ID})
+
+	// the update cannot be checked without network
+	if networkDenied(p.config) {
+		return binary, true
+	}
+
+	// a single check for concurrent requests for the same dependencies
+	if _, checking := p.updating.LoadOrStore(request, true); checking {
+		return binary, true
+	}
+
+	// the check outlives the request, but uses its catalog and build options
+	checkCtx := WithBuildOptions(WithCatalog(p.ctx, catalogFrom(ctx)), buildOptionsFrom(ctx))
+	p.background(func() {
+		defer p.updating.Delete(request)
+		p.checkUpdate(checkCtx, binary, request, k6Constrains, buildDeps)
+	})
+
+	return binary, true
+}
+
+// checkUpdate requests the artifact for the dependencies to the build service and, if it is not
+// the binary's artifact, notifies the update and forgets the binary satisfied the request, so
+// the next request obtains the new artifact. Otherwise, records the request was resolved again.
+// Failures are ignored, as the binary was provided.
+func (p *Provider) checkUpdate(
+	ctx context.Context,
+	binary K6Binary,
+	request string,
+	k6Constrains string,
+	deps []k6build.Dependency,
+) {
+	latest, err := p.build(ctx, k6Constrains, deps)
+	if err != nil {
+		return
+	}
+
+	if latest.ID == binary.ID {
+		_ = writeMetadata(filepath.Dir(binary.Path), latest, request)
+		p.remember(request, binary)
+		return
+	}
+
+	p.resolutions.Delete(request)
+	_ = forgetRequest(filepath.Dir(binary.Path), request)
+
+	p.events.publish(Event{Type: EventUpdateAvailable, ArtifactID: latest.ID})
+	if p.config.OnUpdateAvailable != nil {
+		p.config.OnUpdateAvailable(UpdateNotice{Current: binary, Latest: latest})
+	}
+}
@@ -0,0 +1,133 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestUpdateCheck(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	latest := atomic.Value{}
+	latest.Store("artifact-1")
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			return k6build.Artifact{ID: latest.Load().(string), URL: store.URL, Checksum: checksum}, nil
+		},
+	)
+
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+	provider.config.UpdateCheck = true
+
+	notices := make(chan UpdateNotice, 1)
+	provider.config.OnUpdateAvailable = func(notice UpdateNotice) { notices <- notice }
+
+	getBinary := func(expected string) {
+		t.Helper()
+
+		binary, err := provider.GetBinary(context.TODO(), nil)
+		if err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+		if binary.ID != expected {
+			t.Fatalf("expected %s got %s", expected, binary.ID)
+		}
+
+		// wait for the update check
+		provider.tasks.Wait()
+	}
+
+	// not in the cache
+	getBinary("artifact-1")
+
+	// provided from the cache, without update
+	getBinary("artifact-1")
+	if len(notices) != 0 {
+		t.Fatalf("unexpected update %+v", <-notices)
+	}
+
+	// provided from the cache, while a newer artifact is available
+	latest.Store("artifact-2")
+	getBinary("artifact-1")
+	if len(notices) != 1 {
+		t.Fatalf("expected update notice")
+	}
+	if notice := <-notices; notice.Current.ID != "artifact-1" || notice.Latest.ID != "artifact-2" {
+		t.Fatalf("expected update from artifact-1 to artifact-2 got %+v", notice)
+	}
+
+	// the next request obtains the newer artifact
+	getBinary("artifact-2")
+}
+
+func TestUpdateCheckRemembersResolution(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	available := atomic.Bool{}
+	available.Store(true)
+	buildSrv := buildServiceFunc(
+		func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+			if !available.Load() {
+				return k6build.Artifact{}, errors.New("connection refused")
+			}
+			return k6build.Artifact{ID: "artifact", URL: store.URL, Checksum: checksum}, nil
+		},
+	)
+
+	binDir := t.TempDir()
+	provider := newTestProvider(t, buildSrv, binDir)
+	provider.config.UpdateCheck = true
+
+	if _, err := provider.GetBinary(context.TODO(), nil); err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	// without metadata, the binary can only be found if the resolution is remembered
+	if err := os.Remove(filepath.Join(binDir, "artifact", metadataFile)); err != nil {
+		t.Fatalf("removing metadata %v", err)
+	}
+	available.Store(false)
+
+	binary, err := provider.GetBinary(context.TODO(), nil)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if binary.ID != "artifact" {
+		t.Fatalf("expected artifact got %s", binary.ID)
+	}
+	provider.tasks.Wait()
+
+	// the resolution is forgotten if the binary is removed
+	if err := os.RemoveAll(filepath.Join(binDir, "artifact")); err != nil {
+		t.Fatalf("removing artifact %v", err)
+	}
+	if _, err := provider.GetBinary(context.TODO(), nil); !errors.Is(err, ErrBuild) {
+		t.Fatalf("expected %v got %v", ErrBuild, err)
+	}
+}
@@ -0,0 +1,229 @@
+package k6provider
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// usageLogFile is the file in the binary directory that records the dependencies of the
+	// binaries provisioned, one JSON object per line
+	usageLogFile = ".usage.jsonl"
+	// maxUsageLogSize is the size of the usage log above which its oldest half is discarded
+	maxUsageLogSize = 4 << 20
+)
+
+// DependencyUsage describes the dependencies of a binary provisioned. See [Config.UsageAnalytics]
+type DependencyUsage struct {
+	// Time when the binary was provisioned
+	Time time.Time `json:"time"`
+	// ArtifactID id of the binary's artifact
+	ArtifactID string `json:"artifactId"`
+	// Dependencies versions of the dependencies of the binary, including k6
+	// e.g. {"k6": "v0.50.0", "k6/x/kubernetes": "v0.9.0"}
+	Dependencies map[string]string `json:"dependencies"`
+	// Cached the binary was found in the cache
+	Cached bool `json:"cached"`
+}
+
+// VersionUsage is the usage of a version of a dependency
+type VersionUsage struct {
+	// Version of the dependency
+	Version string `json:"version"`
+	// Provisioned number of binaries provisioned with the version
+	Provisioned int `json:"provisioned"`
+	// LastProvisioned time a binary with the version was last provisioned
+	LastProvisioned time.Time `json:"lastProvisioned"`
+}
+
+// ExtensionUsage is the usage of a dependency, k6 or an extension
+type ExtensionUsage struct {
+	// Name of the dependency
+	Name string `json:"name"`
+	// Provisioned number of binaries provisioned with the dependency
+	Provisioned int `json:"provisioned"`
+	// LastProvisioned time a binary with the dependency was last provisioned
+	LastProvisioned time.Time `json:"lastProvisioned"`
+	// Versions of the dependency provisioned, most provisioned first
+	Versions []VersionUsage `json:"versions"`
+}
+
+// UsageReport summarizes the dependencies of the binaries provisioned. See [Provider.UsageReport]
+type UsageReport struct {
+	// Since time of the oldest provision recorded
+	Since time.Time `json:"since"`
+	// Until time of the latest provision recorded
+	Until time.Time `json:"until"`
+	// Provisioned number of binaries provisioned
+	Provisioned int `json:"provisioned"`
+	// Dependencies usage of each dependency, most provisioned first
+	Dependencies []ExtensionUsage `json:"dependencies"`
+}
+
+// UsageReport returns which extensions and versions were provisioned and how often, so platform
+// teams can decide which ones to pre-build, deprecate or add to their curated catalog.
+//
+// The binaries provisioned are recorded in the binary directory if [Config.UsageAnalytics] is
+// enabled, so the report includes those provisioned by other processes sharing it. Only the most
+// recent provisions are kept. Returns an empty report if no binary was recorded.
+func (p *Provider) UsageReport() (UsageReport, error) {
+	usages, err := readUsageLog(filepath.Join(p.binDir, usageLogFile))
+	if err != nil {
+		return UsageReport{}, NewWrappedError(ErrBinary, err)
+	}
+
+	return summarizeUsage(usages), nil
+}
+
+// recordDependencyUsage records the dependencies of the binary provisioned in the usage log, if
+// enabled, and reports them to the OnDependencyUsage callback. Failing to record them is not an error.
+func (p *Provider) recordDependencyUsage(binary K6Binary, cached bool) {
+	if !p.config.UsageAnalytics && p.config.OnDependencyUsage == nil {
+		return
+	}
+
+	usage := DependencyUsage{
+		Time:         time.Now().UTC(),
+		ArtifactID:   binary.ID,
+		Dependencies: binary.Dependencies,
+		Cached:       cached,
+	}
+
+	if p.config.OnDependencyUsage != nil {
+		p.config.OnDependencyUsage(usage)
+	}
+
+	if !p.config.UsageAnalytics {
+		return
+	}
+
+	logPath := filepath.Join(p.binDir, usageLogFile)
+	size, err := appendUsage(logPath, usage)
+	if err != nil {
+		return
+	}
+
+	_ = p.permissions.shareFile(logPath)
+
+	if size > maxUsageLogSize {
+		p.truncateSharedLog(logPath)
+	}
+}
+
+// appendUsage appends the usage to the log, returning the size of the log
+func appendUsage(logPath string, usage DependencyUsage) (int64, error) {
+	line, err := json.Marshal(usage)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, cacheFilePerm) //nolint:gosec
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	// a single write per usage, so concurrent records are not interleaved
+	if _, err = fmt.Fprintf(file, "%s\n", line); err != nil {
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// readUsageLog reads the usages recorded in the log, if it exists
+func readUsageLog(logPath string) ([]DependencyUsage, error) {
+	file, err := os.Open(logPath) //nolint:gosec
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	usages := []DependencyUsage{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		usage := DependencyUsage{}
+		// lines partially written by an interrupted process or truncated are ignored
+		if json.Unmarshal(scanner.Bytes(), &usage) == nil {
+			usages = append(usages, usage)
+		}
+	}
+
+	return usages, scanner.Err()
+}
+
+// summarizeUsage aggregates the usages by dependency and version
+func summarizeUsage(usages []DependencyUsage) UsageReport {
+	report := UsageReport{Provisioned: len(usages), Dependencies: []ExtensionUsage{}}
+
+	dependencies := map[string]*ExtensionUsage{}
+	versions := map[string]map[string]*VersionUsage{}
+	for _, usage := range usages {
+		if report.Since.IsZero() || usage.Time.Before(report.Since) {
+			report.Since = usage.Time
+		}
+		if usage.Time.After(report.Until) {
+			report.Until = usage.Time
+		}
+
+		for name, version := range usage.Dependencies {
+			dependency, found := dependencies[name]
+			if !found {
+				dependency = &ExtensionUsage{Name: name}
+				dependencies[name] = dependency
+				versions[name] = map[string]*VersionUsage{}
+			}
+			dependency.Provisioned++
+			if usage.Time.After(dependency.LastProvisioned) {
+				dependency.LastProvisioned = usage.Time
+			}
+
+			versionUsage, found := versions[name][version]
+			if !found {
+				versionUsage = &VersionUsage{Version: version}
+				versions[name][version] = versionUsage
+			}
+			versionUsage.Provisioned++
+			if usage.Time.After(versionUsage.LastProvisioned) {
+				versionUsage.LastProvisioned = usage.Time
+			}
+		}
+	}
+
+	for name, dependency := range dependencies {
+		for _, version := range versions[name] {
+			dependency.Versions = append(dependency.Versions, *version)
+		}
+		sort.Slice(dependency.Versions, func(i, j int) bool {
+			a, b := dependency.Versions[i], dependency.Versions[j]
+			if a.Provisioned != b.Provisioned {
+				return a.Provisioned > b.Provisioned
+			}
+			return a.Version < b.Version
+		})
+		report.Dependencies = append(report.Dependencies, *dependency)
+	}
+
+	sort.Slice(report.Dependencies, func(i, j int) bool {
+		a, b := report.Dependencies[i], report.Dependencies[j]
+		if a.Provisioned != b.Provisioned {
+			return a.Provisioned > b.Provisioned
+		}
+		return a.Name < b.Name
+	})
+
+	return report
+}
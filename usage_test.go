@@ -0,0 +1,124 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+	"github.com/grafana/k6deps"
+)
+
+func TestUsageReport(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(_ context.Context, _ string, _ string, deps []k6build.Dependency) (k6build.Artifact, error) {
+			dependencies := map[string]string{"k6": "v0.55.0"}
+			for _, dep := range deps {
+				dependencies[dep.Name] = "v0.4.0"
+			}
+			return k6build.Artifact{
+				ID:           fmt.Sprintf("artifact-%d", len(deps)),
+				URL:          store.URL,
+				Dependencies: dependencies,
+				Checksum:     checksum,
+			}, nil
+		},
+	)
+
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+	provider.config.UsageAnalytics = true
+
+	callbacks := 0
+	provider.config.OnDependencyUsage = func(DependencyUsage) { callbacks++ }
+
+	faker := k6deps.Dependencies{}
+	if err := faker.UnmarshalText([]byte("k6/x/faker>0.3")); err != nil {
+		t.Fatalf("test setup %v", err)
+	}
+
+	for _, deps := range []k6deps.Dependencies{nil, faker, faker} {
+		if _, err := provider.GetBinary(context.TODO(), deps); err != nil {
+			t.Fatalf("unexpected %v", err)
+		}
+	}
+
+	if callbacks != 3 {
+		t.Fatalf("expected 3 callbacks got %d", callbacks)
+	}
+
+	report, err := provider.UsageReport()
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if report.Provisioned != 3 {
+		t.Fatalf("expected 3 provisioned got %d", report.Provisioned)
+	}
+
+	usage := map[string]int{}
+	for _, dependency := range report.Dependencies {
+		usage[dependency.Name] = dependency.Provisioned
+	}
+	expected := map[string]int{"k6": 3, "k6/x/faker": 2}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Fatalf("expected %v got %v", expected, usage)
+	}
+}
+
+func TestSummarizeUsage(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	usages := []DependencyUsage{
+		{Time: now.Add(-2 * time.Hour), Dependencies: map[string]string{"k6": "v0.54.0", "k6/x/sql": "v0.4.0"}},
+		{Time: now.Add(-time.Hour), Dependencies: map[string]string{"k6": "v0.55.0"}},
+		{Time: now, Dependencies: map[string]string{"k6": "v0.55.0", "k6/x/sql": "v0.4.1"}},
+	}
+
+	report := summarizeUsage(usages)
+
+	expected := UsageReport{
+		Since:       now.Add(-2 * time.Hour),
+		Until:       now,
+		Provisioned: 3,
+		Dependencies: []ExtensionUsage{
+			{
+				Name:            "k6",
+				Provisioned:     3,
+				LastProvisioned: now,
+				Versions: []VersionUsage{
+					{Version: "v0.55.0", Provisioned: 2, LastProvisioned: now},
+					{Version: "v0.54.0", Provisioned: 1, LastProvisioned: now.Add(-2 * time.Hour)},
+				},
+			},
+			{
+				Name:            "k6/x/sql",
+				Provisioned:     2,
+				LastProvisioned: now,
+				Versions: []VersionUsage{
+					{Version: "v0.4.0", Provisioned: 1, LastProvisioned: now.Add(-2 * time.Hour)},
+					{Version: "v0.4.1", Provisioned: 1, LastProvisioned: now},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("expected %+v got %+v", expected, report)
+	}
+}
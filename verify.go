@@ -0,0 +1,209 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+// verifiedFile is the file in an artifact directory whose modification time records the last
+// time the binary was verified
+const verifiedFile = "verified"
+
+// CacheReport summarizes the verification of the cache. See [Provider.VerifyCache]
+type CacheReport struct {
+	// Valid number of binaries verified
+	Valid int
+	// Skipped number of binaries not verified because they were verified recently. See [VerifyOptions]
+	Skipped int
+	// Removed artifact directories removed because they don't contain a valid binary
+	Removed []string
+	// Corrupted artifact directories removed because their binary doesn't match its checksum
+	Corrupted []string
+}
+
+// add adds the results of another report
+func (r *CacheReport) add(other CacheReport) {
+	r.Valid += other.Valid
+	r.Skipped += other.Skipped
+	r.Removed = append(r.Removed, other.Removed...)
+	r.Corrupted = append(r.Corrupted, other.Corrupted...)
+}
+
+// VerifyOptions defines how the cache is verified. See [Provider.VerifyCacheWithOptions]
+type VerifyOptions struct {
+	// Workers number of binaries verified concurrently. Defaults to the number of CPUs
+	Workers int
+	// MaxAge if set, only the binaries not verified within this period are verified
+	MaxAge time.Duration
+}
+
+// VerifyCache checks the binaries in the cache directories. The artifact directories without a
+// valid binary, left by failed downloads or manual tampering, are removed. Binaries are verified
+// against the checksum stored with the artifact, if available, and removed if they don't match.
+// Directories of downloads that may be in progress are kept.
+func (p *Provider) VerifyCache(ctx context.Context) (CacheReport, error) {
+	return p.VerifyCacheWithOptions(ctx, VerifyOptions{})
+}
+
+// VerifyCacheWithOptions checks the binaries in the cache directories as [Provider.VerifyCache],
+// verifying multiple binaries concurrently and, optionally, only those not verified recently.
+func (p *Provider) VerifyCacheWithOptions(ctx context.Context, opts VerifyOptions) (CacheReport, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	artifactDirs := make(chan string)
+	report := CacheReport{}
+	errs := []error{}
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for artifactDir := range artifactDirs {
+				result := CacheReport{}
+				err := p.verifyArtifact(artifactDir, opts.MaxAge, &result)
+
+				mutex.Lock()
+				report.add(result)
+				if err != nil {
+					errs = append(errs, err)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	listErrs := listArtifactDirs(ctx, p.binDirs(), artifactDirs)
+	close(artifactDirs)
+	wg.Wait()
+
+	slices.Sort(report.Removed)
+	slices.Sort(report.Corrupted)
+
+	if ctx.Err() != nil {
+		return report, ctx.Err()
+	}
+
+	errs = append(errs, listErrs...)
+	if len(errs) > 0 {
+		return report, NewWrappedError(ErrBinary, errors.Join(errs...))
+	}
+
+	return report, nil
+}
+
+// listArtifactDirs sends the artifact directories in the binary directories to the channel,
+// until the context is done
+func listArtifactDirs(ctx context.Context, binDirs []string, artifactDirs chan<- string) []error {
+	errs := []error{}
+	for _, dir := range binDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		for _, entry := range entries {
+			if !isArtifactDir(entry) {
+				continue
+			}
+
+			select {
+			case artifactDirs <- filepath.Join(dir, entry.Name()):
+			case <-ctx.Done():
+				return errs
+			}
+		}
+	}
+
+	return errs
+}
+
+// verifyArtifact verifies an artifact directory, adding the result to the report. The binary is
+// not verified if it was verified within maxAge.
+func (p *Provider) verifyArtifact(artifactDir string, maxAge time.Duration, report *CacheReport) error {
+	removed, err := collectOrphan(artifactDir)
+	if err != nil {
+		return err
+	}
+	if removed {
+		report.Removed = append(report.Removed, artifactDir)
+		return nil
+	}
+
+	binPath := filepath.Join(artifactDir, k6Binary)
+	if _, err = os.Stat(binPath); err != nil {
+		// download in progress
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	verified := filepath.Join(artifactDir, verifiedFile)
+	if maxAge > 0 {
+		if info, err := os.Stat(verified); err == nil && time.Since(info.ModTime()) < maxAge {
+			report.Skipped++
+			return nil
+		}
+	}
+
+	// the artifact's metadata is not required, binaries without it are not verified
+	if metadata, err := readMetadata(artifactDir); err == nil {
+		err = p.verifyBinary(binPath, metadata.Artifact.Checksum)
+		if errors.Is(err, ErrChecksumMismatch) {
+			if err := os.RemoveAll(artifactDir); err != nil {
+				return err
+			}
+			report.Corrupted = append(report.Corrupted, artifactDir)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// failing to record the verification is not an error, the binary is verified again next time
+		if err := os.WriteFile(verified, nil, 0o600); err == nil {
+			now := time.Now()
+			_ = os.Chtimes(verified, now, now)
+		}
+	}
+
+	report.Valid++
+
+	return nil
+}
+
+// verifyBinary checks the binary matches the expected checksum. Compressed binaries are
+// verified by their decompressed content.
+func (p *Provider) verifyBinary(binPath string, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+
+	binary, err := p.openCached(binPath)
+	if err != nil {
+		return err
+	}
+	defer binary.Close() //nolint:errcheck
+
+	hash := sha256.New()
+	if _, err := defaultBuffers.copy(hash, binary); err != nil {
+		return err
+	}
+
+	return verifyChecksum(checksum, hash)
+}
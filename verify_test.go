@@ -0,0 +1,138 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestVerifyCache(t *testing.T) {
+	t.Parallel()
+
+	binary := []byte("k6 binary")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+
+	old := time.Now().Add(-2 * partialFileTTL)
+
+	binDir := t.TempDir()
+	setup := []struct {
+		id       string
+		binary   []byte
+		checksum string
+		modTime  time.Time
+	}{
+		{id: "valid", binary: binary, checksum: checksum, modTime: old},
+		{id: "no-metadata", binary: binary, modTime: old},
+		{id: "corrupted", binary: []byte("tampered"), checksum: checksum, modTime: old},
+		{id: "orphan", modTime: old},
+		{id: "starting", modTime: time.Now()},
+	}
+
+	for _, a := range setup {
+		artifactDir := filepath.Join(binDir, a.id)
+		if err := os.MkdirAll(artifactDir, 0o750); err != nil {
+			t.Fatalf("test setup: creating dir %v", err)
+		}
+		if a.binary != nil {
+			if err := os.WriteFile(filepath.Join(artifactDir, k6Binary), a.binary, 0o600); err != nil {
+				t.Fatalf("test setup writing file %v", err)
+			}
+		}
+		if a.checksum != "" {
+			artifact := Artifact{ID: a.id, Checksum: a.checksum}
+			if err := writeMetadata(artifactDir, artifact, "request"); err != nil {
+				t.Fatalf("test setup writing metadata %v", err)
+			}
+		}
+		if err := os.Chtimes(artifactDir, a.modTime, a.modTime); err != nil {
+			t.Fatalf("test setup changing mod timestamp %v", err)
+		}
+	}
+
+	provider := newTestProvider(t, nil, binDir)
+
+	report, err := provider.VerifyCache(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+
+	if report.Valid != 2 {
+		t.Fatalf("expected 2 valid binaries got %d", report.Valid)
+	}
+
+	if !slices.Equal(report.Removed, []string{filepath.Join(binDir, "orphan")}) {
+		t.Fatalf("expected orphan removed got %v", report.Removed)
+	}
+
+	if !slices.Equal(report.Corrupted, []string{filepath.Join(binDir, "corrupted")}) {
+		t.Fatalf("expected corrupted removed got %v", report.Corrupted)
+	}
+
+	for _, id := range []string{"valid", "no-metadata", "starting"} {
+		if _, err := os.Stat(filepath.Join(binDir, id)); err != nil {
+			t.Fatalf("expected %s kept got %v", id, err)
+		}
+	}
+}
+
+func TestVerifyCacheIncremental(t *testing.T) {
+	t.Parallel()
+
+	binary := []byte("k6 binary")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+
+	binDir := t.TempDir()
+	for _, id := range []string{"a", "b", "c", "d"} {
+		artifactDir := filepath.Join(binDir, id)
+		if err := os.MkdirAll(artifactDir, 0o750); err != nil {
+			t.Fatalf("test setup: creating dir %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(artifactDir, k6Binary), binary, 0o600); err != nil {
+			t.Fatalf("test setup writing file %v", err)
+		}
+		if err := writeMetadata(artifactDir, Artifact{ID: id, Checksum: checksum}, "request"); err != nil {
+			t.Fatalf("test setup writing metadata %v", err)
+		}
+	}
+
+	provider := newTestProvider(t, nil, binDir)
+	opts := VerifyOptions{Workers: 3, MaxAge: time.Hour}
+
+	report, err := provider.VerifyCacheWithOptions(context.TODO(), opts)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if report.Valid != 4 || report.Skipped != 0 {
+		t.Fatalf("expected 4 valid binaries got %+v", report)
+	}
+
+	// binaries verified recently are skipped, unless the verification is older than the max age
+	old := time.Now().Add(-2 * time.Hour)
+	if err = os.Chtimes(filepath.Join(binDir, "a", verifiedFile), old, old); err != nil {
+		t.Fatalf("test setup changing mod timestamp %v", err)
+	}
+
+	report, err = provider.VerifyCacheWithOptions(context.TODO(), opts)
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if report.Valid != 1 || report.Skipped != 3 {
+		t.Fatalf("expected 1 valid and 3 skipped binaries got %+v", report)
+	}
+
+	// without max age, all binaries are verified
+	report, err = provider.VerifyCache(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected %v", err)
+	}
+	if report.Valid != 4 || report.Skipped != 0 {
+		t.Fatalf("expected 4 valid binaries got %+v", report)
+	}
+}
@@ -0,0 +1,68 @@
+package k6provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// versionVerifiedFile is the file in the artifact directory that records its binary passed
+	// the version verification
+	versionVerifiedFile = ".version-verified"
+	// versionTimeout is the maximum time for running the binary's version command
+	versionTimeout = 30 * time.Second
+)
+
+// deliver returns the binary from the cache ready to be used by the caller: with the path to
+// an executable file and, if a version verifier is configured, with its version verified
+func (p *Provider) deliver(binary K6Binary) (K6Binary, error) {
+	artifactDir := filepath.Dir(binary.Path)
+
+	binary, err := p.executable(binary)
+	if err != nil {
+		return K6Binary{}, err
+	}
+
+	if err = p.verifyVersion(artifactDir, binary); err != nil {
+		return K6Binary{}, p.recordError(withArtifact(err, binary.ID))
+	}
+
+	return binary, nil
+}
+
+// verifyVersion runs the binary's version command and checks its output using the version
+// verifier, unless the binary in the artifact directory was verified before
+func (p *Provider) verifyVersion(artifactDir string, binary K6Binary) error {
+	verifier := p.config.VersionVerifier
+	if verifier == nil {
+		return nil
+	}
+
+	marker := filepath.Join(artifactDir, versionVerifiedFile)
+	if _, err := os.Stat(marker); err == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, versionTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, binary.Path, "version").Output() //nolint:gosec
+	if err != nil {
+		return NewWrappedError(ErrBinary, fmt.Errorf("running version command: %w", err))
+	}
+
+	if err = verifier(string(output), binary.Dependencies); err != nil {
+		return NewWrappedError(ErrVersionMismatch, err)
+	}
+
+	// failing to record the verification is not an error, the binary is verified again next time
+	if err = os.WriteFile(marker, nil, cacheFilePerm); err == nil {
+		_ = p.permissions.shareFile(marker)
+	}
+
+	return nil
+}
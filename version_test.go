@@ -0,0 +1,94 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestVersionVerifier(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the test binary is a shell script")
+	}
+
+	script := []byte("#!/bin/sh\necho \"k6 v0.55.0\"\necho \"Extensions:\"\necho \"  k6/x/faker v0.4.0\"\n")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(script))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(script)
+	}))
+	t.Cleanup(store.Close)
+
+	testCases := []struct {
+		title       string
+		deps        map[string]string
+		expectErr   error
+		expectCalls int32
+	}{
+		{
+			// verified binaries are not verified again
+			title:       "extensions match",
+			deps:        map[string]string{"k6": "v0.55.0", "k6/x/faker": "v0.4.0"},
+			expectErr:   nil,
+			expectCalls: 1,
+		},
+		{
+			title:       "extension missing",
+			deps:        map[string]string{"k6": "v0.55.0", "k6/x/sql": "v0.1.0"},
+			expectErr:   ErrVersionMismatch,
+			expectCalls: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			buildSrv := buildServiceFunc(
+				func(context.Context, string, string, []k6build.Dependency) (k6build.Artifact, error) {
+					return k6build.Artifact{
+						ID:           "artifact",
+						URL:          store.URL,
+						Checksum:     checksum,
+						Dependencies: tc.deps,
+					}, nil
+				},
+			)
+
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+
+			calls := atomic.Int32{}
+			provider.config.VersionVerifier = func(output string, deps map[string]string) error {
+				calls.Add(1)
+				for name, version := range deps {
+					if !strings.Contains(output, name+" "+version) {
+						return fmt.Errorf("%s %s not in binary", name, version)
+					}
+				}
+				return nil
+			}
+
+			for range 2 {
+				_, err := provider.GetBinary(context.TODO(), nil)
+				if !errors.Is(err, tc.expectErr) {
+					t.Fatalf("expected %v got %v", tc.expectErr, err)
+				}
+			}
+
+			if calls.Load() != tc.expectCalls {
+				t.Fatalf("expected %d calls got %d", tc.expectCalls, calls.Load())
+			}
+		})
+	}
+}
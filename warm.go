@@ -0,0 +1,275 @@
+package k6provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/grafana/k6deps"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultWarmConcurrency default number of dependency sets warmed concurrently
+const defaultWarmConcurrency = 2
+
+// WarmStatus is the outcome of warming the cache for a dependency set and platform
+type WarmStatus string
+
+const (
+	// WarmCached the binary was already in the cache
+	WarmCached WarmStatus = "cached"
+	// WarmDownloaded the binary was built, if needed, and downloaded into the cache
+	WarmDownloaded WarmStatus = "downloaded"
+	// WarmBuilt the artifact was built by the build service, but not downloaded,
+	// as it is for a platform other than the provider's
+	WarmBuilt WarmStatus = "built"
+	// WarmFailed the artifact could not be built or downloaded
+	WarmFailed WarmStatus = "failed"
+)
+
+// WarmEntry is a dependency set in a warming manifest. See [Provider.WarmFromManifest]
+type WarmEntry struct {
+	// Dependencies constraints of the dependency set, using the same format as the profiles
+	// e.g. "k6>=0.52;k6/x/faker>0.3". See [Config.Profiles]
+	Dependencies string `json:"dependencies"`
+	// Platforms for which the artifacts are built. If empty, the provider's platform
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// UnmarshalJSON accepts an entry as an object or as the constraints of the dependencies
+func (e *WarmEntry) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		return json.Unmarshal(data, &e.Dependencies)
+	}
+
+	type entry WarmEntry
+
+	return json.Unmarshal(data, (*entry)(e))
+}
+
+// WarmManifest is a list of dependency sets to warm the cache with. See [Provider.WarmFromManifest]
+type WarmManifest struct {
+	// Concurrency maximum number of dependency sets warmed concurrently. Defaults to 2
+	Concurrency int `json:"concurrency,omitempty"`
+	// Entries dependency sets
+	Entries []WarmEntry `json:"entries"`
+}
+
+// WarmResult is the result of warming the cache for a dependency set and platform
+type WarmResult struct {
+	// Dependencies constraints of the dependency set, as in the manifest
+	Dependencies string `json:"dependencies"`
+	// Platform of the artifact
+	Platform string `json:"platform"`
+	// ArtifactID id of the artifact, if it was built
+	ArtifactID string `json:"artifactId,omitempty"`
+	// Status outcome of warming the cache
+	Status WarmStatus `json:"status"`
+	// Error reason of the failure, if failed
+	Error string `json:"error,omitempty"`
+}
+
+// WarmReport is the summary of warming the cache from a manifest. See [Provider.WarmFromManifest]
+type WarmReport struct {
+	// Results of each dependency set and platform, in the order of the manifest
+	Results []WarmResult `json:"results"`
+	// Cached number of binaries already in the cache
+	Cached int `json:"cached"`
+	// Downloaded number of binaries downloaded
+	Downloaded int `json:"downloaded"`
+	// Built number of artifacts built for other platforms
+	Built int `json:"built"`
+	// Failed number of dependency sets and platforms that failed
+	Failed int `json:"failed"`
+}
+
+// warmJob is a dependency set to be warmed for a platform
+type warmJob struct {
+	constraints string
+	deps        k6deps.Dependencies
+	platform    string
+}
+
+// WarmFromManifest pre-builds and downloads the binaries for the dependency sets in a manifest,
+// for example, from a nightly job that keeps the caches of an organization hot.
+//
+// The manifest is a YAML or JSON list of dependency sets, each one as its constraints or as an
+// object with its constraints and platforms:
+//
+//	# extensions used by the load tests
+//	- k6>=0.52;k6/x/faker>0.3
+//	- dependencies: k6/x/sql>0.4
+//	  platforms:
+//	    - linux/amd64
+//	    - darwin/arm64
+//
+// Alternatively, it is an object with the list as "entries" and the maximum number of dependency
+// sets warmed concurrently as "concurrency" (see [WarmManifest]).
+//
+// Binaries for the provider's platform are downloaded into the cache. Artifacts for other platforms
+// are built by the build service, so they are ready when requested from hosts of that platform.
+//
+// Failures of dependency sets are reported in the summary and don't stop the warming of the others.
+// If the manifest is not valid, an [ErrConfig] error is returned.
+func (p *Provider) WarmFromManifest(ctx context.Context, r io.Reader) (WarmReport, error) {
+	if p.ctx.Err() != nil {
+		return WarmReport{}, ErrClosed
+	}
+
+	manifest, err := readWarmManifest(r)
+	if err != nil {
+		return WarmReport{}, NewWrappedError(ErrConfig, err)
+	}
+
+	jobs, err := p.warmJobs(manifest)
+	if err != nil {
+		return WarmReport{}, NewWrappedError(ErrConfig, err)
+	}
+
+	concurrency := manifest.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultWarmConcurrency
+	}
+
+	results := make([]WarmResult, len(jobs))
+	slots := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, job := range jobs {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = WarmResult{Dependencies: job.constraints, Platform: job.platform}
+			results[i].fail(ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = p.warm(ctx, job)
+		}()
+	}
+	wg.Wait()
+
+	report := WarmReport{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case WarmCached:
+			report.Cached++
+		case WarmDownloaded:
+			report.Downloaded++
+		case WarmBuilt:
+			report.Built++
+		case WarmFailed:
+			report.Failed++
+		}
+	}
+
+	return report, ctx.Err()
+}
+
+// readWarmManifest reads a manifest given as a list of entries or as a [WarmManifest] object,
+// in YAML or JSON. The YAML document is converted to JSON, so the entries are decoded the same
+// way in both formats.
+func readWarmManifest(r io.Reader) (WarmManifest, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return WarmManifest{}, fmt.Errorf("reading manifest %w", err)
+	}
+
+	var document any
+	if err = yaml.Unmarshal(content, &document); err != nil {
+		return WarmManifest{}, fmt.Errorf("parsing manifest %w", err)
+	}
+
+	content, err = json.Marshal(document)
+	if err != nil {
+		return WarmManifest{}, fmt.Errorf("parsing manifest %w", err)
+	}
+
+	manifest := WarmManifest{}
+	if _, isList := document.([]any); isList {
+		err = json.Unmarshal(content, &manifest.Entries)
+	} else {
+		err = json.Unmarshal(content, &manifest)
+	}
+	if err != nil {
+		return WarmManifest{}, fmt.Errorf("parsing manifest %w", err)
+	}
+
+	return manifest, nil
+}
+
+// warmJobs returns the dependency sets in the manifest for each of their platforms
+func (p *Provider) warmJobs(manifest WarmManifest) ([]warmJob, error) {
+	jobs := []warmJob{}
+	errs := []error{}
+	for _, entry := range manifest.Entries {
+		deps := make(k6deps.Dependencies)
+		if err := deps.UnmarshalText([]byte(entry.Dependencies)); err != nil {
+			errs = append(errs, fmt.Errorf("parsing dependencies %q %w", entry.Dependencies, err))
+			continue
+		}
+
+		platforms := entry.Platforms
+		if len(platforms) == 0 {
+			platforms = []string{p.platform}
+		}
+
+		for _, platform := range platforms {
+			platform, err := NormalizePlatform(platform)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			jobs = append(jobs, warmJob{constraints: entry.Dependencies, deps: deps, platform: platform})
+		}
+	}
+
+	return jobs, errors.Join(errs...)
+}
+
+// warm gets the binary for the job's dependencies, if it is for the provider's platform,
+// or requests the artifact to the build service otherwise
+func (p *Provider) warm(ctx context.Context, job warmJob) WarmResult {
+	result := WarmResult{Dependencies: job.constraints, Platform: job.platform}
+
+	if job.platform != p.platform {
+		k6Constrains, buildDeps := p.buildDeps(job.deps)
+		artifact, err := p.buildFor(ctx, job.platform, k6Constrains, buildDeps)
+		if err != nil {
+			result.fail(err)
+			return result
+		}
+		result.ArtifactID = artifact.ID
+		result.Status = WarmBuilt
+		return result
+	}
+
+	ctx = WithTiming(ctx)
+	binary, err := p.GetBinary(ctx, job.deps)
+	if err != nil {
+		result.fail(err)
+		return result
+	}
+	result.ArtifactID = binary.ID
+
+	// the binary was downloaded if time was spent downloading it
+	result.Status = WarmDownloaded
+	if timing, _ := TimingFromContext(ctx); timing.Download == 0 {
+		result.Status = WarmCached
+	}
+
+	return result
+}
+
+// fail records the failure of the result
+func (r *WarmResult) fail(err error) {
+	r.Status = WarmFailed
+	r.Error = err.Error()
+}
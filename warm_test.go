@@ -0,0 +1,136 @@
+package k6provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grafana/k6build"
+)
+
+func TestWarmFromManifest(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("k6")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(store.Close)
+
+	buildSrv := buildServiceFunc(
+		func(_ context.Context, platform string, _ string, deps []k6build.Dependency) (k6build.Artifact, error) {
+			for _, dep := range deps {
+				if dep.Name == "k6/x/broken" {
+					return k6build.Artifact{}, errors.New("build failed")
+				}
+			}
+			return k6build.Artifact{
+				ID:       fmt.Sprintf("%s-%d", strings.ReplaceAll(platform, "/", "-"), len(deps)),
+				URL:      store.URL,
+				Platform: platform,
+				Checksum: checksum,
+			}, nil
+		},
+	)
+
+	testCases := []struct {
+		title        string
+		manifest     string
+		expectErr    error
+		expectStatus []WarmStatus
+	}{
+		{
+			title:        "list of dependency sets",
+			manifest:     `["k6>0.50", {"dependencies": "k6/x/faker>0.3", "platforms": ["darwin-arm64"]}]`,
+			expectStatus: []WarmStatus{WarmDownloaded, WarmBuilt},
+		},
+		{
+			title:        "manifest object",
+			manifest:     `{"concurrency": 1, "entries": ["k6>0.50", "k6>0.50;k6/x/faker>0.3"]}`,
+			expectStatus: []WarmStatus{WarmDownloaded, WarmDownloaded},
+		},
+		{
+			title: "yaml list of dependency sets",
+			manifest: `
+# extensions used by the load tests
+- k6>0.50
+- dependencies: k6/x/faker>0.3 # only for some platforms
+  platforms:
+    - linux/amd64
+    - darwin/arm64
+`,
+			expectStatus: []WarmStatus{WarmDownloaded, WarmDownloaded, WarmBuilt},
+		},
+		{
+			title: "yaml manifest object",
+			manifest: `
+concurrency: 1
+entries:
+  - k6>0.50
+  - "k6>0.50;k6/x/faker>0.3"
+`,
+			expectStatus: []WarmStatus{WarmDownloaded, WarmDownloaded},
+		},
+		{
+			title:        "failed dependency set",
+			manifest:     `["k6/x/broken>0.1", "k6>0.50"]`,
+			expectStatus: []WarmStatus{WarmFailed, WarmDownloaded},
+		},
+		{
+			title:     "invalid platform",
+			manifest:  `[{"dependencies": "k6>0.50", "platforms": ["amiga"]}]`,
+			expectErr: ErrConfig,
+		},
+		{
+			title:     "invalid manifest",
+			manifest:  `{"entries": "k6>0.50"}`,
+			expectErr: ErrConfig,
+		},
+		{
+			title:     "invalid yaml",
+			manifest:  "entries:\n  - k6>0.50\n - k6/x/faker>0.3\n",
+			expectErr: ErrConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Parallel()
+
+			provider := newTestProvider(t, buildSrv, t.TempDir())
+
+			report, err := provider.WarmFromManifest(context.TODO(), strings.NewReader(tc.manifest))
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected %v got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			status := []WarmStatus{}
+			for _, result := range report.Results {
+				status = append(status, result.Status)
+			}
+			if !reflect.DeepEqual(status, tc.expectStatus) {
+				t.Fatalf("expected %v got %v", tc.expectStatus, status)
+			}
+
+			// warming again finds the binaries in the cache
+			report, err = provider.WarmFromManifest(context.TODO(), strings.NewReader(tc.manifest))
+			if err != nil {
+				t.Fatalf("unexpected %v", err)
+			}
+			if report.Downloaded != 0 {
+				t.Fatalf("expected no downloads got %d", report.Downloaded)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package k6provider
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is the time a watched file must remain unmodified before checking its dependencies
+const watchDelay = 100 * time.Millisecond
+
+// WatchFunc is called by a [Watcher] with the binary provisioned for the dependencies
+// of the watched file, or the error analyzing the file or provisioning the binary.
+type WatchFunc func(binary K6Binary, err error)
+
+// Watcher watches a k6 script or archive and provisions a new binary each time the
+// file changes in a way that modifies its dependencies.
+//
+// Watchers are created with [Provider.Watch] and must be stopped using [Watcher.Close].
+type Watcher struct {
+	provider *Provider
+	path     string
+	callback WatchFunc
+	watcher  *fsnotify.Watcher
+	deps     string
+	cancel   context.CancelFunc
+	done     sync.WaitGroup
+}
+
+// Watch starts watching a k6 script or archive (files with the .tar extension),
+// calling the callback with the binary that satisfies its dependencies.
+// The callback is called once when the watch starts and then every time the file's
+// dependencies change.
+//
+// The watcher stops when the context is done or [Watcher.Close] is called.
+func (p *Provider) Watch(ctx context.Context, path string, callback WatchFunc) (*Watcher, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// watch the directory as editors frequently replace the file when saving it
+	if err = fsWatcher.Add(filepath.Dir(path)); err != nil {
+		_ = fsWatcher.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := &Watcher{
+		provider: p,
+		path:     path,
+		callback: callback,
+		watcher:  fsWatcher,
+		cancel:   cancel,
+	}
+
+	w.done.Add(1)
+	go w.run(ctx)
+
+	return w, nil
+}
+
+// Close stops the watcher
+func (w *Watcher) Close() error {
+	w.cancel()
+	w.done.Wait()
+	return nil
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	defer w.done.Done()
+	defer w.watcher.Close() //nolint:errcheck
+
+	w.check(ctx)
+
+	// changes are checked once the file has not been modified for watchDelay,
+	// as saving a file can produce multiple events
+	delay := time.NewTimer(watchDelay)
+	delay.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			delay.Stop()
+			return
+		case <-delay.C:
+			w.check(ctx)
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Name != w.path || !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+			delay.Reset(watchDelay)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.callback(K6Binary{}, err)
+		}
+	}
+}
+
+// check analyzes the watched file and provisions a binary if its dependencies changed or
+// provisioning the binary failed the last time
+func (w *Watcher) check(ctx context.Context) {
+	deps, err := w.provider.Analyze(w.path)
+	if err != nil {
+		w.callback(K6Binary{}, err)
+		return
+	}
+
+	text, err := deps.MarshalText()
+	if err != nil {
+		w.callback(K6Binary{}, err)
+		return
+	}
+
+	if string(text) == w.deps {
+		return
+	}
+
+	binary, err := w.provider.GetBinary(ctx, deps)
+	if ctx.Err() != nil {
+		return
+	}
+
+	// the dependencies are provisioned again on the next change if it failed
+	if err == nil {
+		w.deps = string(text)
+	}
+
+	w.callback(binary, err)
+}
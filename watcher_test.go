@@ -0,0 +1,97 @@
+package k6provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/k6build"
+)
+
+func TestWatcher(t *testing.T) {
+	t.Parallel()
+
+	script := filepath.Join(t.TempDir(), "script.js")
+	writeScript := func(k6Version string) {
+		contents := `"use k6 = ` + k6Version + `";` + "\nexport default function() {}\n"
+		if err := os.WriteFile(script, []byte(contents), 0o600); err != nil {
+			t.Fatalf("writing script %v", err)
+		}
+	}
+	writeScript("v0.50.0")
+
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("k6"))
+	}))
+	t.Cleanup(store.Close)
+
+	// only v0.50.0 can be built
+	requested := make(chan string, 10)
+	buildSrv := buildServiceFunc(
+		func(_ context.Context, _ string, k6 string, _ []k6build.Dependency) (k6build.Artifact, error) {
+			requested <- k6
+			if k6 != "=v0.50.0" {
+				return k6build.Artifact{}, errors.New("build failed")
+			}
+			return k6build.Artifact{ID: "artifact", URL: store.URL}, nil
+		},
+	)
+	provider := newTestProvider(t, buildSrv, t.TempDir())
+
+	watcher, err := provider.Watch(context.TODO(), script, func(binary K6Binary, err error) {
+		if binary.ID != "artifact" && !errors.Is(err, ErrBuild) {
+			t.Errorf("expected %v got %v", ErrBuild, err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("starting watcher %v", err)
+	}
+	t.Cleanup(func() { _ = watcher.Close() })
+
+	expectRequest := func(expected string) {
+		t.Helper()
+
+		select {
+		case k6 := <-requested:
+			if k6 != expected {
+				t.Fatalf("expected %q got %q", expected, k6)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %q", expected)
+		}
+	}
+
+	// the file is checked once it is not modified for watchDelay
+	expectNoRequest := func() {
+		t.Helper()
+
+		select {
+		case k6 := <-requested:
+			t.Fatalf("unexpected request %q", k6)
+		case <-time.After(5 * watchDelay):
+		}
+	}
+
+	// initial provisioning
+	expectRequest("=v0.50.0")
+
+	// changing the script without changing the dependencies should not provision
+	if err = os.WriteFile(script, []byte(`"use k6 = v0.50.0";`+"\n"), 0o600); err != nil {
+		t.Fatalf("writing script %v", err)
+	}
+	expectNoRequest()
+
+	writeScript("v0.51.0")
+	expectRequest("=v0.51.0")
+
+	// the dependencies are provisioned again on the next change if it failed
+	if err = os.WriteFile(script, []byte(`"use k6 = v0.51.0";`+"\n"), 0o600); err != nil {
+		t.Fatalf("writing script %v", err)
+	}
+	expectRequest("=v0.51.0")
+}